@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary [外部接口]获取应用列表
+// @Id GetPipelineApps
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/apps [get]
+func GetPipelineApps(c *gin.Context) {
+	list, err := pipeline.ListPipelineApps()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新应用
+// @Id UpsertPipelineApp
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineAppReq	true  "应用名称及所属团队"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/apps [post]
+func UpsertPipelineApp(c *gin.Context) {
+	var req pipeline.PipelineAppReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	app, err := pipeline.UpsertPipelineApp(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(app, c)
+}
+
+// @Summary [外部接口]删除应用
+// @Id DeletePipelineApp
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"应用名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/apps/{name} [delete]
+func DeletePipelineApp(c *gin.Context) {
+	if err := pipeline.DeletePipelineApp(c.Param("name")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
@@ -0,0 +1,28 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary [外部接口]获取流水线实例的k8s资源变更
+// @Id GetPipelineApplyDiffs
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	id		path 	string	true		"流水线实例ID"
+// @Param	task	query 	string	false		"任务ID, 为空返回全部k8s-apply任务的变更"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/apply-diffs/{id} [get]
+func GetPipelineApplyDiffs(c *gin.Context) {
+	list, err := pipeline.GetPipelineApplyDiffs(c.Param("id"), c.Query("task"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
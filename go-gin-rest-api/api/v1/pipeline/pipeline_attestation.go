@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"fmt"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary [外部接口]获取流水线实例的构建证明列表
+// @Id GetPipelineAttestations
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	id		path 	string	true		"流水线实例ID"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/attestations/{id} [get]
+func GetPipelineAttestations(c *gin.Context) {
+	list, err := pipeline.GetPipelineAttestations(c.Param("id"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]下载流水线实例的构建证明
+// @Id DownloadPipelineAttestation
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Produce application/json
+// @Param	id		path 	string	true		"流水线实例ID"
+// @Param	type	path 	string	true		"证明类型: provenance/sbom"
+// @Success 200 {file} file 证明内容
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/attestations/{id}/{type} [get]
+func DownloadPipelineAttestation(c *gin.Context) {
+	attestation, err := pipeline.GetPipelineAttestation(c.Param("id"), c.Param("type"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s.json"`, attestation.DagInsID, attestation.Type, attestation.Format))
+	c.Data(http.StatusOK, attestation.MediaType, []byte(attestation.Content))
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary [外部接口]获取构建缓存使用情况
+// @Id GetPipelineBuildCaches
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回各存活worker的构建缓存列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/build-caches [get]
+func GetPipelineBuildCaches(c *gin.Context) {
+	list, err := flow.ListBuildCaches()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]清除worker上的构建缓存
+// @Id PurgePipelineBuildCache
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	worker		path 	string	true		"worker key"
+// @Param	type		path 	string	true		"缓存类型: buildkit/kaniko"
+// @Param	namespace	path 	string	true		"命名空间"
+// @Success 200 object models.Resp 返回worker命令, Status为pending时worker尚未执行完成
+// @Failure 400 object models.Resp 清除失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/build-caches/{worker}/{type}/{namespace} [delete]
+func PurgePipelineBuildCache(c *gin.Context) {
+	cmd, err := flow.RequestPurgeBuildCache(c.Request.Context(), c.Param("worker"), c.Param("type"), c.Param("namespace"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if cmd.Status == pipeline.WorkerCommandFailed {
+		models.FailWithDetailed(errors.New(cmd.Error), models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(cmd, c)
+}
+
+// @Summary [外部接口]获取构建缓存配额列表
+// @Id GetPipelineBuildCacheQuotas
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/build-cache-quotas [get]
+func GetPipelineBuildCacheQuotas(c *gin.Context) {
+	list, err := pipeline.ListPipelineBuildCacheQuotas()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新构建缓存配额
+// @Id UpsertPipelineBuildCacheQuota
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineBuildCacheQuotaReq	true  "缓存类型、命名空间及大小上限"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/build-cache-quotas [post]
+func UpsertPipelineBuildCacheQuota(c *gin.Context) {
+	var req pipeline.PipelineBuildCacheQuotaReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if _, err := flow.BuildCacheDir(req.Type, req.Namespace); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	quota, err := pipeline.UpsertPipelineBuildCacheQuota(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(quota, c)
+}
+
+// @Summary [外部接口]删除构建缓存配额
+// @Id DeletePipelineBuildCacheQuota
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	type		path 	string	true		"缓存类型: buildkit/kaniko"
+// @Param	namespace	path 	string	true		"命名空间"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/build-cache-quotas/{type}/{namespace} [delete]
+func DeletePipelineBuildCacheQuota(c *gin.Context) {
+	if err := pipeline.DeletePipelineBuildCacheQuota(c.Param("type"), c.Param("namespace")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
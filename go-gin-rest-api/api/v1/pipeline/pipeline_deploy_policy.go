@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/policy"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary [外部接口]获取部署准入策略列表
+// @Id GetPipelineDeployPolicies
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/deploy-policies [get]
+func GetPipelineDeployPolicies(c *gin.Context) {
+	list, err := pipeline.ListPipelineDeployPolicies()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新部署准入策略
+// @Id UpsertPipelineDeployPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineDeployPolicyReq	true  "策略名称、匹配的环境和应用、CEL表达式及拒绝原因"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/deploy-policies [post]
+func UpsertPipelineDeployPolicy(c *gin.Context) {
+	var req pipeline.PipelineDeployPolicyReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	deployPolicy, err := pipeline.UpsertPipelineDeployPolicy(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(deployPolicy, c)
+}
+
+// @Summary [外部接口]删除部署准入策略
+// @Id DeletePipelineDeployPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"策略名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/deploy-policies/{name} [delete]
+func DeletePipelineDeployPolicy(c *gin.Context) {
+	if err := pipeline.DeletePipelineDeployPolicy(c.Param("name")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
+
+// @Summary [外部接口]测试部署准入策略
+// @Id TestPipelineDeployPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineDeployPolicyTestReq	true  "待测试的表达式及策略输入, 表达式为空时测试已启用的策略"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/deploy-policies/test [post]
+func TestPipelineDeployPolicy(c *gin.Context) {
+	var req pipeline.PipelineDeployPolicyTestReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	build := map[string]interface{}{}
+	if req.Build != "" {
+		if build, err = flow.BuildRecord(req.Build); err != nil {
+			models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+			return
+		}
+	}
+	for key, value := range req.BuildInfo {
+		build[key] = value
+	}
+	input := policy.Input{Vars: req.Vars, Build: build, Env: req.Env, App: req.App, Caller: req.Caller, Now: time.Now()}
+	if req.Now != nil {
+		input.Now = *req.Now
+	}
+	var rules []policy.Rule
+	if req.Expression != "" {
+		if err := policy.Validate(req.Expression); err != nil {
+			models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+			return
+		}
+		rules = []policy.Rule{{Name: "test", Expression: req.Expression}}
+	} else if rules, err = pipeline.GetPipelineDeployRules(req.Env, req.App); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	results := policy.Evaluate(rules, input)
+	models.OkWithData(pipeline.PipelineDeployAdmission{
+		Allowed: len(policy.Denied(results)) == 0,
+		Input:   input,
+		Results: results,
+	}, c)
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/report"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DORA指标最多查询的天数
+const doraMaxDays = 366
+
+// @Summary [外部接口]获取DORA指标
+// @Id GetPipelineDoraMetrics
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	env			query 	string	false		"目标环境"
+// @Param	app			query 	string	false		"应用名称"
+// @Param	team		query 	string	false		"团队"
+// @Param	groupBy		query 	string	false		"分组方式: app/team/env, 为空时不分组"
+// @Param	interval	query 	string	false		"统计周期: week/month, 默认week"
+// @Param	start		query 	string	false		"开始日期, 格式2006-01-02, 默认最近12周或6个月"
+// @Param	end			query 	string	false		"结束日期(不含), 格式2006-01-02, 默认明天"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/dora [get]
+func GetPipelineDoraMetrics(c *gin.Context) {
+	interval, groupBy := c.DefaultQuery("interval", report.DoraWeek), c.Query("groupBy")
+	if err := report.ValidateDora(interval, groupBy); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	now := time.Now()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	if c.Query("end") != "" {
+		var err error
+		if end, err = time.ParseInLocation(time.DateOnly, c.Query("end"), now.Location()); err != nil {
+			models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+			return
+		}
+	}
+	start := report.DoraPeriodStart(end.AddDate(0, 0, -1), interval)
+	if interval == report.DoraMonth {
+		start = start.AddDate(0, -5, 0)
+	} else {
+		start = start.AddDate(0, 0, -7*11)
+	}
+	if c.Query("start") != "" {
+		var err error
+		if start, err = time.ParseInLocation(time.DateOnly, c.Query("start"), now.Location()); err != nil {
+			models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+			return
+		}
+	}
+	if !end.After(start) || end.Sub(start) > doraMaxDays*24*time.Hour {
+		models.FailWithDetailed(errors.New("结束日期需晚于开始日期且查询范围不超过366天"), models.CustomError[models.NotOk], c)
+		return
+	}
+	deployments, err := pipeline.GetPipelineDoraDeployments(c.Query("env"), c.Query("app"), c.Query("team"), start, end)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(report.NewDoraReport(deployments, start, end, interval, groupBy), c)
+}
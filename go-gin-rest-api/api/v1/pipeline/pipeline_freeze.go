@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+// 部署日历最多查询的天数
+const deployCalendarMaxDays = 93
+
+// @Summary [外部接口]获取部署冻结窗口列表
+// @Id GetPipelineFreezeWindows
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/freeze-windows [get]
+func GetPipelineFreezeWindows(c *gin.Context) {
+	list, err := pipeline.ListPipelineFreezeWindows()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新部署冻结窗口
+// @Id UpsertPipelineFreezeWindow
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineFreezeWindowReq	true  "窗口名称、匹配的环境和应用, 周期性窗口设置cron和持续分钟数, 一次性窗口设置开始和结束时间"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/freeze-windows [post]
+func UpsertPipelineFreezeWindow(c *gin.Context) {
+	var req pipeline.PipelineFreezeWindowReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	window, err := pipeline.UpsertPipelineFreezeWindow(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(window, c)
+}
+
+// @Summary [外部接口]删除部署冻结窗口
+// @Id DeletePipelineFreezeWindow
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"窗口名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/freeze-windows/{name} [delete]
+func DeletePipelineFreezeWindow(c *gin.Context) {
+	if err := pipeline.DeletePipelineFreezeWindow(c.Param("name")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
+
+// @Summary [外部接口]获取即将生效的部署冻结时段
+// @Id GetUpcomingPipelineFreezes
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	env		query 	string	false		"目标环境"
+// @Param	app		query 	string	false		"应用名称"
+// @Param	days	query 	int		false		"查询天数, 默认7"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/freeze-windows/upcoming [get]
+func GetUpcomingPipelineFreezes(c *gin.Context) {
+	days := cast.ToInt(c.DefaultQuery("days", "7"))
+	if days <= 0 || days > deployCalendarMaxDays {
+		days = 7
+	}
+	now := time.Now()
+	list, err := pipeline.GetPipelineFreezePeriods(c.Query("env"), c.Query("app"), now, now.AddDate(0, 0, days))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]获取部署日历
+// @Id GetPipelineDeployCalendar
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	env		query 	string	false		"目标环境"
+// @Param	app		query 	string	false		"应用名称"
+// @Param	start	query 	string	false		"开始日期, 格式2006-01-02, 默认本月第一天"
+// @Param	end		query 	string	false		"结束日期(不含), 格式2006-01-02, 默认开始日期一个月后"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/deploy-calendar [get]
+func GetPipelineDeployCalendar(c *gin.Context) {
+	env, app := c.Query("env"), c.Query("app")
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if c.Query("start") != "" {
+		var err error
+		if start, err = time.ParseInLocation(time.DateOnly, c.Query("start"), now.Location()); err != nil {
+			models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+			return
+		}
+	}
+	end := start.AddDate(0, 1, 0)
+	if c.Query("end") != "" {
+		var err error
+		if end, err = time.ParseInLocation(time.DateOnly, c.Query("end"), now.Location()); err != nil {
+			models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+			return
+		}
+	}
+	if !end.After(start) || end.Sub(start) > deployCalendarMaxDays*24*time.Hour {
+		models.FailWithDetailed(errors.New("结束日期需晚于开始日期且查询范围不超过93天"), models.CustomError[models.NotOk], c)
+		return
+	}
+	deployments, err := pipeline.ListPipelineDeployments(env, app, start, end)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	// 待执行的部署按当前时间放入日历
+	if now.After(start) && now.Before(end) {
+		scheduled, err := flow.ScheduledDeployments(env, app)
+		if err != nil {
+			models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+			return
+		}
+		for _, deployment := range scheduled {
+			deployment.StartedAt = now
+			deployments = append(deployments, deployment)
+		}
+	}
+	freezes, err := pipeline.GetPipelineFreezePeriods(env, app, start, end)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(pipeline.NewPipelineDeployCalendar(start, end, deployments, freezes), c)
+}
@@ -0,0 +1,300 @@
+package pipeline
+
+import (
+	"context"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/git"
+	"go-gin-rest-api/pkg/global"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linclin/fastflow/pkg/entity"
+)
+
+// webhook请求体大小上限
+const maxWebhookBodySize = 25 << 20
+
+// @Summary [外部接口]获取代码仓库列表
+// @Id GetPipelineGitRepos
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos [get]
+func GetPipelineGitRepos(c *gin.Context) {
+	list, err := pipeline.ListPipelineGitRepos()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新代码仓库
+// @Id UpsertPipelineGitRepo
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineGitRepoReq	true  "仓库地址、代码托管平台、默认分支及认证信息"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos [post]
+func UpsertPipelineGitRepo(c *gin.Context) {
+	var req pipeline.PipelineGitRepoReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	repo, err := pipeline.UpsertPipelineGitRepo(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if repo.Mirror {
+		go syncGitMirror(*repo)
+	}
+	models.OkWithData(repo, c)
+}
+
+// @Summary [外部接口]删除代码仓库
+// @Id DeletePipelineGitRepo
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos/{name} [delete]
+func DeletePipelineGitRepo(c *gin.Context) {
+	if err := pipeline.DeletePipelineGitRepo(c.Request.Context(), c.Param("name")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
+
+// @Summary [外部接口]获取代码仓库分支列表
+// @Id GetPipelineGitRepoBranches
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos/{name}/branches [get]
+func GetPipelineGitRepoBranches(c *gin.Context) {
+	listGitRepoRefs(c, git.RefBranch)
+}
+
+// @Summary [外部接口]获取代码仓库标签列表
+// @Id GetPipelineGitRepoTags
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos/{name}/tags [get]
+func GetPipelineGitRepoTags(c *gin.Context) {
+	listGitRepoRefs(c, git.RefTag)
+}
+
+// 列出代码仓库指定类型的远程引用
+func listGitRepoRefs(c *gin.Context, refType string) {
+	repo, err := pipeline.GetPipelineGitRepo(c.Param("name"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	refs, err := repo.ListRefs(c.Request.Context())
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(git.FilterRefs(refs, refType), c)
+}
+
+// @Summary [外部接口]注册代码仓库webhook
+// @Id RegisterPipelineGitRepoWebhook
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos/{name}/webhook [post]
+func RegisterPipelineGitRepoWebhook(c *gin.Context) {
+	repo, err := pipeline.GetPipelineGitRepo(c.Param("name"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if err := repo.RegisterWebhook(c.Request.Context()); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(gin.H{"WebhookID": repo.WebhookID, "WebhookURL": repo.WebhookURL()}, c)
+}
+
+// @Summary [外部接口]删除代码仓库webhook
+// @Id UnregisterPipelineGitRepoWebhook
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos/{name}/webhook [delete]
+func UnregisterPipelineGitRepoWebhook(c *gin.Context) {
+	repo, err := pipeline.GetPipelineGitRepo(c.Param("name"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if err := repo.UnregisterWebhook(c.Request.Context()); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
+
+// @Summary [外部接口]同步代码仓库本地裸镜像
+// @Id SyncPipelineGitRepoMirror
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos/{name}/mirror [post]
+func SyncPipelineGitRepoMirror(c *gin.Context) {
+	repo, err := pipeline.GetPipelineGitRepo(c.Param("name"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if err := repo.SyncMirror(c.Request.Context()); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("同步成功", c)
+}
+
+// @Summary [外部接口]按代码仓库中的流水线文件运行流水线
+// @Id RunPipelineGitRepo
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Param body body pipeline.PipelineGitRepoRun	true  "分支、标签或提交及流水线参数"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/git-repos/{name}/run [post]
+func RunPipelineGitRepo(c *gin.Context) {
+	var req pipeline.PipelineGitRepoRun
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	repo, err := pipeline.GetPipelineGitRepo(c.Param("name"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	// 记录调用方, 部署准入策略按调用系统、操作人和角色判断
+	appId := c.GetString("AppId")
+	roles, _ := global.CasbinACLEnforcer.GetImplicitRolesForUser(appId)
+	caller := &pipeline.PipelineCaller{
+		AppId:          appId,
+		User:           req.User,
+		Roles:          strings.Join(roles, ","),
+		OverrideReason: req.OverrideReason,
+	}
+	result, err := flow.RunDagFile(c.Request.Context(), flow.DagFileRun{Repo: repo, Ref: req.Ref, Vars: req.Var, Trigger: entity.TriggerManually, Caller: caller})
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(result, c)
+}
+
+// @Summary [外部接口]接收代码托管平台webhook
+// @Id ReceivePipelineGitRepoWebhook
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/json
+// @Param	name		path 	string	true		"代码仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Router /api/v1/public/git-repos/{name}/webhook [post]
+func ReceivePipelineGitRepoWebhook(c *gin.Context) {
+	repo, err := pipeline.GetPipelineGitRepo(c.Param("name"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	event, err := repo.ParseWebhook(c.Request.Header, body)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if event.Event == git.EventPush || event.Event == git.EventTagPush {
+		switch {
+		case repo.RunOnPush && !event.Deleted && repo.RunOnRef(event.Ref):
+			go runGitRepoDagFile(*repo, event)
+		case repo.Mirror:
+			go syncGitMirror(*repo)
+		}
+	}
+	models.OkWithData(event, c)
+}
+
+// 后台同步本地裸镜像
+func syncGitMirror(repo pipeline.PipelineGitRepo) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	if err := repo.SyncMirror(ctx); err != nil {
+		global.Log.Error("syncGitMirror同步本地裸镜像失败", "repo", repo.Name, "err", err.Error())
+	}
+}
+
+// 后台按推送的提交运行流水线文件, 只运行代码仓库允许的引用, 运行流水线时会同步本地裸镜像
+func runGitRepoDagFile(repo pipeline.PipelineGitRepo, event *git.WebhookEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	ref := event.Branch
+	if ref == "" {
+		ref = event.Tag
+	}
+	// webhook没有调用系统和角色, 推送者为平台上报的用户名, 部署准入策略可按caller.trigger == "webhook"限制
+	caller := &pipeline.PipelineCaller{User: event.Pusher}
+	result, err := flow.RunDagFile(ctx, flow.DagFileRun{Repo: &repo, Ref: ref, Commit: event.After, Trigger: flow.TriggerWebhook, Caller: caller})
+	if err != nil {
+		global.Log.Error("runGitRepoDagFile运行流水线文件失败", "repo", repo.Name, "ref", ref, "err", err.Error())
+		return
+	}
+	if len(result.Errors) > 0 {
+		global.Log.Error("runGitRepoDagFile流水线文件校验失败", "repo", repo.Name, "ref", ref, "commit", result.Commit, "err", result.Errors.Error())
+	}
+}
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"errors"
 	"go-gin-rest-api/models"
+	"go-gin-rest-api/pkg/flow"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linclin/fastflow/pkg/entity"
@@ -27,6 +28,11 @@ func UpsertPipeline(c *gin.Context) {
 		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
 		return
 	}
+	// 任务要求的worker标签冲突时没有节点能执行该流水线
+	if _, err := flow.DagRequiredLabels(&dag); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
 	oldDag, err := mod.GetStore().GetDag(dag.ID)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		if err := mod.GetStore().CreateDag(&dag); err != nil {
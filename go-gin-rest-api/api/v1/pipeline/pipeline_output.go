@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// @Summary [外部接口]获取流水线实例输出
+// @Id GetPipelineOutputs
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	id		path 	string	true		"流水线实例ID"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/outputs/{id} [get]
+func GetPipelineOutputs(c *gin.Context) {
+	dagIns, err := mod.GetStore().GetDagInstance(c.Param("id"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	list, err := pipeline.GetPipelineOutputs(dagIns.ID)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(pipeline.NewPipelineRunOutputs(dagIns.DagID, dagIns.ID, string(dagIns.Status), list), c)
+}
+
+// @Summary [外部接口]获取流水线最近一次成功运行的输出
+// @Id GetLatestPipelineOutputs
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineLatestOutputsReq	true  "流水线id和参数过滤条件"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/outputs/latest [post]
+func GetLatestPipelineOutputs(c *gin.Context) {
+	var req pipeline.PipelineLatestOutputsReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	dagIns, err := flow.LatestSuccessDagIns(req.Dag, req.Var)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	list, err := pipeline.GetPipelineOutputs(dagIns.ID)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if len(req.Names) > 0 {
+		filtered := make([]pipeline.PipelineOutput, 0, len(list))
+		for _, output := range list {
+			for _, name := range req.Names {
+				if output.Name == name {
+					filtered = append(filtered, output)
+					break
+				}
+			}
+		}
+		list = filtered
+	}
+	models.OkWithData(pipeline.NewPipelineRunOutputs(dagIns.DagID, dagIns.ID, string(dagIns.Status), list), c)
+}
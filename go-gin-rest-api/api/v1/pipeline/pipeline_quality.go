@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary [外部接口]获取质量门禁策略列表
+// @Id GetPipelineQualityPolicies
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/quality-policies [get]
+func GetPipelineQualityPolicies(c *gin.Context) {
+	list, err := pipeline.ListPipelineQualityPolicies()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新质量门禁策略
+// @Id UpsertPipelineQualityPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineQualityPolicyReq	true  "应用名称和规则, 应用名称为*时作为默认策略"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/quality-policies [post]
+func UpsertPipelineQualityPolicy(c *gin.Context) {
+	var req pipeline.PipelineQualityPolicyReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	policy, err := pipeline.UpsertPipelineQualityPolicy(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(policy, c)
+}
+
+// @Summary [外部接口]删除质量门禁策略
+// @Id DeletePipelineQualityPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	app		path 	string	true		"应用名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/quality-policies/{app} [delete]
+func DeletePipelineQualityPolicy(c *gin.Context) {
+	if err := pipeline.DeletePipelineQualityPolicy(c.Param("app")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/registry"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+// 查询标签详情时默认的标签数
+const registryTagLimit = 50
+
+// @Summary [外部接口]获取镜像仓库列表
+// @Id GetPipelineRegistries
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/registries [get]
+func GetPipelineRegistries(c *gin.Context) {
+	list, err := pipeline.ListPipelineRegistries()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新镜像仓库
+// @Id UpsertPipelineRegistry
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineRegistryReq	true  "镜像仓库地址、类型及认证信息"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/registries [post]
+func UpsertPipelineRegistry(c *gin.Context) {
+	var req pipeline.PipelineRegistryReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	reg, err := pipeline.UpsertPipelineRegistry(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(reg, c)
+}
+
+// @Summary [外部接口]删除镜像仓库
+// @Id DeletePipelineRegistry
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"镜像仓库名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/registries/{name} [delete]
+func DeletePipelineRegistry(c *gin.Context) {
+	if err := pipeline.DeletePipelineRegistry(c.Param("name")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
+
+// @Summary [外部接口]获取镜像仓库中的镜像列表
+// @Id GetPipelineRegistryRepositories
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"镜像仓库名称"
+// @Param	q			query 	string	false		"按镜像名称过滤"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/registries/{name}/repositories [get]
+func GetPipelineRegistryRepositories(c *gin.Context) {
+	endpoint, err := registryEndpoint(c)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	repos, err := endpoint.Repositories(c.Request.Context())
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if q := c.Query("q"); q != "" {
+		filtered := make([]string, 0)
+		for _, repo := range repos {
+			if strings.Contains(repo, q) {
+				filtered = append(filtered, repo)
+			}
+		}
+		repos = filtered
+	}
+	models.OkWithData(repos, c)
+}
+
+// @Summary [外部接口]获取镜像的标签列表
+// @Id GetPipelineRegistryTags
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"镜像仓库名称"
+// @Param	repository	query 	string	true		"镜像名称, 如library/nginx"
+// @Param	detail		query 	bool	false		"是否查询摘要、大小和创建时间, 按创建时间从新到旧排列"
+// @Param	limit		query 	int		false		"返回的标签数, 查询详情时默认50, 否则不限制"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/registries/{name}/tags [get]
+func GetPipelineRegistryTags(c *gin.Context) {
+	repository := c.Query("repository")
+	if repository == "" {
+		models.FailWithDetailed(errors.New("未指定镜像名称repository"), models.CustomError[models.NotOk], c)
+		return
+	}
+	endpoint, err := registryEndpoint(c)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	tags, err := endpoint.Tags(c.Request.Context(), repository)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if !cast.ToBool(c.Query("detail")) {
+		models.OkWithData(registry.LimitTags(tags, cast.ToInt(c.Query("limit"))), c)
+		return
+	}
+	tags = registry.LimitTags(tags, cast.ToInt(c.DefaultQuery("limit", cast.ToString(registryTagLimit))))
+	infos, err := endpoint.TagInfos(c.Request.Context(), repository, tags)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(infos, c)
+}
+
+// @Summary [外部接口]查看镜像清单及配置
+// @Id GetPipelineRegistryManifest
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"镜像仓库名称"
+// @Param	repository	query 	string	true		"镜像名称, 如library/nginx"
+// @Param	reference	query 	string	true		"标签或摘要"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/registries/{name}/manifest [get]
+func GetPipelineRegistryManifest(c *gin.Context) {
+	repository, reference := c.Query("repository"), c.Query("reference")
+	if repository == "" || reference == "" {
+		models.FailWithDetailed(errors.New("未指定镜像名称repository或标签reference"), models.CustomError[models.NotOk], c)
+		return
+	}
+	endpoint, err := registryEndpoint(c)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	manifest, err := endpoint.Inspect(c.Request.Context(), repository, reference)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(manifest, c)
+}
+
+// 按路径参数name获取镜像仓库的地址和认证信息
+func registryEndpoint(c *gin.Context) (registry.Endpoint, error) {
+	reg, err := pipeline.GetPipelineRegistry(c.Param("name"))
+	if err != nil {
+		return registry.Endpoint{}, err
+	}
+	return reg.Endpoint()
+}
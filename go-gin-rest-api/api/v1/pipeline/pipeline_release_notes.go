@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"fmt"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/release"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary [外部接口]生成两次部署或两个提交之间的发布说明
+// @Id GetPipelineReleaseNotes
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineReleaseNotesReq	true  "已登记的代码仓库名称及起止部署记录或提交"
+// @Success 200 object models.Resp 返回发布说明, markdown格式为字符串, json格式为按类型分组的提交
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/release-notes [post]
+func GetPipelineReleaseNotes(c *gin.Context) {
+	var req pipeline.PipelineReleaseNotesReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if req.Format == "" {
+		req.Format = release.FormatMarkdown
+	}
+	if err := release.ValidateFormat(req.Format); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	from, to, err := req.Range()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	repo, err := pipeline.GetPipelineGitRepo(req.Repo)
+	if err != nil {
+		models.FailWithDetailed(fmt.Errorf("代码仓库%s未登记: %w", req.Repo, err), models.CustomError[models.NotOk], c)
+		return
+	}
+	credential, err := repo.Credential()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	notes, err := release.FromRepo(c.Request.Context(), repo.URL, credential, from, to)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if req.Format == release.FormatJSON {
+		models.OkWithData(notes, c)
+		return
+	}
+	models.OkWithData(notes.Markdown(), c)
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/models/sys"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+// @Summary [外部接口]获取标签保留策略列表
+// @Id GetPipelineRetentionPolicies
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/retention-policies [get]
+func GetPipelineRetentionPolicies(c *gin.Context) {
+	list, err := pipeline.ListPipelineRetentionPolicies()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]创建/更新标签保留策略
+// @Id UpsertPipelineRetentionPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param body body pipeline.PipelineRetentionPolicyReq	true  "镜像仓库、镜像名称模式及保留规则"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/retention-policies [post]
+func UpsertPipelineRetentionPolicy(c *gin.Context) {
+	var req pipeline.PipelineRetentionPolicyReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	policy, err := pipeline.UpsertPipelineRetentionPolicy(&req)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(policy, c)
+}
+
+// @Summary [外部接口]删除标签保留策略
+// @Id DeletePipelineRetentionPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"策略名称"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/retention-policies/{name} [delete]
+func DeletePipelineRetentionPolicy(c *gin.Context) {
+	if err := pipeline.DeletePipelineRetentionPolicy(c.Param("name")); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithMessage("删除成功", c)
+}
+
+// @Summary [外部接口]执行标签保留策略
+// @Id RunPipelineRetentionPolicy
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	name		path 	string	true		"策略名称"
+// @Param	dryRun		query 	bool	false		"只报告将删除的标签, 默认true; 策略设置DryRun时始终只报告"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/retention-policies/{name}/run [post]
+func RunPipelineRetentionPolicy(c *gin.Context) {
+	policy, err := pipeline.GetPipelineRetentionPolicy(c.Param("name"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	dryRun := cast.ToBool(c.DefaultQuery("dryRun", "true"))
+	if !dryRun && !policy.DryRun {
+		lock := sys.NewLock(pipeline.RetentionLockMethod, pipeline.RetentionLockExpire)
+		if !lock.TryLock() {
+			models.FailWithDetailed(errors.New("标签清理正在执行, 请稍后重试"), models.CustomError[models.NotOk], c)
+			return
+		}
+		defer lock.DeleteLock()
+	}
+	report, err := policy.Run(c.Request.Context(), dryRun)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(report, c)
+}
@@ -3,6 +3,9 @@ package pipeline
 import (
 	"go-gin-rest-api/models"
 	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/global"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linclin/fastflow/pkg/entity"
@@ -36,8 +39,16 @@ func RunPipeline(c *gin.Context) {
 		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
 		return
 	}
-	err = mod.GetStore().CreateDagIns(dagIns)
-	if err != nil {
+	// 记录调用方, 部署准入策略按调用系统和角色判断, 调用方在流水线实例创建前保存
+	appId := c.GetString("AppId")
+	roles, _ := global.CasbinACLEnforcer.GetImplicitRolesForUser(appId)
+	caller := &pipeline.PipelineCaller{
+		AppId:          appId,
+		User:           pipelineRun.User,
+		Roles:          strings.Join(roles, ","),
+		OverrideReason: pipelineRun.OverrideReason,
+	}
+	if err := flow.CreateDagInsWithCaller(dagIns, caller); err != nil {
 		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
 		return
 	}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linclin/fastflow/pkg/mod"
+	"github.com/spf13/cast"
+)
+
+// @Summary [外部接口]获取流水线实例测试报告
+// @Id GetPipelineTestReport
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	id		path 	string	true		"流水线实例ID"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/test-reports/{id} [get]
+func GetPipelineTestReport(c *gin.Context) {
+	dagIns, err := mod.GetStore().GetDagInstance(c.Param("id"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	report, err := pipeline.GetPipelineTestReport(dagIns.DagID, dagIns.ID)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(report, c)
+}
+
+// @Summary [外部接口]获取流水线实例失败的测试用例
+// @Id GetPipelineTestFailures
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	id		path 	string	true		"流水线实例ID"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/test-reports/{id}/failures [get]
+func GetPipelineTestFailures(c *gin.Context) {
+	list, err := pipeline.GetPipelineTestFailures(c.Param("id"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(list, c)
+}
+
+// @Summary [外部接口]获取流水线测试历史
+// @Id GetPipelineTestHistory
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	dag		path 	string	true		"流水线ID"
+// @Param	limit	query 	int		false		"最近运行次数, 默认20"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/test-history/{dag} [get]
+func GetPipelineTestHistory(c *gin.Context) {
+	limit := cast.ToInt(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	history, err := pipeline.GetPipelineTestHistory(c.Param("dag"), limit)
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	models.OkWithData(history, c)
+}
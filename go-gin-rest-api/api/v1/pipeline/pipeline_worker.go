@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/models"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// @Summary [外部接口]获取流水线worker列表
+// @Id GetPipelineWorkers
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/workers [get]
+func GetPipelineWorkers(c *gin.Context) {
+	workers, err := pipeline.ListPipelineWorkers()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	aliveNodes, err := mod.GetKeeper().AliveNodes()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	runningTasks, err := flow.ListRunningTasks()
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	list := make([]pipeline.PipelineWorkerStatus, 0, len(workers))
+	for _, worker := range workers {
+		status := pipeline.PipelineWorkerStatus{
+			PipelineWorker: worker,
+			Alive:          flow.WorkerAlive(worker),
+			RunningTasks:   make([]pipeline.PipelineRunningTask, 0),
+		}
+		for _, task := range runningTasks {
+			if task.Worker == worker.WorkerKey {
+				status.RunningTasks = append(status.RunningTasks, task)
+			}
+		}
+		list = append(list, status)
+	}
+	models.OkWithData(gin.H{"Workers": list, "AliveNodes": aliveNodes}, c)
+}
+
+// @Summary [外部接口]排空流水线worker
+// @Id DrainPipelineWorker
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	key		path 	string	true		"worker key"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/workers/{key}/drain [post]
+func DrainPipelineWorker(c *gin.Context) {
+	setPipelineWorkerDraining(c, true)
+}
+
+// @Summary [外部接口]取消排空流水线worker
+// @Id UndrainPipelineWorker
+// @Tags [外部接口]流水线
+// @version 1.0
+// @Accept application/x-json-stream
+// @Param	key		path 	string	true		"worker key"
+// @Success 200 object models.Resp 返回列表
+// @Failure 400 object models.Resp 查询失败
+// @Security ApiKeyAuth
+// @Router /api/v1/pipeline/workers/{key}/undrain [post]
+func UndrainPipelineWorker(c *gin.Context) {
+	setPipelineWorkerDraining(c, false)
+}
+
+func setPipelineWorkerDraining(c *gin.Context, draining bool) {
+	worker, err := pipeline.GetPipelineWorker(c.Param("key"))
+	if err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	if err := pipeline.SetPipelineWorkerDraining(worker.WorkerKey, draining); err != nil {
+		models.FailWithDetailed(err, models.CustomError[models.NotOk], c)
+		return
+	}
+	worker.Draining = draining
+	models.OkWithData(worker, c)
+}
@@ -0,0 +1,50 @@
+package cronjob
+
+import (
+	"fmt"
+	"go-gin-rest-api/models/sys"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/global"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// 按配额淘汰BuildKit/Kaniko构建缓存
+// 构建缓存在各节点本地, 任务锁按worker区分, 各节点分别清理
+type CleanBuildCache struct {
+}
+
+func (u CleanBuildCache) Run() {
+	startTime := time.Now()
+	global.Log.Debug("cronjob定时任务:CleanBuildCache开始执行")
+	lockMethod := "CleanBuildCache"
+	if keeper := mod.GetKeeper(); keeper != nil {
+		lockMethod += "-" + keeper.WorkerKey()
+	}
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			global.Log.Error(fmt.Sprintf("cronjob定时任务:CleanBuildCache执行失败: %v\n堆栈信息: %v", panicErr, string(debug.Stack())))
+		}
+	}()
+	//获取任务锁, 获取成功后才释放
+	lock := sys.NewLock(lockMethod, 600)
+	if !lock.TryLock() {
+		global.Log.Error("cronjob定时任务:CleanBuildCache获取任务锁失败")
+		return
+	}
+	defer lock.DeleteLock()
+	status, errMsg := "success", ""
+	removed, err := flow.EvictBuildCaches()
+	if err != nil {
+		status, errMsg = "failed", "淘汰构建缓存失败: "+err.Error()
+		global.Log.Error("cronjob定时任务:CleanBuildCache淘汰构建缓存失败", "err", err.Error())
+	}
+	cronParam := fmt.Sprintf("caches=%d [%s]", len(removed), strings.Join(removed, ","))
+	//记录任务日志表
+	endTime := time.Now()
+	execTime := endTime.Sub(startTime).Seconds()
+	go sys.AddSysCronjobLog(lockMethod, cronParam, status, errMsg, startTime, endTime, execTime)
+}
@@ -2,6 +2,7 @@ package cronjob
 
 import (
 	"fmt"
+	"go-gin-rest-api/models/pipeline"
 	"go-gin-rest-api/models/sys"
 	"go-gin-rest-api/pkg/global"
 	"runtime/debug"
@@ -44,6 +45,9 @@ func (u CleanLog) Run() {
 	if err := global.Mysql.Table("sys_change_logs").Where("created_at < ? ", time.Now().AddDate(0, 0, -7).Unix()).Unscoped().Delete(loggable.ChangeLog{}).Error; err != nil {
 		global.Log.Error("cronjob定时任务:CleanLog删除ChangeLog失败")
 	}
+	if err := pipeline.DeletePipelineWorkerCommands(time.Now().AddDate(0, 0, -7)); err != nil {
+		global.Log.Error("cronjob定时任务:CleanLog删除PipelineWorkerCommand失败")
+	}
 	//记录任务日志表
 	endTime := time.Now()
 	execTime := endTime.Sub(startTime).Seconds()
@@ -0,0 +1,58 @@
+package cronjob
+
+import (
+	"fmt"
+	"go-gin-rest-api/models/sys"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/global"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// 清理过期的流水线工作空间并按总大小上限淘汰依赖缓存
+// 工作空间和缓存在各节点本地, 任务锁按worker区分, 各节点分别清理
+type CleanWorkspace struct {
+}
+
+func (u CleanWorkspace) Run() {
+	startTime := time.Now()
+	global.Log.Debug("cronjob定时任务:CleanWorkspace开始执行")
+	lockMethod := "CleanWorkspace"
+	if keeper := mod.GetKeeper(); keeper != nil {
+		lockMethod += "-" + keeper.WorkerKey()
+	}
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			global.Log.Error(fmt.Sprintf("cronjob定时任务:CleanWorkspace执行失败: %v\n堆栈信息: %v", panicErr, string(debug.Stack())))
+		}
+	}()
+	//获取任务锁, 获取成功后才释放
+	lock := sys.NewLock(lockMethod, 600)
+	if !lock.TryLock() {
+		global.Log.Error("cronjob定时任务:CleanWorkspace获取任务锁失败")
+		return
+	}
+	defer lock.DeleteLock()
+	status := "success"
+	errMsgs := make([]string, 0)
+	workspaces, err := flow.CleanWorkspaces()
+	if err != nil {
+		status = "failed"
+		errMsgs = append(errMsgs, "清理工作空间失败: "+err.Error())
+		global.Log.Error("cronjob定时任务:CleanWorkspace清理工作空间失败", "err", err.Error())
+	}
+	caches, err := flow.EvictCaches()
+	if err != nil {
+		status = "failed"
+		errMsgs = append(errMsgs, "淘汰依赖缓存失败: "+err.Error())
+		global.Log.Error("cronjob定时任务:CleanWorkspace淘汰依赖缓存失败", "err", err.Error())
+	}
+	cronParam := fmt.Sprintf("workspaces=%d,caches=%d", len(workspaces), len(caches))
+	//记录任务日志表
+	endTime := time.Now()
+	execTime := endTime.Sub(startTime).Seconds()
+	go sys.AddSysCronjobLog(lockMethod, cronParam, status, strings.Join(errMsgs, "; "), startTime, endTime, execTime)
+}
@@ -0,0 +1,57 @@
+package cronjob
+
+import (
+	"context"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/models/sys"
+	"go-gin-rest-api/pkg/global"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// 按标签保留策略清理镜像仓库中的标签, 每个策略记录一条任务日志, 设置DryRun的策略只记录将删除的标签
+type TagRetention struct {
+}
+
+func (u TagRetention) Run() {
+	global.Log.Debug("cronjob定时任务:TagRetention开始执行")
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			global.Log.Error(fmt.Sprintf("cronjob定时任务:TagRetention执行失败: %v\n堆栈信息: %v", panicErr, string(debug.Stack())))
+		}
+	}()
+	//获取任务锁, 获取成功后才释放, 不能删除其他节点持有的锁
+	lock := sys.NewLock(pipeline.RetentionLockMethod, pipeline.RetentionLockExpire)
+	if !lock.TryLock() {
+		global.Log.Error("cronjob定时任务:TagRetention获取任务锁失败")
+		return
+	}
+	defer lock.DeleteLock()
+	policies, err := pipeline.ListPipelineRetentionPolicies()
+	if err != nil {
+		global.Log.Error("cronjob定时任务:TagRetention获取标签保留策略失败", "err", err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pipeline.RetentionLockExpire*time.Second)
+	defer cancel()
+	for _, policy := range policies {
+		startTime := time.Now()
+		status, errMsg, cronParam := "success", "", "policy="+policy.Name
+		report, err := policy.Run(ctx, false)
+		if err != nil {
+			status, errMsg = "failed", err.Error()
+			global.Log.Error("cronjob定时任务:TagRetention执行标签保留策略失败", "policy", policy.Name, "err", err.Error())
+		} else {
+			cronParam = report.Summary()
+			if len(report.Errors) > 0 {
+				status, errMsg = "failed", strings.Join(report.Errors, "; ")
+			}
+		}
+		//记录任务日志表
+		endTime := time.Now()
+		execTime := endTime.Sub(startTime).Seconds()
+		go sys.AddSysCronjobLog(pipeline.RetentionLockMethod, cronParam, status, errMsg, startTime, endTime, execTime)
+	}
+}
@@ -48,9 +48,11 @@ func InitConfig() {
 	// 监听文件修改回调函数
 	v.OnConfigChange(func(e fsnotify.Event) {
 		fmt.Printf("配置文件:%s 发生变更:%s\n", e.Name, e.Op)
-		// 转换为结构体
-		if err := v.Unmarshal(&global.Conf); err != nil {
+		// 转换为新结构体后整体替换, 避免map类型配置(如流水线worker标签)残留已删除的键
+		var conf global.Configuration
+		if err := v.Unmarshal(&conf); err != nil {
 			panic(fmt.Sprintf("初始化配置文件失败: %v", err))
 		}
+		global.Conf = conf
 	})
 }
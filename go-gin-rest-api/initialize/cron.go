@@ -14,6 +14,12 @@ func Cron() {
 	c := cron.New(cron.WithLocation(nyc), cron.WithSeconds(), cron.WithLogger(cron.VerbosePrintfLogger(global.Logger)))
 	//清理超过一周的日志表数据
 	c.AddJob("@every 1d", cron.NewChain(cron.Recover(cron.VerbosePrintfLogger(global.Logger)), cron.SkipIfStillRunning(cron.VerbosePrintfLogger(global.Logger))).Then(&cronjob.CleanLog{}))
+	//清理过期的流水线工作空间, 淘汰超出上限的依赖缓存
+	c.AddJob("@every 10m", cron.NewChain(cron.Recover(cron.VerbosePrintfLogger(global.Logger)), cron.SkipIfStillRunning(cron.VerbosePrintfLogger(global.Logger))).Then(&cronjob.CleanWorkspace{}))
+	//按配额淘汰BuildKit/Kaniko构建缓存
+	c.AddJob("@every 30m", cron.NewChain(cron.Recover(cron.VerbosePrintfLogger(global.Logger)), cron.SkipIfStillRunning(cron.VerbosePrintfLogger(global.Logger))).Then(&cronjob.CleanBuildCache{}))
+	//按标签保留策略清理镜像仓库
+	c.AddJob("@every 1d", cron.NewChain(cron.Recover(cron.VerbosePrintfLogger(global.Logger)), cron.SkipIfStillRunning(cron.VerbosePrintfLogger(global.Logger))).Then(&cronjob.TagRetention{}))
 	c.Start()
 	global.Log.Info("初始化定时任务完成")
 }
@@ -3,6 +3,7 @@ package initialize
 import (
 	"database/sql"
 	"fmt"
+	"go-gin-rest-api/models/pipeline"
 	"go-gin-rest-api/models/sys"
 	"go-gin-rest-api/pkg/global"
 	"time"
@@ -82,6 +83,23 @@ func Mysql() {
 	global.Mysql.AutoMigrate(&sys.SysReqApiLog{})
 	global.Mysql.AutoMigrate(&sys.SysCronjobLog{})
 	global.Mysql.AutoMigrate(&sys.SysLock{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineOutput{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineWorker{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineWorkerCommand{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineTestCase{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineQualityPolicy{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineQualityMetric{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineDeployPolicy{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineCaller{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineFreezeWindow{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineDeployment{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineApplyDiff{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineApp{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineAttestation{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineRegistry{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineRetentionPolicy{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineBuildCacheQuota{})
+	global.Mysql.AutoMigrate(&pipeline.PipelineGitRepo{})
 	global.Mysql.Table("sys_change_logs").AutoMigrate(&loggable.ChangeLog{})
 	global.Log.Info("初始化mysql完成")
 	//初始化数据变更记录插件
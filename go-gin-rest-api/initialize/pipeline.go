@@ -2,9 +2,12 @@ package initialize
 
 import (
 	"fmt"
+	"go-gin-rest-api/pkg/action"
+	"go-gin-rest-api/pkg/flow"
 	"go-gin-rest-api/pkg/global"
 	"go-gin-rest-api/pkg/utils"
-	"net"
+	"os"
+	"time"
 
 	"github.com/linclin/fastflow"
 	mysqlKeeper "github.com/linclin/fastflow/keeper/mysql"
@@ -13,7 +16,6 @@ import (
 
 // 初始化流水线
 func InitPipeline() {
-	net.InterfaceAddrs()
 	mysqlDsn := fmt.Sprintf(
 		"%s:%s@tcp(%s:%d)/%s?%s",
 		global.Conf.Mysql.Username,
@@ -23,15 +25,25 @@ func InitPipeline() {
 		global.Conf.Mysql.Database,
 		global.Conf.Mysql.Query,
 	)
+	conf := global.Conf.Pipeline
 	localIp, err := utils.GetLocalIP()
 	if err != nil {
 		panic(fmt.Sprintf("获取本机IP错误: %s", err))
 	}
+	// worker key优先使用配置, 未配置时按本机IP生成
+	workerKey := os.ExpandEnv(conf.WorkerKey)
+	if workerKey == "" {
+		workerNumber, err := utils.IPv4ToNumber(localIp)
+		if err != nil {
+			panic(fmt.Sprintf("获取本机IP错误: %s", err))
+		}
+		workerKey = "worker-" + workerNumber
+	}
 	// init keeper
 	keeper := mysqlKeeper.NewKeeper(&mysqlKeeper.KeeperOption{
-		Key:     "worker-" + localIp,
+		Key:     workerKey,
 		ConnStr: mysqlDsn,
-		Prefix:  "pipeline",
+		Prefix:  flow.TablePrefix,
 	})
 	if err := keeper.Init(); err != nil {
 		panic(fmt.Sprintf("初始化流水线组件keeper错误: %s", err))
@@ -39,21 +51,31 @@ func InitPipeline() {
 	// init store
 	st := mysqlStore.NewStore(&mysqlStore.StoreOption{
 		ConnStr: mysqlDsn,
-		Prefix:  "pipeline",
+		Prefix:  flow.TablePrefix,
 	})
 	if err := st.Init(); err != nil {
 		panic(fmt.Sprintf("初始化流水线组件store错误: %s", err))
 	}
 
+	// 流水线动作、指标、任务心跳和生命周期钩子需在fastflow.Init之前注册
+	action.Register()
+	flow.InitMetrics()
+	flow.StartTaskHeartbeat()
+	fastflow.SetDagInstanceLifecycleHook(flow.LifecycleHook())
 	// init fastflow
 	if err := fastflow.Init(&fastflow.InitialOption{
-		Keeper:            keeper,
-		Store:             st,
-		ParserWorkersCnt:  10,
-		ExecutorWorkerCnt: 50,
-		ExecutorTimeout:   600,
+		Keeper:             keeper,
+		Store:              st,
+		ParserWorkersCnt:   conf.ParserWorkers,
+		ExecutorWorkerCnt:  conf.ExecutorWorkers,
+		ExecutorTimeout:    time.Duration(conf.ExecutorTimeout) * time.Second,
+		DagScheduleTimeout: time.Duration(conf.DagScheduleTimeout) * time.Second,
 	}); err != nil {
 		panic(fmt.Sprintf("初始化流水线组件错误: %s", err))
 	}
+	// 替换执行器, 注入任务元数据
+	flow.InstallExecutor()
+	// 替换keeper和store并启动worker心跳, 支持排空和按标签分配
+	flow.StartWorker(localIp)
 	global.Log.Info("初始化流水线组件完成")
 }
@@ -6,6 +6,7 @@ import (
 	"go-gin-rest-api/middleware"
 	"go-gin-rest-api/models/sys"
 	"go-gin-rest-api/pkg/global"
+	pipelineRouter "go-gin-rest-api/router/pipeline"
 	sysRouter "go-gin-rest-api/router/sys"
 
 	"github.com/gin-contrib/cors"
@@ -111,5 +112,8 @@ func Routers() *gin.Engine {
 	sysRouter.InitReqApiLogRouter(v1Group, authMiddleware)  // 注册请求接口日志路由
 	sysRouter.InitCronjobLogRouter(v1Group, authMiddleware) // 注册任务日志路由
 	global.Log.Info("初始化基础路由完成")
+	pipelineRouter.InitPipelineRouter(v1Group, authMiddleware) // 注册流水线路由
+	pipelineRouter.InitPipelinePublicRouter(v1Group)           // 注册流水线公共路由, 接收webhook
+	global.Log.Info("初始化流水线路由完成")
 	return r
 }
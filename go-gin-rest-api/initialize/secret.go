@@ -0,0 +1,31 @@
+package initialize
+
+import (
+	"errors"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/secret"
+	"os"
+)
+
+// 直接设置凭据加密密钥的环境变量, 优先于配置的密钥文件
+const encryptionKeyEnv = "PipelineEncryptionKey"
+
+// 加载加密数据库中凭据的密钥, 不自动生成, 以免多副本各自生成不同的密钥;
+// 未设置或密钥错误时只记录警告, 不使用凭据的功能不受影响, 加密和解密凭据时返回错误
+func InitSecret() {
+	var err error
+	if value := os.Getenv(encryptionKeyEnv); value != "" {
+		err = secret.LoadEncryptionKey(value)
+		os.Unsetenv(encryptionKeyEnv)
+	} else if file := global.Conf.Pipeline.EncryptionKeyFile; file != "" {
+		err = secret.LoadEncryptionKeyFile(file)
+	} else {
+		err = errors.New("未设置加密密钥, 请配置pipeline.encryption-key-file或设置环境变量" + encryptionKeyEnv)
+	}
+	if err != nil {
+		global.Log.Warn("InitSecret未加载加密密钥, 代码仓库和镜像仓库等凭据无法保存和使用", "err", err.Error())
+	}
+	if secret.LegacyEncryptionKeyExists() {
+		global.Log.Warn("密钥目录中的encryption-key已不再使用, 请将其移出密钥目录并配置为加密密钥文件", "dir", secret.Dir)
+	}
+}
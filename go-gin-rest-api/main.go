@@ -5,6 +5,7 @@ import (
 	"fmt"
 	_ "go-gin-rest-api/docs"
 	"go-gin-rest-api/initialize"
+	"go-gin-rest-api/pkg/flow"
 	"go-gin-rest-api/pkg/global"
 	"go-gin-rest-api/pkg/paniclog"
 	"net/http"
@@ -37,6 +38,8 @@ func init() {
 	initialize.Logger()
 	// 初始化数据库
 	initialize.Mysql()
+	// 加载凭据加密密钥
+	initialize.InitSecret()
 	// 初始化Sentinel流控规则
 	initialize.InitSentinel()
 	// 初始校验器
@@ -51,6 +54,8 @@ func init() {
 	initialize.InitCasbin()
 	// 初始化定时任务
 	initialize.Cron()
+	// 初始化流水线组件
+	initialize.InitPipeline()
 }
 
 // @title go-gin-rest-api
@@ -104,7 +109,11 @@ func main() {
 		Cache:      autocert.DirCache("./cache"),
 	}
 	global.Log.Info(fmt.Sprintf("HTTPS Server is running at %s:%d/%s", host, 443, global.Conf.System.UrlPathPrefix))
-	autotls.RunWithManager(r, &certManager)
+	go func() {
+		if err := autotls.RunWithManager(r, &certManager); err != nil {
+			global.Log.Error("HTTPS listen error", "err", err.Error())
+		}
+	}()
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
 	quit := make(chan os.Signal)
@@ -112,7 +121,11 @@ func main() {
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// 收到退出信号或流水线worker排空完成时关闭服务
+	select {
+	case <-quit:
+	case <-flow.Drained():
+	}
 	global.Log.Info("Shutting down server...")
 	// The context is used to inform the server it has 5 seconds to finish
 	// the request it is currently handling
@@ -2,6 +2,8 @@ package pipeline
 
 // 流水线
 type PipelineRun struct {
-	Dag string            `json:"dag" binding:"required" ` // 流水线名称
-	Var map[string]string `json:"var" binding:"required" ` // 流水线参数
+	Dag            string            `json:"dag" binding:"required" ` // 流水线名称
+	Var            map[string]string `json:"var" binding:"required" ` // 流水线参数
+	User           string            `json:"user"`                    // 操作人, 由调用系统自行声明未经认证, 只用于审计, 部署准入策略应按appId和roles判断
+	OverrideReason string            `json:"overrideReason"`          // 破窗原因, 调用系统拥有破窗角色时可在冻结期间部署
 }
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+
+	"gorm.io/gorm"
+)
+
+// 应用表, 记录应用所属团队, 用于按团队统计
+type PipelineApp struct {
+	gorm.Model
+	Name        string `gorm:"column:Name;uniqueIndex;size:128;comment:应用名称" json:"Name" rql:"filter,sort,column=Name"` // 应用名称, 与部署目标的app一致
+	Team        string `gorm:"column:Team;index;size:128;comment:所属团队" json:"Team" rql:"filter,sort,column=Team"`       // 所属团队
+	Description string `gorm:"column:Description;comment:描述" json:"Description"`                                        // 描述
+}
+
+// 创建/更新应用
+type PipelineAppReq struct {
+	Name        string `json:"Name" binding:"required"` // 应用名称
+	Team        string `json:"Team"`                    // 所属团队
+	Description string `json:"Description"`             // 描述
+}
+
+// 创建/更新应用
+func UpsertPipelineApp(req *PipelineAppReq) (*PipelineApp, error) {
+	app := new(PipelineApp)
+	err := global.Mysql.Where(PipelineApp{Name: req.Name}).
+		Assign(map[string]interface{}{
+			"Team":        req.Team,
+			"Description": req.Description,
+		}).FirstOrCreate(app).Error
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// 获取全部应用
+func ListPipelineApps() ([]PipelineApp, error) {
+	list := make([]PipelineApp, 0)
+	err := global.Mysql.Order("Name").Find(&list).Error
+	return list, err
+}
+
+// 删除应用
+func DeletePipelineApp(name string) error {
+	return global.Mysql.Unscoped().Where("Name = ?", name).Delete(&PipelineApp{}).Error
+}
+
+// 获取应用到团队的映射
+func GetPipelineAppTeams() (map[string]string, error) {
+	list, err := ListPipelineApps()
+	if err != nil {
+		return nil, err
+	}
+	teams := make(map[string]string, len(list))
+	for _, app := range list {
+		teams[app.Name] = app.Team
+	}
+	return teams, nil
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+
+	"gorm.io/gorm"
+)
+
+// k8s资源变更表, k8s-apply任务计算的diff, 供审批时查看
+type PipelineApplyDiff struct {
+	gorm.Model
+	DagID      string `gorm:"column:DagID;size:128;comment:流水线ID" json:"DagID" rql:"filter,sort,column=DagID"`                                // 流水线ID
+	DagInsID   string `gorm:"column:DagInsID;index:idx_apply_diff;size:64;comment:流水线实例ID" json:"DagInsID" rql:"filter,sort,column=DagInsID"` // 流水线实例ID
+	TaskID     string `gorm:"column:TaskID;index:idx_apply_diff;size:128;comment:任务ID" json:"TaskID" rql:"filter,sort,column=TaskID"`         // 任务ID
+	Cluster    string `gorm:"column:Cluster;size:128;comment:集群" json:"Cluster" rql:"filter,sort,column=Cluster"`                             // 集群
+	ApiVersion string `gorm:"column:ApiVersion;size:128;comment:资源版本" json:"ApiVersion"`                                                      // 资源版本
+	Kind       string `gorm:"column:Kind;size:128;comment:资源类型" json:"Kind" rql:"filter,sort,column=Kind"`                                    // 资源类型
+	Namespace  string `gorm:"column:Namespace;size:128;comment:命名空间" json:"Namespace" rql:"filter,sort,column=Namespace"`                     // 命名空间
+	Name       string `gorm:"column:Name;size:255;comment:名称" json:"Name" rql:"filter,sort,column=Name"`                                      // 名称
+	Action     string `gorm:"column:Action;size:32;comment:变更类型" json:"Action" rql:"filter,sort,column=Action"`                               // 变更类型: create/update/delete/unchanged
+	Diff       string `gorm:"column:Diff;type:longtext;comment:diff" json:"Diff"`                                                             // diff
+	Error      string `gorm:"column:Error;type:text;comment:dry-run失败原因" json:"Error"`                                                        // dry-run失败原因, 不为空时diff为与渲染结果的比较
+	Applied    bool   `gorm:"column:Applied;comment:是否已执行" json:"Applied"`                                                                    // 是否已执行, dry-run时为false
+}
+
+// 保存任务的资源变更, 任务重试时覆盖
+func SavePipelineApplyDiffs(dagInsId, taskId string, diffs []PipelineApplyDiff) error {
+	return global.Mysql.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("DagInsID = ? AND TaskID = ?", dagInsId, taskId).Delete(&PipelineApplyDiff{}).Error; err != nil {
+			return err
+		}
+		if len(diffs) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(diffs, 100).Error
+	})
+}
+
+// 标记任务的资源变更已执行
+func MarkPipelineApplyDiffsApplied(dagInsId, taskId string) error {
+	return global.Mysql.Model(&PipelineApplyDiff{}).Where("DagInsID = ? AND TaskID = ?", dagInsId, taskId).Update("Applied", true).Error
+}
+
+// 获取流水线实例的资源变更, taskId为空时返回全部任务
+func GetPipelineApplyDiffs(dagInsId, taskId string) ([]PipelineApplyDiff, error) {
+	list := make([]PipelineApplyDiff, 0)
+	query := global.Mysql.Where("DagInsID = ?", dagInsId)
+	if taskId != "" {
+		query = query.Where("TaskID = ?", taskId)
+	}
+	err := query.Order("id").Find(&list).Error
+	return list, err
+}
+
+// 获取任务部署的资源
+func GetPipelineApplyResources(dagInsId, taskId string) ([]PipelineApplyDiff, error) {
+	list := make([]PipelineApplyDiff, 0)
+	err := global.Mysql.Select("Cluster", "ApiVersion", "Kind", "Namespace", "Name", "Action").
+		Where("DagInsID = ? AND TaskID = ? AND Action <> ?", dagInsId, taskId, "delete").Order("id").Find(&list).Error
+	return list, err
+}
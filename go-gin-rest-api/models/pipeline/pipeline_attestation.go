@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+
+	"gorm.io/gorm"
+)
+
+// 构建证明类型
+const (
+	AttestationProvenance = "provenance"
+	AttestationSbom       = "sbom"
+)
+
+// 构建证明表, 保存构建的SLSA来源证明和SBOM
+type PipelineAttestation struct {
+	gorm.Model
+	DagID     string `gorm:"column:DagID;size:128;comment:流水线ID" json:"DagID" rql:"filter,sort,column=DagID"`                                       // 流水线ID
+	DagInsID  string `gorm:"column:DagInsID;uniqueIndex:idx_attestation;size:64;comment:流水线实例ID" json:"DagInsID" rql:"filter,sort,column=DagInsID"` // 流水线实例ID
+	Type      string `gorm:"column:Type;uniqueIndex:idx_attestation;size:32;comment:证明类型" json:"Type" rql:"filter,sort,column=Type"`                // 证明类型: provenance/sbom
+	Format    string `gorm:"column:Format;size:64;comment:格式" json:"Format"`                                                                        // 格式: slsa-provenance-v1/cyclonedx/spdx
+	MediaType string `gorm:"column:MediaType;size:128;comment:媒体类型" json:"MediaType"`                                                               // 媒体类型
+	Digest    string `gorm:"column:Digest;size:128;comment:内容摘要" json:"Digest"`                                                                     // 内容的sha256摘要
+	Subject   string `gorm:"column:Subject;size:512;comment:构建产物" json:"Subject"`                                                                   // 构建产物, 如镜像摘要引用
+	Referrer  string `gorm:"column:Referrer;size:512;comment:OCI引用" json:"Referrer"`                                                                // 作为OCI referrer附加到镜像时的引用
+	Content   string `gorm:"column:Content;type:longtext;comment:内容" json:"Content,omitempty"`                                                      // 内容
+}
+
+// 保存构建证明, 同一流水线实例同类型的证明覆盖
+func SavePipelineAttestation(attestation *PipelineAttestation) error {
+	return global.Mysql.Where(PipelineAttestation{DagInsID: attestation.DagInsID, Type: attestation.Type}).
+		Assign(map[string]interface{}{
+			"DagID":     attestation.DagID,
+			"Format":    attestation.Format,
+			"MediaType": attestation.MediaType,
+			"Digest":    attestation.Digest,
+			"Subject":   attestation.Subject,
+			"Referrer":  attestation.Referrer,
+			"Content":   attestation.Content,
+		}).FirstOrCreate(attestation).Error
+}
+
+// 获取流水线实例的构建证明, 不包含内容
+func GetPipelineAttestations(dagInsId string) ([]PipelineAttestation, error) {
+	list := make([]PipelineAttestation, 0)
+	err := global.Mysql.Omit("Content").Where("DagInsID = ?", dagInsId).Order("Type").Find(&list).Error
+	return list, err
+}
+
+// 获取流水线实例指定类型的构建证明
+func GetPipelineAttestation(dagInsId, attestationType string) (*PipelineAttestation, error) {
+	attestation := new(PipelineAttestation)
+	err := global.Mysql.Where("DagInsID = ? AND Type = ?", dagInsId, attestationType).First(attestation).Error
+	if err != nil {
+		return nil, err
+	}
+	return attestation, nil
+}
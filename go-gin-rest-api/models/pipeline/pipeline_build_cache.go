@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/pkg/global"
+
+	"gorm.io/gorm"
+)
+
+// 构建缓存配额表, 按缓存类型和命名空间覆盖配置中的命名空间默认大小上限
+type PipelineBuildCacheQuota struct {
+	gorm.Model
+	Type        string `gorm:"column:Type;uniqueIndex:idx_build_cache_quota;size:32;comment:缓存类型" json:"Type" rql:"filter,sort,column=Type"`                 // 缓存类型: buildkit/kaniko
+	Namespace   string `gorm:"column:Namespace;uniqueIndex:idx_build_cache_quota;size:128;comment:命名空间" json:"Namespace" rql:"filter,sort,column=Namespace"` // 命名空间, 如应用名称或应用-Dockerfile
+	MaxSize     int64  `gorm:"column:MaxSize;comment:大小上限(MB)" json:"MaxSize"`                                                                               // 大小上限, MB, 0为不限制
+	Description string `gorm:"column:Description;comment:描述" json:"Description"`                                                                             // 描述
+}
+
+// 创建/更新构建缓存配额
+type PipelineBuildCacheQuotaReq struct {
+	Type        string `json:"Type" binding:"required"`      // 缓存类型: buildkit/kaniko
+	Namespace   string `json:"Namespace" binding:"required"` // 命名空间
+	MaxSize     int64  `json:"MaxSize"`                      // 大小上限, MB, 0为不限制
+	Description string `json:"Description"`                  // 描述
+}
+
+// 创建/更新构建缓存配额, 缓存类型和命名空间由调用方校验
+func UpsertPipelineBuildCacheQuota(req *PipelineBuildCacheQuotaReq) (*PipelineBuildCacheQuota, error) {
+	if req.MaxSize < 0 {
+		return nil, errors.New("大小上限不能为负数")
+	}
+	quota := new(PipelineBuildCacheQuota)
+	err := global.Mysql.Where(PipelineBuildCacheQuota{Type: req.Type, Namespace: req.Namespace}).
+		Assign(map[string]interface{}{
+			"MaxSize":     req.MaxSize,
+			"Description": req.Description,
+		}).FirstOrCreate(quota).Error
+	if err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// 获取全部构建缓存配额
+func ListPipelineBuildCacheQuotas() ([]PipelineBuildCacheQuota, error) {
+	list := make([]PipelineBuildCacheQuota, 0)
+	err := global.Mysql.Order("Type").Order("Namespace").Find(&list).Error
+	return list, err
+}
+
+// 获取构建缓存配额, 键为缓存类型/命名空间, 值为字节数
+func GetPipelineBuildCacheQuotas() (map[string]int64, error) {
+	list, err := ListPipelineBuildCacheQuotas()
+	if err != nil {
+		return nil, err
+	}
+	quotas := make(map[string]int64, len(list))
+	for _, quota := range list {
+		quotas[quota.Type+"/"+quota.Namespace] = quota.MaxSize * 1024 * 1024
+	}
+	return quotas, nil
+}
+
+// 删除构建缓存配额
+func DeletePipelineBuildCacheQuota(cacheType, namespace string) error {
+	return global.Mysql.Unscoped().Where("Type = ? AND Namespace = ?", cacheType, namespace).Delete(&PipelineBuildCacheQuota{}).Error
+}
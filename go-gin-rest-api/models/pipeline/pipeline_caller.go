@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/pkg/global"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 流水线实例调用方表, 通过接口启动流水线时记录, 用于部署准入策略
+type PipelineCaller struct {
+	gorm.Model
+	DagID          string `gorm:"column:DagID;index;size:128;comment:流水线ID" json:"DagID" rql:"filter,sort,column=DagID"`                 // 流水线ID
+	DagInsID       string `gorm:"column:DagInsID;uniqueIndex;size:64;comment:流水线实例ID" json:"DagInsID" rql:"filter,sort,column=DagInsID"` // 流水线实例ID
+	Trigger        string `gorm:"column:Trigger;size:32;comment:触发方式" json:"Trigger" rql:"filter,sort,column=Trigger"`                   // 触发方式
+	AppId          string `gorm:"column:AppId;size:128;comment:调用系统" json:"AppId" rql:"filter,sort,column=AppId"`                        // 调用系统
+	User           string `gorm:"column:User;size:128;comment:操作人" json:"User" rql:"filter,sort,column=User"`                            // 操作人, 由调用系统自行声明未经认证, 只用于审计
+	Roles          string `gorm:"column:Roles;comment:调用系统角色" json:"Roles"`                                                              // 调用系统角色, 逗号分隔
+	OverrideReason string `gorm:"column:OverrideReason;comment:破窗原因" json:"OverrideReason"`                                              // 破窗原因, 拥有破窗角色时可在冻结期间部署
+	Branch         string `gorm:"column:Branch;size:128;comment:代码分支" json:"Branch"`                                                     // 流水线即代码按触发引用解析的分支, 不取自调用方传入的参数, 其他方式启动时为空
+}
+
+// 保存流水线实例调用方
+func SavePipelineCaller(caller *PipelineCaller) error {
+	err := global.Mysql.Create(caller).Error
+	if err != nil {
+		global.Log.Error("SavePipelineCaller写入流水线调用方失败", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 删除流水线实例调用方, 流水线实例创建失败时调用
+func DeletePipelineCaller(dagInsId string) error {
+	return global.Mysql.Unscoped().Where("DagInsID = ?", dagInsId).Delete(&PipelineCaller{}).Error
+}
+
+// 获取流水线实例调用方, 未记录(如定时触发)时返回nil
+func GetPipelineCaller(dagInsId string) (*PipelineCaller, error) {
+	caller := new(PipelineCaller)
+	err := global.Mysql.Where("DagInsID = ?", dagInsId).First(caller).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return caller, nil
+}
+
+// 是否拥有角色
+func (c *PipelineCaller) HasRole(role string) bool {
+	for _, r := range c.RoleList() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// 调用方角色列表
+func (c *PipelineCaller) RoleList() []string {
+	roles := make([]string, 0)
+	for _, role := range strings.Split(c.Roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"errors"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/policy"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 匹配全部环境或应用
+const DeployPolicyMatchAll = "*"
+
+// 部署准入策略表
+type PipelineDeployPolicy struct {
+	gorm.Model
+	Name       string `gorm:"column:Name;uniqueIndex;size:128;comment:策略名称" json:"Name" rql:"filter,sort,column=Name"` // 策略名称
+	Env        string `gorm:"column:Env;size:64;comment:目标环境" json:"Env" rql:"filter,sort,column=Env"`                 // 目标环境, *或为空匹配全部环境
+	App        string `gorm:"column:App;size:128;comment:应用名称" json:"App" rql:"filter,sort,column=App"`                // 应用名称, *或为空匹配全部应用
+	Expression string `gorm:"column:Expression;type:text;comment:CEL表达式" json:"Expression"`                            // CEL表达式, 结果为true时允许部署
+	Message    string `gorm:"column:Message;comment:拒绝原因" json:"Message"`                                              // 拒绝原因
+	Enabled    bool   `gorm:"column:Enabled;comment:是否启用" json:"Enabled" rql:"filter,sort,column=Enabled"`             // 是否启用
+	SignKeys   string `gorm:"column:SignKeys;comment:验签公钥" json:"SignKeys"`                                            // 可信公钥的密钥名称, 多个以逗号分隔, 设置时部署的镜像摘要须有任一公钥的有效签名
+}
+
+// 创建/更新部署准入策略
+type PipelineDeployPolicyReq struct {
+	Name       string `json:"Name" binding:"required"` // 策略名称
+	Env        string `json:"Env"`                     // 目标环境, *或为空匹配全部环境
+	App        string `json:"App"`                     // 应用名称, *或为空匹配全部应用
+	Expression string `json:"Expression"`              // CEL表达式, 结果为true时允许部署, 设置验签公钥时可为空
+	Message    string `json:"Message"`                 // 拒绝原因
+	Enabled    bool   `json:"Enabled"`                 // 是否启用
+	SignKeys   string `json:"SignKeys"`                // 可信公钥的密钥名称, 多个以逗号分隔
+}
+
+// 测试部署准入策略
+type PipelineDeployPolicyTestReq struct {
+	Expression string                 `json:"Expression"` // 待测试的CEL表达式, 为空时测试匹配环境和应用的已启用策略
+	Env        string                 `json:"Env"`        // 目标环境
+	App        string                 `json:"App"`        // 应用名称
+	Vars       map[string]string      `json:"Vars"`       // 流水线参数
+	Build      string                 `json:"Build"`      // 构建流水线实例ID, 设置时从实例和输出生成构建记录
+	BuildInfo  map[string]interface{} `json:"BuildInfo"`  // 构建记录, 与Build同时设置时覆盖同名字段
+	Caller     map[string]interface{} `json:"Caller"`     // 调用方, 如{"appId":"cdms","user":"admin","roles":["admin"]}
+	Now        *time.Time             `json:"Now"`        // 当前时间, 为空时使用服务器时间
+}
+
+// 部署准入检查结果
+type PipelineDeployAdmission struct {
+	Allowed bool            `json:"allowed"` // 是否允许部署
+	Input   policy.Input    `json:"input"`   // 策略输入
+	Results []policy.Result `json:"results"` // 各策略检查结果
+}
+
+// 创建/更新部署准入策略
+func UpsertPipelineDeployPolicy(req *PipelineDeployPolicyReq) (*PipelineDeployPolicy, error) {
+	if req.Expression == "" && req.SignKeys == "" {
+		return nil, errors.New("部署准入策略须设置CEL表达式或验签公钥")
+	}
+	if req.Expression != "" {
+		if err := policy.Validate(req.Expression); err != nil {
+			return nil, err
+		}
+	}
+	deployPolicy := new(PipelineDeployPolicy)
+	err := global.Mysql.Where(PipelineDeployPolicy{Name: req.Name}).
+		Assign(map[string]interface{}{
+			"Env":        req.Env,
+			"App":        req.App,
+			"Expression": req.Expression,
+			"Message":    req.Message,
+			"Enabled":    req.Enabled,
+			"SignKeys":   req.SignKeys,
+		}).FirstOrCreate(deployPolicy).Error
+	if err != nil {
+		return nil, err
+	}
+	return deployPolicy, nil
+}
+
+// 获取全部部署准入策略
+func ListPipelineDeployPolicies() ([]PipelineDeployPolicy, error) {
+	list := make([]PipelineDeployPolicy, 0)
+	err := global.Mysql.Order("Name").Find(&list).Error
+	return list, err
+}
+
+// 删除部署准入策略
+func DeletePipelineDeployPolicy(name string) error {
+	return global.Mysql.Unscoped().Where("Name = ?", name).Delete(&PipelineDeployPolicy{}).Error
+}
+
+// 获取匹配目标环境和应用的已启用策略
+func getPipelineDeployPolicies(env, app string) ([]PipelineDeployPolicy, error) {
+	list := make([]PipelineDeployPolicy, 0)
+	err := global.Mysql.Where("Enabled = ?", true).
+		Where("Env IN ?", []string{"", DeployPolicyMatchAll, env}).
+		Where("App IN ?", []string{"", DeployPolicyMatchAll, app}).
+		Order("Name").Find(&list).Error
+	return list, err
+}
+
+// 获取匹配目标环境和应用的已启用策略的CEL规则
+func GetPipelineDeployRules(env, app string) ([]policy.Rule, error) {
+	list, err := getPipelineDeployPolicies(env, app)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]policy.Rule, 0, len(list))
+	for _, deployPolicy := range list {
+		if deployPolicy.Expression == "" {
+			continue
+		}
+		rules = append(rules, policy.Rule{Name: deployPolicy.Name, Expression: deployPolicy.Expression, Message: deployPolicy.Message})
+	}
+	return rules, nil
+}
+
+// 获取匹配目标环境和应用且设置了验签公钥的已启用策略
+func GetPipelineSignPolicies(env, app string) ([]PipelineDeployPolicy, error) {
+	list, err := getPipelineDeployPolicies(env, app)
+	if err != nil {
+		return nil, err
+	}
+	signPolicies := make([]PipelineDeployPolicy, 0)
+	for _, deployPolicy := range list {
+		if strings.TrimSpace(deployPolicy.SignKeys) != "" {
+			signPolicies = append(signPolicies, deployPolicy)
+		}
+	}
+	return signPolicies, nil
+}
+
+// 验签公钥列表
+func (p *PipelineDeployPolicy) SignKeyList() []string {
+	keys := make([]string, 0)
+	for _, key := range strings.Split(p.SignKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,256 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 部署状态
+const (
+	DeploymentScheduled = "scheduled" // 流水线实例已创建, 部署任务尚未开始
+	DeploymentRunning   = "running"   // 部署中
+	DeploymentSuccess   = "success"   // 部署成功
+	DeploymentFailed    = "failed"    // 部署失败
+	DeploymentBlocked   = "blocked"   // 被冻结窗口或准入策略拦截
+	DeploymentRollback  = "rollback"  // 部署成功后因金丝雀分析等校验失败被回滚
+)
+
+// 部署记录表, 部署类任务开始时创建, 结束时更新状态
+type PipelineDeployment struct {
+	gorm.Model
+	DagID          string     `gorm:"column:DagID;index;size:128;comment:流水线ID" json:"DagID" rql:"filter,sort,column=DagID"`           // 流水线ID
+	DagInsID       string     `gorm:"column:DagInsID;index;size:64;comment:流水线实例ID" json:"DagInsID" rql:"filter,sort,column=DagInsID"` // 流水线实例ID
+	TaskID         string     `gorm:"column:TaskID;size:128;comment:任务ID" json:"TaskID" rql:"filter,sort,column=TaskID"`               // 任务ID
+	TaskInsID      string     `gorm:"column:TaskInsID;size:64;comment:任务实例ID" json:"TaskInsID"`                                        // 任务实例ID
+	Env            string     `gorm:"column:Env;index:idx_deployment;size:64;comment:目标环境" json:"Env" rql:"filter,sort,column=Env"`    // 目标环境
+	App            string     `gorm:"column:App;index:idx_deployment;size:128;comment:应用名称" json:"App" rql:"filter,sort,column=App"`   // 应用名称
+	Build          string     `gorm:"column:Build;size:64;comment:构建流水线实例ID" json:"Build" rql:"filter,sort,column=Build"`              // 构建流水线实例ID
+	Image          string     `gorm:"column:Image;comment:镜像" json:"Image" rql:"filter,sort,column=Image"`                             // 镜像
+	Digest         string     `gorm:"column:Digest;size:128;comment:镜像摘要" json:"Digest" rql:"filter,sort,column=Digest"`               // 镜像摘要
+	Commit         string     `gorm:"column:Commit;size:64;comment:代码提交" json:"Commit" rql:"filter,sort,column=Commit"`                // 代码提交
+	Version        string     `gorm:"column:Version;size:128;comment:版本" json:"Version" rql:"filter,sort,column=Version"`              // 版本
+	CommitAt       *time.Time `gorm:"column:CommitAt;comment:提交时间" json:"CommitAt"`                                                    // 代码提交时间, 用于计算变更前置时间
+	GitOpsCommit   string     `gorm:"column:GitOpsCommit;size:64;comment:配置仓库提交" json:"GitOpsCommit"`                                  // GitOps部署时配置仓库的提交
+	GitOpsChange   string     `gorm:"column:GitOpsChange;comment:配置仓库合并请求" json:"GitOpsChange"`                                        // GitOps部署时创建的合并请求地址
+	ReleaseNotes   string     `gorm:"column:ReleaseNotes;type:longtext;comment:发布说明" json:"ReleaseNotes"`                              // 与上一次部署之间的发布说明
+	Status         string     `gorm:"column:Status;index;size:32;comment:部署状态" json:"Status" rql:"filter,sort,column=Status"`          // 部署状态
+	Reason         string     `gorm:"column:Reason;type:text;comment:拦截或失败原因" json:"Reason"`                                           // 拦截或失败原因
+	AppId          string     `gorm:"column:AppId;size:128;comment:调用系统" json:"AppId" rql:"filter,sort,column=AppId"`                  // 调用系统
+	User           string     `gorm:"column:User;size:128;comment:操作人" json:"User" rql:"filter,sort,column=User"`                      // 操作人
+	Override       bool       `gorm:"column:Override;comment:是否破窗部署" json:"Override" rql:"filter,sort,column=Override"`                // 是否在冻结期间破窗部署
+	OverrideReason string     `gorm:"column:OverrideReason;comment:破窗原因" json:"OverrideReason"`                                        // 破窗原因
+	StartedAt      time.Time  `gorm:"column:StartedAt;index;comment:开始时间" json:"StartedAt" rql:"filter,sort,column=StartedAt"`         // 开始时间
+	FinishedAt     *time.Time `gorm:"column:FinishedAt;comment:结束时间" json:"FinishedAt" rql:"filter,sort,column=FinishedAt"`            // 结束时间
+	RolledBackAt   *time.Time `gorm:"column:RolledBackAt;comment:回滚时间" json:"RolledBackAt" rql:"filter,sort,column=RolledBackAt"`      // 回滚时间
+}
+
+// 部署日历
+type PipelineDeployCalendar struct {
+	Start time.Time           `json:"start"` // 开始时间
+	End   time.Time           `json:"end"`   // 结束时间
+	Days  []PipelineDeployDay `json:"days"`  // 按天汇总
+}
+
+// 部署日历中的一天
+type PipelineDeployDay struct {
+	Date        string                 `json:"date"`        // 日期, 格式2006-01-02
+	Deployments []PipelineDeployment   `json:"deployments"` // 当天开始的部署, 包含待执行的部署
+	Freezes     []PipelineFreezePeriod `json:"freezes"`     // 当天生效的冻结时段
+}
+
+// 创建部署记录
+func CreatePipelineDeployment(deployment *PipelineDeployment) error {
+	err := global.Mysql.Create(deployment).Error
+	if err != nil {
+		global.Log.Error("CreatePipelineDeployment写入部署记录失败", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 更新部署记录状态
+func FinishPipelineDeployment(deployment *PipelineDeployment, status, reason string) error {
+	now := time.Now()
+	deployment.Status = status
+	deployment.Reason = reason
+	deployment.FinishedAt = &now
+	err := global.Mysql.Model(deployment).Updates(map[string]interface{}{
+		"Status":     status,
+		"Reason":     reason,
+		"FinishedAt": now,
+	}).Error
+	if err != nil {
+		global.Log.Error("FinishPipelineDeployment更新部署记录失败", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 记录GitOps部署的配置仓库提交和合并请求
+func SetPipelineDeploymentGitOps(deployment *PipelineDeployment, commit, change string) error {
+	deployment.GitOpsCommit = commit
+	deployment.GitOpsChange = change
+	err := global.Mysql.Model(deployment).Updates(map[string]interface{}{
+		"GitOpsCommit": commit,
+		"GitOpsChange": change,
+	}).Error
+	if err != nil {
+		global.Log.Error("SetPipelineDeploymentGitOps更新部署记录失败", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 记录部署的发布说明
+func SetPipelineDeploymentReleaseNotes(deployment *PipelineDeployment, notes string) error {
+	deployment.ReleaseNotes = notes
+	err := global.Mysql.Model(deployment).Update("ReleaseNotes", notes).Error
+	if err != nil {
+		global.Log.Error("SetPipelineDeploymentReleaseNotes更新部署记录失败", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 标记部署记录已回滚
+func RollbackPipelineDeployment(deployment *PipelineDeployment, reason string) error {
+	now := time.Now()
+	deployment.Status = DeploymentRollback
+	deployment.Reason = reason
+	deployment.RolledBackAt = &now
+	err := global.Mysql.Model(deployment).Updates(map[string]interface{}{
+		"Status":       DeploymentRollback,
+		"Reason":       reason,
+		"RolledBackAt": now,
+	}).Error
+	if err != nil {
+		global.Log.Error("RollbackPipelineDeployment更新部署记录失败", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 获取流水线实例最近一次成功的部署, 没有时返回nil
+func GetLastPipelineDeployment(dagInsId string) (*PipelineDeployment, error) {
+	list := make([]PipelineDeployment, 0)
+	err := global.Mysql.Where("DagInsID = ? AND Status = ?", dagInsId, DeploymentSuccess).Order("id DESC").Limit(1).Find(&list).Error
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// 获取部署记录
+func GetPipelineDeployment(id uint) (*PipelineDeployment, error) {
+	deployment := new(PipelineDeployment)
+	if err := global.Mysql.First(deployment, id).Error; err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// 获取环境和应用最近一次成功的部署, 没有时返回nil
+func GetLatestPipelineDeployment(env, app string) (*PipelineDeployment, error) {
+	list := make([]PipelineDeployment, 0)
+	err := global.Mysql.Where("Env = ? AND App = ? AND Status = ?", env, app, DeploymentSuccess).Order("id DESC").Limit(1).Find(&list).Error
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// 标签清理时各环境和应用保留的最近成功部署的镜像数, 作为回滚目标
+const RollbackDeployments = 5
+
+// 获取当前在用的镜像: 各环境和应用最近RollbackDeployments个不同的成功部署的镜像, 及待执行和部署中的部署
+func GetPipelineDeployedImages() ([]PipelineDeployment, error) {
+	succeeded := make([]PipelineDeployment, 0)
+	err := global.Mysql.Model(&PipelineDeployment{}).Select("MAX(id) AS id", "Env", "App", "Image", "Digest").
+		Where("Status = ?", DeploymentSuccess).Group("Env, App, Image, Digest").Order("id DESC").Find(&succeeded).Error
+	if err != nil {
+		return nil, err
+	}
+	list := make([]PipelineDeployment, 0)
+	counts := map[string]int{}
+	for _, deployment := range succeeded {
+		key := deployment.Env + "/" + deployment.App
+		if counts[key] < RollbackDeployments {
+			counts[key]++
+			list = append(list, deployment)
+		}
+	}
+	pending := make([]PipelineDeployment, 0)
+	err = global.Mysql.Select("id", "Env", "App", "Image", "Digest", "Status").
+		Where("Status IN ?", []string{DeploymentScheduled, DeploymentRunning}).Find(&pending).Error
+	return append(list, pending...), err
+}
+
+// 获取同一环境和应用在该部署之前最近一次成功的部署, 没有时返回nil
+func GetPreviousPipelineDeployment(deployment *PipelineDeployment) (*PipelineDeployment, error) {
+	list := make([]PipelineDeployment, 0)
+	err := global.Mysql.Where("Env = ? AND App = ? AND Status = ? AND id < ?", deployment.Env, deployment.App, DeploymentSuccess, deployment.ID).
+		Order("id DESC").Limit(1).Find(&list).Error
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// 获取时间范围内的部署记录, env/app为空时不过滤
+func ListPipelineDeployments(env, app string, from, to time.Time) ([]PipelineDeployment, error) {
+	list := make([]PipelineDeployment, 0)
+	query := global.Mysql.Omit("ReleaseNotes").Where("StartedAt >= ? AND StartedAt < ?", from, to)
+	if env != "" {
+		query = query.Where("Env = ?", env)
+	}
+	if app != "" {
+		query = query.Where("App = ?", app)
+	}
+	err := query.Order("StartedAt").Find(&list).Error
+	return list, err
+}
+
+// 批量查询已有部署记录的流水线实例
+func GetDeployedDagInsIDs(dagInsIds []string) (map[string]bool, error) {
+	deployed := map[string]bool{}
+	if len(dagInsIds) == 0 {
+		return deployed, nil
+	}
+	ids := make([]string, 0)
+	err := global.Mysql.Model(&PipelineDeployment{}).Distinct("DagInsID").Where("DagInsID IN ?", dagInsIds).Pluck("DagInsID", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		deployed[id] = true
+	}
+	return deployed, nil
+}
+
+// 按天汇总部署记录和冻结时段, 跨天的冻结时段出现在每一天
+func NewPipelineDeployCalendar(from, to time.Time, deployments []PipelineDeployment, freezes []PipelineFreezePeriod) PipelineDeployCalendar {
+	calendar := PipelineDeployCalendar{Start: from, End: to, Days: make([]PipelineDeployDay, 0)}
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		item := PipelineDeployDay{
+			Date:        day.Format("2006-01-02"),
+			Deployments: make([]PipelineDeployment, 0),
+			Freezes:     make([]PipelineFreezePeriod, 0),
+		}
+		for _, deployment := range deployments {
+			startedAt := deployment.StartedAt.In(from.Location())
+			if !startedAt.Before(day) && startedAt.Before(next) {
+				item.Deployments = append(item.Deployments, deployment)
+			}
+		}
+		for _, freeze := range freezes {
+			if freeze.Start.Before(next) && freeze.End.After(day) {
+				item.Freezes = append(item.Freezes, freeze)
+			}
+		}
+		calendar.Days = append(calendar.Days, item)
+	}
+	return calendar
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/report"
+	"time"
+)
+
+// 查找部署失败后的恢复部署时, 在统计范围之后额外查询的时间
+const doraRestoreLookahead = 30 * 24 * time.Hour
+
+// 获取用于计算DORA指标的部署, 按部署结束时间过滤和统计, env/app/team为空时不过滤, 不包含被拦截和未结束的部署
+func GetPipelineDoraDeployments(env, app, team string, from, to time.Time) ([]report.DoraDeployment, error) {
+	list := make([]PipelineDeployment, 0)
+	query := global.Mysql.Omit("ReleaseNotes").Where("Status IN ?", []string{DeploymentSuccess, DeploymentFailed, DeploymentRollback}).
+		Where("FinishedAt >= ? AND FinishedAt < ?", from, to.Add(doraRestoreLookahead))
+	if env != "" {
+		query = query.Where("Env = ?", env)
+	}
+	if app != "" {
+		query = query.Where("App = ?", app)
+	}
+	if err := query.Order("FinishedAt").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	teams, err := GetPipelineAppTeams()
+	if err != nil {
+		return nil, err
+	}
+	deployments := make([]report.DoraDeployment, 0, len(list))
+	for _, deployment := range list {
+		if deployment.FinishedAt == nil || team != "" && teams[deployment.App] != team {
+			continue
+		}
+		deployments = append(deployments, report.DoraDeployment{
+			Env:        deployment.Env,
+			App:        deployment.App,
+			Team:       teams[deployment.App],
+			Deployed:   deployment.Status != DeploymentFailed,
+			Failed:     deployment.Status != DeploymentSuccess,
+			DeployedAt: *deployment.FinishedAt,
+			CommitAt:   deployment.CommitAt,
+			RestoredAt: deployment.RolledBackAt,
+		})
+	}
+	report.FillDoraRestores(deployments)
+	return deployments, nil
+}
@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/policy"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 破窗角色, 拥有该角色的调用系统填写变更原因后可在冻结期间部署
+const BreakGlassRole = "group_break-glass"
+
+// 部署冻结窗口表
+type PipelineFreezeWindow struct {
+	gorm.Model
+	Name     string     `gorm:"column:Name;uniqueIndex;size:128;comment:窗口名称" json:"Name" rql:"filter,sort,column=Name"` // 窗口名称
+	Env      string     `gorm:"column:Env;size:64;comment:目标环境" json:"Env" rql:"filter,sort,column=Env"`                 // 目标环境, *或为空匹配全部环境
+	App      string     `gorm:"column:App;size:128;comment:应用名称" json:"App" rql:"filter,sort,column=App"`                // 应用名称, *或为空匹配全部应用
+	Cron     string     `gorm:"column:Cron;size:128;comment:周期性窗口开始时间" json:"Cron"`                                      // 周期性窗口开始时间, cron表达式(含秒), 如"0 0 22 * * *"
+	Duration int        `gorm:"column:Duration;comment:周期性窗口持续分钟数" json:"Duration"`                                      // 周期性窗口持续分钟数
+	StartAt  *time.Time `gorm:"column:StartAt;comment:一次性窗口开始时间" json:"StartAt"`                                         // 一次性窗口开始时间
+	EndAt    *time.Time `gorm:"column:EndAt;comment:一次性窗口结束时间" json:"EndAt"`                                             // 一次性窗口结束时间
+	Reason   string     `gorm:"column:Reason;comment:冻结原因" json:"Reason"`                                                // 冻结原因
+	Enabled  bool       `gorm:"column:Enabled;comment:是否启用" json:"Enabled" rql:"filter,sort,column=Enabled"`             // 是否启用
+}
+
+// 创建/更新部署冻结窗口
+type PipelineFreezeWindowReq struct {
+	Name     string     `json:"Name" binding:"required"` // 窗口名称
+	Env      string     `json:"Env"`                     // 目标环境, *或为空匹配全部环境
+	App      string     `json:"App"`                     // 应用名称, *或为空匹配全部应用
+	Cron     string     `json:"Cron"`                    // 周期性窗口开始时间, cron表达式(含秒)
+	Duration int        `json:"Duration"`                // 周期性窗口持续分钟数
+	StartAt  *time.Time `json:"StartAt"`                 // 一次性窗口开始时间
+	EndAt    *time.Time `json:"EndAt"`                   // 一次性窗口结束时间
+	Reason   string     `json:"Reason"`                  // 冻结原因
+	Enabled  bool       `json:"Enabled"`                 // 是否启用
+}
+
+// 冻结时段
+type PipelineFreezePeriod struct {
+	Name   string    `json:"name"`   // 窗口名称
+	Env    string    `json:"env"`    // 目标环境
+	App    string    `json:"app"`    // 应用名称
+	Reason string    `json:"reason"` // 冻结原因
+	Start  time.Time `json:"start"`  // 开始时间
+	End    time.Time `json:"end"`    // 结束时间
+}
+
+// 转换为冻结窗口计算规则
+func (w *PipelineFreezeWindow) Window() policy.FreezeWindow {
+	window := policy.FreezeWindow{Cron: w.Cron, Duration: time.Duration(w.Duration) * time.Minute}
+	if w.StartAt != nil {
+		window.StartAt = *w.StartAt
+	}
+	if w.EndAt != nil {
+		window.EndAt = *w.EndAt
+	}
+	return window
+}
+
+// 创建/更新部署冻结窗口
+func UpsertPipelineFreezeWindow(req *PipelineFreezeWindowReq) (*PipelineFreezeWindow, error) {
+	window := &PipelineFreezeWindow{
+		Name:     req.Name,
+		Env:      req.Env,
+		App:      req.App,
+		Cron:     req.Cron,
+		Duration: req.Duration,
+		StartAt:  req.StartAt,
+		EndAt:    req.EndAt,
+		Reason:   req.Reason,
+		Enabled:  req.Enabled,
+	}
+	if err := window.Window().Validate(); err != nil {
+		return nil, err
+	}
+	err := global.Mysql.Where(PipelineFreezeWindow{Name: req.Name}).
+		Assign(map[string]interface{}{
+			"Env":      req.Env,
+			"App":      req.App,
+			"Cron":     req.Cron,
+			"Duration": req.Duration,
+			"StartAt":  req.StartAt,
+			"EndAt":    req.EndAt,
+			"Reason":   req.Reason,
+			"Enabled":  req.Enabled,
+		}).FirstOrCreate(window).Error
+	if err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// 获取全部部署冻结窗口
+func ListPipelineFreezeWindows() ([]PipelineFreezeWindow, error) {
+	list := make([]PipelineFreezeWindow, 0)
+	err := global.Mysql.Order("Name").Find(&list).Error
+	return list, err
+}
+
+// 删除部署冻结窗口
+func DeletePipelineFreezeWindow(name string) error {
+	return global.Mysql.Unscoped().Where("Name = ?", name).Delete(&PipelineFreezeWindow{}).Error
+}
+
+// 获取与[from, to)有交集的冻结时段, env/app为空时不过滤
+func GetPipelineFreezePeriods(env, app string, from, to time.Time) ([]PipelineFreezePeriod, error) {
+	list := make([]PipelineFreezeWindow, 0)
+	query := global.Mysql.Where("Enabled = ?", true)
+	if env != "" {
+		query = query.Where("Env IN ?", []string{"", DeployPolicyMatchAll, env})
+	}
+	if app != "" {
+		query = query.Where("App IN ?", []string{"", DeployPolicyMatchAll, app})
+	}
+	if err := query.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	periods := make([]PipelineFreezePeriod, 0)
+	for _, window := range list {
+		for _, period := range window.Window().Periods(from, to, 1000) {
+			periods = append(periods, PipelineFreezePeriod{
+				Name:   window.Name,
+				Env:    window.Env,
+				App:    window.App,
+				Reason: window.Reason,
+				Start:  period.Start,
+				End:    period.End,
+			})
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start.Before(periods[j].Start)
+	})
+	return periods, nil
+}
+
+// 获取t时刻对目标环境和应用生效的冻结时段
+func GetActivePipelineFreezes(env, app string, t time.Time) ([]PipelineFreezePeriod, error) {
+	return GetPipelineFreezePeriods(env, app, t, t.Add(time.Second))
+}
@@ -0,0 +1,281 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go-gin-rest-api/pkg/git"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/secret"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"gorm.io/gorm"
+)
+
+// 代码仓库表, 访问令牌和webhook密钥加密保存, 接口不返回
+type PipelineGitRepo struct {
+	gorm.Model
+	Name          string `gorm:"column:Name;uniqueIndex;size:128;comment:代码仓库名称" json:"Name" rql:"filter,sort,column=Name"` // 代码仓库名称
+	URL           string `gorm:"column:URL;size:255;comment:仓库地址" json:"URL" rql:"filter,sort,column=URL"`                  // 仓库地址, https或SSH地址
+	Provider      string `gorm:"column:Provider;size:32;comment:代码托管平台" json:"Provider" rql:"filter,sort,column=Provider"`  // 代码托管平台: github/gitlab/gitee, 为空时不支持webhook
+	APIURL        string `gorm:"column:APIURL;size:255;comment:平台API地址" json:"APIURL"`                                      // 平台API地址, 为空时按仓库地址推断
+	DefaultBranch string `gorm:"column:DefaultBranch;size:128;comment:默认分支" json:"DefaultBranch"`                           // 默认分支
+	Username      string `gorm:"column:Username;size:128;comment:用户名" json:"Username"`                                      // 用户名, token认证时默认oauth2, SSH认证时默认git
+	Token         string `gorm:"column:Token;type:text;comment:访问令牌(加密)" json:"-"`                                          // 访问令牌或密码, AES-GCM加密
+	SSHKey        string `gorm:"column:SSHKey;size:128;comment:SSH私钥文件名" json:"SSHKey"`                                     // SSH私钥文件名, 位于storage/git-key目录
+	Mirror        bool   `gorm:"column:Mirror;comment:保留本地裸镜像" json:"Mirror"`                                               // 在storage/git-source保留本地裸镜像, 加速重复克隆
+	RunOnPush     bool   `gorm:"column:RunOnPush;comment:推送时运行流水线" json:"RunOnPush"`                                        // 收到推送webhook时按仓库中的.cdms.yml运行流水线
+	PushRefs      string `gorm:"column:PushRefs;size:255;comment:推送时运行流水线的引用" json:"PushRefs"`                              // 推送时允许运行流水线的引用, 逗号分隔的通配符, 如refs/heads/release/*,refs/tags/v*, 为空时只允许默认分支
+	WebhookID     string `gorm:"column:WebhookID;size:64;comment:平台webhook ID" json:"WebhookID"`                            // 平台上注册的webhook ID, 为空表示未注册
+	WebhookSecret string `gorm:"column:WebhookSecret;type:text;comment:webhook密钥(加密)" json:"-"`                             // webhook签名密钥, AES-GCM加密
+	Description   string `gorm:"column:Description;comment:描述" json:"Description"`                                          // 描述
+}
+
+// 创建/更新代码仓库
+type PipelineGitRepoReq struct {
+	Name          string `json:"Name" binding:"required"` // 代码仓库名称
+	URL           string `json:"URL" binding:"required"`  // 仓库地址
+	Provider      string `json:"Provider"`                // 代码托管平台: github/gitlab/gitee
+	APIURL        string `json:"APIURL"`                  // 平台API地址
+	DefaultBranch string `json:"DefaultBranch"`           // 默认分支, 默认main
+	Username      string `json:"Username"`                // 用户名
+	Token         string `json:"Token"`                   // 访问令牌或密码, 更新时为空则保留原令牌
+	SSHKey        string `json:"SSHKey"`                  // SSH私钥文件名
+	Mirror        bool   `json:"Mirror"`                  // 保留本地裸镜像
+	RunOnPush     bool   `json:"RunOnPush"`               // 推送时按.cdms.yml运行流水线
+	PushRefs      string `json:"PushRefs"`                // 推送时允许运行流水线的引用, 逗号分隔的通配符, 为空时只允许默认分支
+	Description   string `json:"Description"`             // 描述
+}
+
+// 按仓库中的流水线文件运行流水线
+type PipelineGitRepoRun struct {
+	Ref            string            `json:"ref"`            // 分支、标签或提交, 为空时使用默认分支
+	Var            map[string]string `json:"var"`            // 流水线参数, 不能覆盖自动注入的repo/ref/commit等参数
+	User           string            `json:"user"`           // 操作人, 由调用系统自行声明未经认证, 只用于审计, 部署准入策略应按appId和roles判断
+	OverrideReason string            `json:"overrideReason"` // 破窗原因, 调用系统拥有破窗角色时可在冻结期间部署
+}
+
+// 仓库认证信息
+func (r *PipelineGitRepo) Credential() (git.Credential, error) {
+	token, err := secret.Decrypt(r.Token)
+	if err != nil {
+		return git.Credential{}, err
+	}
+	return git.Credential{Username: r.Username, SSHKey: r.SSHKey, Password: token}, nil
+}
+
+// 代码托管平台API客户端
+func (r *PipelineGitRepo) ProviderClient() (*git.Provider, error) {
+	if r.Provider == "" {
+		return nil, errors.New("代码仓库未设置代码托管平台")
+	}
+	credential, err := r.Credential()
+	if err != nil {
+		return nil, err
+	}
+	return git.NewProvider(r.Provider, r.URL, r.APIURL, credential.Password)
+}
+
+// 推送的引用是否允许运行流水线, 未配置PushRefs时只允许默认分支
+func (r *PipelineGitRepo) RunOnRef(ref string) bool {
+	if r.PushRefs == "" {
+		return ref == plumbing.NewBranchReferenceName(r.DefaultBranch).String()
+	}
+	for _, pattern := range strings.Split(r.PushRefs, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), ref); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 平台回调本服务的webhook地址
+func (r *PipelineGitRepo) WebhookURL() string {
+	return strings.TrimRight(global.Conf.System.BaseApi, "/") + "/" + global.Conf.System.UrlPathPrefix +
+		"/v1/public/git-repos/" + url.PathEscape(r.Name) + "/webhook"
+}
+
+// 列出远程分支和标签, 不克隆仓库
+func (r *PipelineGitRepo) ListRefs(ctx context.Context) ([]git.Ref, error) {
+	credential, err := r.Credential()
+	if err != nil {
+		return nil, err
+	}
+	auth, err := credential.Auth()
+	if err != nil {
+		return nil, err
+	}
+	return git.ListRefs(ctx, r.URL, auth)
+}
+
+// 创建或更新本地裸镜像
+func (r *PipelineGitRepo) SyncMirror(ctx context.Context) error {
+	credential, err := r.Credential()
+	if err != nil {
+		return err
+	}
+	auth, err := credential.Auth()
+	if err != nil {
+		return err
+	}
+	_, err = git.Mirror(ctx, r.URL, auth)
+	return err
+}
+
+// 在代码托管平台注册webhook, 已注册时先删除旧webhook, 每次注册生成新密钥
+func (r *PipelineGitRepo) RegisterWebhook(ctx context.Context) error {
+	provider, err := r.ProviderClient()
+	if err != nil {
+		return err
+	}
+	if r.WebhookID != "" {
+		if err := provider.DeleteWebhook(ctx, r.WebhookID); err != nil {
+			return err
+		}
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	hookSecret := hex.EncodeToString(buf)
+	encrypted, err := secret.Encrypt(hookSecret)
+	if err != nil {
+		return err
+	}
+	id, err := provider.CreateWebhook(ctx, r.WebhookURL(), hookSecret)
+	if err != nil {
+		return err
+	}
+	r.WebhookID, r.WebhookSecret = id, encrypted
+	return global.Mysql.Model(r).Updates(map[string]interface{}{"WebhookID": id, "WebhookSecret": encrypted}).Error
+}
+
+// 删除代码托管平台上的webhook
+func (r *PipelineGitRepo) UnregisterWebhook(ctx context.Context) error {
+	if r.WebhookID == "" {
+		return nil
+	}
+	provider, err := r.ProviderClient()
+	if err != nil {
+		return err
+	}
+	if err := provider.DeleteWebhook(ctx, r.WebhookID); err != nil {
+		return err
+	}
+	r.WebhookID, r.WebhookSecret = "", ""
+	return global.Mysql.Model(r).Updates(map[string]interface{}{"WebhookID": "", "WebhookSecret": ""}).Error
+}
+
+// 校验并解析平台推送的webhook请求
+func (r *PipelineGitRepo) ParseWebhook(header http.Header, body []byte) (*git.WebhookEvent, error) {
+	if r.WebhookSecret == "" {
+		return nil, errors.New("代码仓库未注册webhook")
+	}
+	hookSecret, err := secret.Decrypt(r.WebhookSecret)
+	if err != nil {
+		return nil, err
+	}
+	if err := git.VerifyWebhook(r.Provider, header, body, hookSecret); err != nil {
+		return nil, err
+	}
+	return git.ParseWebhook(r.Provider, header, body)
+}
+
+// 创建/更新代码仓库
+func UpsertPipelineGitRepo(req *PipelineGitRepoReq) (*PipelineGitRepo, error) {
+	if _, _, err := git.ParseURL(req.URL); err != nil {
+		return nil, err
+	}
+	switch req.Provider {
+	case "", git.ProviderGitHub, git.ProviderGitLab, git.ProviderGitee:
+	default:
+		return nil, errors.New("不支持的代码托管平台: " + req.Provider)
+	}
+	if req.SSHKey != "" {
+		if _, err := (git.Credential{SSHKey: req.SSHKey}).Auth(); err != nil {
+			return nil, err
+		}
+	}
+	if req.DefaultBranch == "" {
+		req.DefaultBranch = "main"
+	}
+	if req.PushRefs != "" {
+		for _, pattern := range strings.Split(req.PushRefs, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if _, err := path.Match(pattern, ""); err != nil || !strings.HasPrefix(pattern, "refs/") {
+				return nil, errors.New("推送引用格式错误, 应为refs/开头的通配符: " + pattern)
+			}
+		}
+	}
+	values := map[string]interface{}{
+		"URL":           req.URL,
+		"Provider":      req.Provider,
+		"APIURL":        req.APIURL,
+		"DefaultBranch": req.DefaultBranch,
+		"Username":      req.Username,
+		"SSHKey":        req.SSHKey,
+		"Mirror":        req.Mirror,
+		"RunOnPush":     req.RunOnPush,
+		"PushRefs":      req.PushRefs,
+		"Description":   req.Description,
+	}
+	if req.Token != "" {
+		token, err := secret.Encrypt(req.Token)
+		if err != nil {
+			return nil, err
+		}
+		values["Token"] = token
+	}
+	repo := new(PipelineGitRepo)
+	err := global.Mysql.Where(PipelineGitRepo{Name: req.Name}).Assign(values).FirstOrCreate(repo).Error
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// 获取全部代码仓库
+func ListPipelineGitRepos() ([]PipelineGitRepo, error) {
+	list := make([]PipelineGitRepo, 0)
+	err := global.Mysql.Order("Name").Find(&list).Error
+	return list, err
+}
+
+// 获取代码仓库
+func GetPipelineGitRepo(name string) (*PipelineGitRepo, error) {
+	repo := new(PipelineGitRepo)
+	if err := global.Mysql.Where("Name = ?", name).First(repo).Error; err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// 删除代码仓库, 同时删除平台webhook和本地裸镜像, 其他代码仓库使用相同地址时保留镜像
+func DeletePipelineGitRepo(ctx context.Context, name string) error {
+	repo, err := GetPipelineGitRepo(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := repo.UnregisterWebhook(ctx); err != nil {
+		return err
+	}
+	if err := global.Mysql.Unscoped().Delete(repo).Error; err != nil {
+		return err
+	}
+	var count int64
+	if err := global.Mysql.Model(&PipelineGitRepo{}).Where("URL = ? AND Mirror = ?", repo.URL, true).Count(&count).Error; err != nil {
+		return err
+	}
+	if repo.Mirror && count == 0 {
+		return git.RemoveMirror(repo.URL)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+
+	"gorm.io/gorm"
+)
+
+// 流水线任务输出表
+type PipelineOutput struct {
+	gorm.Model
+	DagID     string `gorm:"column:DagID;index;comment:流水线ID" json:"DagID" rql:"filter,sort,column=DagID"`                                     // 流水线ID
+	DagInsID  string `gorm:"column:DagInsID;uniqueIndex:idx_output;size:64;comment:流水线实例ID" json:"DagInsID" rql:"filter,sort,column=DagInsID"` // 流水线实例ID
+	TaskID    string `gorm:"column:TaskID;uniqueIndex:idx_output;size:128;comment:任务ID" json:"TaskID" rql:"filter,sort,column=TaskID"`         // 任务ID
+	TaskInsID string `gorm:"column:TaskInsID;comment:任务实例ID" json:"TaskInsID"`                                                                 // 任务实例ID
+	Name      string `gorm:"column:Name;uniqueIndex:idx_output;size:128;comment:输出名称" json:"Name" rql:"filter,sort,column=Name"`               // 输出名称
+	Value     string `gorm:"column:Value;type:text;comment:输出值" json:"Value"`                                                                  // 输出值
+}
+
+// 流水线实例输出
+type PipelineRunOutputs struct {
+	DagID    string                       `json:"dagId"`    // 流水线ID
+	DagInsID string                       `json:"dagInsId"` // 流水线实例ID
+	Status   string                       `json:"status"`   // 流水线实例状态
+	Outputs  map[string]string            `json:"outputs"`  // 输出名称和值, 同名输出以后写入的为准
+	Tasks    map[string]map[string]string `json:"tasks"`    // 按任务ID分组的输出
+}
+
+// 查询最近一次成功运行的输出
+type PipelineLatestOutputsReq struct {
+	Dag   string            `json:"dag" binding:"required"` // 流水线ID
+	Var   map[string]string `json:"var"`                    // 按流水线参数过滤, 如{"branch":"main"}
+	Names []string          `json:"names"`                  // 只返回指定名称的输出, 为空返回全部
+}
+
+// 写入任务输出, 同一实例同一任务同名输出重复写入时覆盖
+func SavePipelineOutput(output *PipelineOutput) error {
+	err := global.Mysql.Where(PipelineOutput{DagInsID: output.DagInsID, TaskID: output.TaskID, Name: output.Name}).
+		Assign(PipelineOutput{DagID: output.DagID, TaskInsID: output.TaskInsID, Value: output.Value}).
+		FirstOrCreate(output).Error
+	if err != nil {
+		global.Log.Error("SavePipelineOutput写入流水线输出失败", "err", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 获取流水线实例的全部输出
+func GetPipelineOutputs(dagInsId string) ([]PipelineOutput, error) {
+	list := make([]PipelineOutput, 0)
+	err := global.Mysql.Where("DagInsID = ?", dagInsId).Order("id").Find(&list).Error
+	return list, err
+}
+
+// 汇总输出列表
+func NewPipelineRunOutputs(dagId, dagInsId, status string, list []PipelineOutput) PipelineRunOutputs {
+	runOutputs := PipelineRunOutputs{
+		DagID:    dagId,
+		DagInsID: dagInsId,
+		Status:   status,
+		Outputs:  map[string]string{},
+		Tasks:    map[string]map[string]string{},
+	}
+	for _, output := range list {
+		runOutputs.Outputs[output.Name] = output.Value
+		if runOutputs.Tasks[output.TaskID] == nil {
+			runOutputs.Tasks[output.TaskID] = map[string]string{}
+		}
+		runOutputs.Tasks[output.TaskID][output.Name] = output.Value
+	}
+	return runOutputs
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/report"
+
+	"gorm.io/gorm"
+)
+
+// 默认质量门禁策略的应用名称, 应用没有单独配置时使用
+const DefaultQualityPolicyApp = "*"
+
+// 质量门禁规则列表, 以JSON保存
+type PipelineQualityRules []report.QualityRule
+
+func (r PipelineQualityRules) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	return string(data), err
+}
+
+func (r *PipelineQualityRules) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("质量门禁规则类型错误: %T", value)
+}
+
+// 质量门禁策略表
+type PipelineQualityPolicy struct {
+	gorm.Model
+	App   string               `gorm:"column:App;uniqueIndex;size:128;comment:应用名称" json:"App" rql:"filter,sort,column=App"` // 应用名称, *为默认策略
+	Rules PipelineQualityRules `gorm:"column:Rules;type:text;comment:规则" json:"Rules"`                                       // 规则
+}
+
+// 创建/更新质量门禁策略
+type PipelineQualityPolicyReq struct {
+	App   string               `json:"App" binding:"required"`   // 应用名称, *为默认策略
+	Rules PipelineQualityRules `json:"Rules" binding:"required"` // 规则
+}
+
+// 质量指标表, 用于与基线分支比较
+type PipelineQualityMetric struct {
+	gorm.Model
+	DagID    string  `gorm:"column:DagID;size:128;comment:流水线ID" json:"DagID" rql:"filter,sort,column=DagID"`                          // 流水线ID
+	DagInsID string  `gorm:"column:DagInsID;index;size:64;comment:流水线实例ID" json:"DagInsID" rql:"filter,sort,column=DagInsID"`          // 流水线实例ID
+	App      string  `gorm:"column:App;index:idx_quality_metric;size:128;comment:应用名称" json:"App" rql:"filter,sort,column=App"`        // 应用名称
+	Branch   string  `gorm:"column:Branch;index:idx_quality_metric;size:255;comment:分支" json:"Branch" rql:"filter,sort,column=Branch"` // 分支
+	Name     string  `gorm:"column:Name;index:idx_quality_metric;size:64;comment:指标名称" json:"Name" rql:"filter,sort,column=Name"`      // 指标名称
+	Value    float64 `gorm:"column:Value;comment:指标值" json:"Value"`                                                                    // 指标值
+	Passed   bool    `gorm:"column:Passed;comment:质量门禁是否通过" json:"Passed" rql:"filter,column=Passed"`                                  // 质量门禁是否通过, 只有通过的运行作为基线
+}
+
+// 创建/更新质量门禁策略
+func UpsertPipelineQualityPolicy(req *PipelineQualityPolicyReq) (*PipelineQualityPolicy, error) {
+	for _, rule := range req.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	policy := new(PipelineQualityPolicy)
+	err := global.Mysql.Where(PipelineQualityPolicy{App: req.App}).
+		Assign(PipelineQualityPolicy{Rules: req.Rules}).FirstOrCreate(policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// 获取全部质量门禁策略
+func ListPipelineQualityPolicies() ([]PipelineQualityPolicy, error) {
+	list := make([]PipelineQualityPolicy, 0)
+	err := global.Mysql.Order("App").Find(&list).Error
+	return list, err
+}
+
+// 删除质量门禁策略
+func DeletePipelineQualityPolicy(app string) error {
+	return global.Mysql.Unscoped().Where("App = ?", app).Delete(&PipelineQualityPolicy{}).Error
+}
+
+// 获取应用的质量门禁规则, 应用没有单独配置时使用默认策略
+func GetPipelineQualityRules(app string) ([]report.QualityRule, error) {
+	for _, name := range []string{app, DefaultQualityPolicyApp} {
+		policy := new(PipelineQualityPolicy)
+		err := global.Mysql.Where("App = ?", name).First(policy).Error
+		if err == nil {
+			return policy.Rules, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("应用%s没有配置质量门禁策略", app)
+}
+
+// 保存流水线实例的质量指标及门禁结果, 任务重试时覆盖
+func SavePipelineQualityMetrics(dagId, dagInsId, app, branch string, metrics map[string]float64, passed bool) error {
+	return global.Mysql.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("DagInsID = ? AND App = ?", dagInsId, app).Delete(&PipelineQualityMetric{}).Error; err != nil {
+			return err
+		}
+		for name, value := range metrics {
+			metric := PipelineQualityMetric{DagID: dagId, DagInsID: dagInsId, App: app, Branch: branch, Name: name, Value: value, Passed: passed}
+			if err := tx.Create(&metric).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 获取应用在基线分支最近一次通过质量门禁的各项质量指标, 排除当前流水线实例
+func GetPipelineQualityBaseline(app, branch, excludeDagInsId string) (map[string]float64, error) {
+	list := make([]PipelineQualityMetric, 0)
+	latest := global.Mysql.Model(&PipelineQualityMetric{}).Select("MAX(id)").
+		Where("App = ? AND Branch = ? AND DagInsID <> ? AND Passed = ?", app, branch, excludeDagInsId, true).Group("Name")
+	err := global.Mysql.Where("id IN (?)", latest).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	baseline := make(map[string]float64, len(list))
+	for _, metric := range list {
+		baseline[metric.Name] = metric.Value
+	}
+	return baseline, nil
+}
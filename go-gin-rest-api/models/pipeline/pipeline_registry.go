@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"fmt"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/registry"
+	"go-gin-rest-api/pkg/secret"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gorm.io/gorm"
+)
+
+// 镜像仓库表, 密码加密保存, 接口不返回
+type PipelineRegistry struct {
+	gorm.Model
+	Name        string `gorm:"column:Name;uniqueIndex;size:128;comment:镜像仓库名称" json:"Name" rql:"filter,sort,column=Name"` // 镜像仓库名称
+	URL         string `gorm:"column:URL;size:255;comment:镜像仓库地址" json:"URL" rql:"filter,sort,column=URL"`                // 镜像仓库地址, 如https://harbor.example.com, http地址按非安全仓库访问
+	Type        string `gorm:"column:Type;size:32;comment:镜像仓库类型" json:"Type" rql:"filter,sort,column=Type"`              // 镜像仓库类型: harbor/acr/generic
+	Username    string `gorm:"column:Username;size:128;comment:用户名" json:"Username"`                                      // 用户名, 为空时使用docker配置文件中的凭据
+	Password    string `gorm:"column:Password;type:text;comment:密码(加密)" json:"-"`                                         // 密码或令牌, AES-GCM加密
+	Insecure    bool   `gorm:"column:Insecure;comment:使用http访问" json:"Insecure"`                                          // 使用http访问仓库
+	ACRInstance string `gorm:"column:ACRInstance;size:64;comment:ACR企业版实例ID" json:"ACRInstance"`                          // ACR企业版实例ID, 仅acr类型使用
+	ACRKeyID    string `gorm:"column:ACRKeyID;size:128;comment:ACR AccessKey ID" json:"ACRKeyID"`                         // 获取ACR仓库列表的AccessKey ID
+	ACRKey      string `gorm:"column:ACRKey;type:text;comment:ACR AccessKey Secret(加密)" json:"-"`                         // 获取ACR仓库列表的AccessKey Secret, AES-GCM加密
+	Description string `gorm:"column:Description;comment:描述" json:"Description"`                                          // 描述
+}
+
+// 创建/更新镜像仓库
+type PipelineRegistryReq struct {
+	Name        string `json:"Name" binding:"required"` // 镜像仓库名称
+	URL         string `json:"URL" binding:"required"`  // 镜像仓库地址
+	Type        string `json:"Type"`                    // 镜像仓库类型: harbor/acr/generic, 默认generic
+	Username    string `json:"Username"`                // 用户名
+	Password    string `json:"Password"`                // 密码或令牌, 更新时为空则保留原密码
+	Insecure    bool   `json:"Insecure"`                // 使用http访问仓库
+	ACRInstance string `json:"ACRInstance"`             // ACR企业版实例ID, acr类型必填
+	ACRKeyID    string `json:"ACRKeyID"`                // ACR AccessKey ID, acr类型必填
+	ACRKey      string `json:"ACRKey"`                  // ACR AccessKey Secret, 更新时为空则保留原密钥
+	Description string `json:"Description"`             // 描述
+}
+
+// 访问镜像仓库的地址和认证信息
+func (r *PipelineRegistry) Endpoint() (registry.Endpoint, error) {
+	password, err := secret.Decrypt(r.Password)
+	if err != nil {
+		return registry.Endpoint{}, err
+	}
+	acrKey, err := secret.Decrypt(r.ACRKey)
+	if err != nil {
+		return registry.Endpoint{}, err
+	}
+	return registry.Endpoint{
+		Type: r.Type,
+		URL:  r.URL,
+		Auth: registry.Auth{Username: r.Username, Password: password, Insecure: r.Insecure},
+		ACR:  registry.ACR{InstanceID: r.ACRInstance, AccessKeyID: r.ACRKeyID, AccessKeySecret: acrKey},
+	}, nil
+}
+
+// 创建/更新镜像仓库
+func UpsertPipelineRegistry(req *PipelineRegistryReq) (*PipelineRegistry, error) {
+	if req.Type == "" {
+		req.Type = registry.TypeGeneric
+	}
+	if err := registry.ValidateType(req.Type); err != nil {
+		return nil, err
+	}
+	host, _, err := (registry.Endpoint{URL: req.URL}).Registry()
+	if err != nil {
+		return nil, err
+	}
+	if req.Type == registry.TypeACR {
+		if err := (registry.ACR{InstanceID: req.ACRInstance, AccessKeyID: req.ACRKeyID}).Validate(host.RegistryStr()); err != nil {
+			return nil, err
+		}
+	}
+	values := map[string]interface{}{
+		"URL":         req.URL,
+		"Type":        req.Type,
+		"Username":    req.Username,
+		"Insecure":    req.Insecure,
+		"ACRInstance": req.ACRInstance,
+		"ACRKeyID":    req.ACRKeyID,
+		"Description": req.Description,
+	}
+	if req.Password != "" {
+		password, err := secret.Encrypt(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		values["Password"] = password
+	}
+	if req.ACRKey != "" {
+		acrKey, err := secret.Encrypt(req.ACRKey)
+		if err != nil {
+			return nil, err
+		}
+		values["ACRKey"] = acrKey
+	}
+	reg := new(PipelineRegistry)
+	err = global.Mysql.Where(PipelineRegistry{Name: req.Name}).Assign(values).FirstOrCreate(reg).Error
+	if err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// 获取全部镜像仓库
+func ListPipelineRegistries() ([]PipelineRegistry, error) {
+	list := make([]PipelineRegistry, 0)
+	err := global.Mysql.Order("Name").Find(&list).Error
+	return list, err
+}
+
+// 获取镜像仓库
+func GetPipelineRegistry(name string) (*PipelineRegistry, error) {
+	reg := new(PipelineRegistry)
+	if err := global.Mysql.Where("Name = ?", name).First(reg).Error; err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// 按镜像地址的仓库主机名查找已登记的镜像仓库, 没有匹配时返回nil
+func FindPipelineRegistry(image string) (*PipelineRegistry, error) {
+	ref, err := name.ParseReference(image, name.WeakValidation)
+	if err != nil {
+		return nil, fmt.Errorf("镜像地址%s错误: %w", image, err)
+	}
+	list, err := ListPipelineRegistries()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		reg, _, err := (registry.Endpoint{URL: list[i].URL}).Registry()
+		if err == nil && reg.Name() == ref.Context().RegistryStr() {
+			return &list[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// 删除镜像仓库
+func DeletePipelineRegistry(name string) error {
+	return global.Mysql.Unscoped().Where("Name = ?", name).Delete(&PipelineRegistry{}).Error
+}
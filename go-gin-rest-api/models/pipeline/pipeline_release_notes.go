@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 生成发布说明
+type PipelineReleaseNotesReq struct {
+	Repo           string `json:"Repo" binding:"required"` // 代码仓库名称, 只能是已登记的代码仓库, 使用其保存的认证信息
+	FromDeployment uint   `json:"FromDeployment"`          // 起始部署记录ID, 为空时取结束部署之前同一环境和应用最近一次成功的部署
+	ToDeployment   uint   `json:"ToDeployment"`            // 结束部署记录ID
+	From           string `json:"From"`                    // 起始提交(不含), 分支、标签或SHA, 设置FromDeployment时忽略
+	To             string `json:"To"`                      // 结束提交, 设置ToDeployment时忽略
+	Format         string `json:"Format"`                  // 格式: markdown/json, 默认markdown
+}
+
+// 确定发布说明的提交范围, 部署记录优先于提交
+func (req *PipelineReleaseNotesReq) Range() (string, string, error) {
+	from, to := req.From, req.To
+	if req.ToDeployment != 0 {
+		deployment, err := GetPipelineDeployment(req.ToDeployment)
+		if err != nil {
+			return "", "", fmt.Errorf("获取部署记录%d失败: %w", req.ToDeployment, err)
+		}
+		if deployment.Commit == "" {
+			return "", "", fmt.Errorf("部署记录%d没有代码提交", req.ToDeployment)
+		}
+		to = deployment.Commit
+		if req.FromDeployment == 0 && from == "" {
+			previous, err := GetPreviousPipelineDeployment(deployment)
+			if err != nil {
+				return "", "", err
+			}
+			if previous != nil {
+				from = previous.Commit
+			}
+		}
+	}
+	if req.FromDeployment != 0 {
+		deployment, err := GetPipelineDeployment(req.FromDeployment)
+		if err != nil {
+			return "", "", fmt.Errorf("获取部署记录%d失败: %w", req.FromDeployment, err)
+		}
+		if deployment.Commit == "" {
+			return "", "", fmt.Errorf("部署记录%d没有代码提交", req.FromDeployment)
+		}
+		from = deployment.Commit
+	}
+	if to == "" {
+		return "", "", errors.New("未设置结束部署记录或结束提交")
+	}
+	return from, to, nil
+}
@@ -0,0 +1,208 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/registry"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gorm.io/gorm"
+)
+
+// 标签清理的任务锁, 定时任务与手动执行共用, 避免同时删除
+const (
+	RetentionLockMethod = "TagRetention"
+	RetentionLockExpire = 7200 // 任务锁过期时间, 秒
+)
+
+// 镜像标签保留策略表, 定时清理镜像仓库中不再需要的标签, 当前在用的镜像及各环境和应用最近几次成功部署的回滚镜像不删除
+type PipelineRetentionPolicy struct {
+	gorm.Model
+	Name          string `gorm:"column:Name;uniqueIndex;size:128;comment:策略名称" json:"Name" rql:"filter,sort,column=Name"`         // 策略名称
+	Registry      string `gorm:"column:Registry;index;size:128;comment:镜像仓库名称" json:"Registry" rql:"filter,sort,column=Registry"` // 镜像仓库名称
+	Repository    string `gorm:"column:Repository;size:255;comment:镜像名称模式" json:"Repository" rql:"filter,sort,column=Repository"` // 镜像名称模式, 语法同path.Match, 如team/*, 为空匹配全部
+	KeepLast      int    `gorm:"column:KeepLast;comment:保留最近的标签数" json:"KeepLast"`                                                // 按创建时间保留最近的标签数
+	KeepSemver    bool   `gorm:"column:KeepSemver;comment:保留语义化版本标签" json:"KeepSemver"`                                           // 保留语义化版本标签
+	OlderThanDays int    `gorm:"column:OlderThanDays;comment:只删除超过该天数的标签" json:"OlderThanDays"`                                   // 只删除创建时间超过该天数的标签
+	DryRun        bool   `gorm:"column:DryRun;comment:只报告不删除" json:"DryRun" rql:"filter,sort,column=DryRun"`                      // 只报告将删除的标签, 不实际删除
+	Description   string `gorm:"column:Description;comment:描述" json:"Description"`                                                // 描述
+}
+
+// 创建/更新标签保留策略
+type PipelineRetentionPolicyReq struct {
+	Name          string `json:"Name" binding:"required"`     // 策略名称
+	Registry      string `json:"Registry" binding:"required"` // 镜像仓库名称
+	Repository    string `json:"Repository"`                  // 镜像名称模式, 语法同path.Match, 如team/*, 为空匹配全部
+	KeepLast      int    `json:"KeepLast"`                    // 按创建时间保留最近的标签数
+	KeepSemver    bool   `json:"KeepSemver"`                  // 保留语义化版本标签
+	OlderThanDays int    `json:"OlderThanDays"`               // 只删除创建时间超过该天数的标签
+	DryRun        bool   `json:"DryRun"`                      // 只报告不删除
+	Description   string `json:"Description"`                 // 描述
+}
+
+// 保留规则
+func (p *PipelineRetentionPolicy) Rule() registry.RetentionRule {
+	return registry.RetentionRule{KeepLast: p.KeepLast, KeepSemver: p.KeepSemver, OlderThanDays: p.OlderThanDays}
+}
+
+// 创建/更新标签保留策略
+func UpsertPipelineRetentionPolicy(req *PipelineRetentionPolicyReq) (*PipelineRetentionPolicy, error) {
+	policy := &PipelineRetentionPolicy{KeepLast: req.KeepLast, KeepSemver: req.KeepSemver, OlderThanDays: req.OlderThanDays}
+	if err := policy.Rule().Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := registry.MatchRepository(req.Repository, ""); err != nil {
+		return nil, err
+	}
+	if _, err := GetPipelineRegistry(req.Registry); err != nil {
+		return nil, fmt.Errorf("镜像仓库%s不存在: %w", req.Registry, err)
+	}
+	err := global.Mysql.Where(PipelineRetentionPolicy{Name: req.Name}).
+		Assign(map[string]interface{}{
+			"Registry":      req.Registry,
+			"Repository":    req.Repository,
+			"KeepLast":      req.KeepLast,
+			"KeepSemver":    req.KeepSemver,
+			"OlderThanDays": req.OlderThanDays,
+			"DryRun":        req.DryRun,
+			"Description":   req.Description,
+		}).FirstOrCreate(policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// 获取全部标签保留策略
+func ListPipelineRetentionPolicies() ([]PipelineRetentionPolicy, error) {
+	list := make([]PipelineRetentionPolicy, 0)
+	err := global.Mysql.Order("Name").Find(&list).Error
+	return list, err
+}
+
+// 获取标签保留策略
+func GetPipelineRetentionPolicy(name string) (*PipelineRetentionPolicy, error) {
+	policy := new(PipelineRetentionPolicy)
+	if err := global.Mysql.Where("Name = ?", name).First(policy).Error; err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// 删除标签保留策略
+func DeletePipelineRetentionPolicy(name string) error {
+	return global.Mysql.Unscoped().Where("Name = ?", name).Delete(&PipelineRetentionPolicy{}).Error
+}
+
+// 执行标签保留策略, dryRun或策略设置DryRun时只报告; 单个镜像失败时记录错误并继续
+func (p *PipelineRetentionPolicy) Run(ctx context.Context, dryRun bool) (*registry.RetentionReport, error) {
+	report := &registry.RetentionReport{Policy: p.Name, DryRun: dryRun || p.DryRun, Deleted: make([]registry.RetentionItem, 0), Errors: make([]string, 0)}
+	if err := p.Rule().Validate(); err != nil {
+		return nil, err
+	}
+	reg, err := GetPipelineRegistry(p.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("镜像仓库%s不存在: %w", p.Registry, err)
+	}
+	endpoint, err := reg.Endpoint()
+	if err != nil {
+		return nil, err
+	}
+	repos := []string{p.Repository}
+	if p.Repository == "" || strings.ContainsAny(p.Repository, "*?[") {
+		if repos, err = endpoint.Repositories(ctx); err != nil {
+			return nil, err
+		}
+	}
+	protected, err := deployedImageFilter()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, repo := range repos {
+		if matched, err := registry.MatchRepository(p.Repository, repo); err != nil {
+			return nil, err
+		} else if !matched {
+			continue
+		}
+		report.Repositories++
+		if err := p.clean(ctx, endpoint, repo, protected, now, report); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			global.Log.Error("PipelineRetentionPolicy清理镜像标签失败", "policy", p.Name, "repository", repo, "err", err.Error())
+		}
+	}
+	return report, nil
+}
+
+// 清理单个镜像的标签
+func (p *PipelineRetentionPolicy) clean(ctx context.Context, endpoint registry.Endpoint, repository string, protected func(name.Repository, registry.TagInfo) bool, now time.Time, report *registry.RetentionReport) error {
+	repo, _, err := endpoint.Repository(repository)
+	if err != nil {
+		return err
+	}
+	tags, err := endpoint.Tags(ctx, repository)
+	if err != nil {
+		return err
+	}
+	infos, err := endpoint.TagInfos(ctx, repository, tags)
+	if err != nil {
+		return err
+	}
+	report.Tags += len(infos)
+	deletes := p.Rule().Select(infos, func(tag registry.TagInfo) bool { return protected(repo, tag) }, now)
+	digests, byDigest := make([]string, 0), map[string][]registry.TagInfo{}
+	for _, tag := range deletes {
+		if _, ok := byDigest[tag.Digest]; !ok {
+			digests = append(digests, tag.Digest)
+		}
+		byDigest[tag.Digest] = append(byDigest[tag.Digest], tag)
+	}
+	for _, digest := range digests {
+		tags := make([]string, 0, len(byDigest[digest]))
+		for _, tag := range byDigest[digest] {
+			tags = append(tags, tag.Tag)
+		}
+		if !report.DryRun {
+			if err := endpoint.DeleteManifest(ctx, repository, digest, tags...); err != nil {
+				return err
+			}
+		}
+		for _, tag := range byDigest[digest] {
+			report.Deleted = append(report.Deleted, registry.RetentionItem{Repository: repository, Tag: tag.Tag, Digest: tag.Digest, Created: tag.Created})
+		}
+	}
+	return nil
+}
+
+// 当前在用镜像的过滤器: 部署记录中的摘要, 及只有标签的镜像地址
+func deployedImageFilter() (func(name.Repository, registry.TagInfo) bool, error) {
+	deployments, err := GetPipelineDeployedImages()
+	if err != nil {
+		return nil, err
+	}
+	digests, tags := map[string]bool{}, map[string]bool{}
+	for _, deployment := range deployments {
+		if deployment.Digest != "" {
+			digests[deployment.Digest] = true
+		}
+		if deployment.Image == "" {
+			continue
+		}
+		ref, err := name.ParseReference(deployment.Image, name.WeakValidation)
+		if err != nil {
+			continue
+		}
+		switch r := ref.(type) {
+		case name.Digest:
+			digests[r.DigestStr()] = true
+		case name.Tag:
+			tags[r.Name()] = true
+		}
+	}
+	return func(repo name.Repository, tag registry.TagInfo) bool {
+		return digests[tag.Digest] || tags[repo.Tag(tag.Tag).Name()]
+	}, nil
+}
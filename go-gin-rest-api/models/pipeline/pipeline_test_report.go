@@ -0,0 +1,191 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+
+	"gorm.io/gorm"
+)
+
+// 流水线测试用例结果表
+type PipelineTestCase struct {
+	gorm.Model
+	DagID     string  `gorm:"column:DagID;index;size:128;comment:流水线ID" json:"DagID" rql:"filter,sort,column=DagID"`           // 流水线ID
+	DagInsID  string  `gorm:"column:DagInsID;index;size:64;comment:流水线实例ID" json:"DagInsID" rql:"filter,sort,column=DagInsID"` // 流水线实例ID
+	TaskID    string  `gorm:"column:TaskID;size:128;comment:任务ID" json:"TaskID" rql:"filter,sort,column=TaskID"`               // 任务ID
+	Suite     string  `gorm:"column:Suite;size:255;comment:测试套件" json:"Suite" rql:"filter,sort,column=Suite"`                  // 测试套件
+	Name      string  `gorm:"column:Name;size:512;comment:用例名称" json:"Name" rql:"filter,sort,column=Name"`                     // 用例名称
+	Classname string  `gorm:"column:Classname;size:255;comment:类名" json:"Classname"`                                           // 类名
+	Status    string  `gorm:"column:Status;size:16;comment:状态" json:"Status" rql:"filter,sort,column=Status"`                  // 状态: passed/failed/error/skipped
+	Duration  float64 `gorm:"column:Duration;comment:耗时(秒)" json:"Duration" rql:"filter,sort,column=Duration"`                 // 耗时, 秒
+	Message   string  `gorm:"column:Message;type:text;comment:失败信息" json:"Message"`                                            // 失败信息
+	Output    string  `gorm:"column:Output;type:mediumtext;comment:失败输出" json:"Output"`                                        // 失败输出
+}
+
+// 测试结果统计
+type PipelineTestSummary struct {
+	Total    int64   `json:"total"`    // 用例总数
+	Passed   int64   `json:"passed"`   // 通过数
+	Failed   int64   `json:"failed"`   // 失败数(含error)
+	Skipped  int64   `json:"skipped"`  // 跳过数
+	Duration float64 `json:"duration"` // 用例总耗时, 秒
+	PassRate float64 `json:"passRate"` // 通过率, 不含跳过的用例, 百分比
+}
+
+// 测试套件统计
+type PipelineTestSuiteSummary struct {
+	Suite string `json:"suite"` // 测试套件
+	PipelineTestSummary
+}
+
+// 流水线实例测试报告
+type PipelineTestReport struct {
+	DagID    string                     `json:"dagId"`    // 流水线ID
+	DagInsID string                     `json:"dagInsId"` // 流水线实例ID
+	Summary  PipelineTestSummary        `json:"summary"`  // 汇总
+	Suites   []PipelineTestSuiteSummary `json:"suites"`   // 按测试套件统计
+}
+
+// 历史运行的测试统计
+type PipelineTestRun struct {
+	DagInsID string `json:"dagInsId"` // 流水线实例ID
+	PipelineTestSummary
+}
+
+// 用例耗时变化
+type PipelineTestTrend struct {
+	Suite       string  `json:"suite"`       // 测试套件
+	Name        string  `json:"name"`        // 用例名称
+	Duration    float64 `json:"duration"`    // 最近一次耗时, 秒
+	AvgDuration float64 `json:"avgDuration"` // 之前运行的平均耗时, 秒
+}
+
+// 流水线测试历史
+type PipelineTestHistory struct {
+	DagID        string              `json:"dagId"`        // 流水线ID
+	Runs         []PipelineTestRun   `json:"runs"`         // 最近的运行, 按时间倒序
+	NewlyFailing []PipelineTestCase  `json:"newlyFailing"` // 最近一次运行失败而上一次运行通过的用例
+	Slowing      []PipelineTestTrend `json:"slowing"`      // 最近一次运行明显慢于之前平均耗时的用例
+}
+
+const (
+	// 判定变慢的耗时倍数
+	slowingRatio = 1.5
+	// 判定变慢的最小耗时增加, 秒
+	slowingMinDelta = 1.0
+)
+
+// 汇总统计的查询字段
+const summarySelect = "COUNT(*) AS total, " +
+	"SUM(Status = 'passed') AS passed, " +
+	"SUM(Status IN ('failed','error')) AS failed, " +
+	"SUM(Status = 'skipped') AS skipped, " +
+	"SUM(Duration) AS duration"
+
+func (s *PipelineTestSummary) calcPassRate() {
+	if executed := s.Passed + s.Failed; executed > 0 {
+		s.PassRate = float64(s.Passed) * 100 / float64(executed)
+	}
+}
+
+// 写入任务的测试用例结果, 任务重试时覆盖之前的结果
+func SavePipelineTestCases(dagInsId, taskId string, cases []PipelineTestCase) error {
+	return global.Mysql.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("DagInsID = ? AND TaskID = ?", dagInsId, taskId).Delete(&PipelineTestCase{}).Error; err != nil {
+			return err
+		}
+		if len(cases) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(cases, 200).Error
+	})
+}
+
+// 获取流水线实例的测试报告
+func GetPipelineTestReport(dagId, dagInsId string) (*PipelineTestReport, error) {
+	report := &PipelineTestReport{DagID: dagId, DagInsID: dagInsId, Suites: make([]PipelineTestSuiteSummary, 0)}
+	err := global.Mysql.Model(&PipelineTestCase{}).Select("Suite AS suite, "+summarySelect).
+		Where("DagInsID = ?", dagInsId).Group("Suite").Order("Suite").Scan(&report.Suites).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Suites {
+		report.Suites[i].calcPassRate()
+		report.Summary.Total += report.Suites[i].Total
+		report.Summary.Passed += report.Suites[i].Passed
+		report.Summary.Failed += report.Suites[i].Failed
+		report.Summary.Skipped += report.Suites[i].Skipped
+		report.Summary.Duration += report.Suites[i].Duration
+	}
+	report.Summary.calcPassRate()
+	return report, nil
+}
+
+// 获取流水线实例失败的测试用例及输出
+func GetPipelineTestFailures(dagInsId string) ([]PipelineTestCase, error) {
+	list := make([]PipelineTestCase, 0)
+	err := global.Mysql.Where("DagInsID = ? AND Status IN ?", dagInsId, []string{"failed", "error"}).Order("Suite, Name").Find(&list).Error
+	return list, err
+}
+
+// 获取流水线最近limit次运行的测试历史
+func GetPipelineTestHistory(dagId string, limit int) (*PipelineTestHistory, error) {
+	history := &PipelineTestHistory{
+		DagID:        dagId,
+		Runs:         make([]PipelineTestRun, 0),
+		NewlyFailing: make([]PipelineTestCase, 0),
+		Slowing:      make([]PipelineTestTrend, 0),
+	}
+	err := global.Mysql.Model(&PipelineTestCase{}).Select("DagInsID AS dag_ins_id, "+summarySelect).
+		Where("DagID = ?", dagId).Group("DagInsID").Order("MAX(id) DESC").Limit(limit).Scan(&history.Runs).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range history.Runs {
+		history.Runs[i].calcPassRate()
+	}
+	if len(history.Runs) < 2 {
+		return history, nil
+	}
+	latest := make([]PipelineTestCase, 0)
+	if err := global.Mysql.Where("DagInsID = ?", history.Runs[0].DagInsID).Find(&latest).Error; err != nil {
+		return nil, err
+	}
+	// 上一次运行的用例状态
+	previous := make([]PipelineTestCase, 0)
+	if err := global.Mysql.Select("Suite, Name, Status").Where("DagInsID = ?", history.Runs[1].DagInsID).Find(&previous).Error; err != nil {
+		return nil, err
+	}
+	previousStatus := make(map[string]string, len(previous))
+	for _, c := range previous {
+		previousStatus[c.Suite+"\x00"+c.Name] = c.Status
+	}
+	// 之前运行的平均耗时
+	var avgs []struct {
+		Suite    string
+		Name     string
+		Duration float64
+	}
+	olderIds := make([]string, 0, len(history.Runs)-1)
+	for _, run := range history.Runs[1:] {
+		olderIds = append(olderIds, run.DagInsID)
+	}
+	err = global.Mysql.Model(&PipelineTestCase{}).Select("Suite AS suite, Name AS name, AVG(Duration) AS duration").
+		Where("DagInsID IN ? AND Status = ?", olderIds, "passed").Group("Suite, Name").Scan(&avgs).Error
+	if err != nil {
+		return nil, err
+	}
+	avgDuration := make(map[string]float64, len(avgs))
+	for _, avg := range avgs {
+		avgDuration[avg.Suite+"\x00"+avg.Name] = avg.Duration
+	}
+	for _, c := range latest {
+		key := c.Suite + "\x00" + c.Name
+		if (c.Status == "failed" || c.Status == "error") && previousStatus[key] == "passed" {
+			history.NewlyFailing = append(history.NewlyFailing, c)
+		}
+		if avg, ok := avgDuration[key]; ok && c.Status == "passed" && c.Duration > avg*slowingRatio && c.Duration-avg >= slowingMinDelta {
+			history.Slowing = append(history.Slowing, PipelineTestTrend{Suite: c.Suite, Name: c.Name, Duration: c.Duration, AvgDuration: avg})
+		}
+	}
+	return history, nil
+}
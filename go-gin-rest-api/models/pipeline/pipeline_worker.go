@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 流水线worker注册表, 各节点定时心跳上报
+type PipelineWorker struct {
+	gorm.Model
+	WorkerKey    string    `gorm:"column:WorkerKey;uniqueIndex;size:64;comment:worker key" json:"WorkerKey" rql:"filter,sort,column=WorkerKey"` // worker key
+	Ip           string    `gorm:"column:Ip;comment:节点IP" json:"Ip" rql:"filter,sort,column=Ip"`                                                // 节点IP
+	Leader       bool      `gorm:"column:Leader;comment:是否主节点" json:"Leader"`                                                                   // 是否主节点
+	Labels       string    `gorm:"column:Labels;type:text;comment:worker标签" json:"Labels"`                                                      // worker标签, 格式为k1=v1,k2=v2
+	Draining     bool      `gorm:"column:Draining;comment:是否排空" json:"Draining"`                                                                // 是否排空, 排空后不再接收新的流水线实例
+	RunningTasks int64     `gorm:"column:RunningTasks;comment:执行中的任务数" json:"RunningTasks"`                                                     // 执行中的任务数
+	HeartbeatAt  time.Time `gorm:"column:HeartbeatAt;comment:最近心跳时间" json:"HeartbeatAt"`                                                        // 最近心跳时间
+	BuildCaches  string    `gorm:"column:BuildCaches;type:mediumtext;comment:构建缓存使用情况" json:"-"`                                                // 节点本地构建缓存的使用情况, JSON格式, 缓存变化时上报
+}
+
+// worker执行中的任务实例
+type PipelineRunningTask struct {
+	Worker     string `gorm:"column:worker" json:"worker"`          // worker key
+	DagID      string `gorm:"column:dag_id" json:"dagId"`           // 流水线ID
+	DagInsID   string `gorm:"column:dag_ins_id" json:"dagInsId"`    // 流水线实例ID
+	TaskID     string `gorm:"column:task_id" json:"taskId"`         // 任务ID
+	TaskInsID  string `gorm:"column:id" json:"taskInsId"`           // 任务实例ID
+	ActionName string `gorm:"column:action_name" json:"actionName"` // 动作名称
+	UpdatedAt  int64  `gorm:"column:updated_at" json:"updatedAt"`   // 任务实例更新时间
+}
+
+// worker状态
+type PipelineWorkerStatus struct {
+	PipelineWorker
+	Alive        bool                  `json:"Alive"`          // 是否存活
+	RunningTasks []PipelineRunningTask `json:"RunningTaskIns"` // 执行中的任务实例
+}
+
+// worker心跳, 不存在时注册; 返回注册表中的worker记录
+func HeartbeatPipelineWorker(worker *PipelineWorker) (*PipelineWorker, error) {
+	record := new(PipelineWorker)
+	err := global.Mysql.Where(PipelineWorker{WorkerKey: worker.WorkerKey}).
+		Assign(map[string]interface{}{
+			"Ip":           worker.Ip,
+			"Leader":       worker.Leader,
+			"Labels":       worker.Labels,
+			"RunningTasks": worker.RunningTasks,
+			"HeartbeatAt":  worker.HeartbeatAt,
+		}).FirstOrCreate(record).Error
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// 获取全部worker
+func ListPipelineWorkers() ([]PipelineWorker, error) {
+	list := make([]PipelineWorker, 0)
+	err := global.Mysql.Order("WorkerKey").Find(&list).Error
+	return list, err
+}
+
+// 获取worker
+func GetPipelineWorker(workerKey string) (*PipelineWorker, error) {
+	worker := new(PipelineWorker)
+	err := global.Mysql.Where("WorkerKey = ?", workerKey).First(worker).Error
+	if err != nil {
+		return nil, err
+	}
+	return worker, nil
+}
+
+// 设置worker排空标记
+func SetPipelineWorkerDraining(workerKey string, draining bool) error {
+	return global.Mysql.Model(&PipelineWorker{}).Where("WorkerKey = ?", workerKey).Update("Draining", draining).Error
+}
+
+// 上报worker本地构建缓存的使用情况
+func SetPipelineWorkerBuildCaches(workerKey, buildCaches string) error {
+	return global.Mysql.Model(&PipelineWorker{}).Where("WorkerKey = ?", workerKey).Update("BuildCaches", buildCaches).Error
+}
+
+// 注销worker
+func DeletePipelineWorker(workerKey string) error {
+	return global.Mysql.Unscoped().Where("WorkerKey = ?", workerKey).Delete(&PipelineWorker{}).Error
+}
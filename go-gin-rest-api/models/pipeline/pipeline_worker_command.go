@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"go-gin-rest-api/pkg/global"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// worker命令, 只能在指定节点执行的操作(如清除本地构建缓存)写入命令表, 由该节点心跳时领取执行
+const (
+	WorkerCommandPurgeBuildCache = "purge-build-cache" // 清除构建缓存, 参数为类型/命名空间
+)
+
+// worker命令状态
+const (
+	WorkerCommandPending = "pending" // 待执行
+	WorkerCommandSuccess = "success" // 执行成功
+	WorkerCommandFailed  = "failed"  // 执行失败
+)
+
+// worker命令表
+type PipelineWorkerCommand struct {
+	gorm.Model
+	WorkerKey  string     `gorm:"column:WorkerKey;index:idx_worker_command_status;size:64;comment:worker key" json:"WorkerKey"` // 执行命令的worker key
+	Command    string     `gorm:"column:Command;size:64;comment:命令" json:"Command"`                                             // 命令
+	Args       string     `gorm:"column:Args;size:255;comment:命令参数" json:"Args"`                                                // 命令参数
+	Status     string     `gorm:"column:Status;index:idx_worker_command_status;size:32;comment:状态" json:"Status"`               // 状态: pending/success/failed
+	Error      string     `gorm:"column:Error;type:text;comment:失败原因" json:"Error"`                                             // 失败原因
+	FinishedAt *time.Time `gorm:"column:FinishedAt;comment:完成时间" json:"FinishedAt"`                                             // 完成时间
+}
+
+// 创建worker命令
+func CreatePipelineWorkerCommand(workerKey, command, args string) (*PipelineWorkerCommand, error) {
+	cmd := &PipelineWorkerCommand{WorkerKey: workerKey, Command: command, Args: args, Status: WorkerCommandPending}
+	if err := global.Mysql.Create(cmd).Error; err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// 获取worker命令
+func GetPipelineWorkerCommand(id uint) (*PipelineWorkerCommand, error) {
+	cmd := new(PipelineWorkerCommand)
+	if err := global.Mysql.First(cmd, id).Error; err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// 获取worker待执行的命令, 按创建顺序排列
+func GetPendingPipelineWorkerCommands(workerKey string) ([]PipelineWorkerCommand, error) {
+	list := make([]PipelineWorkerCommand, 0)
+	err := global.Mysql.Where("WorkerKey = ? AND Status = ?", workerKey, WorkerCommandPending).Order("id").Find(&list).Error
+	return list, err
+}
+
+// 记录worker命令的执行结果
+func FinishPipelineWorkerCommand(cmd *PipelineWorkerCommand, err error) error {
+	now := time.Now()
+	cmd.Status, cmd.Error, cmd.FinishedAt = WorkerCommandSuccess, "", &now
+	if err != nil {
+		cmd.Status, cmd.Error = WorkerCommandFailed, err.Error()
+	}
+	return global.Mysql.Model(cmd).Updates(map[string]interface{}{"Status": cmd.Status, "Error": cmd.Error, "FinishedAt": cmd.FinishedAt}).Error
+}
+
+// 删除指定时间之前创建的worker命令, 节点已下线时待执行的命令也一并删除
+func DeletePipelineWorkerCommands(before time.Time) error {
+	return global.Mysql.Unscoped().Where("created_at < ?", before).Delete(&PipelineWorkerCommand{}).Error
+}
@@ -50,6 +50,14 @@ func InitSysRole() {
 			Keyword: "user",
 			Desc:    "普通用户",
 		},
+		{
+			Model: gorm.Model{
+				ID: 5,
+			},
+			Name:    "break-glass",
+			Keyword: "break-glass",
+			Desc:    "破窗用户, 填写变更原因后可在部署冻结期间部署",
+		},
 	}
 	for _, role := range roles {
 		err := global.Mysql.Where(&role).FirstOrCreate(&role).Error
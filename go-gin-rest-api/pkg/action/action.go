@@ -0,0 +1,26 @@
+// 流水线动作(fastflow action), 在流水线任务中通过actionName引用
+package action
+
+import (
+	"github.com/linclin/fastflow"
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 注册全部动作, 需在fastflow.Init之前调用
+func Register() {
+	fastflow.RegisterAction([]run.Action{
+		&Shell{},
+		&TestReport{},
+		&QualityGate{},
+		&K8sApply{},
+		&RolloutVerify{},
+		&CanaryAnalysis{},
+		&ArgoWorkflow{},
+		&GitOpsCommit{},
+		&SignImage{},
+		&VerifyImage{},
+		&AttestBuild{},
+		&Version{},
+		&ReleaseNotes{},
+	})
+}
@@ -0,0 +1,189 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/kube"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// 默认值
+const (
+	defaultArgoNamespace    = "argo"
+	defaultArgoTimeout      = 3600   // 超时时间(秒)
+	defaultArgoInterval     = 5      // 状态检查间隔(秒)
+	defaultArgoLogContainer = "main" // Argo执行模板的容器名称
+	argoLogLimitBytes       = 256 * 1024
+)
+
+// Workflow标签, 记录提交的流水线实例
+const argoDagInsLabel = "cdms.io/dag-ins-id"
+
+// argo-workflow动作参数
+type ArgoWorkflowParams struct {
+	flow.TaskMeta    `json:",squash"`
+	Cluster          string            `json:"cluster"`          // 集群名称, 为空时使用所在集群
+	Namespace        string            `json:"namespace"`        // Workflow所在命名空间, 默认argo
+	WorkflowTemplate string            `json:"workflowTemplate"` // WorkflowTemplate名称
+	ClusterScope     bool              `json:"clusterScope"`     // 引用ClusterWorkflowTemplate
+	Entrypoint       string            `json:"entrypoint"`       // 入口模板, 为空时使用WorkflowTemplate的入口
+	ServiceAccount   string            `json:"serviceAccount"`   // 运行Workflow的ServiceAccount
+	Vars             []string          `json:"vars"`             // 作为Workflow参数传入的流水线参数名称, 参数名与流水线参数名相同
+	Parameters       map[string]string `json:"parameters"`       // Workflow参数, 支持{{.vars.name.Value}}、{{.shareData.name}}模板, 与vars同名时优先
+	LogContainer     string            `json:"logContainer"`     // 复制日志的容器, 默认main
+	Timeout          int               `json:"timeout"`          // 超时时间(秒), 超时后终止Workflow, 默认3600
+	Interval         int               `json:"interval"`         // 状态检查间隔(秒), 默认5
+}
+
+// 从WorkflowTemplate提交Argo Workflow并等待完成, 节点结束时将其日志写入任务trace
+// Workflow的输出参数(入口节点的outputs.parameters)作为任务输出, Workflow失败时任务失败
+// 输出: workflowName、workflowPhase及Workflow输出参数
+type ArgoWorkflow struct{}
+
+func (a *ArgoWorkflow) Name() string {
+	return "argo-workflow"
+}
+
+func (a *ArgoWorkflow) ParameterNew() interface{} {
+	return &ArgoWorkflowParams{}
+}
+
+func (a *ArgoWorkflow) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*ArgoWorkflowParams)
+	if !ok {
+		return fmt.Errorf("argo-workflow参数类型错误: %T", params)
+	}
+	if p.WorkflowTemplate == "" {
+		return fmt.Errorf("argo-workflow未设置workflowTemplate")
+	}
+	namespace := p.Namespace
+	if namespace == "" {
+		namespace = defaultArgoNamespace
+	}
+	parameters := map[string]string{}
+	for _, name := range p.Vars {
+		value, ok := ctx.GetVar(name)
+		if !ok {
+			return fmt.Errorf("流水线参数%s不存在", name)
+		}
+		parameters[name] = value
+	}
+	for name, value := range p.Parameters {
+		parameters[name] = value
+	}
+	labels := map[string]string{}
+	if len(validation.IsValidLabelValue(p.DagInsID)) == 0 {
+		labels[argoDagInsLabel] = p.DagInsID
+	}
+	clients, err := kube.NewClients(p.Cluster)
+	if err != nil {
+		return err
+	}
+	name, err := clients.SubmitWorkflow(ctx.Context(), kube.WorkflowSubmit{
+		Namespace:      namespace,
+		Template:       p.WorkflowTemplate,
+		ClusterScope:   p.ClusterScope,
+		Entrypoint:     p.Entrypoint,
+		ServiceAccount: p.ServiceAccount,
+		Parameters:     parameters,
+		Labels:         labels,
+	})
+	if err != nil {
+		return err
+	}
+	ctx.Tracef("已提交Workflow %s/%s", namespace, name)
+	p.SetOutput(ctx, "workflowName", name)
+	status, err := a.wait(ctx, clients, namespace, name, p)
+	if status != nil {
+		p.SetOutput(ctx, "workflowPhase", status.Phase)
+		for key, value := range status.Outputs {
+			p.SetOutput(ctx, key, value)
+		}
+	}
+	return err
+}
+
+// 跟踪Workflow节点状态直到结束, 节点结束时复制日志
+func (a *ArgoWorkflow) wait(ctx run.ExecuteContext, clients *kube.Clients, namespace, name string, p *ArgoWorkflowParams) (*kube.WorkflowStatus, error) {
+	timeout := secondsOrDefault(p.Timeout, defaultArgoTimeout)
+	interval := secondsOrDefault(p.Interval, defaultArgoInterval)
+	container := p.LogContainer
+	if container == "" {
+		container = defaultArgoLogContainer
+	}
+	deadline := time.Now().Add(timeout)
+	tw := newTraceWriter(ctx, nil)
+	defer tw.Close()
+	phases := map[string]string{}
+	logged := map[string]bool{}
+	for {
+		status, err := clients.GetWorkflow(ctx.Context(), namespace, name)
+		if err != nil {
+			return nil, fmt.Errorf("获取Workflow %s状态失败: %w", name, err)
+		}
+		var pods map[string]string
+		for _, node := range status.Nodes {
+			if node.Phase != phases[node.ID] {
+				phases[node.ID] = node.Phase
+				line := fmt.Sprintf("节点%s(%s): %s", node.DisplayName, node.Type, node.Phase)
+				if node.Message != "" {
+					line += " " + node.Message
+				}
+				tw.Line(line)
+			}
+			if node.Type != "Pod" || !node.Completed() || logged[node.ID] {
+				continue
+			}
+			logged[node.ID] = true
+			if pods == nil {
+				if pods, err = clients.WorkflowPods(ctx.Context(), namespace, name); err != nil {
+					tw.Line(fmt.Sprintf("查询Workflow %s的Pod失败: %s", name, err))
+					pods = map[string]string{}
+				}
+			}
+			pod, ok := pods[node.ID]
+			if !ok {
+				continue
+			}
+			logs, err := clients.PodLogs(ctx.Context(), namespace, pod, container, argoLogLimitBytes)
+			if err != nil {
+				tw.Line(fmt.Sprintf("[%s] 获取日志失败: %s", node.DisplayName, err))
+				continue
+			}
+			for _, line := range strings.Split(logs, "\n") {
+				tw.Line("[" + node.DisplayName + "] " + line)
+			}
+		}
+		if status.Completed() {
+			if status.Phase != kube.WorkflowSucceeded {
+				return status, fmt.Errorf("Workflow %s执行失败(%s): %s", name, status.Phase, status.Message)
+			}
+			tw.Line(fmt.Sprintf("Workflow %s执行成功", name))
+			return status, nil
+		}
+		if time.Now().After(deadline) {
+			a.terminate(ctx, clients, namespace, name)
+			return status, fmt.Errorf("Workflow %s执行超时(%s), 已终止", name, timeout)
+		}
+		select {
+		case <-ctx.Context().Done():
+			a.terminate(ctx, clients, namespace, name)
+			return status, ctx.Context().Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// 终止Workflow, 任务取消时ctx已结束, 使用新的context
+func (a *ArgoWorkflow) terminate(ctx run.ExecuteContext, clients *kube.Clients, namespace, name string) {
+	terminateCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := clients.TerminateWorkflow(terminateCtx, namespace, name); err != nil {
+		ctx.Tracef("终止Workflow %s失败: %s", name, err)
+	}
+}
@@ -0,0 +1,219 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"go-gin-rest-api/pkg/kube"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// 内存中的ShareData
+type memShareData struct {
+	sync.Mutex
+	data map[string]string
+}
+
+func (m *memShareData) Get(key string) (string, bool) {
+	m.Lock()
+	defer m.Unlock()
+	v, ok := m.data[key]
+	return v, ok
+}
+
+func (m *memShareData) Set(key, val string) {
+	m.Lock()
+	defer m.Unlock()
+	m.data[key] = val
+}
+
+// 测试用的执行上下文, 记录trace
+type testContext struct {
+	run.ExecuteContext
+	share  *memShareData
+	mu     sync.Mutex
+	traces []string
+}
+
+func newTestContext(vars map[string]string) *testContext {
+	c := &testContext{share: &memShareData{data: map[string]string{}}}
+	c.ExecuteContext = run.NewDefExecuteContext(context.Background(), c.share, func(msg string, opt ...run.TraceOp) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.traces = append(c.traces, msg)
+	}, func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}, nil)
+	return c
+}
+
+func (c *testContext) Trace(msg string, opt ...run.TraceOp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.traces = append(c.traces, msg)
+}
+
+func (c *testContext) trace() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.traces, "\n")
+}
+
+// 模拟Argo控制器: 提交时按generateName生成名称, 每次查询推进一个阶段
+type fakeArgo struct {
+	phases  []string
+	gets    int
+	dynamic *dynamicfake.FakeDynamicClient
+}
+
+func newFakeArgo(t *testing.T, phases ...string) *fakeArgo {
+	t.Helper()
+	argo := &fakeArgo{phases: phases}
+	argo.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{kube.WorkflowResource: "WorkflowList"})
+	argo.dynamic.PrependReactor("create", "workflows", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetName() == "" {
+			obj.SetName(obj.GetGenerateName() + "x7k2p")
+		}
+		return false, nil, nil
+	})
+	argo.dynamic.PrependReactor("get", "workflows", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		obj, err := argo.dynamic.Tracker().Get(kube.WorkflowResource, action.GetNamespace(), name)
+		if err != nil {
+			return true, nil, err
+		}
+		wf := obj.(*unstructured.Unstructured).DeepCopy()
+		phase := argo.phases[len(argo.phases)-1]
+		if argo.gets < len(argo.phases) {
+			phase = argo.phases[argo.gets]
+		}
+		argo.gets++
+		message := ""
+		if phase == kube.WorkflowFailed {
+			message = "child failed"
+		}
+		wf.Object["status"] = map[string]interface{}{
+			"phase":   phase,
+			"message": message,
+			"nodes": map[string]interface{}{
+				name: map[string]interface{}{
+					"name": name, "displayName": name, "type": "Steps", "phase": phase,
+					"startedAt": "2026-01-01T00:00:00Z",
+					"outputs": map[string]interface{}{"parameters": []interface{}{
+						map[string]interface{}{"name": "image", "value": "demo:1.0"},
+					}},
+				},
+				name + "-1": map[string]interface{}{
+					"name": name + ".build", "displayName": "build", "type": "Pod", "phase": phase,
+					"startedAt": "2026-01-01T00:00:01Z",
+				},
+			},
+		}
+		return true, wf, nil
+	})
+	return argo
+}
+
+func (a *fakeArgo) install(t *testing.T, objs ...runtime.Object) {
+	t.Helper()
+	newClients := kube.NewClients
+	kube.NewClients = func(cluster string) (*kube.Clients, error) {
+		return &kube.Clients{Kubernetes: fake.NewSimpleClientset(objs...), Dynamic: a.dynamic}, nil
+	}
+	t.Cleanup(func() { kube.NewClients = newClients })
+}
+
+func workflowPod(workflow string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        workflow + "-build-1",
+		Namespace:   "argo",
+		Labels:      map[string]string{"workflows.argoproj.io/workflow": workflow},
+		Annotations: map[string]string{"workflows.argoproj.io/node-id": workflow + "-1"},
+	}}
+}
+
+func TestArgoWorkflowSucceeded(t *testing.T) {
+	argo := newFakeArgo(t, kube.WorkflowRunning, kube.WorkflowSucceeded)
+	argo.install(t, workflowPod("build-x7k2p"))
+	ctx := newTestContext(map[string]string{"branch": "main"})
+	params := &ArgoWorkflowParams{
+		WorkflowTemplate: "build",
+		Vars:             []string{"branch"},
+		Parameters:       map[string]string{"image": "demo"},
+		Interval:         1,
+	}
+	if err := (&ArgoWorkflow{}).Run(ctx, params); err != nil {
+		t.Fatalf("Run: %v\n%s", err, ctx.trace())
+	}
+	for key, want := range map[string]string{"workflowName": "build-x7k2p", "workflowPhase": kube.WorkflowSucceeded, "image": "demo:1.0"} {
+		if got, _ := ctx.share.Get(key); got != want {
+			t.Errorf("output %s = %q, want %q", key, got, want)
+		}
+	}
+	obj, err := argo.dynamic.Tracker().Get(kube.WorkflowResource, "argo", "build-x7k2p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameters, _, _ := unstructured.NestedSlice(obj.(*unstructured.Unstructured).Object, "spec", "arguments", "parameters")
+	if got := fmt.Sprint(parameters); got != "[map[name:branch value:main] map[name:image value:demo]]" {
+		t.Errorf("workflow parameters = %s", got)
+	}
+	trace := ctx.trace()
+	for _, want := range []string{"节点build(Pod): Running", "节点build(Pod): Succeeded", "[build] fake logs", "执行成功"} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace missing %q:\n%s", want, trace)
+		}
+	}
+}
+
+func TestArgoWorkflowFailed(t *testing.T) {
+	argo := newFakeArgo(t, kube.WorkflowFailed)
+	argo.install(t)
+	ctx := newTestContext(nil)
+	err := (&ArgoWorkflow{}).Run(ctx, &ArgoWorkflowParams{WorkflowTemplate: "build", Interval: 1})
+	if err == nil || !strings.Contains(err.Error(), "child failed") {
+		t.Fatalf("Run error = %v, want workflow failure", err)
+	}
+	if got, _ := ctx.share.Get("workflowPhase"); got != kube.WorkflowFailed {
+		t.Errorf("workflowPhase = %q, want Failed", got)
+	}
+}
+
+func TestArgoWorkflowTimeoutTerminates(t *testing.T) {
+	argo := newFakeArgo(t, kube.WorkflowRunning)
+	argo.install(t)
+	ctx := newTestContext(nil)
+	err := (&ArgoWorkflow{}).Run(ctx, &ArgoWorkflowParams{WorkflowTemplate: "build", Timeout: 1, Interval: 1})
+	if err == nil || !strings.Contains(err.Error(), "超时") {
+		t.Fatalf("Run error = %v, want timeout", err)
+	}
+	obj, err := argo.dynamic.Tracker().Get(kube.WorkflowResource, "argo", "build-x7k2p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shutdown, _, _ := unstructured.NestedString(obj.(*unstructured.Unstructured).Object, "spec", "shutdown"); shutdown != "Terminate" {
+		t.Errorf("spec.shutdown = %q, want Terminate", shutdown)
+	}
+}
+
+func TestArgoWorkflowMissingVar(t *testing.T) {
+	newFakeArgo(t, kube.WorkflowSucceeded).install(t)
+	err := (&ArgoWorkflow{}).Run(newTestContext(nil), &ArgoWorkflowParams{WorkflowTemplate: "build", Vars: []string{"branch"}})
+	if err == nil || !strings.Contains(err.Error(), "branch") {
+		t.Fatalf("Run error = %v, want missing var", err)
+	}
+}
@@ -0,0 +1,223 @@
+package action
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/attest"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/registry"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 来源证明的格式名称
+const provenanceFormat = "slsa-provenance-v1"
+
+// attest-build动作参数
+type AttestBuildParams struct {
+	flow.TaskMeta `json:",squash"`
+	Image         string                      `json:"image"`     // 镜像地址, 为空时取本流水线实例的image输出
+	Digest        string                      `json:"digest"`    // 镜像摘要, 为空时取imageDigest输出
+	Artifacts     []string                    `json:"artifacts"` // 作为构建产物的文件, 工作空间内的相对路径, 支持通配符
+	Repo          string                      `json:"repo"`      // 源码仓库地址, 为空时取流水线参数repo
+	Branch        string                      `json:"branch"`    // 分支, 为空时取流水线参数branch
+	Commit        string                      `json:"commit"`    // 提交SHA, 为空时取commit输出
+	BuilderID     string                      `json:"builderId"` // 构建者ID, 默认为cdms地址
+	Materials     []attest.ResourceDescriptor `json:"materials"` // 其他构建材料, 如基础镜像
+	Sbom          string                      `json:"sbom"`      // SBOM格式: cyclonedx/spdx, 默认cyclonedx, none时不生成
+	SbomDir       string                      `json:"sbomDir"`   // 扫描依赖清单的目录, 工作空间内的相对路径, 默认工作空间
+	Attach        bool                        `json:"attach"`    // 将来源证明和SBOM作为OCI referrer附加到镜像
+	Registry      registry.Auth               `json:"registry"`  // 镜像仓库认证信息
+}
+
+// 构建证明: 生成in-toto/SLSA v1来源证明(构建者、源码仓库和提交、流水线实例及构建材料)
+// 和工作空间依赖清单(go.mod、package-lock.json、requirements.txt)的SBOM, 保存到构建证明表, 可通过接口下载
+// 输出: provenanceDigest、sbomDigest
+type AttestBuild struct{}
+
+func (a *AttestBuild) Name() string {
+	return "attest-build"
+}
+
+func (a *AttestBuild) ParameterNew() interface{} {
+	return &AttestBuildParams{}
+}
+
+func (a *AttestBuild) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*AttestBuildParams)
+	if !ok {
+		return fmt.Errorf("attest-build参数类型错误: %T", params)
+	}
+	sbomFormat := p.Sbom
+	if sbomFormat == "" {
+		sbomFormat = attest.SbomCycloneDX
+	}
+	if sbomFormat != "none" {
+		if err := attest.ValidateSbomFormat(sbomFormat); err != nil {
+			return err
+		}
+	}
+	workspace, err := p.WorkspaceDir()
+	if err != nil {
+		return err
+	}
+	build, err := flow.BuildRecord(p.DagInsID)
+	if err != nil {
+		return err
+	}
+	outputs, _ := build["outputs"].(map[string]string)
+	vars, _ := build["vars"].(map[string]string)
+	subjects, image, err := a.subjects(ctx, p, workspace, outputs)
+	if err != nil {
+		return err
+	}
+	in := attest.ProvenanceInput{
+		BuilderID: p.BuilderID,
+		DagID:     p.DagID,
+		DagInsID:  p.DagInsID,
+		Vars:      vars,
+		Repo:      firstNonEmpty(p.Repo, vars["repo"]),
+		Branch:    firstNonEmpty(p.Branch, vars["branch"]),
+		Commit:    firstNonEmpty(p.Commit, outputs[flow.OutputCommit]),
+		Subjects:  subjects,
+		Materials: p.Materials,
+	}
+	if in.BuilderID == "" {
+		in.BuilderID = global.Conf.System.BaseApi
+	}
+	in.Trigger, _ = build["trigger"].(string)
+	if createdAt, ok := build["createdAt"].(int64); ok && createdAt > 0 {
+		in.StartedOn = time.Unix(createdAt, 0)
+	}
+	provenance, err := json.MarshalIndent(attest.NewProvenance(in), "", "  ")
+	if err != nil {
+		return err
+	}
+	digest, err := a.save(ctx, p, image, pipeline.AttestationProvenance, provenanceFormat, attest.ProvenanceMediaType, provenance)
+	if err != nil {
+		return err
+	}
+	p.SetOutput(ctx, "provenanceDigest", digest)
+	if sbomFormat == "none" {
+		return nil
+	}
+	dir, err := flow.WorkspacePath(workspace, p.SbomDir)
+	if err != nil {
+		return err
+	}
+	components, err := attest.ScanComponents(dir)
+	if err != nil {
+		return err
+	}
+	ctx.Tracef("扫描到%d个依赖组件", len(components))
+	sbom, err := attest.NewSbom(sbomFormat, attest.SbomSubject{
+		Name:      p.DagID,
+		Version:   outputs[flow.OutputVersion],
+		Namespace: global.Conf.System.BaseApi,
+	}, components)
+	if err != nil {
+		return err
+	}
+	digest, err = a.save(ctx, p, image, pipeline.AttestationSbom, sbomFormat, attest.SbomMediaType(sbomFormat), sbom)
+	if err != nil {
+		return err
+	}
+	p.SetOutput(ctx, "sbomDigest", digest)
+	return nil
+}
+
+// 构建产物: 镜像摘要和artifacts文件, 至少需要一个
+func (a *AttestBuild) subjects(ctx run.ExecuteContext, p *AttestBuildParams, workspace string, outputs map[string]string) ([]attest.Subject, *name.Digest, error) {
+	subjects := make([]attest.Subject, 0)
+	var image *name.Digest
+	if p.Image != "" || outputs[flow.OutputImage] != "" {
+		ref, err := imageDigest(ctx, p.TaskMeta, p.Image, p.Digest, p.Registry, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		image = &ref
+		subjects = append(subjects, attest.NewSubject(ref.Context().Name(), strings.TrimPrefix(ref.DigestStr(), "sha256:")))
+	}
+	paths, err := globWorkspace(workspace, p.Artifacts)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range paths {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		rel, _ := filepath.Rel(workspace, path)
+		subjects = append(subjects, attest.NewSubject(filepath.ToSlash(rel), sum))
+	}
+	if len(subjects) == 0 {
+		return nil, nil, errors.New("attest-build没有构建产物: 未设置镜像image或构建产物文件artifacts")
+	}
+	return subjects, image, nil
+}
+
+// 保存构建证明, 设置attach时作为OCI referrer附加到镜像, 返回内容的sha256摘要
+func (a *AttestBuild) save(ctx run.ExecuteContext, p *AttestBuildParams, image *name.Digest, attestationType, format, mediaType string, content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	attestation := &pipeline.PipelineAttestation{
+		DagID:     p.DagID,
+		DagInsID:  p.DagInsID,
+		Type:      attestationType,
+		Format:    format,
+		MediaType: mediaType,
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Content:   string(content),
+	}
+	if image != nil {
+		attestation.Subject = image.String()
+	}
+	if image != nil && p.Attach {
+		ref, err := registry.Attach(ctx.Context(), *image, mediaType, content, map[string]string{
+			"org.opencontainers.image.created": time.Now().UTC().Format(time.RFC3339),
+			"cdms.io/dag-ins-id":               p.DagInsID,
+		}, p.Registry)
+		if err != nil {
+			return "", err
+		}
+		attestation.Referrer = ref.String()
+		ctx.Tracef("已附加%s到镜像%s: %s", attestationType, image, ref)
+	}
+	if err := pipeline.SavePipelineAttestation(attestation); err != nil {
+		return "", fmt.Errorf("保存%s失败: %w", attestationType, err)
+	}
+	ctx.Tracef("已生成%s(%s): %s", attestationType, format, attestation.Digest)
+	return attestation.Digest, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/canary"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/kube"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 金丝雀分析结果
+const (
+	CanaryPassed = "passed"
+	CanaryFailed = "failed"
+)
+
+// 默认值
+const (
+	defaultCanaryInterval = 60 // 分析间隔(秒)
+	defaultCanaryCount    = 5  // 分析次数
+)
+
+// canary-analysis动作参数
+type CanaryAnalysisParams struct {
+	flow.TaskMeta `json:",squash"`
+	Prometheus    string            `json:"prometheus"`   // Prometheus兼容接口地址, 如http://prometheus:9090
+	Headers       map[string]string `json:"headers"`      // 请求头, 如Authorization、X-Scope-OrgID, 支持{{.vars.name.Value}}模板
+	Metrics       []canary.Metric   `json:"metrics"`      // 分析指标
+	InitialDelay  int               `json:"initialDelay"` // 首次分析前等待时间(秒), 等待金丝雀版本产生流量
+	Interval      int               `json:"interval"`     // 分析间隔(秒), 默认60
+	Count         int               `json:"count"`        // 分析次数, 默认5
+	FailureLimit  int               `json:"failureLimit"` // 允许不通过的次数, 超过时分析失败, 默认0
+	Rollback      bool              `json:"rollback"`     // 分析失败时回滚工作负载到上一个版本
+	Cluster       string            `json:"cluster"`      // 回滚的集群名称, 为空时使用applyTask部署的集群或所在集群
+	Namespace     string            `json:"namespace"`    // 未指定命名空间的工作负载使用的命名空间, 默认default
+	Workloads     []string          `json:"workloads"`    // 回滚的工作负载, 格式同rollout-verify
+	ApplyTask     string            `json:"applyTask"`    // k8s-apply任务ID, 回滚该任务部署的全部工作负载
+}
+
+// 金丝雀分析: 在金丝雀发布阶段按间隔查询Prometheus兼容接口, 将错误率、延迟等指标与阈值或基线版本比较
+// 不通过的次数超过failureLimit时任务失败, 开启rollback时回滚工作负载到上一个版本并将本次部署记录标记为已回滚
+// 输出: canaryAnalysis(passed/failed)、canaryFailures
+type CanaryAnalysis struct{}
+
+func (a *CanaryAnalysis) Name() string {
+	return "canary-analysis"
+}
+
+func (a *CanaryAnalysis) ParameterNew() interface{} {
+	return &CanaryAnalysisParams{}
+}
+
+func (a *CanaryAnalysis) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*CanaryAnalysisParams)
+	if !ok {
+		return fmt.Errorf("canary-analysis参数类型错误: %T", params)
+	}
+	if len(p.Metrics) == 0 {
+		return errors.New("canary-analysis未设置分析指标metrics")
+	}
+	for _, metric := range p.Metrics {
+		if err := metric.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Rollback && len(p.Workloads) == 0 && p.ApplyTask == "" {
+		return errors.New("开启rollback时须设置workloads或applyTask")
+	}
+	querier, err := canary.NewPrometheus(p.Prometheus, p.Headers)
+	if err != nil {
+		return err
+	}
+	failures, err := a.analyze(ctx, querier, p)
+	p.SetOutput(ctx, "canaryFailures", strconv.Itoa(failures))
+	if err != nil {
+		return err
+	}
+	if failures <= p.FailureLimit {
+		p.SetOutput(ctx, "canaryAnalysis", CanaryPassed)
+		ctx.Tracef("金丝雀分析通过, 不通过%d次, 允许%d次", failures, p.FailureLimit)
+		return nil
+	}
+	p.SetOutput(ctx, "canaryAnalysis", CanaryFailed)
+	analysisErr := fmt.Errorf("金丝雀分析不通过, 不通过%d次, 允许%d次", failures, p.FailureLimit)
+	if p.Rollback {
+		if err := a.rollback(ctx, p, analysisErr.Error()); err != nil {
+			return fmt.Errorf("%s, 回滚失败: %w", analysisErr, err)
+		}
+	}
+	return analysisErr
+}
+
+// 按间隔分析, 不通过次数超过failureLimit时提前结束, 返回不通过的次数
+func (a *CanaryAnalysis) analyze(ctx run.ExecuteContext, querier canary.Querier, p *CanaryAnalysisParams) (int, error) {
+	count := p.Count
+	if count <= 0 {
+		count = defaultCanaryCount
+	}
+	interval := secondsOrDefault(p.Interval, defaultCanaryInterval)
+	wait := time.Duration(p.InitialDelay) * time.Second
+	failures := 0
+	for i := 1; i <= count; i++ {
+		if wait > 0 {
+			select {
+			case <-ctx.Context().Done():
+				return failures, ctx.Context().Err()
+			case <-time.After(wait):
+			}
+		}
+		wait = interval
+		results := canary.Check(ctx.Context(), querier, p.Metrics)
+		lines := make([]string, 0, len(results))
+		for _, result := range results {
+			lines = append(lines, "  "+result.String())
+		}
+		result := "通过"
+		if !canary.Passed(results) {
+			result = "不通过"
+			failures++
+		}
+		ctx.Trace(fmt.Sprintf("第%d/%d次分析%s:\n%s", i, count, result, strings.Join(lines, "\n")))
+		if failures > p.FailureLimit {
+			break
+		}
+	}
+	return failures, nil
+}
+
+// 回滚工作负载, 并将本流水线实例最近一次成功的部署标记为已回滚
+func (a *CanaryAnalysis) rollback(ctx run.ExecuteContext, p *CanaryAnalysisParams, reason string) error {
+	cluster, workloads, err := resolveWorkloads(p.DagInsID, p.Cluster, p.Namespace, p.Workloads, p.ApplyTask)
+	if err != nil {
+		return err
+	}
+	if len(workloads) == 0 {
+		return errors.New("没有可回滚的工作负载")
+	}
+	clients, err := kube.NewClients(cluster)
+	if err != nil {
+		return err
+	}
+	for _, w := range workloads {
+		revision, err := clients.Undo(ctx.Context(), w)
+		if err != nil {
+			return fmt.Errorf("回滚%s失败: %w", w, err)
+		}
+		ctx.Tracef("%s已回滚到版本%d", w, revision)
+	}
+	deployment, err := pipeline.GetLastPipelineDeployment(p.DagInsID)
+	if err != nil || deployment == nil {
+		ctx.Tracef("未找到本次部署记录, 不更新部署状态")
+		return nil
+	}
+	if previous, err := pipeline.GetPreviousPipelineDeployment(deployment); err == nil && previous != nil {
+		ctx.Tracef("回滚到上一次部署: 流水线实例%s, 版本%s, 镜像%s", previous.DagInsID, previous.Version, previous.Image)
+	}
+	if err := pipeline.RollbackPipelineDeployment(deployment, reason); err != nil {
+		ctx.Tracef("更新部署记录失败: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/git"
+	"go-gin-rest-api/pkg/gitops"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 默认值
+const (
+	defaultGitOpsBranch      = "main"
+	defaultGitOpsAuthorName  = "cdms"
+	defaultGitOpsAuthorEmail = "cdms@localhost"
+	gitOpsPushRetries        = 3 // 直接推送时目标分支被抢先更新的最大尝试次数
+)
+
+// gitops-commit动作参数
+type GitOpsCommitParams struct {
+	flow.TaskMeta `json:",squash"`
+	Deploy        flow.DeployTarget `json:"deploy"`       // 部署目标, 必须设置env
+	Repo          string            `json:"repo"`         // 配置仓库地址
+	Branch        string            `json:"branch"`       // 目标分支, 默认main
+	Credential    git.Credential    `json:"credential"`   // 仓库认证信息
+	Updates       []gitops.Update   `json:"updates"`      // 配置文件更新, 支持{{.shareData.image}}等模板
+	Message       string            `json:"message"`      // 提交说明标题, 默认deploy(<应用>): <环境>
+	Author        git.Signature     `json:"author"`       // 提交作者, 默认cdms
+	MergeRequest  bool              `json:"mergeRequest"` // 推送到新分支并创建合并请求, 否则直接推送到目标分支
+	SourceBranch  string            `json:"sourceBranch"` // 合并请求的源分支, 默认cdms/<应用>-<环境>-<流水线实例ID>
+	Provider      string            `json:"provider"`     // 代码托管平台: github/gitlab/gitee, 创建合并请求时必须设置
+	ApiURL        string            `json:"apiURL"`       // 代码托管平台API地址, 为空时按仓库地址推断
+}
+
+// GitOps部署: 克隆配置仓库, 更新镜像标签或values文件, 提交后推送到目标分支或创建合并请求, 由Argo CD、Flux同步到集群
+// 提交说明包含流水线和流水线实例ID, 提交SHA和合并请求地址记录到部署记录
+// 输出: gitopsCommit、gitopsMergeRequest
+type GitOpsCommit struct{}
+
+func (a *GitOpsCommit) Name() string {
+	return "gitops-commit"
+}
+
+func (a *GitOpsCommit) ParameterNew() interface{} {
+	return &GitOpsCommitParams{}
+}
+
+func (a *GitOpsCommit) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*GitOpsCommitParams)
+	if !ok {
+		return fmt.Errorf("gitops-commit参数类型错误: %T", params)
+	}
+	if p.Repo == "" {
+		return errors.New("gitops-commit未设置配置仓库repo")
+	}
+	if len(p.Updates) == 0 {
+		return errors.New("gitops-commit未设置配置文件更新updates")
+	}
+	for _, update := range p.Updates {
+		if err := update.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.MergeRequest && p.Provider == "" {
+		return errors.New("创建合并请求须设置代码托管平台provider")
+	}
+	deployment, err := p.StartDeploy(ctx, p.Deploy)
+	if err != nil {
+		return err
+	}
+	commit, change, err := a.commit(ctx, p, deployment)
+	if err == nil {
+		p.SetOutput(ctx, "gitopsCommit", commit)
+		p.SetOutput(ctx, "gitopsMergeRequest", change)
+		if saveErr := pipeline.SetPipelineDeploymentGitOps(deployment, commit, change); saveErr != nil {
+			ctx.Tracef("更新部署记录失败: %s", saveErr)
+		}
+	}
+	flow.FinishDeploy(ctx, deployment, err)
+	return err
+}
+
+// 克隆、更新、提交并推送, 返回提交SHA和合并请求地址
+func (a *GitOpsCommit) commit(ctx run.ExecuteContext, p *GitOpsCommitParams, deployment *pipeline.PipelineDeployment) (string, string, error) {
+	branch := p.Branch
+	if branch == "" {
+		branch = defaultGitOpsBranch
+	}
+	auth, err := p.Credential.Auth()
+	if err != nil {
+		return "", "", err
+	}
+	workspace, err := p.WorkspaceDir()
+	if err != nil {
+		return "", "", err
+	}
+	// 直接推送时目标分支在克隆后有新提交会推送失败, 重新克隆最新提交并重新应用更新后重试
+	for attempt := 1; ; attempt++ {
+		sha, change, err := a.commitOnce(ctx, p, deployment, workspace, branch, auth)
+		if err == nil || p.MergeRequest || !git.IsNonFastForward(err) || attempt >= gitOpsPushRetries {
+			return sha, change, err
+		}
+		ctx.Tracef("分支%s有新的提交, 基于最新提交重新更新(第%d次重试): %s", branch, attempt, err)
+	}
+}
+
+// 在临时目录中克隆、更新、提交并推送一次
+func (a *GitOpsCommit) commitOnce(ctx run.ExecuteContext, p *GitOpsCommitParams, deployment *pipeline.PipelineDeployment, workspace, branch string, auth transport.AuthMethod) (string, string, error) {
+	dir, err := os.MkdirTemp(workspace, ".gitops-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(dir)
+	repo, err := git.Clone(ctx.Context(), p.Repo, branch, dir, 1, auth)
+	if err != nil {
+		return "", "", err
+	}
+	ctx.Tracef("已克隆配置仓库%s分支%s", p.Repo, branch)
+	changed, err := a.update(ctx, dir, p.Updates)
+	if err != nil {
+		return "", "", err
+	}
+	if changed == 0 {
+		head, err := repo.Head()
+		if err != nil {
+			return "", "", err
+		}
+		ctx.Tracef("配置没有变化, 不提交, 当前提交%s", head.Hash())
+		return head.Hash().String(), "", nil
+	}
+	author := p.Author
+	if author.Name == "" {
+		author.Name = defaultGitOpsAuthorName
+	}
+	if author.Email == "" {
+		author.Email = defaultGitOpsAuthorEmail
+	}
+	title := p.Message
+	if title == "" {
+		title = fmt.Sprintf("deploy(%s): %s", deployment.App, deployment.Env)
+		if deployment.Version != "" {
+			title += " " + deployment.Version
+		}
+	}
+	message := a.message(title, p, deployment)
+	sha, err := git.CommitAll(repo, message, author)
+	if err != nil {
+		return "", "", err
+	}
+	if !p.MergeRequest {
+		if err := git.Push(ctx.Context(), repo, branch, auth); err != nil {
+			return "", "", err
+		}
+		ctx.Tracef("已提交%s并推送到分支%s", sha, branch)
+		return sha, "", nil
+	}
+	source := p.SourceBranch
+	if source == "" {
+		source = fmt.Sprintf("cdms/%s-%s-%s", deployment.App, deployment.Env, p.DagInsID)
+	}
+	if err := git.Push(ctx.Context(), repo, source, auth); err != nil {
+		return "", "", err
+	}
+	ctx.Tracef("已提交%s并推送到分支%s", sha, source)
+	token, err := p.Credential.Token()
+	if err != nil {
+		return "", "", err
+	}
+	provider, err := git.NewProvider(p.Provider, p.Repo, p.ApiURL, token)
+	if err != nil {
+		return "", "", err
+	}
+	change, err := provider.CreateMergeRequest(ctx.Context(), git.MergeRequest{
+		Source:      source,
+		Target:      branch,
+		Title:       title,
+		Description: strings.TrimSpace(strings.TrimPrefix(message, title)),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("创建合并请求失败: %w", err)
+	}
+	ctx.Tracef("已创建合并请求: %s", change)
+	return sha, change, nil
+}
+
+// 更新配置文件, 返回修改的文件数
+func (a *GitOpsCommit) update(ctx run.ExecuteContext, dir string, updates []gitops.Update) (int, error) {
+	changed := map[string]bool{}
+	for _, update := range updates {
+		paths, err := globWorkspace(dir, []string{update.File})
+		if err != nil {
+			return 0, err
+		}
+		if len(paths) == 0 {
+			return 0, fmt.Errorf("配置仓库中没有匹配的文件: %s", update.File)
+		}
+		for _, path := range paths {
+			rel, _ := filepath.Rel(dir, path)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return 0, err
+			}
+			out, count, err := gitops.Apply(data, update)
+			if err != nil {
+				return 0, fmt.Errorf("更新%s失败: %w", rel, err)
+			}
+			if count == 0 {
+				continue
+			}
+			if err := os.WriteFile(path, out, 0o644); err != nil {
+				return 0, err
+			}
+			changed[rel] = true
+			if update.Image != "" {
+				ctx.Tracef("%s: 镜像%s更新为%s, %d处", rel, update.Image, update.Tag, count)
+			} else {
+				ctx.Tracef("%s: %s更新为%s", rel, update.Key, update.Value)
+			}
+		}
+	}
+	return len(changed), nil
+}
+
+// 提交说明, 以trailer关联流水线实例
+func (a *GitOpsCommit) message(title string, p *GitOpsCommitParams, deployment *pipeline.PipelineDeployment) string {
+	var b strings.Builder
+	b.WriteString(title)
+	b.WriteString("\n\n")
+	if deployment.Image != "" {
+		fmt.Fprintf(&b, "Image: %s\n", deployment.Image)
+	}
+	if deployment.Commit != "" {
+		fmt.Fprintf(&b, "Source-Commit: %s\n", deployment.Commit)
+	}
+	if deployment.User != "" {
+		fmt.Fprintf(&b, "Deployed-By: %s\n", deployment.User)
+	}
+	fmt.Fprintf(&b, "Cdms-Dag: %s\n", p.DagID)
+	fmt.Fprintf(&b, "Cdms-Dag-Instance: %s\n", p.DagInsID)
+	return b.String()
+}
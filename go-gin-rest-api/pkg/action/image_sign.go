@@ -0,0 +1,137 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/registry"
+	"go-gin-rest-api/pkg/signing"
+	"strconv"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// sign-image动作参数
+type SignImageParams struct {
+	flow.TaskMeta `json:",squash"`
+	Image         string            `json:"image"`       // 镜像地址, 为空时取本流水线实例的image输出
+	Digest        string            `json:"digest"`      // 镜像摘要, 为空时取imageDigest输出, 仍为空时从仓库查询标签的摘要
+	Key           string            `json:"key"`         // 私钥的密钥名称, 支持cosign generate-key-pair生成的加密私钥
+	KeyPassword   string            `json:"keyPassword"` // 私钥密码的密钥名称, 私钥未加密时为空
+	Annotations   map[string]string `json:"annotations"` // 写入签名载荷的附加信息
+	Registry      registry.Auth     `json:"registry"`    // 镜像仓库认证信息
+}
+
+// 镜像签名: 使用密钥存储中的私钥签名已推送的镜像摘要, 签名以cosign格式保存在镜像所在仓库
+// 签名载荷附加流水线和流水线实例ID, 可用cosign verify --key校验
+// 输出: imageSignature(签名标签)、imageDigest
+type SignImage struct{}
+
+func (a *SignImage) Name() string {
+	return "sign-image"
+}
+
+func (a *SignImage) ParameterNew() interface{} {
+	return &SignImageParams{}
+}
+
+func (a *SignImage) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*SignImageParams)
+	if !ok {
+		return fmt.Errorf("sign-image参数类型错误: %T", params)
+	}
+	if p.Key == "" {
+		return errors.New("sign-image未设置私钥key")
+	}
+	signer, err := signing.LoadSigner(p.Key, p.KeyPassword)
+	if err != nil {
+		return err
+	}
+	ref, err := imageDigest(ctx, p.TaskMeta, p.Image, p.Digest, p.Registry, true)
+	if err != nil {
+		return err
+	}
+	optional := map[string]interface{}{"dagId": p.DagID, "dagInsId": p.DagInsID}
+	for key, value := range p.Annotations {
+		optional[key] = value
+	}
+	tag, err := signing.Sign(ctx.Context(), ref, signer, optional, p.Registry)
+	if err != nil {
+		return err
+	}
+	ctx.Tracef("已签名镜像%s, 签名: %s", ref, tag)
+	p.SetOutput(ctx, "imageSignature", tag)
+	p.SetOutput(ctx, flow.OutputImageDigest, ref.DigestStr())
+	return nil
+}
+
+// verify-image动作参数
+type VerifyImageParams struct {
+	flow.TaskMeta `json:",squash"`
+	Image         string        `json:"image"`    // 镜像地址, 为空时取本流水线实例的image输出
+	Digest        string        `json:"digest"`   // 镜像摘要, 为空时取imageDigest输出
+	Keys          []string      `json:"keys"`     // 可信公钥的密钥名称, 任一公钥的有效签名即通过
+	Registry      registry.Auth `json:"registry"` // 镜像仓库认证信息
+}
+
+// 镜像验签: 校验镜像摘要是否有可信公钥的有效cosign签名, 没有时任务失败, 可放在部署任务之前
+// 输出: imageVerified(有效签名数)
+type VerifyImage struct{}
+
+func (a *VerifyImage) Name() string {
+	return "verify-image"
+}
+
+func (a *VerifyImage) ParameterNew() interface{} {
+	return &VerifyImageParams{}
+}
+
+func (a *VerifyImage) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*VerifyImageParams)
+	if !ok {
+		return fmt.Errorf("verify-image参数类型错误: %T", params)
+	}
+	if len(p.Keys) == 0 {
+		return errors.New("verify-image未设置可信公钥keys")
+	}
+	keys, err := signing.LoadPublicKeys(p.Keys)
+	if err != nil {
+		return err
+	}
+	ref, err := imageDigest(ctx, p.TaskMeta, p.Image, p.Digest, p.Registry, false)
+	if err != nil {
+		return err
+	}
+	verified, err := signing.Verify(ctx.Context(), ref, keys, p.Registry)
+	if err != nil {
+		return err
+	}
+	for _, result := range verified {
+		ctx.Tracef("镜像%s验签通过: 公钥%s, 签名仓库%s", ref, p.Keys[result.Key], result.Identity)
+	}
+	p.SetOutput(ctx, "imageVerified", strconv.Itoa(len(verified)))
+	return nil
+}
+
+// 确定镜像摘要引用, 未设置镜像时取本流水线实例的image和imageDigest输出; resolve为true时可从仓库查询标签的摘要
+func imageDigest(ctx run.ExecuteContext, meta flow.TaskMeta, image, digest string, auth registry.Auth, resolve bool) (name.Digest, error) {
+	if image == "" {
+		build, err := flow.BuildRecord(meta.DagInsID)
+		if err != nil {
+			return name.Digest{}, err
+		}
+		outputs, _ := build["outputs"].(map[string]string)
+		image = outputs[flow.OutputImage]
+		if digest == "" {
+			digest = outputs[flow.OutputImageDigest]
+		}
+	}
+	if image == "" {
+		return name.Digest{}, errors.New("未设置镜像image, 且流水线实例没有image输出")
+	}
+	if resolve {
+		return registry.ResolveDigest(ctx.Context(), image, digest, auth)
+	}
+	return registry.ParseDigest(image, digest, auth)
+}
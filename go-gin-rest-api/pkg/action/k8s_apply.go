@@ -0,0 +1,197 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/kube"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// 默认server-side apply字段管理者
+const defaultFieldManager = "cdms"
+
+// k8s-apply动作参数
+type K8sApplyParams struct {
+	flow.TaskMeta `json:",squash"`
+	Deploy        flow.DeployTarget `json:"deploy"`       // 部署目标, dryRun为false时必须设置env
+	Cluster       string            `json:"cluster"`      // 集群名称, 对应storage/kubeconfig下的文件名, 为空时使用所在集群
+	Namespace     string            `json:"namespace"`    // 未设置命名空间的资源使用的命名空间, 默认default
+	Files         []string          `json:"files"`        // 清单文件, 工作空间内的相对路径, 支持通配符
+	Manifest      string            `json:"manifest"`     // 内联清单, 支持{{.vars.name.Value}}、{{.shareData.name}}模板
+	FieldManager  string            `json:"fieldManager"` // server-side apply字段管理者, 默认cdms
+	ApplySet      string            `json:"applySet"`     // 应用集合名称, 写入cdms.io/apply-set标签, 默认<应用>-<环境>
+	Prune         bool              `json:"prune"`        // 删除带有相同应用集合标签但不再渲染的资源
+	PruneKinds    []string          `json:"pruneKinds"`   // 清理时额外检查的资源类型, 格式为apiVersion/kind, 如apps/v1/Deployment、v1/ConfigMap
+	Force         bool              `json:"force"`        // 与其他字段管理者冲突时强制覆盖
+	DryRun        bool              `json:"dryRun"`       // 只计算diff不执行, 用于审批前预览
+}
+
+// 将工作空间中的清单或内联清单以server-side apply方式部署到k8s集群
+// 执行前与集群中的对象比较生成diff并保存到流水线实例, 开启prune时删除不再渲染的资源
+// 输出: applyCreated/applyUpdated/applyDeleted/applyUnchanged及applyChanged
+type K8sApply struct{}
+
+func (a *K8sApply) Name() string {
+	return "k8s-apply"
+}
+
+func (a *K8sApply) ParameterNew() interface{} {
+	return &K8sApplyParams{}
+}
+
+func (a *K8sApply) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*K8sApplyParams)
+	if !ok {
+		return fmt.Errorf("k8s-apply参数类型错误: %T", params)
+	}
+	objs, err := a.manifests(p)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		return errors.New("没有需要部署的k8s资源")
+	}
+	opts, err := a.options(p)
+	if err != nil {
+		return err
+	}
+	clients, err := kube.NewClients(p.Cluster)
+	if err != nil {
+		return err
+	}
+	plan, err := clients.Plan(ctx.Context(), objs, opts)
+	if err != nil {
+		return err
+	}
+	diffs := make([]pipeline.PipelineApplyDiff, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		ctx.Tracef("[%s] %s %s/%s", change.Action, change.Kind, change.Namespace, change.Name)
+		if change.Error != "" {
+			ctx.Tracef("%s %s/%s dry-run失败, 按渲染结果比较: %s", change.Kind, change.Namespace, change.Name, change.Error)
+		}
+		diffs = append(diffs, pipeline.PipelineApplyDiff{
+			DagID:      p.DagID,
+			DagInsID:   p.DagInsID,
+			TaskID:     p.TaskID,
+			Cluster:    p.Cluster,
+			ApiVersion: change.APIVersion,
+			Kind:       change.Kind,
+			Namespace:  change.Namespace,
+			Name:       change.Name,
+			Action:     change.Action,
+			Diff:       change.Diff,
+			Error:      change.Error,
+		})
+	}
+	if err := pipeline.SavePipelineApplyDiffs(p.DagInsID, p.TaskID, diffs); err != nil {
+		return fmt.Errorf("保存资源变更失败: %w", err)
+	}
+	p.SetOutput(ctx, "applyCreated", strconv.Itoa(plan.Count(kube.ActionCreate)))
+	p.SetOutput(ctx, "applyUpdated", strconv.Itoa(plan.Count(kube.ActionUpdate)))
+	p.SetOutput(ctx, "applyDeleted", strconv.Itoa(plan.Count(kube.ActionDelete)))
+	p.SetOutput(ctx, "applyUnchanged", strconv.Itoa(plan.Count(kube.ActionUnchanged)))
+	p.SetOutput(ctx, "applyChanged", strconv.FormatBool(plan.Changed()))
+	ctx.Tracef("新建%d, 更新%d, 删除%d, 未变更%d", plan.Count(kube.ActionCreate), plan.Count(kube.ActionUpdate), plan.Count(kube.ActionDelete), plan.Count(kube.ActionUnchanged))
+	if p.DryRun {
+		ctx.Tracef("dry-run模式, 不执行变更")
+		return nil
+	}
+
+	deployment, err := p.StartDeploy(ctx, p.Deploy)
+	if err != nil {
+		return err
+	}
+	err = clients.Apply(ctx.Context(), plan, opts, ctx.Tracef)
+	if err == nil {
+		if markErr := pipeline.MarkPipelineApplyDiffsApplied(p.DagInsID, p.TaskID); markErr != nil {
+			ctx.Tracef("更新资源变更状态失败: %s", markErr)
+		}
+	}
+	flow.FinishDeploy(ctx, deployment, err)
+	return err
+}
+
+// 读取清单文件和内联清单
+func (a *K8sApply) manifests(p *K8sApplyParams) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0)
+	if len(p.Files) > 0 {
+		workspace, err := p.WorkspaceDir()
+		if err != nil {
+			return nil, err
+		}
+		paths, err := globWorkspace(workspace, p.Files)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("没有匹配的清单文件: %s", strings.Join(p.Files, ","))
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			fileObjs, err := kube.DecodeManifests(data)
+			if err != nil {
+				return nil, fmt.Errorf("清单文件%s: %w", strings.TrimPrefix(path, workspace+string(os.PathSeparator)), err)
+			}
+			objs = append(objs, fileObjs...)
+		}
+	}
+	if strings.TrimSpace(p.Manifest) != "" {
+		inlineObjs, err := kube.DecodeManifests([]byte(p.Manifest))
+		if err != nil {
+			return nil, fmt.Errorf("内联清单: %w", err)
+		}
+		objs = append(objs, inlineObjs...)
+	}
+	return objs, nil
+}
+
+// 生成apply参数
+func (a *K8sApply) options(p *K8sApplyParams) (kube.ApplyOptions, error) {
+	opts := kube.ApplyOptions{
+		FieldManager: p.FieldManager,
+		Namespace:    p.Namespace,
+		ApplySet:     p.ApplySet,
+		Prune:        p.Prune,
+		Force:        p.Force,
+	}
+	if opts.FieldManager == "" {
+		opts.FieldManager = defaultFieldManager
+	}
+	if opts.ApplySet == "" {
+		app := p.Deploy.App
+		if app == "" {
+			app = p.DagID
+		}
+		opts.ApplySet = app
+		if p.Deploy.Env != "" {
+			opts.ApplySet += "-" + p.Deploy.Env
+		}
+	}
+	if errs := validation.IsValidLabelValue(opts.ApplySet); len(errs) > 0 {
+		return opts, fmt.Errorf("非法的应用集合名称%s: %s", opts.ApplySet, strings.Join(errs, "; "))
+	}
+	for _, kind := range p.PruneKinds {
+		i := strings.LastIndex(kind, "/")
+		if i <= 0 || i == len(kind)-1 {
+			return opts, fmt.Errorf("非法的清理资源类型%s, 格式为apiVersion/kind", kind)
+		}
+		gv, err := schema.ParseGroupVersion(kind[:i])
+		if err != nil {
+			return opts, fmt.Errorf("非法的清理资源类型%s: %w", kind, err)
+		}
+		opts.PruneKinds = append(opts.PruneKinds, gv.WithKind(kind[i+1:]))
+	}
+	return opts, nil
+}
@@ -0,0 +1,30 @@
+//go:build unix
+// +build unix
+
+package action
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// 子进程使用独立进程组, 取消时结束整个进程组
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// 资源限制脚本, 通过ulimit限制CPU时间(秒)和虚拟内存(MB)
+func limitScript(cpuSeconds, memoryMB int) (string, error) {
+	script := ""
+	if cpuSeconds > 0 {
+		script += fmt.Sprintf("ulimit -t %d || exit 126\n", cpuSeconds)
+	}
+	if memoryMB > 0 {
+		script += fmt.Sprintf("ulimit -v %d || exit 126\n", memoryMB*1024)
+	}
+	return script, nil
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package action
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// windows不支持进程组, 取消时只结束脚本进程
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// windows不支持资源限制
+func limitScript(cpuSeconds, memoryMB int) (string, error) {
+	if cpuSeconds > 0 || memoryMB > 0 {
+		return "", errors.New("windows不支持CPU和内存限制")
+	}
+	return "", nil
+}
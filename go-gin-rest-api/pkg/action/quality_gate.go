@@ -0,0 +1,190 @@
+package action
+
+import (
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/report"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 默认回归比较的基线分支
+const defaultBaseBranch = "main"
+
+// quality-gate动作参数
+type QualityGateParams struct {
+	flow.TaskMeta `json:",squash"`
+	App           string               `json:"app"`        // 应用名称, 用于匹配质量门禁策略, 为空时使用流水线ID
+	Branch        string               `json:"branch"`     // 当前分支, 为空时取流水线参数branch
+	BaseBranch    string               `json:"baseBranch"` // 回归比较的基线分支, 默认main
+	Coverage      []string             `json:"coverage"`   // 覆盖率文件, 工作空间内的相对路径, 支持通配符, .xml为Cobertura, 其他为go coverprofile
+	Lint          []string             `json:"lint"`       // 代码检查结果文件, 支持通配符, .xml为checkstyle, 其他为SARIF
+	Rules         []report.QualityRule `json:"rules"`      // 规则, 为空时使用应用的质量门禁策略
+}
+
+// 质量门禁: 汇总本次运行的测试结果、覆盖率和代码检查结果, 按策略检查, 不满足时任务失败并在trace中列出明细
+// 输出: qualityGate(passed/failed)及采集到的各项指标
+type QualityGate struct{}
+
+func (a *QualityGate) Name() string {
+	return "quality-gate"
+}
+
+func (a *QualityGate) ParameterNew() interface{} {
+	return &QualityGateParams{}
+}
+
+func (a *QualityGate) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*QualityGateParams)
+	if !ok {
+		return fmt.Errorf("quality-gate参数类型错误: %T", params)
+	}
+	app := p.App
+	if app == "" {
+		app = p.DagID
+	}
+	branch := p.Branch
+	if branch == "" {
+		branch, _ = ctx.GetVar("branch")
+	}
+	baseBranch := p.BaseBranch
+	if baseBranch == "" {
+		baseBranch = defaultBaseBranch
+	}
+	rules := p.Rules
+	if len(rules) == 0 {
+		var err error
+		if rules, err = pipeline.GetPipelineQualityRules(app); err != nil {
+			return err
+		}
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	metrics, err := a.collect(p)
+	if err != nil {
+		return err
+	}
+	// 基线分支自身运行时不做回归比较
+	baseline := map[string]float64{}
+	if branch != baseBranch {
+		if baseline, err = pipeline.GetPipelineQualityBaseline(app, baseBranch, p.DagInsID); err != nil {
+			return fmt.Errorf("获取基线分支%s的质量指标失败: %w", baseBranch, err)
+		}
+	}
+	for name, value := range metrics {
+		p.SetOutput(ctx, name, strconv.FormatFloat(value, 'f', 2, 64))
+	}
+	results := report.EvaluateQuality(rules, metrics, baseline)
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	// 门禁结果随指标保存, 未通过的运行不作为基线
+	if err := pipeline.SavePipelineQualityMetrics(p.DagID, p.DagInsID, app, branch, metrics, failed == 0); err != nil {
+		return fmt.Errorf("保存质量指标失败: %w", err)
+	}
+	breakdown := report.FormatQualityResults(results)
+	if failed > 0 {
+		p.SetOutput(ctx, "qualityGate", "failed")
+		ctx.Trace(fmt.Sprintf("质量门禁未通过(应用%s, 分支%s, 基线%s):\n%s", app, branch, baseBranch, breakdown))
+		return fmt.Errorf("质量门禁未通过, %d条规则不满足", failed)
+	}
+	p.SetOutput(ctx, "qualityGate", "passed")
+	ctx.Trace(fmt.Sprintf("质量门禁通过(应用%s, 分支%s, 基线%s):\n%s", app, branch, baseBranch, breakdown))
+	return nil
+}
+
+// 采集指标: 测试结果取本次运行test-report保存的用例, 覆盖率和代码检查结果解析工作空间中的文件
+func (a *QualityGate) collect(p *QualityGateParams) (map[string]float64, error) {
+	metrics := map[string]float64{}
+	testReport, err := pipeline.GetPipelineTestReport(p.DagID, p.DagInsID)
+	if err != nil {
+		return nil, fmt.Errorf("统计测试结果失败: %w", err)
+	}
+	if testReport.Summary.Total > 0 {
+		metrics[report.MetricTestPassRate] = testReport.Summary.PassRate
+		metrics[report.MetricTestFailed] = float64(testReport.Summary.Failed)
+	}
+	if len(p.Coverage) == 0 && len(p.Lint) == 0 {
+		return metrics, nil
+	}
+	workspace, err := p.WorkspaceDir()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Coverage) > 0 {
+		paths, err := globWorkspace(workspace, p.Coverage)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("没有找到覆盖率文件: %s", strings.Join(p.Coverage, ","))
+		}
+		var coverage report.Coverage
+		for _, path := range paths {
+			c, err := parseCoverageFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("解析覆盖率文件%s失败: %w", filepath.Base(path), err)
+			}
+			coverage.Add(c)
+		}
+		metrics[report.MetricCoverage] = coverage.Percent()
+	}
+	if len(p.Lint) > 0 {
+		paths, err := globWorkspace(workspace, p.Lint)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("没有找到代码检查结果文件: %s", strings.Join(p.Lint, ","))
+		}
+		var lint report.LintResult
+		for _, path := range paths {
+			l, err := parseLintFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("解析代码检查结果文件%s失败: %w", filepath.Base(path), err)
+			}
+			lint.Add(l)
+		}
+		metrics[report.MetricLintErrors] = float64(lint.Errors)
+		metrics[report.MetricLintWarnings] = float64(lint.Warnings)
+		metrics[report.MetricLintIssues] = float64(lint.Issues())
+	}
+	return metrics, nil
+}
+
+// 解析覆盖率文件, .xml为Cobertura, 其他为go coverprofile
+func parseCoverageFile(path string) (report.Coverage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return report.Coverage{}, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".xml") {
+		return report.ParseCobertura(f)
+	}
+	return report.ParseGoCoverprofile(f)
+}
+
+// 解析代码检查结果文件, .xml为checkstyle, 其他为SARIF
+func parseLintFile(path string) (report.LintResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return report.LintResult{}, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".xml") {
+		return report.ParseCheckstyle(f)
+	}
+	return report.ParseSARIF(f)
+}
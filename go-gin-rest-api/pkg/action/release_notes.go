@@ -0,0 +1,124 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/git"
+	"go-gin-rest-api/pkg/release"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 输出值的最大长度, 与任务输出表Value字段的text类型一致, 超出部分截断, 部署记录中保存完整内容
+const maxOutputSize = 65535
+
+// release-notes动作参数
+type ReleaseNotesParams struct {
+	flow.TaskMeta `json:",squash"`
+	Repo          string         `json:"repo"`       // 代码仓库地址, 为空时取流水线参数repo
+	Credential    git.Credential `json:"credential"` // 仓库认证信息
+	Env           string         `json:"env"`        // 目标环境, 未设置from时取该环境和应用最近一次成功部署的提交
+	App           string         `json:"app"`        // 应用名称, 为空时使用流水线ID
+	From          string         `json:"from"`       // 起始提交(不含), 分支、标签或SHA
+	To            string         `json:"to"`         // 结束提交, 为空时取本流水线实例的commit输出或参数
+	Format        string         `json:"format"`     // 格式: markdown/json, 默认markdown
+}
+
+// 发布说明: 汇总上一次部署到本次构建之间的提交, 按约定式提交类型和需求编号分组,
+// 本流水线实例已有部署记录时写入部署记录, 否则由之后的部署任务从releaseNotes输出写入;
+// 本服务没有通知渠道, 发布说明不会自动发送通知, 需要通知时由调用系统通过流水线输出接口读取releaseNotes输出自行发送
+// 输出: releaseNotes、releaseCommits
+type ReleaseNotes struct{}
+
+func (a *ReleaseNotes) Name() string {
+	return "release-notes"
+}
+
+func (a *ReleaseNotes) ParameterNew() interface{} {
+	return &ReleaseNotesParams{}
+}
+
+func (a *ReleaseNotes) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*ReleaseNotesParams)
+	if !ok {
+		return fmt.Errorf("release-notes参数类型错误: %T", params)
+	}
+	format := firstNonEmpty(p.Format, release.FormatMarkdown)
+	if err := release.ValidateFormat(format); err != nil {
+		return err
+	}
+	repo := p.Repo
+	if repo == "" {
+		repo, _ = ctx.GetVar("repo")
+	}
+	if repo == "" {
+		return errors.New("release-notes未设置代码仓库repo")
+	}
+	from, to, err := a.commitRange(p)
+	if err != nil {
+		return err
+	}
+	notes, err := release.FromRepo(ctx.Context(), repo, p.Credential, from, to)
+	if err != nil {
+		return err
+	}
+	content, err := notes.Render(format)
+	if err != nil {
+		return err
+	}
+	ctx.Tracef("%s..%s共%d个提交", firstNonEmpty(from, "最早的提交"), to, notes.Total)
+	ctx.Trace(notes.Markdown())
+	p.SetOutput(ctx, flow.OutputReleaseNotes, truncateUTF8(content, maxOutputSize))
+	p.SetOutput(ctx, "releaseCommits", strconv.Itoa(notes.Total))
+	deployment, err := pipeline.GetLastPipelineDeployment(p.DagInsID)
+	if err != nil {
+		return err
+	}
+	if deployment != nil {
+		if err := pipeline.SetPipelineDeploymentReleaseNotes(deployment, content); err != nil {
+			return fmt.Errorf("更新部署记录失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// 提交范围: to默认为本次构建的提交, from默认为目标环境和应用最近一次成功部署的提交
+func (a *ReleaseNotes) commitRange(p *ReleaseNotesParams) (string, string, error) {
+	from, to := p.From, p.To
+	if to == "" {
+		build, err := flow.BuildRecord(p.DagInsID)
+		if err != nil {
+			return "", "", err
+		}
+		outputs, _ := build["outputs"].(map[string]string)
+		vars, _ := build["vars"].(map[string]string)
+		if to = firstNonEmpty(outputs[flow.OutputCommit], vars[flow.OutputCommit]); to == "" {
+			return "", "", errors.New("release-notes未设置结束提交to, 且流水线实例没有commit输出")
+		}
+	}
+	if from == "" && p.Env != "" {
+		deployment, err := pipeline.GetLatestPipelineDeployment(p.Env, firstNonEmpty(p.App, p.DagID))
+		if err != nil {
+			return "", "", err
+		}
+		if deployment != nil {
+			from = deployment.Commit
+		}
+	}
+	return from, to, nil
+}
+
+// 按字节截断, 不截断多字节字符
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
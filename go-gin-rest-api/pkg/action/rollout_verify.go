@@ -0,0 +1,228 @@
+package action
+
+import (
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/kube"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 发布状态
+const (
+	RolloutComplete = "complete" // 全部工作负载发布完成
+	RolloutDegraded = "degraded" // 存在不会自愈的异常Pod
+	RolloutStalled  = "stalled"  // 发布停滞, 超过progressDeadlineSeconds或长时间没有进展
+	RolloutTimeout  = "timeout"  // 超过总超时时间
+)
+
+// 默认值
+const (
+	defaultRolloutTimeout         = 600 // 总超时时间(秒)
+	defaultRolloutProgressTimeout = 180 // 没有进展的超时时间(秒)
+	defaultRolloutInterval        = 5   // 检查间隔(秒)
+	rolloutMaxEvents              = 5   // 每个Pod输出的最近事件数
+)
+
+// rollout-verify动作参数
+type RolloutVerifyParams struct {
+	flow.TaskMeta   `json:",squash"`
+	Cluster         string   `json:"cluster"`         // 集群名称, 为空时使用applyTask部署的集群或所在集群
+	Namespace       string   `json:"namespace"`       // 未指定命名空间的工作负载使用的命名空间, 默认default
+	Workloads       []string `json:"workloads"`       // 工作负载, 格式为Kind/name或Kind/namespace/name, Kind支持Deployment、StatefulSet、CloneSet、AdvancedStatefulSet
+	ApplyTask       string   `json:"applyTask"`       // k8s-apply任务ID, 校验该任务部署的全部工作负载
+	Timeout         int      `json:"timeout"`         // 总超时时间(秒), 默认600
+	ProgressTimeout int      `json:"progressTimeout"` // 没有进展的超时时间(秒), 默认180
+	Interval        int      `json:"interval"`        // 检查间隔(秒), 默认5
+	IgnoreDegraded  bool     `json:"ignoreDegraded"`  // 出现CrashLoopBackOff、镜像拉取失败等异常时继续等待, 不立即失败
+}
+
+// 校验k8s工作负载的发布状态, 等待全部工作负载发布完成
+// 发布停滞、超时或出现不会自愈的异常Pod时任务失败, 并在trace中输出异常Pod的容器状态、终止原因和最近事件
+// 输出: rolloutStatus(complete/degraded/stalled/timeout)
+type RolloutVerify struct{}
+
+func (a *RolloutVerify) Name() string {
+	return "rollout-verify"
+}
+
+func (a *RolloutVerify) ParameterNew() interface{} {
+	return &RolloutVerifyParams{}
+}
+
+func (a *RolloutVerify) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*RolloutVerifyParams)
+	if !ok {
+		return fmt.Errorf("rollout-verify参数类型错误: %T", params)
+	}
+	cluster, workloads, err := resolveWorkloads(p.DagInsID, p.Cluster, p.Namespace, p.Workloads, p.ApplyTask)
+	if err != nil {
+		return err
+	}
+	if len(workloads) == 0 {
+		ctx.Tracef("没有需要校验的工作负载")
+		p.SetOutput(ctx, "rolloutStatus", RolloutComplete)
+		return nil
+	}
+	clients, err := kube.NewClients(cluster)
+	if err != nil {
+		return err
+	}
+	status, err := a.verify(ctx, clients, workloads, p)
+	p.SetOutput(ctx, "rolloutStatus", status)
+	return err
+}
+
+// 轮询工作负载状态直到全部完成或失败
+func (a *RolloutVerify) verify(ctx run.ExecuteContext, clients *kube.Clients, workloads []kube.Workload, p *RolloutVerifyParams) (string, error) {
+	timeout := secondsOrDefault(p.Timeout, defaultRolloutTimeout)
+	progressTimeout := secondsOrDefault(p.ProgressTimeout, defaultRolloutProgressTimeout)
+	interval := secondsOrDefault(p.Interval, defaultRolloutInterval)
+	deadline := time.Now().Add(timeout)
+	// 各工作负载最近一次的进度和进度变化时间
+	progress := map[kube.Workload]string{}
+	progressAt := map[kube.Workload]time.Time{}
+	// 已输出的状态说明, 状态变化时才写trace
+	messages := map[kube.Workload]string{}
+	done := map[kube.Workload]bool{}
+	for {
+		pending := make([]kube.Workload, 0)
+		for _, w := range workloads {
+			if done[w] {
+				continue
+			}
+			status, err := clients.RolloutStatus(ctx.Context(), w)
+			if err != nil {
+				return RolloutStalled, fmt.Errorf("获取%s状态失败: %w", w, err)
+			}
+			if status.Message != messages[w] {
+				messages[w] = status.Message
+				ctx.Tracef("%s: %s(期望%d, 已更新%d, 就绪%d)", w, status.Message, status.Replicas, status.Updated, status.Ready)
+			}
+			if status.Done {
+				done[w] = true
+				continue
+			}
+			if status.Progress() != progress[w] {
+				progress[w] = status.Progress()
+				progressAt[w] = time.Now()
+			}
+			pods, err := clients.UnhealthyPods(ctx.Context(), w.Namespace, status.Selector, rolloutMaxEvents)
+			if err != nil {
+				ctx.Tracef("查询%s的Pod失败: %s", w, err)
+			}
+			degraded := false
+			for _, pod := range pods {
+				degraded = degraded || pod.Degraded
+			}
+			switch {
+			case status.Stalled:
+				a.tracePods(ctx, w, pods)
+				return RolloutStalled, fmt.Errorf("%s发布停滞: %s", w, status.Message)
+			case degraded && !p.IgnoreDegraded:
+				a.tracePods(ctx, w, pods)
+				return RolloutDegraded, fmt.Errorf("%s存在异常Pod, 发布失败", w)
+			case time.Since(progressAt[w]) > progressTimeout:
+				a.tracePods(ctx, w, pods)
+				return RolloutStalled, fmt.Errorf("%s超过%s没有进展: %s", w, progressTimeout, status.Message)
+			}
+			pending = append(pending, w)
+		}
+		if len(pending) == 0 {
+			ctx.Tracef("全部%d个工作负载发布完成", len(workloads))
+			return RolloutComplete, nil
+		}
+		if time.Now().After(deadline) {
+			for _, w := range pending {
+				if status, err := clients.RolloutStatus(ctx.Context(), w); err == nil {
+					pods, _ := clients.UnhealthyPods(ctx.Context(), w.Namespace, status.Selector, rolloutMaxEvents)
+					a.tracePods(ctx, w, pods)
+				}
+			}
+			return RolloutTimeout, fmt.Errorf("等待发布超时(%s), 未完成: %s", timeout, workloadNames(pending))
+		}
+		select {
+		case <-ctx.Context().Done():
+			return RolloutTimeout, ctx.Context().Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// 输出异常Pod的容器状态和最近事件
+func (a *RolloutVerify) tracePods(ctx run.ExecuteContext, w kube.Workload, pods []kube.UnhealthyPod) {
+	if len(pods) == 0 {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s的异常Pod:\n", w)
+	for _, pod := range pods {
+		fmt.Fprintf(&b, "  %s(%s)\n", pod.Name, pod.Phase)
+		for _, container := range pod.Containers {
+			fmt.Fprintf(&b, "    %s\n", container)
+		}
+		for _, event := range pod.Events {
+			fmt.Fprintf(&b, "    事件 %s\n", event)
+		}
+	}
+	ctx.Trace(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// 解析工作负载, 合并workloads参数和applyTask部署的资源, 集群为空时使用applyTask部署的集群
+func resolveWorkloads(dagInsId, cluster, namespace string, names []string, applyTask string) (string, []kube.Workload, error) {
+	workloads := make([]kube.Workload, 0, len(names))
+	seen := map[kube.Workload]bool{}
+	for _, name := range names {
+		w, err := kube.ParseWorkload(name, namespace)
+		if err != nil {
+			return "", nil, err
+		}
+		if !seen[w] {
+			seen[w] = true
+			workloads = append(workloads, w)
+		}
+	}
+	if applyTask == "" {
+		return cluster, workloads, nil
+	}
+	resources, err := pipeline.GetPipelineApplyResources(dagInsId, applyTask)
+	if err != nil {
+		return "", nil, fmt.Errorf("获取任务%s部署的资源失败: %w", applyTask, err)
+	}
+	if len(resources) == 0 {
+		return "", nil, fmt.Errorf("任务%s没有部署记录", applyTask)
+	}
+	for _, resource := range resources {
+		if cluster == "" {
+			cluster = resource.Cluster
+		}
+		kind := kube.WorkloadKind(resource.ApiVersion, resource.Kind)
+		if kind == "" {
+			continue
+		}
+		w := kube.Workload{Kind: kind, Namespace: resource.Namespace, Name: resource.Name}
+		if !seen[w] {
+			seen[w] = true
+			workloads = append(workloads, w)
+		}
+	}
+	return cluster, workloads, nil
+}
+
+func workloadNames(workloads []kube.Workload) string {
+	names := make([]string, 0, len(workloads))
+	for _, w := range workloads {
+		names = append(names, w.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+func secondsOrDefault(seconds, def int) time.Duration {
+	if seconds <= 0 {
+		seconds = def
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,255 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/secret"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+const (
+	// 默认解释器
+	defaultShell = "/bin/sh"
+	// 输出文件最大读取字节数
+	outputFileMaxBytes = 64 * 1024
+)
+
+// 环境变量名
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shell动作参数
+type ShellParams struct {
+	flow.TaskMeta `json:",squash"`
+	Script        string              `json:"script"`      // 脚本内容
+	Shell         string              `json:"shell"`       // 解释器, 默认/bin/sh, 以"-c"方式执行脚本
+	Dir           string              `json:"dir"`         // 执行目录, 工作空间内的相对路径
+	Env           map[string]string   `json:"env"`         // 环境变量
+	Secrets       map[string]string   `json:"secrets"`     // 从密钥注入的环境变量, 键为环境变量名, 值为密钥名称
+	Timeout       int                 `json:"timeout"`     // 超时时间, 秒, 为0时使用任务超时时间
+	CpuSeconds    int                 `json:"cpuSeconds"`  // CPU时间限制, 秒
+	MemoryMB      int                 `json:"memoryMB"`    // 内存限制, MB
+	OutputFiles   map[string]string   `json:"outputFiles"` // 输出文件, 键为输出名称, 值为工作空间内的相对路径, 文件内容作为任务输出
+	Caches        map[string][]string `json:"caches"`      // 依赖缓存, 键为缓存名称, 值为计算缓存键的锁文件(工作空间内的相对路径), 如{"gomod":["go.sum"]}
+	BuildCaches   map[string]string   `json:"buildCaches"` // 构建缓存, 键为缓存类型buildkit/kaniko, 值为命名空间(为空时使用流水线ID), 目录通过CDMS_BUILDKIT_CACHE/CDMS_KANIKO_CACHE传入
+	Deploy        *flow.DeployTarget  `json:"deploy"`      // 部署目标, 设置时作为部署任务, 执行前检查冻结窗口和部署准入策略并记录部署
+}
+
+// 在流水线实例工作空间中执行shell脚本
+// 环境变量只包含PATH、流水线参数、依赖缓存和构建缓存目录、env和secrets, 不继承服务进程的环境变量
+// 输出: exitCode及outputFiles声明的文件内容
+type Shell struct{}
+
+func (s *Shell) Name() string {
+	return "shell"
+}
+
+func (s *Shell) ParameterNew() interface{} {
+	return &ShellParams{}
+}
+
+func (s *Shell) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*ShellParams)
+	if !ok {
+		return fmt.Errorf("shell参数类型错误: %T", params)
+	}
+	if strings.TrimSpace(p.Script) == "" {
+		return errors.New("script不能为空")
+	}
+	if p.Deploy == nil {
+		return s.run(ctx, p)
+	}
+	deployment, err := p.StartDeploy(ctx, *p.Deploy)
+	if err != nil {
+		return err
+	}
+	err = s.run(ctx, p)
+	flow.FinishDeploy(ctx, deployment, err)
+	return err
+}
+
+// 在工作空间中执行脚本
+func (s *Shell) run(ctx run.ExecuteContext, p *ShellParams) error {
+	workspace, err := p.WorkspaceDir()
+	if err != nil {
+		return err
+	}
+	dir, err := flow.WorkspacePath(workspace, p.Dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("创建执行目录失败: %w", err)
+	}
+	caches := make([]*flow.Cache, 0, len(p.Caches))
+	defer func() {
+		for _, cache := range caches {
+			cache.Release()
+		}
+	}()
+	for name, lockFiles := range p.Caches {
+		cache, err := flow.AcquireCache(workspace, name, lockFiles)
+		if err != nil {
+			return err
+		}
+		caches = append(caches, cache)
+		if cache.Hit {
+			ctx.Tracef("依赖缓存%s命中: %s", name, cache.Key)
+		} else {
+			ctx.Tracef("依赖缓存%s未命中, 新建: %s", name, cache.Key)
+		}
+	}
+	buildCaches := make([]*flow.BuildCache, 0, len(p.BuildCaches))
+	defer func() {
+		for _, cache := range buildCaches {
+			cache.Release()
+		}
+	}()
+	for cacheType, namespace := range p.BuildCaches {
+		cache, err := flow.AcquireBuildCache(cacheType, firstNonEmpty(namespace, p.DagID))
+		if err != nil {
+			return err
+		}
+		buildCaches = append(buildCaches, cache)
+		ctx.Tracef("构建缓存%s/%s: %s", cache.Type, cache.Namespace, cache.Dir)
+	}
+	env, masks, err := shellEnv(ctx, p, workspace, caches, buildCaches)
+	if err != nil {
+		return err
+	}
+	limit, err := limitScript(p.CpuSeconds, p.MemoryMB)
+	if err != nil {
+		return err
+	}
+	shell := p.Shell
+	if shell == "" {
+		shell = defaultShell
+	}
+
+	runCtx := ctx.Context()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, time.Duration(p.Timeout)*time.Second)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(runCtx, shell, "-c", limit+p.Script)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.WaitDelay = 5 * time.Second
+	setProcessGroup(cmd)
+	// 通过io.Pipe读取输出, Wait等待输出复制结束(最多WaitDelay)后再关闭写端, 读取不会丢失输出
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动脚本失败: %w", err)
+	}
+	tw := newTraceWriter(ctx, masks)
+	tw.Stream(stdout, "")
+	tw.Stream(stderr, "[stderr] ")
+	runErr := cmd.Wait()
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	tw.Close()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	p.SetOutput(ctx, "exitCode", strconv.Itoa(exitCode))
+	// 脚本失败时也记录输出文件, 便于排查(如测试报告)
+	if err := s.setOutputFiles(ctx, p, workspace); err != nil && runErr == nil {
+		runErr = err
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("脚本执行超时, 已结束进程组")
+	}
+	if runErr != nil {
+		return fmt.Errorf("脚本执行失败, 退出码%d: %w", exitCode, runErr)
+	}
+	return nil
+}
+
+// 构建脚本环境变量, 同时返回需要在输出中屏蔽的密钥值
+func shellEnv(ctx run.ExecuteContext, p *ShellParams, workspace string, caches []*flow.Cache, buildCaches []*flow.BuildCache) ([]string, []string, error) {
+	envs := map[string]string{
+		"PATH": os.Getenv("PATH"),
+		"HOME": workspace,
+	}
+	ctx.IterateVars(func(key, val string) bool {
+		if envNameRegexp.MatchString(key) {
+			envs[key] = val
+		}
+		return false
+	})
+	envs["CDMS_DAG_ID"] = p.DagID
+	envs["CDMS_DAG_INS_ID"] = p.DagInsID
+	envs["CDMS_TASK_ID"] = p.TaskID
+	envs["CDMS_WORKSPACE"] = workspace
+	for _, cache := range caches {
+		for key, val := range cache.Env() {
+			envs[key] = val
+		}
+	}
+	for _, cache := range buildCaches {
+		for key, val := range cache.Env() {
+			envs[key] = val
+		}
+	}
+	for key, val := range p.Env {
+		if !envNameRegexp.MatchString(key) {
+			return nil, nil, fmt.Errorf("非法的环境变量名: %s", key)
+		}
+		envs[key] = val
+	}
+	masks := make([]string, 0, len(p.Secrets))
+	for key, name := range p.Secrets {
+		if !envNameRegexp.MatchString(key) {
+			return nil, nil, fmt.Errorf("非法的环境变量名: %s", key)
+		}
+		val, err := secret.Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		envs[key] = val
+		masks = append(masks, val)
+	}
+	env := make([]string, 0, len(envs))
+	for key, val := range envs {
+		env = append(env, key+"="+val)
+	}
+	sort.Strings(env)
+	return env, masks, nil
+}
+
+// 读取输出文件写入任务输出
+func (s *Shell) setOutputFiles(ctx run.ExecuteContext, p *ShellParams, workspace string) error {
+	for name, rel := range p.OutputFiles {
+		path, err := flow.WorkspacePath(workspace, rel)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("读取输出文件%s失败: %w", rel, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(f, outputFileMaxBytes))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("读取输出文件%s失败: %w", rel, err)
+		}
+		p.SetOutput(ctx, name, strings.TrimRight(string(data), "\r\n"))
+	}
+	return nil
+}
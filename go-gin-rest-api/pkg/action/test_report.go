@@ -0,0 +1,158 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/report"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// trace中最多列出的失败用例数
+const traceMaxFailures = 20
+
+// test-report动作参数
+type TestReportParams struct {
+	flow.TaskMeta `json:",squash"`
+	Files         []string `json:"files"`         // 报告文件, 工作空间内的相对路径, 支持通配符
+	Format        string   `json:"format"`        // 报告格式: junit/gotest, 为空时按扩展名判断, .xml为junit, 其他为gotest(go test -json输出)
+	FailOnFailure bool     `json:"failOnFailure"` // 有失败用例时任务失败
+}
+
+// 解析工作空间中的测试报告, 用例结果关联到流水线实例保存
+// 输出: testTotal、testPassed、testFailed、testSkipped、testPassRate
+type TestReport struct{}
+
+func (a *TestReport) Name() string {
+	return "test-report"
+}
+
+func (a *TestReport) ParameterNew() interface{} {
+	return &TestReportParams{}
+}
+
+func (a *TestReport) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*TestReportParams)
+	if !ok {
+		return fmt.Errorf("test-report参数类型错误: %T", params)
+	}
+	if len(p.Files) == 0 {
+		return errors.New("files不能为空")
+	}
+	workspace, err := p.WorkspaceDir()
+	if err != nil {
+		return err
+	}
+	paths, err := globWorkspace(workspace, p.Files)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("没有找到测试报告: %s", strings.Join(p.Files, ","))
+	}
+	cases := make([]report.TestCase, 0)
+	for _, path := range paths {
+		list, err := parseTestReport(path, p.Format)
+		if err != nil {
+			return fmt.Errorf("解析测试报告%s失败: %w", filepath.Base(path), err)
+		}
+		cases = append(cases, list...)
+	}
+	records := make([]pipeline.PipelineTestCase, 0, len(cases))
+	for _, c := range cases {
+		records = append(records, pipeline.PipelineTestCase{
+			DagID:     p.DagID,
+			DagInsID:  p.DagInsID,
+			TaskID:    p.TaskID,
+			Suite:     c.Suite,
+			Name:      c.Name,
+			Classname: c.Classname,
+			Status:    c.Status,
+			Duration:  c.Duration,
+			Message:   c.Message,
+			Output:    c.Output,
+		})
+	}
+	if err := pipeline.SavePipelineTestCases(p.DagInsID, p.TaskID, records); err != nil {
+		return fmt.Errorf("保存测试结果失败: %w", err)
+	}
+	testReport, err := pipeline.GetPipelineTestReport(p.DagID, p.DagInsID)
+	if err != nil {
+		return fmt.Errorf("统计测试结果失败: %w", err)
+	}
+	summary := testReport.Summary
+	p.SetOutput(ctx, "testTotal", strconv.FormatInt(summary.Total, 10))
+	p.SetOutput(ctx, "testPassed", strconv.FormatInt(summary.Passed, 10))
+	p.SetOutput(ctx, "testFailed", strconv.FormatInt(summary.Failed, 10))
+	p.SetOutput(ctx, "testSkipped", strconv.FormatInt(summary.Skipped, 10))
+	p.SetOutput(ctx, "testPassRate", strconv.FormatFloat(summary.PassRate, 'f', 2, 64))
+	ctx.Tracef("测试结果: 共%d个用例, 通过%d, 失败%d, 跳过%d, 通过率%.2f%%, 耗时%.2fs",
+		summary.Total, summary.Passed, summary.Failed, summary.Skipped, summary.PassRate, summary.Duration)
+	failures := make([]string, 0)
+	for _, c := range cases {
+		if c.Failed() {
+			failures = append(failures, fmt.Sprintf("%s %s: %s", c.Suite, c.Name, c.Message))
+		}
+	}
+	if len(failures) > 0 {
+		msg := failures
+		if len(msg) > traceMaxFailures {
+			msg = append(msg[:traceMaxFailures:traceMaxFailures], fmt.Sprintf("...等%d个失败用例", len(failures)))
+		}
+		ctx.Trace("失败用例:\n" + strings.Join(msg, "\n"))
+		if p.FailOnFailure {
+			return fmt.Errorf("存在%d个失败用例", len(failures))
+		}
+	}
+	return nil
+}
+
+// 按通配符匹配工作空间内的文件
+func globWorkspace(workspace string, patterns []string) ([]string, error) {
+	paths := make([]string, 0)
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		full, err := flow.WorkspacePath(workspace, pattern)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := filepath.Glob(full)
+		if err != nil {
+			return nil, fmt.Errorf("非法的文件通配符%s: %w", pattern, err)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+func parseTestReport(path, format string) ([]report.TestCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if format == "" {
+		format = "gotest"
+		if strings.EqualFold(filepath.Ext(path), ".xml") {
+			format = "junit"
+		}
+	}
+	switch format {
+	case "junit":
+		return report.ParseJUnit(f)
+	case "gotest":
+		return report.ParseGoTestJSON(f)
+	}
+	return nil, fmt.Errorf("不支持的报告格式: %s", format)
+}
@@ -0,0 +1,113 @@
+package action
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+const (
+	// 每次写入trace的最大行数
+	traceBatchLines = 50
+	// trace写入间隔
+	traceFlushInterval = time.Second
+	// 单个任务写入trace的最大字节数, 超出后截断
+	traceMaxBytes = 1 << 20
+)
+
+// 按批次把输出写入任务trace
+// fastflow每次写trace都会保存全部trace, 逐行写入代价太高; 同时屏蔽输出中的密钥
+type traceWriter struct {
+	ctx     run.ExecuteContext
+	masks   []string
+	lines   chan string
+	done    chan struct{}
+	written int
+	wg      sync.WaitGroup
+}
+
+func newTraceWriter(ctx run.ExecuteContext, masks []string) *traceWriter {
+	w := &traceWriter{
+		ctx:   ctx,
+		masks: masks,
+		lines: make(chan string, traceBatchLines*4),
+		done:  make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// 逐行读取reader写入trace, 读取结束后返回
+// 单行超过上限等读取错误时记录错误并丢弃剩余输出, 避免写端阻塞
+func (w *traceWriter) Stream(r io.Reader, prefix string) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			w.lines <- prefix + scanner.Text()
+		}
+		if err := scanner.Err(); err != nil {
+			w.lines <- prefix + "读取输出失败, 后续输出不再记录: " + err.Error()
+			io.Copy(io.Discard, r)
+		}
+	}()
+}
+
+// 写入一行
+func (w *traceWriter) Line(line string) {
+	w.lines <- line
+}
+
+// 等待全部reader读取结束并写入剩余输出
+func (w *traceWriter) Close() {
+	w.wg.Wait()
+	close(w.lines)
+	<-w.done
+}
+
+func (w *traceWriter) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	batch := make([]string, 0, traceBatchLines)
+	for {
+		select {
+		case line, ok := <-w.lines:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= traceBatchLines {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (w *traceWriter) flush(batch []string) {
+	if len(batch) == 0 || w.written >= traceMaxBytes {
+		return
+	}
+	msg := strings.Join(batch, "\n")
+	for _, mask := range w.masks {
+		if mask != "" {
+			msg = strings.ReplaceAll(msg, mask, "******")
+		}
+	}
+	w.written += len(msg)
+	if w.written >= traceMaxBytes {
+		msg += "\n输出超过上限, 后续输出不再记录"
+	}
+	w.ctx.Trace(msg)
+}
@@ -0,0 +1,200 @@
+package action
+
+import (
+	"fmt"
+	"go-gin-rest-api/pkg/flow"
+	"go-gin-rest-api/pkg/git"
+	"go-gin-rest-api/pkg/semver"
+	"os"
+	"strconv"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+// 默认值
+const (
+	defaultTagPrefix      = "v"
+	defaultInitialVersion = "0.1.0"
+	shortCommitLength     = 7
+)
+
+// 默认的主干分支, 主干分支生成正式版本, 其他分支生成预发布版本
+var defaultMainBranches = []string{"main", "master"}
+
+// version动作参数
+type VersionParams struct {
+	flow.TaskMeta `json:",squash"`
+	Dir           string         `json:"dir"`          // 仓库目录, 工作空间内的相对路径, 默认工作空间; 设置repo时忽略
+	Repo          string         `json:"repo"`         // 仓库地址, 设置时完整克隆到工作空间的临时目录
+	Credential    git.Credential `json:"credential"`   // 仓库认证信息, 克隆或推送标签时使用
+	Branch        string         `json:"branch"`       // 分支, 为空时取流水线参数branch, 仍为空时取仓库当前分支
+	MainBranches  []string       `json:"mainBranches"` // 主干分支, 默认main、master
+	TagPrefix     *string        `json:"tagPrefix"`    // 版本标签前缀, 默认v
+	Initial       string         `json:"initial"`      // 没有版本标签时的初始版本, 默认0.1.0
+	Tag           bool           `json:"tag"`          // 主干分支有新提交时创建版本标签并推送到远程仓库
+}
+
+// 语义化版本: 从HEAD可达的最高版本标签开始, 按之后的约定式提交计算下一个版本,
+// feat为minor, fix/perf为patch, !或BREAKING CHANGE为major, 其他类型的提交按patch;
+// 非主干分支生成<版本>-<分支>.<提交数>+<提交SHA>的预发布版本, 镜像标签中的+替换为_. 需要完整的git历史, 浅克隆时可能找不到标签
+// 输出: version、imageTag、versionTag、previousVersion、versionBump(实际应用的升级类型), 以及HEAD的commit和commitTime(提交时间, 用于计算变更前置时间)
+type Version struct{}
+
+func (a *Version) Name() string {
+	return "version"
+}
+
+func (a *Version) ParameterNew() interface{} {
+	return &VersionParams{}
+}
+
+func (a *Version) Run(ctx run.ExecuteContext, params interface{}) error {
+	p, ok := params.(*VersionParams)
+	if !ok {
+		return fmt.Errorf("version参数类型错误: %T", params)
+	}
+	prefix := defaultTagPrefix
+	if p.TagPrefix != nil {
+		prefix = *p.TagPrefix
+	}
+	initial, err := semver.Parse(firstNonEmpty(p.Initial, defaultInitialVersion))
+	if err != nil {
+		return err
+	}
+	repo, cleanup, err := a.open(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	head, branch, err := git.Head(repo)
+	if err != nil {
+		return err
+	}
+	if p.Branch != "" {
+		branch = p.Branch
+	} else if v, _ := ctx.GetVar("branch"); v != "" {
+		branch = v
+	}
+	latest, err := git.LatestVersionTag(repo, head, prefix)
+	if err != nil {
+		return fmt.Errorf("查找版本标签失败: %w", err)
+	}
+	base, previous := plumbing.ZeroHash, ""
+	if latest != nil {
+		base, previous = latest.Commit, latest.Version.String()
+		ctx.Tracef("最近的版本标签: %s(%s)", latest.Name, latest.Commit)
+	}
+	commits, err := git.CommitsSince(repo, head, base)
+	if err != nil {
+		return fmt.Errorf("读取提交历史失败: %w", err)
+	}
+	messages := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		messages = append(messages, commit.Message)
+	}
+	// 实际应用的升级类型: 没有版本标签时使用初始版本不升级, 有新提交但没有约定式提交时按patch升级
+	bump := semver.ParseCommits(messages)
+	var next semver.Version
+	switch {
+	case latest == nil:
+		next, bump = initial, semver.BumpNone
+	case len(commits) == 0:
+		next = latest.Version
+	default:
+		if bump == semver.BumpNone {
+			bump = semver.BumpPatch
+		}
+		next = latest.Version.Next(bump)
+	}
+	main := a.isMain(p, branch)
+	if !main {
+		identifier := semver.Identifier(branch)
+		if identifier == "" {
+			identifier = "snapshot"
+		}
+		next.Prerelease = identifier + "." + strconv.Itoa(len(commits))
+		next.Build = head.String()[:shortCommitLength]
+	}
+	ctx.Tracef("分支%s, 上一版本%s之后%d个提交, 升级类型%s, 版本%s", branch, firstNonEmpty(previous, "无"), len(commits), bump, next)
+	p.SetOutput(ctx, flow.OutputVersion, next.String())
+	p.SetOutput(ctx, "imageTag", next.ImageTag())
+	p.SetOutput(ctx, "versionTag", prefix+next.String())
+	p.SetOutput(ctx, "previousVersion", previous)
+	p.SetOutput(ctx, "versionBump", bump.String())
+	p.SetOutput(ctx, flow.OutputCommit, head.String())
+	if commit, err := repo.CommitObject(head); err == nil {
+		p.SetOutput(ctx, flow.OutputCommitTime, commit.Committer.When.UTC().Format(time.RFC3339))
+	} else {
+		ctx.Tracef("读取HEAD提交时间失败: %s", err)
+	}
+	if !p.Tag {
+		return nil
+	}
+	if !main || len(commits) == 0 {
+		ctx.Tracef("非主干分支或没有新提交, 不创建版本标签")
+		return nil
+	}
+	auth, err := p.Credential.Auth()
+	if err != nil {
+		return err
+	}
+	if err := git.PushTag(ctx.Context(), repo, prefix+next.String(), head, auth); err != nil {
+		return err
+	}
+	ctx.Tracef("已推送版本标签%s", prefix+next.String())
+	return nil
+}
+
+// 打开工作空间中的仓库, 或完整克隆repo到工作空间的临时目录
+func (a *Version) open(ctx run.ExecuteContext, p *VersionParams) (*gogit.Repository, func(), error) {
+	workspace, err := p.WorkspaceDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Repo == "" {
+		dir, err := flow.WorkspacePath(workspace, p.Dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{DetectDotGit: true})
+		if err != nil {
+			return nil, nil, fmt.Errorf("打开仓库%s失败: %w", dir, err)
+		}
+		return repo, func() {}, nil
+	}
+	auth, err := p.Credential.Auth()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := os.MkdirTemp(workspace, ".version-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	branch := p.Branch
+	if branch == "" {
+		branch, _ = ctx.GetVar("branch")
+	}
+	repo, err := git.Clone(ctx.Context(), p.Repo, branch, dir, 0, auth)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return repo, cleanup, nil
+}
+
+func (a *Version) isMain(p *VersionParams, branch string) bool {
+	mainBranches := p.MainBranches
+	if len(mainBranches) == 0 {
+		mainBranches = defaultMainBranches
+	}
+	for _, mainBranch := range mainBranches {
+		if branch == mainBranch {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+// 构建证明: in-toto/SLSA来源证明和SBOM
+package attest
+
+import (
+	"time"
+)
+
+// in-toto和SLSA约定的类型
+const (
+	StatementType            = "https://in-toto.io/Statement/v1"
+	ProvenancePredicateType  = "https://slsa.dev/provenance/v1"
+	ProvenanceMediaType      = "application/vnd.in-toto+json"
+	BuildType                = "https://github.com/linclin/cdms/pipeline/v1"
+	digestSHA256             = "sha256"
+	digestGitCommit          = "gitCommit"
+	gitDependencyURIPrefix   = "git+"
+	gitDependencyBranchInfix = "@refs/heads/"
+)
+
+// in-toto声明
+type Statement struct {
+	Type          string      `json:"_type"`
+	Subject       []Subject   `json:"subject"`       // 构建产物
+	PredicateType string      `json:"predicateType"` // 证明类型
+	Predicate     interface{} `json:"predicate"`     // 证明内容
+}
+
+// 构建产物, 摘要为算法到十六进制值的映射, 如{"sha256":"..."}
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SLSA v1来源证明
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// 构建定义
+type BuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`           // 外部参数: 流水线、参数、触发方式
+	InternalParameters   map[string]interface{} `json:"internalParameters,omitempty"` // 内部参数
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies"`         // 构建使用的源码和依赖
+}
+
+// 资源描述
+type ResourceDescriptor struct {
+	URI    string            `json:"uri"`              // 资源地址, 如git+https://github.com/org/repo@refs/heads/main
+	Digest map[string]string `json:"digest,omitempty"` // 摘要, 如{"gitCommit":"..."}或{"sha256":"..."}
+	Name   string            `json:"name,omitempty"`   // 名称
+}
+
+// 运行信息
+type RunDetails struct {
+	Builder  Builder       `json:"builder"`
+	Metadata BuildMetadata `json:"metadata"`
+}
+
+// 构建者
+type Builder struct {
+	ID string `json:"id"` // 构建者ID
+}
+
+// 构建元数据, 流水线实例ID作为调用ID
+type BuildMetadata struct {
+	InvocationID string     `json:"invocationId"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// 生成来源证明的输入
+type ProvenanceInput struct {
+	BuilderID string               // 构建者ID
+	DagID     string               // 流水线ID
+	DagInsID  string               // 流水线实例ID
+	Trigger   string               // 触发方式
+	Vars      map[string]string    // 流水线参数
+	Repo      string               // 源码仓库地址
+	Branch    string               // 分支
+	Commit    string               // 提交SHA
+	Subjects  []Subject            // 构建产物
+	Materials []ResourceDescriptor // 其他构建材料, 如基础镜像
+	StartedOn time.Time            // 流水线实例开始时间
+}
+
+// 生成SLSA v1来源证明
+func NewProvenance(in ProvenanceInput) Statement {
+	dependencies := make([]ResourceDescriptor, 0, len(in.Materials)+1)
+	if in.Repo != "" {
+		source := ResourceDescriptor{URI: gitDependencyURIPrefix + in.Repo}
+		if in.Branch != "" {
+			source.URI += gitDependencyBranchInfix + in.Branch
+		}
+		if in.Commit != "" {
+			source.Digest = map[string]string{digestGitCommit: in.Commit}
+		}
+		dependencies = append(dependencies, source)
+	}
+	dependencies = append(dependencies, in.Materials...)
+	finishedOn := time.Now()
+	metadata := BuildMetadata{InvocationID: in.DagInsID, FinishedOn: &finishedOn}
+	if !in.StartedOn.IsZero() {
+		metadata.StartedOn = &in.StartedOn
+	}
+	vars := in.Vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	return Statement{
+		Type:          StatementType,
+		Subject:       in.Subjects,
+		PredicateType: ProvenancePredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: map[string]interface{}{
+					"dagId":   in.DagID,
+					"trigger": in.Trigger,
+					"vars":    vars,
+				},
+				InternalParameters:   map[string]interface{}{"dagInsId": in.DagInsID},
+				ResolvedDependencies: dependencies,
+			},
+			RunDetails: RunDetails{
+				Builder:  Builder{ID: in.BuilderID},
+				Metadata: metadata,
+			},
+		},
+	}
+}
+
+// 生成sha256摘要的构建产物
+func NewSubject(name, sha256Hex string) Subject {
+	return Subject{Name: name, Digest: map[string]string{digestSHA256: sha256Hex}}
+}
@@ -0,0 +1,339 @@
+package attest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/mod/modfile"
+)
+
+// SBOM格式及媒体类型
+const (
+	SbomCycloneDX          = "cyclonedx"
+	SbomSPDX               = "spdx"
+	CycloneDXMediaType     = "application/vnd.cyclonedx+json"
+	SPDXMediaType          = "application/spdx+json"
+	cycloneDXSpecVersion   = "1.5"
+	spdxVersion            = "SPDX-2.3"
+	spdxNoAssertion        = "NOASSERTION"
+	spdxDocumentID         = "SPDXRef-DOCUMENT"
+	spdxRootPackageID      = "SPDXRef-Package-root"
+	spdxDefaultNamespace   = "https://spdx.org/spdxdocs"
+	componentTypeGo        = "golang"
+	componentTypeNpm       = "npm"
+	componentTypePypi      = "pypi"
+	sbomToolName           = "cdms"
+	maxRequirementLineSize = 64 * 1024
+)
+
+// 扫描时跳过的目录
+var skipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+
+// 依赖组件
+type Component struct {
+	Type    string `json:"type"`    // 包管理器: golang/npm/pypi
+	Name    string `json:"name"`    // 名称
+	Version string `json:"version"` // 版本
+	Source  string `json:"source"`  // 来源清单文件, 工作空间内的相对路径
+}
+
+// package url, 如pkg:golang/github.com/gin-gonic/gin@v1.10.0
+func (c Component) PURL() string {
+	name := c.Name
+	// npm的scope需转义, 如@types/node为%40types/node
+	if strings.HasPrefix(name, "@") {
+		name = "%40" + name[1:]
+	}
+	purl := "pkg:" + c.Type + "/" + name
+	if c.Version != "" {
+		purl += "@" + c.Version
+	}
+	return purl
+}
+
+// 校验SBOM格式
+func ValidateSbomFormat(format string) error {
+	if format != SbomCycloneDX && format != SbomSPDX {
+		return fmt.Errorf("不支持的SBOM格式: %s, 可选cyclonedx/spdx", format)
+	}
+	return nil
+}
+
+// SBOM格式的媒体类型
+func SbomMediaType(format string) string {
+	if format == SbomSPDX {
+		return SPDXMediaType
+	}
+	return CycloneDXMediaType
+}
+
+// 扫描目录下的依赖清单: go.mod、package-lock.json(没有时为package.json)、requirements.txt, 跳过.git、node_modules和vendor
+func ScanComponents(dir string) ([]Component, error) {
+	components := make([]Component, 0)
+	seen := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		var found []Component
+		switch d.Name() {
+		case "go.mod":
+			found, err = parseGoMod(path)
+		case "package-lock.json":
+			found, err = parsePackageLock(path)
+		case "package.json":
+			if _, statErr := os.Stat(filepath.Join(filepath.Dir(path), "package-lock.json")); statErr == nil {
+				return nil
+			}
+			found, err = parsePackageJSON(path)
+		case "requirements.txt":
+			found, err = parseRequirements(path)
+		default:
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("解析依赖清单%s失败: %w", path, err)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		for _, component := range found {
+			component.Source = filepath.ToSlash(rel)
+			if purl := component.PURL(); !seen[purl] {
+				seen[purl] = true
+				components = append(components, component)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i].PURL() < components[j].PURL()
+	})
+	return components, nil
+}
+
+func parseGoMod(path string) ([]Component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseLax(path, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	replaces := map[string]string{}
+	for _, r := range f.Replace {
+		if r.New.Version != "" {
+			replaces[r.Old.Path] = r.New.Path + "@" + r.New.Version
+		}
+	}
+	components := make([]Component, 0, len(f.Require))
+	for _, r := range f.Require {
+		name, version := r.Mod.Path, r.Mod.Version
+		if replace, ok := replaces[name]; ok {
+			name, version, _ = strings.Cut(replace, "@")
+		}
+		components = append(components, Component{Type: componentTypeGo, Name: name, Version: version})
+	}
+	return components, nil
+}
+
+func parsePackageLock(path string) ([]Component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+			Link    bool   `json:"link"`
+		} `json:"packages"`
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+	components := make([]Component, 0)
+	// lockfileVersion 2/3使用packages, 键为node_modules/<名称>, 嵌套依赖为node_modules/a/node_modules/b
+	for key, pkg := range lock.Packages {
+		idx := strings.LastIndex(key, "node_modules/")
+		if idx < 0 || pkg.Link || pkg.Version == "" {
+			continue
+		}
+		components = append(components, Component{Type: componentTypeNpm, Name: key[idx+len("node_modules/"):], Version: pkg.Version})
+	}
+	if len(lock.Packages) == 0 {
+		for name, dep := range lock.Dependencies {
+			components = append(components, Component{Type: componentTypeNpm, Name: name, Version: dep.Version})
+		}
+	}
+	return components, nil
+}
+
+// 没有package-lock.json时只能取依赖声明的版本范围
+func parsePackageJSON(path string) ([]Component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+	components := make([]Component, 0)
+	for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		for name, version := range deps {
+			components = append(components, Component{Type: componentTypeNpm, Name: name, Version: version})
+		}
+	}
+	return components, nil
+}
+
+// 解析requirements.txt中的name==version, 忽略注释、选项和未固定版本的依赖
+func parseRequirements(path string) ([]Component, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	components := make([]Component, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequirementLineSize)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line, _, _ = strings.Cut(line, ";")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		name, version, ok := strings.Cut(line, "==")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(name, "[")
+		components = append(components, Component{Type: componentTypePypi, Name: strings.ToLower(strings.TrimSpace(name)), Version: strings.TrimSpace(version)})
+	}
+	return components, scanner.Err()
+}
+
+// SBOM描述的应用
+type SbomSubject struct {
+	Name      string // 应用名称
+	Version   string // 版本
+	Namespace string // SPDX文档命名空间前缀, 如cdms的地址, 为空时使用https://spdx.org/spdxdocs
+}
+
+// 生成SBOM, format为cyclonedx或spdx, 均为JSON格式
+func NewSbom(format string, subject SbomSubject, components []Component) ([]byte, error) {
+	if err := ValidateSbomFormat(format); err != nil {
+		return nil, err
+	}
+	if format == SbomSPDX {
+		return json.MarshalIndent(newSPDX(subject, components), "", "  ")
+	}
+	return json.MarshalIndent(newCycloneDX(subject, components), "", "  ")
+}
+
+func newCycloneDX(subject SbomSubject, components []Component) map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(components))
+	for _, component := range components {
+		list = append(list, map[string]interface{}{
+			"type":    "library",
+			"bom-ref": component.PURL(),
+			"name":    component.Name,
+			"version": component.Version,
+			"purl":    component.PURL(),
+			"properties": []map[string]string{
+				{"name": "cdms:source", "value": component.Source},
+			},
+		})
+	}
+	return map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  cycloneDXSpecVersion,
+		"serialNumber": "urn:uuid:" + uuid.NewString(),
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools": map[string]interface{}{
+				"components": []map[string]string{{"type": "application", "name": sbomToolName}},
+			},
+			"component": map[string]string{"type": "application", "name": subject.Name, "version": subject.Version},
+		},
+		"components": list,
+	}
+}
+
+func newSPDX(subject SbomSubject, components []Component) map[string]interface{} {
+	namespace := strings.TrimRight(subject.Namespace, "/")
+	if namespace == "" {
+		namespace = spdxDefaultNamespace
+	}
+	root := map[string]interface{}{
+		"name":             subject.Name,
+		"SPDXID":           spdxRootPackageID,
+		"versionInfo":      subject.Version,
+		"downloadLocation": spdxNoAssertion,
+		"filesAnalyzed":    false,
+	}
+	packages := []map[string]interface{}{root}
+	relationships := []map[string]string{{
+		"spdxElementId":      spdxDocumentID,
+		"relationshipType":   "DESCRIBES",
+		"relatedSpdxElement": spdxRootPackageID,
+	}}
+	for i, component := range components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		packages = append(packages, map[string]interface{}{
+			"name":             component.Name,
+			"SPDXID":           id,
+			"versionInfo":      component.Version,
+			"downloadLocation": spdxNoAssertion,
+			"filesAnalyzed":    false,
+			"sourceInfo":       "declared in " + component.Source,
+			"externalRefs": []map[string]string{{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  component.PURL(),
+			}},
+		})
+		relationships = append(relationships, map[string]string{
+			"spdxElementId":      spdxRootPackageID,
+			"relationshipType":   "DEPENDS_ON",
+			"relatedSpdxElement": id,
+		})
+	}
+	return map[string]interface{}{
+		"spdxVersion":       spdxVersion,
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            spdxDocumentID,
+		"name":              subject.Name,
+		"documentNamespace": namespace + "/" + subject.Name + "-" + uuid.NewString(),
+		"creationInfo": map[string]interface{}{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: " + sbomToolName},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
@@ -0,0 +1,201 @@
+// 金丝雀分析, 查询Prometheus兼容的监控接口, 按阈值或与基线比较判断金丝雀版本是否健康
+package canary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// 分析指标, 查询结果须为单个样本的vector或scalar
+// 示例: 错误率 sum(rate(http_requests_total{app="demo",track="canary",code=~"5.."}[1m])) / sum(rate(http_requests_total{app="demo",track="canary"}[1m]))
+type Metric struct {
+	Name          string   `json:"name"`          // 指标名称
+	Query         string   `json:"query"`         // 金丝雀版本的PromQL
+	BaselineQuery string   `json:"baselineQuery"` // 基线版本的PromQL, 与maxRatio一起使用
+	Min           *float64 `json:"min"`           // 最小值, 低于时不通过
+	Max           *float64 `json:"max"`           // 最大值, 高于时不通过
+	MaxRatio      float64  `json:"maxRatio"`      // 与基线的最大比值, 如1.2表示不能超过基线的120%
+	FailOnNoData  bool     `json:"failOnNoData"`  // 查询无数据时不通过, 默认视为通过(如没有错误请求时错误率无数据)
+}
+
+// 校验指标配置
+func (m Metric) Validate() error {
+	if m.Name == "" {
+		return errors.New("分析指标名称不能为空")
+	}
+	if strings.TrimSpace(m.Query) == "" {
+		return fmt.Errorf("分析指标%s的query不能为空", m.Name)
+	}
+	if m.Min == nil && m.Max == nil && m.MaxRatio <= 0 {
+		return fmt.Errorf("分析指标%s须设置min、max或maxRatio", m.Name)
+	}
+	if m.MaxRatio > 0 && strings.TrimSpace(m.BaselineQuery) == "" {
+		return fmt.Errorf("分析指标%s设置了maxRatio, 须设置baselineQuery", m.Name)
+	}
+	return nil
+}
+
+// 单个指标的检查结果
+type Measurement struct {
+	Name     string   `json:"name"`     // 指标名称
+	Value    *float64 `json:"value"`    // 金丝雀版本的值, 无数据时为空
+	Baseline *float64 `json:"baseline"` // 基线版本的值
+	Passed   bool     `json:"passed"`   // 是否通过
+	Message  string   `json:"message"`  // 说明
+}
+
+func (m Measurement) String() string {
+	value := "无数据"
+	if m.Value != nil {
+		value = formatFloat(*m.Value)
+	}
+	if m.Baseline != nil {
+		value += ", 基线" + formatFloat(*m.Baseline)
+	}
+	result := "通过"
+	if !m.Passed {
+		result = "不通过"
+	}
+	if m.Message != "" {
+		result += ", " + m.Message
+	}
+	return fmt.Sprintf("%s=%s [%s]", m.Name, value, result)
+}
+
+// 查询接口
+type Querier interface {
+	// 查询当前值, 无数据时返回false
+	Query(ctx context.Context, query string) (float64, bool, error)
+}
+
+// Prometheus兼容的查询接口, 如Prometheus、Thanos、VictoriaMetrics
+type Prometheus struct {
+	api v1.API
+}
+
+// 创建Prometheus查询客户端, headers用于认证或多租户, 如Authorization、X-Scope-OrgID
+func NewPrometheus(address string, headers map[string]string) (*Prometheus, error) {
+	if address == "" {
+		return nil, errors.New("Prometheus地址不能为空")
+	}
+	client, err := api.NewClient(api.Config{
+		Address:      address,
+		RoundTripper: &headerRoundTripper{headers: headers, next: api.DefaultRoundTripper},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建Prometheus客户端失败: %w", err)
+	}
+	return &Prometheus{api: v1.NewAPI(client)}, nil
+}
+
+func (p *Prometheus) Query(ctx context.Context, query string) (float64, bool, error) {
+	value, _, err := p.api.Query(ctx, query, time.Now())
+	if err != nil {
+		return 0, false, err
+	}
+	switch v := value.(type) {
+	case *model.Scalar:
+		return float64(v.Value), true, nil
+	case model.Vector:
+		if len(v) == 0 {
+			return 0, false, nil
+		}
+		if len(v) > 1 {
+			return 0, false, fmt.Errorf("查询结果有%d个样本, 须聚合为单个样本", len(v))
+		}
+		return float64(v[0].Value), true, nil
+	}
+	return 0, false, fmt.Errorf("不支持的查询结果类型: %s", value.Type())
+}
+
+type headerRoundTripper struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) > 0 {
+		req = req.Clone(req.Context())
+		for k, v := range t.headers {
+			req.Header.Set(k, v)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// 检查全部指标, 查询出错的指标视为不通过
+func Check(ctx context.Context, q Querier, metrics []Metric) []Measurement {
+	results := make([]Measurement, 0, len(metrics))
+	for _, metric := range metrics {
+		results = append(results, check(ctx, q, metric))
+	}
+	return results
+}
+
+func check(ctx context.Context, q Querier, metric Metric) Measurement {
+	result := Measurement{Name: metric.Name}
+	value, ok, err := q.Query(ctx, metric.Query)
+	if err != nil {
+		result.Message = fmt.Sprintf("查询失败: %s", err)
+		return result
+	}
+	if !ok || math.IsNaN(value) {
+		result.Passed = !metric.FailOnNoData
+		result.Message = "查询无数据"
+		return result
+	}
+	result.Value = &value
+	if metric.Min != nil && value < *metric.Min {
+		result.Message = fmt.Sprintf("低于最小值%s", formatFloat(*metric.Min))
+		return result
+	}
+	if metric.Max != nil && value > *metric.Max {
+		result.Message = fmt.Sprintf("高于最大值%s", formatFloat(*metric.Max))
+		return result
+	}
+	if metric.MaxRatio > 0 {
+		baseline, ok, err := q.Query(ctx, metric.BaselineQuery)
+		if err != nil {
+			result.Message = fmt.Sprintf("查询基线失败: %s", err)
+			return result
+		}
+		// 基线无数据或为0时无法按比值比较, 只有金丝雀的值也为0才通过
+		if !ok || math.IsNaN(baseline) || baseline == 0 {
+			if value != 0 {
+				result.Message = "基线无数据或为0"
+				return result
+			}
+		} else {
+			result.Baseline = &baseline
+			if value > baseline*metric.MaxRatio {
+				result.Message = fmt.Sprintf("超过基线的%s倍", formatFloat(metric.MaxRatio))
+				return result
+			}
+		}
+	}
+	result.Passed = true
+	return result
+}
+
+// 是否全部通过
+func Passed(results []Measurement) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func formatFloat(f float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.4f", f), "0"), ".")
+}
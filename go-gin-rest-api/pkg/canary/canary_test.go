@@ -0,0 +1,159 @@
+package canary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 模拟Prometheus查询接口, 按查询语句返回预置的结果
+func fakePrometheus(t *testing.T, results map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Scope-OrgID") != "tenant" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"status":"error","errorType":"unauthorized","error":"missing tenant"}`)
+			return
+		}
+		data, ok := results[r.Form.Get("query")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":%s}`, data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func vector(values ...string) string {
+	samples := make([]string, 0, len(values))
+	for _, v := range values {
+		samples = append(samples, fmt.Sprintf(`{"metric":{},"value":[1700000000,"%s"]}`, v))
+	}
+	return `{"resultType":"vector","result":[` + strings.Join(samples, ",") + `]}`
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func newTestPrometheus(t *testing.T, results map[string]string) *Prometheus {
+	t.Helper()
+	server := fakePrometheus(t, results)
+	prom, err := NewPrometheus(server.URL, map[string]string{"X-Scope-OrgID": "tenant"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prom
+}
+
+func TestPrometheusQuery(t *testing.T) {
+	prom := newTestPrometheus(t, map[string]string{
+		"vector": vector("0.25"),
+		"scalar": `{"resultType":"scalar","result":[1700000000,"3"]}`,
+		"empty":  vector(),
+		"multi":  vector("1", "2"),
+	})
+	ctx := context.Background()
+	cases := []struct {
+		query   string
+		value   float64
+		ok      bool
+		wantErr bool
+	}{
+		{"vector", 0.25, true, false},
+		{"scalar", 3, true, false},
+		{"empty", 0, false, false},
+		{"multi", 0, false, true},
+		{"unknown", 0, false, true},
+	}
+	for _, c := range cases {
+		value, ok, err := prom.Query(ctx, c.query)
+		if (err != nil) != c.wantErr || ok != c.ok || value != c.value {
+			t.Errorf("Query(%s) = %v, %v, %v; want %v, %v, err=%v", c.query, value, ok, err, c.value, c.ok, c.wantErr)
+		}
+	}
+}
+
+func TestPrometheusQueryHeaders(t *testing.T) {
+	server := fakePrometheus(t, map[string]string{"vector": vector("1")})
+	prom, err := NewPrometheus(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := prom.Query(context.Background(), "vector"); err == nil {
+		t.Fatal("query without tenant header succeeded")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	prom := newTestPrometheus(t, map[string]string{
+		"canary_errors":   vector("0.02"),
+		"baseline_errors": vector("0.01"),
+		"canary_latency":  vector("0.3"),
+		"baseline_zero":   vector("0"),
+		"no_data":         vector(),
+	})
+	cases := []struct {
+		name    string
+		metric  Metric
+		passed  bool
+		message string
+	}{
+		{"under max", Metric{Name: "errors", Query: "canary_errors", Max: float(0.05)}, true, ""},
+		{"over max", Metric{Name: "errors", Query: "canary_errors", Max: float(0.01)}, false, "高于最大值0.01"},
+		{"under min", Metric{Name: "latency", Query: "canary_latency", Min: float(0.5)}, false, "低于最小值0.5"},
+		{"within ratio", Metric{Name: "errors", Query: "canary_errors", BaselineQuery: "baseline_errors", MaxRatio: 2.5}, true, ""},
+		{"over ratio", Metric{Name: "errors", Query: "canary_errors", BaselineQuery: "baseline_errors", MaxRatio: 1.5}, false, "超过基线的1.5倍"},
+		{"zero baseline", Metric{Name: "errors", Query: "canary_errors", BaselineQuery: "baseline_zero", MaxRatio: 2}, false, "基线无数据或为0"},
+		{"no data", Metric{Name: "errors", Query: "no_data", Max: float(0.01)}, true, "查询无数据"},
+		{"no data fails", Metric{Name: "errors", Query: "no_data", Max: float(0.01), FailOnNoData: true}, false, "查询无数据"},
+		{"query error", Metric{Name: "errors", Query: "unknown", Max: float(0.01)}, false, "查询失败"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.metric.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			results := Check(context.Background(), prom, []Metric{c.metric})
+			if len(results) != 1 {
+				t.Fatalf("got %d results", len(results))
+			}
+			result := results[0]
+			if result.Passed != c.passed || !strings.HasPrefix(result.Message, c.message) {
+				t.Errorf("Check = %s; want passed=%v message=%q", result, c.passed, c.message)
+			}
+			if Passed(results) != c.passed {
+				t.Errorf("Passed = %v, want %v", Passed(results), c.passed)
+			}
+		})
+	}
+}
+
+func TestMetricValidate(t *testing.T) {
+	invalid := []Metric{
+		{Query: "up", Max: float(1)},
+		{Name: "up", Max: float(1)},
+		{Name: "up", Query: "up"},
+		{Name: "up", Query: "up", MaxRatio: 1.2},
+	}
+	for _, metric := range invalid {
+		if err := metric.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", metric)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/policy"
+	"go-gin-rest-api/pkg/registry"
+	"go-gin-rest-api/pkg/signing"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/entity/run"
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// 部署目标, 部署类动作在参数的deploy字段中声明, 执行前检查冻结窗口和部署准入策略
+type DeployTarget struct {
+	Env      string `json:"env"`      // 目标环境, 如dev/st/prd
+	App      string `json:"app"`      // 应用名称, 为空时使用流水线ID
+	Build    string `json:"build"`    // 构建流水线实例ID, 为空时使用当前实例
+	Image    string `json:"image"`    // 部署的镜像, 为空时取构建记录的image输出; 同时指定其他构建记录时须与其image/imageDigest输出一致
+	Digest   string `json:"digest"`   // 部署的镜像摘要, 设置image时须设置摘要或image为repo@sha256:...格式, 为空时取构建记录的imageDigest输出
+	Registry string `json:"registry"` // 验签时使用的镜像仓库名称, 为空时按镜像地址匹配已登记的镜像仓库, 都没有时使用docker配置文件中的凭据
+}
+
+// 生成构建记录: 流水线实例信息、参数及任务输出; 分支取流水线即代码按触发引用解析的分支, 不取调用方传入的参数
+func BuildRecord(dagInsId string) (map[string]interface{}, error) {
+	dagIns, err := mod.GetStore().GetDagInstance(dagInsId)
+	if err != nil {
+		return nil, fmt.Errorf("获取构建流水线实例%s失败: %w", dagInsId, err)
+	}
+	outputs, err := pipeline.GetPipelineOutputs(dagInsId)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := pipeline.GetPipelineCaller(dagInsId)
+	if err != nil {
+		return nil, err
+	}
+	branch := ""
+	if caller != nil {
+		branch = caller.Branch
+	}
+	vars := make(map[string]string, len(dagIns.Vars))
+	for key, value := range dagIns.Vars {
+		vars[key] = value.Value
+	}
+	runOutputs := pipeline.NewPipelineRunOutputs(dagIns.DagID, dagIns.ID, string(dagIns.Status), outputs)
+	return map[string]interface{}{
+		"dagId":     dagIns.DagID,
+		"dagInsId":  dagIns.ID,
+		"status":    string(dagIns.Status),
+		"trigger":   string(dagIns.Trigger),
+		"branch":    branch,
+		"vars":      vars,
+		"outputs":   runOutputs.Outputs,
+		"createdAt": dagIns.CreatedAt,
+	}, nil
+}
+
+// 生成调用方信息, 没有调用记录(如定时触发)时只包含触发方式
+func CallerInfo(dagInsId string, caller *pipeline.PipelineCaller) map[string]interface{} {
+	if caller == nil {
+		trigger := ""
+		if dagIns, err := mod.GetStore().GetDagInstance(dagInsId); err == nil {
+			trigger = string(dagIns.Trigger)
+		}
+		return map[string]interface{}{"appId": "", "user": "", "roles": []string{}, "trigger": trigger, "overrideReason": ""}
+	}
+	return map[string]interface{}{
+		"appId":          caller.AppId,
+		"user":           caller.User,
+		"roles":          caller.RoleList(),
+		"trigger":        caller.Trigger,
+		"overrideReason": caller.OverrideReason,
+	}
+}
+
+// 先保存调用方再创建流水线实例, 实例开始调度时调用方记录已存在, 部署准入策略和破窗部署不会读到空调用方
+// 创建实例失败时删除调用方记录
+func CreateDagInsWithCaller(dagIns *entity.DagInstance, caller *pipeline.PipelineCaller) error {
+	dagIns.Initial()
+	caller.DagID = dagIns.DagID
+	caller.DagInsID = dagIns.ID
+	caller.Trigger = string(dagIns.Trigger)
+	if err := pipeline.SavePipelineCaller(caller); err != nil {
+		return err
+	}
+	if err := mod.GetStore().CreateDagIns(dagIns); err != nil {
+		if err := pipeline.DeletePipelineCaller(dagIns.ID); err != nil {
+			global.Log.Error("CreateDagInsWithCaller删除流水线调用方失败", "dagInsId", dagIns.ID, "err", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// 检查部署准入策略, 拒绝时在任务trace中记录原因并返回错误
+func admitDeploy(ctx run.ExecuteContext, target DeployTarget, build, caller map[string]interface{}) error {
+	vars := map[string]string{}
+	ctx.IterateVars(func(key, val string) bool {
+		vars[key] = val
+		return false
+	})
+	rules, err := pipeline.GetPipelineDeployRules(target.Env, target.App)
+	if err != nil {
+		return fmt.Errorf("获取部署准入策略失败: %w", err)
+	}
+	input := policy.Input{Vars: vars, Build: build, Env: target.Env, App: target.App, Caller: caller, Now: time.Now()}
+	denied := policy.Denied(policy.Evaluate(rules, input))
+	if len(denied) == 0 {
+		ctx.Tracef("部署准入检查通过: 环境%s, 应用%s, 策略%d条", target.Env, target.App, len(rules))
+		return nil
+	}
+	reasons := make([]string, 0, len(denied))
+	for _, result := range denied {
+		ctx.Tracef("部署准入策略[%s]拒绝: %s", result.Name, result.Message)
+		reasons = append(reasons, fmt.Sprintf("[%s]%s", result.Name, result.Message))
+	}
+	return fmt.Errorf("部署被准入策略拒绝: %s", strings.Join(reasons, "; "))
+}
+
+// 检查镜像签名: 匹配的策略设置了验签公钥时, 部署的镜像摘要须有其中任一公钥的有效签名
+func verifyDeploySignature(ctx run.ExecuteContext, target DeployTarget, deployment *pipeline.PipelineDeployment) error {
+	signPolicies, err := pipeline.GetPipelineSignPolicies(deployment.Env, deployment.App)
+	if err != nil {
+		return fmt.Errorf("获取部署准入策略失败: %w", err)
+	}
+	if len(signPolicies) == 0 {
+		return nil
+	}
+	if deployment.Image == "" {
+		return errors.New("部署准入策略要求镜像签名, 但部署目标未设置image且构建记录没有image输出")
+	}
+	auth, err := deployRegistryAuth(target.Registry, deployment.Image)
+	if err != nil {
+		return fmt.Errorf("部署准入策略要求镜像签名: %w", err)
+	}
+	ref, err := registry.ParseDigest(deployment.Image, deployment.Digest, auth)
+	if err != nil {
+		return fmt.Errorf("部署准入策略要求镜像签名: %w", err)
+	}
+	for _, signPolicy := range signPolicies {
+		keys, err := signing.LoadPublicKeys(signPolicy.SignKeyList())
+		if err == nil {
+			_, err = signing.Verify(ctx.Context(), ref, keys, auth)
+		}
+		if err != nil {
+			ctx.Tracef("部署准入策略[%s]拒绝: %s", signPolicy.Name, err)
+			return fmt.Errorf("部署被准入策略拒绝: [%s]%s", signPolicy.Name, err)
+		}
+		ctx.Tracef("部署准入策略[%s]镜像验签通过: %s", signPolicy.Name, ref)
+	}
+	return nil
+}
+
+// 部署镜像所在仓库的认证信息: 指定镜像仓库名称时使用该镜像仓库, 否则按镜像地址的主机名匹配已登记的镜像仓库,
+// 都没有时使用docker配置文件中的凭据
+func deployRegistryAuth(registryName, image string) (registry.Auth, error) {
+	var reg *pipeline.PipelineRegistry
+	var err error
+	if registryName != "" {
+		if reg, err = pipeline.GetPipelineRegistry(registryName); err != nil {
+			return registry.Auth{}, fmt.Errorf("获取镜像仓库%s失败: %w", registryName, err)
+		}
+	} else if reg, err = pipeline.FindPipelineRegistry(image); err != nil {
+		return registry.Auth{}, err
+	}
+	if reg == nil {
+		return registry.Auth{}, nil
+	}
+	endpoint, err := reg.Endpoint()
+	if err != nil {
+		return registry.Auth{}, err
+	}
+	return endpoint.Auth, nil
+}
@@ -0,0 +1,516 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 构建缓存类型
+const (
+	BuildCacheBuildKit = "buildkit" // BuildKit本地缓存(--export-cache type=local,dest=...), OCI布局
+	BuildCacheKaniko   = "kaniko"   // Kaniko基础镜像缓存(--cache-dir), 每个镜像一个文件及同名.json
+)
+
+var (
+	// 构建缓存根目录, 按命名空间分目录: {根目录}/{命名空间}
+	BuildCacheRoots = map[string]string{
+		BuildCacheBuildKit: "./storage/buildkit-cache",
+		BuildCacheKaniko:   "./storage/kaniko-cache",
+	}
+	// 构建缓存目录的环境变量
+	buildCacheEnvs = map[string]string{
+		BuildCacheBuildKit: "CDMS_BUILDKIT_CACHE",
+		BuildCacheKaniko:   "CDMS_KANIKO_CACHE",
+	}
+	// 命名空间只允许字母数字及._-, 且不能以.开头, 如应用名称或应用-Dockerfile
+	buildCacheNamespaceRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+	// 同一时间只执行一次配额检查
+	buildCacheEvictMu sync.Mutex
+	// 本节点构建缓存有变化, 通知上报到worker注册表
+	buildCacheChanged = make(chan struct{}, 1)
+)
+
+// 等待worker执行清除构建缓存命令的最长时间, 超时后返回命令, 可稍后查询结果
+const buildCachePurgeWait = 15 * time.Second
+
+// 构建缓存
+type BuildCache struct {
+	Type      string // 缓存类型
+	Namespace string // 命名空间
+	Dir       string // 缓存目录
+	Hit       bool   // 是否已有缓存
+}
+
+// 构建缓存目录
+func BuildCacheDir(cacheType, namespace string) (string, error) {
+	root, ok := BuildCacheRoots[cacheType]
+	if !ok {
+		return "", fmt.Errorf("不支持的构建缓存类型: %s, 可选buildkit/kaniko", cacheType)
+	}
+	if !buildCacheNamespaceRegexp.MatchString(namespace) {
+		return "", fmt.Errorf("非法的构建缓存命名空间: %s", namespace)
+	}
+	return filepath.Abs(filepath.Join(root, namespace))
+}
+
+// 获取构建缓存, 使用期间不会被淘汰或清除, 使用结束后需调用Release
+func AcquireBuildCache(cacheType, namespace string) (*BuildCache, error) {
+	dir, err := BuildCacheDir(cacheType, namespace)
+	if err != nil {
+		return nil, err
+	}
+	cache := &BuildCache{Type: cacheType, Namespace: namespace, Dir: dir}
+	cacheInUseMu.Lock()
+	defer cacheInUseMu.Unlock()
+	if _, err := os.Stat(dir); err == nil {
+		cache.Hit = true
+	} else if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("创建构建缓存目录失败: %w", err)
+	}
+	cacheInUse[dir]++
+	touchCache(dir)
+	markBuildCacheChanged()
+	return cache, nil
+}
+
+// 释放构建缓存并更新最近使用时间, 随后检查配额
+func (c *BuildCache) Release() {
+	cacheInUseMu.Lock()
+	cacheInUse[c.Dir]--
+	if cacheInUse[c.Dir] <= 0 {
+		delete(cacheInUse, c.Dir)
+	}
+	touchCache(c.Dir)
+	cacheInUseMu.Unlock()
+	markBuildCacheChanged()
+	go func() {
+		if _, err := EvictBuildCaches(); err != nil {
+			global.Log.Error("淘汰构建缓存失败", "err", err.Error())
+		}
+	}()
+}
+
+// 构建缓存目录环境变量
+func (c *BuildCache) Env() map[string]string {
+	return map[string]string{buildCacheEnvs[c.Type]: c.Dir}
+}
+
+// 构建缓存使用情况
+type BuildCacheUsage struct {
+	Worker    string    `json:"worker"`    // 缓存所在的worker
+	Type      string    `json:"type"`      // 缓存类型
+	Namespace string    `json:"namespace"` // 命名空间
+	Size      int64     `json:"size"`      // 大小, 字节
+	Quota     int64     `json:"quota"`     // 大小上限, 字节, 0为不限制
+	LastUsed  time.Time `json:"lastUsed"`  // 最近使用时间
+	InUse     bool      `json:"inUse"`     // 是否使用中
+	dir       string
+}
+
+// 获取存活worker上报的全部构建缓存使用情况, 按worker、类型和最近使用时间从新到旧排列
+// 构建缓存在各节点本地, 由各节点在缓存变化时上报到worker注册表
+func ListBuildCaches() ([]BuildCacheUsage, error) {
+	workers, err := pipeline.ListPipelineWorkers()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]BuildCacheUsage, 0)
+	for _, worker := range workers {
+		if !WorkerAlive(worker) || worker.BuildCaches == "" {
+			continue
+		}
+		usages := make([]BuildCacheUsage, 0)
+		if err := json.Unmarshal([]byte(worker.BuildCaches), &usages); err != nil {
+			global.Log.Error("ListBuildCaches解析worker构建缓存失败", "worker", worker.WorkerKey, "err", err.Error())
+			continue
+		}
+		for _, usage := range usages {
+			usage.Worker = worker.WorkerKey
+			list = append(list, usage)
+		}
+	}
+	return list, nil
+}
+
+// 获取本节点全部构建缓存的使用情况, 按类型和最近使用时间从新到旧排列
+func localBuildCaches() ([]BuildCacheUsage, error) {
+	quotas, err := pipeline.GetPipelineBuildCacheQuotas()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]BuildCacheUsage, 0)
+	for _, cacheType := range []string{BuildCacheBuildKit, BuildCacheKaniko} {
+		usages, err := buildCacheUsages(cacheType, quotas)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(usages, func(i, j int) bool { return usages[i].LastUsed.After(usages[j].LastUsed) })
+		list = append(list, usages...)
+	}
+	return list, nil
+}
+
+// 通知上报本节点构建缓存, 已有待上报的通知时忽略
+func markBuildCacheChanged() {
+	select {
+	case buildCacheChanged <- struct{}{}:
+	default:
+	}
+}
+
+// 启动时及构建缓存变化时上报本节点构建缓存的使用情况, 统计目录大小较慢, 不在心跳中执行
+func reportBuildCaches(workerKey string) {
+	markBuildCacheChanged()
+	for {
+		select {
+		case <-drainedCh:
+			return
+		case <-buildCacheChanged:
+		}
+		usages, err := localBuildCaches()
+		if err != nil {
+			global.Log.Error("统计构建缓存失败", "err", err.Error())
+			continue
+		}
+		data, err := json.Marshal(usages)
+		if err != nil {
+			continue
+		}
+		if err := pipeline.SetPipelineWorkerBuildCaches(workerKey, string(data)); err != nil {
+			global.Log.Error("上报构建缓存失败", "err", err.Error())
+		}
+	}
+}
+
+// 统计一类构建缓存各命名空间的使用情况
+func buildCacheUsages(cacheType string, quotas map[string]int64) ([]BuildCacheUsage, error) {
+	entries, err := os.ReadDir(BuildCacheRoots[cacheType])
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	usages := make([]BuildCacheUsage, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !buildCacheNamespaceRegexp.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		dir, err := BuildCacheDir(cacheType, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		size, err := utils.DirSize(dir)
+		if err != nil {
+			return nil, err
+		}
+		quota, ok := quotas[cacheType+"/"+entry.Name()]
+		if !ok {
+			quota = global.Conf.Pipeline.BuildCache.NamespaceMaxSize * 1024 * 1024
+		}
+		cacheInUseMu.Lock()
+		inUse := cacheInUse[dir] > 0
+		cacheInUseMu.Unlock()
+		usages = append(usages, BuildCacheUsage{Type: cacheType, Namespace: entry.Name(), Size: size, Quota: quota, LastUsed: info.ModTime(), InUse: inUse, dir: dir})
+	}
+	return usages, nil
+}
+
+// 请求worker清除其本地的构建缓存: 写入worker命令, 由该worker心跳时执行, 等待执行完成或超时后返回命令
+func RequestPurgeBuildCache(ctx context.Context, workerKey, cacheType, namespace string) (*pipeline.PipelineWorkerCommand, error) {
+	if _, err := BuildCacheDir(cacheType, namespace); err != nil {
+		return nil, err
+	}
+	worker, err := pipeline.GetPipelineWorker(workerKey)
+	if err != nil {
+		return nil, fmt.Errorf("获取worker %s失败: %w", workerKey, err)
+	}
+	if !WorkerAlive(*worker) {
+		return nil, fmt.Errorf("worker %s不在线", workerKey)
+	}
+	cmd, err := pipeline.CreatePipelineWorkerCommand(workerKey, pipeline.WorkerCommandPurgeBuildCache, cacheType+"/"+namespace)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, buildCachePurgeWait)
+	defer cancel()
+	ticker := time.NewTicker(WorkerUnhealthyTime / 5)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return cmd, nil
+		case <-ticker.C:
+		}
+		if result, err := pipeline.GetPipelineWorkerCommand(cmd.ID); err == nil && result.Status != pipeline.WorkerCommandPending {
+			return result, nil
+		}
+	}
+}
+
+// 清除本节点命名空间的构建缓存, 用于缓存了错误的层时重建; 使用中的缓存不能清除
+func PurgeBuildCache(cacheType, namespace string) error {
+	dir, err := BuildCacheDir(cacheType, namespace)
+	if err != nil {
+		return err
+	}
+	cacheInUseMu.Lock()
+	defer cacheInUseMu.Unlock()
+	if cacheInUse[dir] > 0 {
+		return fmt.Errorf("构建缓存%s/%s使用中, 请在构建结束后清除", cacheType, namespace)
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("构建缓存%s/%s不存在", cacheType, namespace)
+	}
+	defer markBuildCacheChanged()
+	return os.RemoveAll(dir)
+}
+
+// 按配额淘汰构建缓存, 跳过使用中的缓存, 返回清理的缓存(类型/命名空间):
+// 超出命名空间配额时, BuildKit先删除index.json未引用的blob, 仍超出时删除整个命名空间, Kaniko按修改时间删除最早缓存的镜像;
+// 随后每类缓存总大小超过上限时按最近使用时间删除整个命名空间
+func EvictBuildCaches() ([]string, error) {
+	if !buildCacheEvictMu.TryLock() {
+		return nil, nil
+	}
+	defer buildCacheEvictMu.Unlock()
+	quotas, err := pipeline.GetPipelineBuildCacheQuotas()
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]string, 0)
+	defer func() {
+		if len(removed) > 0 {
+			markBuildCacheChanged()
+		}
+	}()
+	for _, cacheType := range []string{BuildCacheBuildKit, BuildCacheKaniko} {
+		usages, err := buildCacheUsages(cacheType, quotas)
+		if err != nil {
+			return removed, err
+		}
+		var total int64
+		for i := range usages {
+			usage := &usages[i]
+			if usage.Quota > 0 && usage.Size > usage.Quota {
+				size, err := trimBuildCache(usage)
+				if err != nil {
+					return removed, err
+				}
+				if size != usage.Size {
+					removed = append(removed, usage.Type+"/"+usage.Namespace)
+				}
+				usage.Size = size
+			}
+			total += usage.Size
+		}
+		maxSize := global.Conf.Pipeline.BuildCache.MaxSize * 1024 * 1024
+		if maxSize <= 0 || total <= maxSize {
+			continue
+		}
+		sort.Slice(usages, func(i, j int) bool { return usages[i].LastUsed.Before(usages[j].LastUsed) })
+		for _, usage := range usages {
+			if total <= maxSize {
+				break
+			}
+			if usage.Size == 0 {
+				continue
+			}
+			cacheInUseMu.Lock()
+			inUse := cacheInUse[usage.dir] > 0
+			if !inUse {
+				err = os.RemoveAll(usage.dir)
+			}
+			cacheInUseMu.Unlock()
+			if inUse {
+				continue
+			}
+			if err != nil {
+				return removed, err
+			}
+			total -= usage.Size
+			removed = append(removed, usage.Type+"/"+usage.Namespace)
+		}
+	}
+	return removed, nil
+}
+
+// 将命名空间缩减到配额以内, 返回缩减后的大小; 使用中的缓存不处理
+func trimBuildCache(usage *BuildCacheUsage) (int64, error) {
+	cacheInUseMu.Lock()
+	defer cacheInUseMu.Unlock()
+	if cacheInUse[usage.dir] > 0 {
+		return usage.Size, nil
+	}
+	// 删除文件会更新目录修改时间, 结束后恢复最近使用时间
+	defer os.Chtimes(usage.dir, usage.LastUsed, usage.LastUsed)
+	size := usage.Size
+	if usage.Type == BuildCacheBuildKit {
+		freed, err := pruneBuildKitBlobs(usage.dir)
+		if err != nil {
+			global.Log.Error("清理BuildKit缓存未引用的blob失败", "dir", usage.dir, "err", err.Error())
+		}
+		if size -= freed; size <= usage.Quota {
+			return size, nil
+		}
+		return 0, os.RemoveAll(usage.dir)
+	}
+	files, err := kanikoCacheFiles(usage.dir)
+	if err != nil {
+		return size, err
+	}
+	for _, file := range files {
+		if size <= usage.Quota {
+			break
+		}
+		for _, path := range file.paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return size, err
+			}
+		}
+		size -= file.size
+	}
+	return size, nil
+}
+
+type kanikoCacheFile struct {
+	paths   []string
+	size    int64
+	modTime time.Time
+}
+
+// Kaniko缓存的镜像文件, 镜像文件与同名.json一起删除, 按修改时间从早到晚排列
+func kanikoCacheFiles(dir string) ([]kanikoCacheFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	grouped := map[string]*kanikoCacheFile{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size := info.Size()
+		if entry.IsDir() {
+			if size, err = utils.DirSize(filepath.Join(dir, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+		key := strings.TrimSuffix(entry.Name(), ".json")
+		file, ok := grouped[key]
+		if !ok {
+			file = &kanikoCacheFile{}
+			grouped[key] = file
+		}
+		file.paths = append(file.paths, filepath.Join(dir, entry.Name()))
+		file.size += size
+		if info.ModTime().After(file.modTime) {
+			file.modTime = info.ModTime()
+		}
+	}
+	files := make([]kanikoCacheFile, 0, len(grouped))
+	for _, file := range grouped {
+		files = append(files, *file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return files, nil
+}
+
+// OCI描述符及清单中引用其他内容的字段
+type ociDescriptor struct {
+	MediaType string          `json:"mediaType"`
+	Digest    string          `json:"digest"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Config    *ociDescriptor  `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// 删除BuildKit本地缓存中index.json未引用的blob, 返回释放的字节数; 没有index.json时不处理
+func pruneBuildKitBlobs(dir string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var index ociDescriptor
+	if err := json.Unmarshal(data, &index); err != nil {
+		return 0, fmt.Errorf("解析index.json失败: %w", err)
+	}
+	referenced := map[string]bool{}
+	queue := append([]ociDescriptor{}, index.Manifests...)
+	for len(queue) > 0 {
+		desc := queue[0]
+		queue = queue[1:]
+		if referenced[desc.Digest] {
+			continue
+		}
+		referenced[desc.Digest] = true
+		if !strings.Contains(desc.MediaType, "manifest") && !strings.Contains(desc.MediaType, "index") {
+			continue
+		}
+		algorithm, hex, ok := strings.Cut(desc.Digest, ":")
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "blobs", algorithm, hex))
+		if err != nil {
+			// 引用的清单缺失时无法确定引用关系, 不删除
+			return 0, fmt.Errorf("读取清单%s失败: %w", desc.Digest, err)
+		}
+		var manifest ociDescriptor
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return 0, fmt.Errorf("解析清单%s失败: %w", desc.Digest, err)
+		}
+		queue = append(queue, manifest.Manifests...)
+		queue = append(queue, manifest.Layers...)
+		if manifest.Config != nil {
+			queue = append(queue, *manifest.Config)
+		}
+	}
+	algorithms, err := os.ReadDir(filepath.Join(dir, "blobs"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var freed int64
+	for _, algorithm := range algorithms {
+		if !algorithm.IsDir() {
+			continue
+		}
+		blobs, err := os.ReadDir(filepath.Join(dir, "blobs", algorithm.Name()))
+		if err != nil {
+			return freed, err
+		}
+		for _, blob := range blobs {
+			info, err := blob.Info()
+			if err != nil || !info.Mode().IsRegular() || referenced[algorithm.Name()+":"+blob.Name()] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, "blobs", algorithm.Name(), blob.Name())); err != nil {
+				return freed, err
+			}
+			freed += info.Size()
+		}
+	}
+	return freed, nil
+}
@@ -0,0 +1,199 @@
+package flow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// 依赖缓存根目录, 按缓存名称和锁文件哈希分目录: {缓存名称}/{哈希}
+	CacheRoot = "./storage/cache"
+	// 缓存名称只允许字母数字及_-
+	cacheNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// 常用缓存对应的工具环境变量
+	cacheEnvs = map[string]string{
+		"gomod":   "GOMODCACHE",
+		"gobuild": "GOCACHE",
+		"npm":     "npm_config_cache",
+		"yarn":    "YARN_CACHE_FOLDER",
+		"pip":     "PIP_CACHE_DIR",
+	}
+	// 使用中的缓存目录及引用数, 淘汰时跳过
+	cacheInUse   = map[string]int{}
+	cacheInUseMu sync.Mutex
+	// 同一时间只执行一次淘汰
+	evictMu sync.Mutex
+)
+
+// 依赖缓存
+type Cache struct {
+	Name string // 缓存名称
+	Key  string // 锁文件哈希
+	Dir  string // 缓存目录
+	Hit  bool   // 是否命中已有缓存
+}
+
+// 获取依赖缓存, 按工作空间内锁文件内容计算哈希, 相同哈希的运行复用同一目录
+// 使用结束后需调用Release
+func AcquireCache(workspace, name string, lockFiles []string) (*Cache, error) {
+	if !cacheNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("非法的缓存名称: %s", name)
+	}
+	hash := sha256.New()
+	files := append([]string{}, lockFiles...)
+	sort.Strings(files)
+	for _, rel := range files {
+		path, err := WorkspacePath(workspace, rel)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取缓存%s的锁文件%s失败: %w", name, rel, err)
+		}
+		fmt.Fprintf(hash, "%s\n", rel)
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取缓存%s的锁文件%s失败: %w", name, rel, err)
+		}
+	}
+	key := hex.EncodeToString(hash.Sum(nil))[:16]
+	dir, err := filepath.Abs(filepath.Join(CacheRoot, name, key))
+	if err != nil {
+		return nil, err
+	}
+	cache := &Cache{Name: name, Key: key, Dir: dir}
+	cacheInUseMu.Lock()
+	defer cacheInUseMu.Unlock()
+	if _, err := os.Stat(dir); err == nil {
+		cache.Hit = true
+	} else if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	cacheInUse[dir]++
+	touchCache(dir)
+	return cache, nil
+}
+
+// 释放缓存并更新最近使用时间, 随后按总大小上限淘汰
+func (c *Cache) Release() {
+	cacheInUseMu.Lock()
+	cacheInUse[c.Dir]--
+	if cacheInUse[c.Dir] <= 0 {
+		delete(cacheInUse, c.Dir)
+	}
+	touchCache(c.Dir)
+	cacheInUseMu.Unlock()
+	go func() {
+		if _, err := EvictCaches(); err != nil {
+			global.Log.Error("淘汰依赖缓存失败", "err", err.Error())
+		}
+	}()
+}
+
+// 缓存目录环境变量, 常用缓存同时设置对应工具的环境变量
+func (c *Cache) Env() map[string]string {
+	env := map[string]string{
+		"CDMS_CACHE_" + strings.ToUpper(strings.ReplaceAll(c.Name, "-", "_")): c.Dir,
+	}
+	if name, ok := cacheEnvs[c.Name]; ok {
+		env[name] = c.Dir
+	}
+	return env
+}
+
+// 以目录修改时间记录最近使用时间
+func touchCache(dir string) {
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		global.Log.Error("更新缓存使用时间失败", "dir", dir, "err", err.Error())
+	}
+}
+
+type cacheEntry struct {
+	dir      string
+	size     int64
+	lastUsed time.Time
+}
+
+// 缓存总大小超过上限时按最近使用时间淘汰, 返回删除的缓存目录
+func EvictCaches() ([]string, error) {
+	maxSize := global.Conf.Pipeline.Cache.MaxSize * 1024 * 1024
+	if maxSize <= 0 {
+		return nil, nil
+	}
+	if !evictMu.TryLock() {
+		return nil, nil
+	}
+	defer evictMu.Unlock()
+	names, err := os.ReadDir(CacheRoot)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]cacheEntry, 0)
+	var total int64
+	for _, name := range names {
+		if !name.IsDir() {
+			continue
+		}
+		keys, err := os.ReadDir(filepath.Join(CacheRoot, name.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			info, err := key.Info()
+			if err != nil || !key.IsDir() {
+				continue
+			}
+			dir, err := filepath.Abs(filepath.Join(CacheRoot, name.Name(), key.Name()))
+			if err != nil {
+				return nil, err
+			}
+			size, err := utils.DirSize(dir)
+			if err != nil {
+				return nil, err
+			}
+			total += size
+			entries = append(entries, cacheEntry{dir: dir, size: size, lastUsed: info.ModTime()})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+	removed := make([]string, 0)
+	for _, entry := range entries {
+		if total <= maxSize {
+			break
+		}
+		cacheInUseMu.Lock()
+		inUse := cacheInUse[entry.dir] > 0
+		if !inUse {
+			err = os.RemoveAll(entry.dir)
+		}
+		cacheInUseMu.Unlock()
+		if inUse {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		total -= entry.size
+		removed = append(removed, entry.dir)
+	}
+	return removed, nil
+}
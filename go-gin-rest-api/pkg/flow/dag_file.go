@@ -0,0 +1,372 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/git"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/mod"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// 流水线即代码文件, 位于仓库根目录, 格式同entity.Dag的yaml定义
+const DagFile = ".cdms.yml"
+
+// webhook触发
+const TriggerWebhook entity.Trigger = "webhook"
+
+// fastflow任务表主键长度上限, 按提交固定的任务ID为<流水线ID>.<任务ID>
+const maxTaskIDLength = 191
+
+var (
+	// 任务ID只允许字母数字及_-
+	taskIDRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// yaml.v3的错误信息, 如yaml: line 3: did not find expected key
+	yamlLineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.+)$`)
+)
+
+// 流水线文件校验错误, Line为0表示无法定位到行
+type DagFileError struct {
+	Line    int    `json:"line"`    // 行号
+	Column  int    `json:"column"`  // 列号
+	Message string `json:"message"` // 错误信息
+}
+
+func (e DagFileError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("第%d行: %s", e.Line, e.Message)
+}
+
+// 流水线文件校验错误列表
+type DagFileErrors []DagFileError
+
+func (e DagFileErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return DagFile + "校验失败: " + strings.Join(messages, "; ")
+}
+
+func (e *DagFileErrors) add(node *yaml.Node, format string, args ...interface{}) {
+	err := DagFileError{Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		err.Line, err.Column = node.Line, node.Column
+	}
+	*e = append(*e, err)
+}
+
+// 解析并校验流水线文件, 校验失败时返回带行号的DagFileErrors; 文件中的id被忽略, 由调用方按提交生成
+func ParseDagFile(data []byte) (*entity.Dag, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, yamlErrors(err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, DagFileErrors{{Line: 1, Message: "文件为空"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, DagFileErrors{{Line: root.Line, Column: root.Column, Message: "顶层须为流水线定义对象"}}
+	}
+	dag := entity.NewDag()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(dag); err != nil {
+		return nil, yamlErrors(err)
+	}
+	if errs := validateDag(dag, root); len(errs) > 0 {
+		return nil, errs
+	}
+	dag.Status = entity.DagStatusNormal
+	return dag, nil
+}
+
+// 将yaml.v3的语法和类型错误转换为带行号的错误
+func yamlErrors(err error) DagFileErrors {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+	errs := make(DagFileErrors, 0, len(messages))
+	for _, message := range messages {
+		if m := yamlLineRegexp.FindStringSubmatch(message); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			errs = append(errs, DagFileError{Line: line, Message: m[2]})
+			continue
+		}
+		errs = append(errs, DagFileError{Message: strings.TrimPrefix(message, "yaml: ")})
+	}
+	return errs
+}
+
+// 校验流水线定义, root为文件顶层对象, 用于定位行号
+func validateDag(dag *entity.Dag, root *yaml.Node) DagFileErrors {
+	var errs DagFileErrors
+	if dag.Cron != "" {
+		errs.add(yamlValue(root, "cron"), "按提交固定的流水线不支持cron定时运行")
+	}
+	varsNode := yamlValue(root, "vars")
+	for name := range dag.Vars {
+		if !varNameRegexp.MatchString(name) {
+			errs.add(yamlKey(varsNode, name), "非法的流水线参数名%s, 只允许字母数字及._-", name)
+		}
+	}
+	tasksNode := yamlValue(root, "tasks")
+	if len(dag.Tasks) == 0 {
+		if tasksNode == nil {
+			tasksNode = root
+		}
+		errs.add(tasksNode, "至少定义一个任务")
+		return errs
+	}
+	ids := make(map[string]bool, len(dag.Tasks))
+	for _, task := range dag.Tasks {
+		ids[task.ID] = true
+	}
+	seen := make(map[string]bool, len(dag.Tasks))
+	for i, task := range dag.Tasks {
+		node := tasksNode.Content[i]
+		switch {
+		case task.ID == "":
+			errs.add(node, "第%d个任务缺少id", i+1)
+		case !taskIDRegexp.MatchString(task.ID):
+			errs.add(yamlValue(node, "id"), "非法的任务id %s, 只允许字母数字及_-", task.ID)
+		case seen[task.ID]:
+			errs.add(yamlValue(node, "id"), "任务id %s重复", task.ID)
+		}
+		seen[task.ID] = true
+		if task.ActionName == "" {
+			errs.add(node, "任务%s缺少actionName", task.ID)
+		} else if _, ok := mod.ActionMap[task.ActionName]; !ok {
+			errs.add(yamlValue(node, "actionName"), "任务%s的动作%s不存在", task.ID, task.ActionName)
+		}
+		if task.TimeoutSecs < 0 {
+			errs.add(yamlValue(node, "timeoutSecs"), "任务%s的timeoutSecs不能为负数", task.ID)
+		}
+		dependNode := yamlValue(node, "dependOn")
+		for j, depend := range task.DependOn {
+			var dependItem *yaml.Node
+			if dependNode != nil && dependNode.Kind == yaml.SequenceNode && j < len(dependNode.Content) {
+				dependItem = dependNode.Content[j]
+			}
+			switch {
+			case depend == task.ID:
+				errs.add(dependItem, "任务%s不能依赖自身", task.ID)
+			case !ids[depend]:
+				errs.add(dependItem, "任务%s依赖的任务%s不存在", task.ID, depend)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if _, err := mod.BuildRootNode(mod.MapTasksToGetter(dag.Tasks)); err != nil {
+		errs.add(tasksNode, "任务依赖校验失败: %s", err)
+	}
+	return errs
+}
+
+// 对象中键对应的值节点
+func yamlValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// 对象中的键节点
+func yamlKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return node
+}
+
+// 按提交固定的流水线ID, <代码仓库名称>-<提交前12位>
+func DagFileID(repo, commit string) string {
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return repo + "-" + commit
+}
+
+// 流水线即代码的触发参数
+type DagFileRun struct {
+	Repo    *pipeline.PipelineGitRepo // 代码仓库
+	Ref     string                    // 分支、标签或提交, 为空时使用默认分支
+	Commit  string                    // 提交, 如webhook推送后的提交, 为空时解析Ref
+	Vars    map[string]string         // 流水线参数
+	Trigger entity.Trigger            // 触发方式
+	Caller  *pipeline.PipelineCaller  // 调用方, 在创建流水线实例前保存, 供部署准入策略判断, 为空时只记录分支
+}
+
+// 流水线即代码的运行结果, 流水线文件缺失或校验失败时流水线实例为失败状态, Errors为校验错误
+type DagFileResult struct {
+	Commit string              `json:"commit"` // 提交
+	DagIns *entity.DagInstance `json:"dagIns"` // 流水线实例
+	Errors DagFileErrors       `json:"errors"` // 校验错误
+}
+
+// 从代码仓库本地裸镜像读取提交中的流水线文件, 校验后创建/更新按提交固定的流水线并运行;
+// 文件缺失或校验失败时记录失败的流水线实例, 失败原因为带行号的校验错误
+func RunDagFile(ctx context.Context, r DagFileRun) (*DagFileResult, error) {
+	credential, err := r.Repo.Credential()
+	if err != nil {
+		return nil, err
+	}
+	auth, err := credential.Auth()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := git.Mirror(ctx, r.Repo.URL, auth)
+	if err != nil {
+		return nil, err
+	}
+	ref := r.Ref
+	if ref == "" {
+		ref = r.Repo.DefaultBranch
+	}
+	rev := r.Commit
+	if rev == "" {
+		rev = ref
+	}
+	commit, err := git.ResolveCommit(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	result := &DagFileResult{Commit: commit.String()}
+	id := DagFileID(r.Repo.Name, result.Commit)
+	pinned := dagFileVars(repo, r.Repo, ref, result.Commit)
+	// 分支按解析的引用记录在调用方中, 部署准入策略的build.branch取该值
+	caller := r.Caller
+	if caller == nil {
+		caller = &pipeline.PipelineCaller{}
+	}
+	caller.Branch = pinned["branch"]
+	vars := make(map[string]string, len(r.Vars)+len(pinned))
+	for key, value := range r.Vars {
+		vars[key] = value
+	}
+	for key, value := range pinned {
+		vars[key] = value
+	}
+	var dag *entity.Dag
+	data, err := git.ReadFile(repo, commit, DagFile)
+	switch {
+	case errors.Is(err, object.ErrFileNotFound):
+		result.Errors = DagFileErrors{{Message: fmt.Sprintf("提交%s中没有%s", result.Commit, DagFile)}}
+	case err != nil:
+		return nil, err
+	default:
+		dag, err = ParseDagFile(data)
+		if err != nil && !errors.As(err, &result.Errors) {
+			return nil, err
+		}
+	}
+	if len(result.Errors) == 0 {
+		result.Errors = pinDag(dag, id, pinned)
+	}
+	if len(result.Errors) > 0 {
+		dagIns := &entity.DagInstance{
+			DagID:     id,
+			Trigger:   r.Trigger,
+			Vars:      entity.DagInstanceVars{},
+			ShareData: &entity.ShareData{},
+			Status:    entity.DagInstanceStatusFailed,
+			Reason:    result.Errors.Error(),
+		}
+		for key, value := range vars {
+			dagIns.Vars[key] = entity.DagInstanceVar{Value: value}
+		}
+		if err := CreateDagInsWithCaller(dagIns, caller); err != nil {
+			return nil, err
+		}
+		result.DagIns = dagIns
+		return result, nil
+	}
+	if _, err := mod.GetStore().GetDag(id); errors.Is(err, gorm.ErrRecordNotFound) {
+		err = mod.GetStore().CreateDag(dag)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if err := mod.GetStore().UpdateDag(dag); err != nil {
+		return nil, err
+	}
+	dagIns, err := dag.Run(r.Trigger, vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := CreateDagInsWithCaller(dagIns, caller); err != nil {
+		return nil, err
+	}
+	result.DagIns = dagIns
+	return result, nil
+}
+
+// 流水线即代码自动注入的参数: 仓库地址、代码仓库名称、触发引用、提交, 以及分支或标签
+func dagFileVars(repo *gogit.Repository, gitRepo *pipeline.PipelineGitRepo, ref, commit string) map[string]string {
+	// branch和tag总是注入, 不能由调用方传入的参数指定
+	vars := map[string]string{"repo": gitRepo.URL, "gitRepo": gitRepo.Name, "ref": ref, "commit": commit, "branch": "", "tag": ""}
+	if _, err := repo.Reference(plumbing.NewBranchReferenceName(ref), false); err == nil {
+		vars["branch"] = ref
+	} else if _, err := repo.Reference(plumbing.NewTagReferenceName(ref), false); err == nil {
+		vars["tag"] = ref
+	}
+	return vars
+}
+
+// 固定流水线到提交: 设置流水线ID, 任务ID加流水线ID前缀以免与其他流水线冲突, 注入的参数作为默认值
+func pinDag(dag *entity.Dag, id string, pinned map[string]string) DagFileErrors {
+	var errs DagFileErrors
+	dag.ID = id
+	if dag.Name == "" {
+		dag.Name = id
+	}
+	if dag.Vars == nil {
+		dag.Vars = entity.DagVars{}
+	}
+	for key, value := range pinned {
+		v := dag.Vars[key]
+		v.DefaultValue = value
+		dag.Vars[key] = v
+	}
+	for i := range dag.Tasks {
+		task := &dag.Tasks[i]
+		task.ID = id + "." + task.ID
+		if len(task.ID) > maxTaskIDLength {
+			errs = append(errs, DagFileError{Message: fmt.Sprintf("任务ID %s超过%d个字符, 请缩短代码仓库名称或任务id", task.ID, maxTaskIDLength)})
+		}
+		task.DagID = id
+		for j := range task.DependOn {
+			task.DependOn[j] = id + "." + task.DependOn[j]
+		}
+	}
+	return errs
+}
@@ -0,0 +1,255 @@
+package flow
+
+import (
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/global"
+	"go-gin-rest-api/pkg/registry"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/entity/run"
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// 部署任务声明部署目标的参数名
+const KeyDeploy = "deploy"
+
+// 构建产物的输出名称约定, 部署时从构建记录的输出写入部署记录
+const (
+	OutputImage        = "image"
+	OutputImageDigest  = "imageDigest"
+	OutputCommit       = "commit"
+	OutputVersion      = "version"
+	OutputCommitTime   = "commitTime"   // 提交时间, RFC3339格式或unix秒, 用于计算变更前置时间, 没有时不计入变更前置时间
+	OutputReleaseNotes = "releaseNotes" // 发布说明, 由release-notes动作生成
+)
+
+// 开始部署: 检查冻结窗口、部署准入策略和镜像签名并创建部署记录, 被拦截时记录为blocked并返回错误
+// 部署结束后需调用FinishDeploy更新部署记录
+func (m *TaskMeta) StartDeploy(ctx run.ExecuteContext, target DeployTarget) (*pipeline.PipelineDeployment, error) {
+	if target.Env == "" {
+		return nil, fmt.Errorf("部署任务未设置目标环境env")
+	}
+	if target.App == "" {
+		target.App = m.DagID
+	}
+	otherBuild := target.Build != "" && target.Build != m.DagInsID
+	if target.Build == "" {
+		target.Build = m.DagInsID
+	}
+	build, err := BuildRecord(target.Build)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := pipeline.GetPipelineCaller(m.DagInsID)
+	if err != nil {
+		return nil, err
+	}
+	deployment := &pipeline.PipelineDeployment{
+		DagID:     m.DagID,
+		DagInsID:  m.DagInsID,
+		TaskID:    m.TaskID,
+		TaskInsID: m.TaskInsID,
+		Env:       target.Env,
+		App:       target.App,
+		Build:     target.Build,
+		Status:    pipeline.DeploymentRunning,
+		StartedAt: time.Now(),
+	}
+	outputs, ok := build["outputs"].(map[string]string)
+	if ok {
+		deployment.Image = outputs[OutputImage]
+		deployment.Digest = outputs[OutputImageDigest]
+		deployment.Commit = outputs[OutputCommit]
+		deployment.Version = outputs[OutputVersion]
+		deployment.CommitAt = parseCommitTime(outputs[OutputCommitTime])
+		deployment.ReleaseNotes = outputs[OutputReleaseNotes]
+	}
+	if target.Image != "" {
+		deployment.Image, deployment.Digest = target.Image, target.Digest
+	}
+	if caller != nil {
+		deployment.AppId = caller.AppId
+		deployment.User = caller.User
+	}
+	// 指定其他构建记录和镜像时, 镜像须为该构建产出的镜像, 否则准入策略判断的构建与实际部署的镜像不一致
+	if otherBuild && target.Image != "" {
+		err = checkBuildImage(target, outputs)
+	}
+	if err == nil {
+		err = checkFreeze(ctx, deployment, caller)
+	}
+	if err == nil {
+		err = admitDeploy(ctx, target, build, CallerInfo(m.DagInsID, caller))
+	}
+	if err == nil {
+		err = verifyDeploySignature(ctx, target, deployment)
+	}
+	if err != nil {
+		now := time.Now()
+		deployment.Status = pipeline.DeploymentBlocked
+		deployment.Reason = err.Error()
+		deployment.FinishedAt = &now
+		if saveErr := pipeline.CreatePipelineDeployment(deployment); saveErr != nil {
+			ctx.Tracef("保存部署记录失败: %s", saveErr)
+		}
+		return nil, err
+	}
+	if err := pipeline.CreatePipelineDeployment(deployment); err != nil {
+		return nil, fmt.Errorf("保存部署记录失败: %w", err)
+	}
+	return deployment, nil
+}
+
+// 检查部署镜像与构建记录的image/imageDigest输出是否为同一镜像
+func checkBuildImage(target DeployTarget, outputs map[string]string) error {
+	image, err := registry.ParseDigest(target.Image, target.Digest, registry.Auth{})
+	if err != nil {
+		return err
+	}
+	built, err := registry.ParseDigest(outputs[OutputImage], outputs[OutputImageDigest], registry.Auth{})
+	if err != nil {
+		return fmt.Errorf("构建记录%s没有镜像摘要输出, 不能指定部署镜像%s", target.Build, target.Image)
+	}
+	if image.Context().Name() != built.Context().Name() || image.DigestStr() != built.DigestStr() {
+		return fmt.Errorf("部署镜像%s与构建记录%s的镜像%s不一致", image, target.Build, built)
+	}
+	return nil
+}
+
+// 解析提交时间, 支持RFC3339格式和unix秒, 如git log -1 --format=%cI或%ct的输出
+func parseCommitTime(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+		t := time.Unix(seconds, 0)
+		return &t
+	}
+	return nil
+}
+
+// 结束部署, 按部署结果更新部署记录
+func FinishDeploy(ctx run.ExecuteContext, deployment *pipeline.PipelineDeployment, deployErr error) {
+	if deployment == nil {
+		return
+	}
+	status, reason := pipeline.DeploymentSuccess, ""
+	if deployErr != nil {
+		status, reason = pipeline.DeploymentFailed, deployErr.Error()
+	}
+	if err := pipeline.FinishPipelineDeployment(deployment, status, reason); err != nil {
+		ctx.Tracef("更新部署记录失败: %s", err)
+	}
+}
+
+// 检查冻结窗口, 冻结期间只有拥有破窗角色且填写了破窗原因的调用方可以部署
+func checkFreeze(ctx run.ExecuteContext, deployment *pipeline.PipelineDeployment, caller *pipeline.PipelineCaller) error {
+	freezes, err := pipeline.GetActivePipelineFreezes(deployment.Env, deployment.App, deployment.StartedAt)
+	if err != nil {
+		return fmt.Errorf("获取部署冻结窗口失败: %w", err)
+	}
+	if len(freezes) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(freezes))
+	for _, freeze := range freezes {
+		names = append(names, fmt.Sprintf("%s(%s至%s)", freeze.Name, freeze.Start.Format(time.DateTime), freeze.End.Format(time.DateTime)))
+	}
+	if caller != nil && caller.HasRole(pipeline.BreakGlassRole) && strings.TrimSpace(caller.OverrideReason) != "" {
+		deployment.Override = true
+		deployment.OverrideReason = caller.OverrideReason
+		ctx.Tracef("冻结期间破窗部署: 冻结窗口%s, 调用方%s, 操作人%s, 原因: %s", strings.Join(names, ", "), caller.AppId, caller.User, caller.OverrideReason)
+		return nil
+	}
+	for _, freeze := range freezes {
+		ctx.Tracef("部署冻结窗口[%s]生效中(%s至%s): %s", freeze.Name, freeze.Start.Format(time.DateTime), freeze.End.Format(time.DateTime), freeze.Reason)
+	}
+	return fmt.Errorf("环境%s应用%s处于部署冻结期: %s", deployment.Env, deployment.App, strings.Join(names, ", "))
+}
+
+// 获取待执行的部署: 未结束且尚未开始部署的流水线实例中声明了deploy参数的任务
+func ScheduledDeployments(env, app string) ([]pipeline.PipelineDeployment, error) {
+	list := make([]entity.DagInstance, 0)
+	err := global.Mysql.Table(DagInsTable).
+		Where("status IN ?", []entity.DagInstanceStatus{entity.DagInstanceStatusInit, entity.DagInstanceStatusScheduled, entity.DagInstanceStatusRunning}).
+		Order("created_at").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(list))
+	for _, dagIns := range list {
+		ids = append(ids, dagIns.ID)
+	}
+	started, err := pipeline.GetDeployedDagInsIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	dags := map[string]*entity.Dag{}
+	deployments := make([]pipeline.PipelineDeployment, 0)
+	for _, dagIns := range list {
+		if started[dagIns.ID] {
+			continue
+		}
+		dag, ok := dags[dagIns.DagID]
+		if !ok {
+			if dag, err = mod.GetStore().GetDag(dagIns.DagID); err != nil {
+				global.Log.Warn("获取流水线失败", "dagId", dagIns.DagID, "err", err.Error())
+			}
+			dags[dagIns.DagID] = dag
+		}
+		if dag == nil {
+			continue
+		}
+		for _, task := range dag.Tasks {
+			target, ok := deployTargetParam(dagIns.Vars, task.Params)
+			if !ok {
+				continue
+			}
+			if target.App == "" {
+				target.App = dagIns.DagID
+			}
+			if (env != "" && target.Env != env) || (app != "" && target.App != app) {
+				continue
+			}
+			deployments = append(deployments, pipeline.PipelineDeployment{
+				DagID:     dagIns.DagID,
+				DagInsID:  dagIns.ID,
+				TaskID:    task.ID,
+				Env:       target.Env,
+				App:       target.App,
+				Status:    pipeline.DeploymentScheduled,
+				StartedAt: time.Unix(dagIns.CreatedAt, 0),
+			})
+		}
+	}
+	return deployments, nil
+}
+
+// 从任务参数中取部署目标, 按流水线实例参数替换{{变量}}
+func deployTargetParam(vars entity.DagInstanceVars, params entity.StringMap) (DeployTarget, bool) {
+	deploy, ok := params[KeyDeploy].(map[string]interface{})
+	if !ok {
+		return DeployTarget{}, false
+	}
+	fields := map[string]interface{}{}
+	for _, key := range []string{"env", "app"} {
+		if value, ok := deploy[key].(string); ok {
+			fields[key] = value
+		}
+	}
+	fields, err := vars.Render(fields)
+	if err != nil {
+		return DeployTarget{}, false
+	}
+	env, _ := fields["env"].(string)
+	app, _ := fields["app"].(string)
+	return DeployTarget{Env: env, App: app}, env != ""
+}
@@ -0,0 +1,29 @@
+package flow
+
+import (
+	"go-gin-rest-api/pkg/global"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// 执行器装饰, 在任务交给fastflow默认执行器之前做统一处理
+type Executor struct {
+	mod.Executor
+}
+
+// 替换fastflow的执行器, 需在fastflow.Init之后调用
+func InstallExecutor() {
+	mod.SetExecutor(&Executor{Executor: mod.GetExecutor()})
+}
+
+// 推送任务
+func (e *Executor) Push(dagIns *entity.DagInstance, taskIns *entity.TaskInstance) {
+	injectMeta(dagIns, taskIns)
+	// 未设置超时时间的任务使用配置的默认值, 支持热加载
+	if taskIns.TimeoutSecs == 0 && global.Conf.Pipeline.ExecutorTimeout > 0 {
+		taskIns.TimeoutSecs = global.Conf.Pipeline.ExecutorTimeout
+	}
+	observeTaskPush(dagIns, taskIns)
+	e.Executor.Push(dagIns, taskIns)
+}
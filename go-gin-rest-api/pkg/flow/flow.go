@@ -0,0 +1,39 @@
+// 流水线引擎(fastflow)的扩展: 任务元数据注入、任务输出、实例查询等
+package flow
+
+import (
+	"fmt"
+	"go-gin-rest-api/pkg/global"
+	"regexp"
+
+	"github.com/linclin/fastflow/pkg/entity"
+)
+
+// fastflow存储和keeper的表前缀
+const TablePrefix = "pipeline"
+
+var (
+	// fastflow流水线实例表
+	DagInsTable = TablePrefix + "_dag_instance"
+	// fastflow任务实例表
+	TaskInsTable = TablePrefix + "_task_instance"
+	// 流水线参数名只允许字母数字及._-, 用于拼接JSON路径
+	varNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// 获取流水线最近一次成功的实例, vars不为空时按流水线参数过滤
+func LatestSuccessDagIns(dagId string, vars map[string]string) (*entity.DagInstance, error) {
+	query := global.Mysql.Table(DagInsTable).Where("dag_id = ? AND status = ?", dagId, entity.DagInstanceStatusSuccess)
+	for key, value := range vars {
+		if !varNameRegexp.MatchString(key) {
+			return nil, fmt.Errorf("非法的流水线参数名: %s", key)
+		}
+		query = query.Where(fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(vars, '$.\"%s\".value')) = ?", key), value)
+	}
+	dagIns := new(entity.DagInstance)
+	err := query.Order("updated_at DESC").First(dagIns).Error
+	if err != nil {
+		return nil, err
+	}
+	return dagIns, nil
+}
@@ -0,0 +1,57 @@
+package flow
+
+import (
+	"context"
+	"go-gin-rest-api/pkg/global"
+	"sync"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/event"
+	"github.com/shiningrush/goevent"
+)
+
+// 本节点执行中的任务实例
+var localRunningTasks sync.Map
+
+// 启动任务心跳, 需在fastflow.Init之前调用
+// fastflow主节点的watchdog会把5秒内没有更新的执行中任务判定为超时失败, 长时间执行的任务需要定时刷新更新时间
+func StartTaskHeartbeat() {
+	if err := goevent.Subscribe(&taskHeartbeatHandler{}); err != nil {
+		global.Log.Error("订阅流水线任务事件失败", "err", err.Error())
+		return
+	}
+	go func() {
+		for range time.Tick(WorkerUnhealthyTime / 2) {
+			ids := make([]string, 0)
+			localRunningTasks.Range(func(key, value any) bool {
+				ids = append(ids, key.(string))
+				return true
+			})
+			if len(ids) == 0 {
+				continue
+			}
+			err := global.Mysql.Table(TaskInsTable).
+				Where("id IN ? AND status = ?", ids, entity.TaskInstanceStatusRunning).
+				Update("updated_at", time.Now().Unix()).Error
+			if err != nil {
+				global.Log.Error("刷新流水线任务心跳失败", "err", err.Error())
+			}
+		}
+	}()
+}
+
+type taskHeartbeatHandler struct{}
+
+func (h *taskHeartbeatHandler) Topic() []string {
+	return []string{event.KeyTaskBegin, event.KeyTaskCompleted}
+}
+
+func (h *taskHeartbeatHandler) Handle(ctx context.Context, e goevent.Event) {
+	switch taskEvent := e.(type) {
+	case *event.TaskBegin:
+		localRunningTasks.Store(taskEvent.TaskIns.ID, struct{}{})
+	case *event.TaskCompleted:
+		localRunningTasks.Delete(taskEvent.TaskIns.ID)
+	}
+}
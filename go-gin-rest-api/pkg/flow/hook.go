@@ -0,0 +1,54 @@
+package flow
+
+import (
+	"github.com/linclin/fastflow/pkg/entity"
+)
+
+// fastflow只支持设置一个生命周期钩子, 这里汇总各模块注册的钩子
+var dagInsHooks []entity.DagInstanceLifecycleHook
+
+// 注册流水线实例生命周期钩子, 需在fastflow.Init之前调用
+func RegisterDagInsHook(hook entity.DagInstanceLifecycleHook) {
+	dagInsHooks = append(dagInsHooks, hook)
+}
+
+// 汇总后的生命周期钩子, 通过fastflow.SetDagInstanceLifecycleHook设置
+func LifecycleHook() entity.DagInstanceLifecycleHook {
+	return entity.DagInstanceLifecycleHook{
+		BeforeRun: func(dagIns *entity.DagInstance) {
+			for _, hook := range dagInsHooks {
+				if hook.BeforeRun != nil {
+					hook.BeforeRun(dagIns)
+				}
+			}
+		},
+		BeforeSuccess: func(dagIns *entity.DagInstance) {
+			for _, hook := range dagInsHooks {
+				if hook.BeforeSuccess != nil {
+					hook.BeforeSuccess(dagIns)
+				}
+			}
+		},
+		BeforeFail: func(dagIns *entity.DagInstance) {
+			for _, hook := range dagInsHooks {
+				if hook.BeforeFail != nil {
+					hook.BeforeFail(dagIns)
+				}
+			}
+		},
+		BeforeBlock: func(dagIns *entity.DagInstance) {
+			for _, hook := range dagInsHooks {
+				if hook.BeforeBlock != nil {
+					hook.BeforeBlock(dagIns)
+				}
+			}
+		},
+		BeforeRetry: func(dagIns *entity.DagInstance) {
+			for _, hook := range dagInsHooks {
+				if hook.BeforeRetry != nil {
+					hook.BeforeRetry(dagIns)
+				}
+			}
+		},
+	}
+}
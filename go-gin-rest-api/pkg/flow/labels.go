@@ -0,0 +1,92 @@
+package flow
+
+import (
+	"fmt"
+	"go-gin-rest-api/pkg/global"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/linclin/fastflow/pkg/entity"
+)
+
+// 任务参数中声明要求的worker标签, 值可以是{"docker":"true"}或"docker=true,arch=arm64"
+const KeyWorkerLabels = "workerLabels"
+
+// 解析"k1=v1,k2=v2"格式的标签
+func ParseLabels(s string) map[string]string {
+	labels := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(item, "=")
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels
+}
+
+// 标签格式化为按键排序的"k1=v1,k2=v2"
+func FormatLabels(labels map[string]string) string {
+	items := make([]string, 0, len(labels))
+	for key, value := range labels {
+		items = append(items, key+"="+value)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// labels是否满足required的全部标签
+func MatchLabels(required, labels map[string]string) bool {
+	for key, value := range required {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// 本节点的worker标签: 自动附加os和arch, 叠加配置文件和环境变量PipelineLabels, 每次读取最新配置
+func WorkerLabels() map[string]string {
+	labels := map[string]string{
+		"os":   runtime.GOOS,
+		"arch": runtime.GOARCH,
+	}
+	for key, value := range global.Conf.Pipeline.Labels {
+		labels[key] = value
+	}
+	for key, value := range ParseLabels(os.Getenv("PipelineLabels")) {
+		labels[key] = value
+	}
+	return labels
+}
+
+// 流水线全部任务要求的worker标签, 同一标签要求不同值时返回错误
+func DagRequiredLabels(dag *entity.Dag) (map[string]string, error) {
+	required := map[string]string{}
+	for _, task := range dag.Tasks {
+		for key, value := range taskRequiredLabels(task.Params) {
+			if v, ok := required[key]; ok && v != value {
+				return nil, fmt.Errorf("任务%s要求的worker标签%s=%s与其他任务冲突(%s=%s)", task.ID, key, value, key, v)
+			}
+			required[key] = value
+		}
+	}
+	return required, nil
+}
+
+func taskRequiredLabels(params entity.StringMap) map[string]string {
+	switch value := params[KeyWorkerLabels].(type) {
+	case string:
+		return ParseLabels(value)
+	case map[string]interface{}:
+		labels := make(map[string]string, len(value))
+		for k, v := range value {
+			labels[k] = fmt.Sprint(v)
+		}
+		return labels
+	}
+	return nil
+}
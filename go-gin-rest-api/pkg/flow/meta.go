@@ -0,0 +1,52 @@
+package flow
+
+import (
+	"github.com/linclin/fastflow/pkg/entity"
+)
+
+// 执行器注入到任务参数中的元数据键
+const (
+	KeyDagID     = "_dagId"
+	KeyDagInsID  = "_dagInsId"
+	KeyTaskID    = "_taskId"
+	KeyTaskInsID = "_taskInsId"
+	KeyWorkspace = "_workspace"
+)
+
+// 任务元数据, fastflow的ExecuteContext不包含实例信息, 由执行器在推送任务时写入任务参数
+// action的参数结构体通过`json:",squash"`内嵌即可获取
+type TaskMeta struct {
+	DagID     string   `json:"_dagId"`     // 流水线ID
+	DagInsID  string   `json:"_dagInsId"`  // 流水线实例ID
+	TaskID    string   `json:"_taskId"`    // 任务ID
+	TaskInsID string   `json:"_taskInsId"` // 任务实例ID
+	Workspace string   `json:"_workspace"` // 流水线实例工作空间目录
+	Outputs   []string `json:"outputs"`    // 任务声明的输出名称, 为空时记录action产生的全部输出
+}
+
+// 写入任务元数据
+func injectMeta(dagIns *entity.DagInstance, taskIns *entity.TaskInstance) {
+	if taskIns.Params == nil {
+		taskIns.Params = entity.StringMap{}
+	}
+	taskIns.Params[KeyDagID] = dagIns.DagID
+	taskIns.Params[KeyDagInsID] = dagIns.ID
+	taskIns.Params[KeyTaskID] = taskIns.TaskID
+	taskIns.Params[KeyTaskInsID] = taskIns.ID
+	if workspace, err := workspacePath(dagIns.ID); err == nil {
+		taskIns.Params[KeyWorkspace] = workspace
+	}
+}
+
+// 是否声明了该输出
+func (m *TaskMeta) declared(name string) bool {
+	if len(m.Outputs) == 0 {
+		return true
+	}
+	for _, output := range m.Outputs {
+		if output == name {
+			return true
+		}
+	}
+	return false
+}
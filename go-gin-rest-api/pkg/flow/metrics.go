@@ -0,0 +1,191 @@
+package flow
+
+import (
+	"context"
+	"go-gin-rest-api/pkg/global"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/event"
+	"github.com/linclin/fastflow/pkg/mod"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shiningrush/goevent"
+)
+
+var (
+	dagInsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_dag_instance_total",
+		Help: "流水线实例结束数量",
+	}, []string{"dag_id", "trigger", "status"})
+	dagInsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_dag_instance_duration_seconds",
+		Help:    "流水线实例从创建到结束的耗时(秒)",
+		Buckets: []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, []string{"dag_id", "status"})
+	taskInsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_task_instance_total",
+		Help: "任务实例执行结束数量",
+	}, []string{"action", "status"})
+	taskInsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_task_instance_duration_seconds",
+		Help:    "任务实例执行耗时(秒)",
+		Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"action", "status"})
+
+	dagInsStatusDesc = prometheus.NewDesc("pipeline_dag_instances", "各状态的流水线实例数量(全局)", []string{"status"}, nil)
+	queueDepthDesc   = prometheus.NewDesc("pipeline_executor_queue_depth", "已推送给执行器尚未开始执行的任务数量", []string{"worker"}, nil)
+	runningTaskDesc  = prometheus.NewDesc("pipeline_executor_running_tasks", "执行器正在执行的任务数量", []string{"worker"}, nil)
+	workerUpDesc     = prometheus.NewDesc("pipeline_worker_up", "keeper记录的worker是否存活", []string{"worker"}, nil)
+	workerLeaderDesc = prometheus.NewDesc("pipeline_worker_leader", "keeper记录的worker是否主节点", []string{"worker"}, nil)
+
+	// 已推送尚未开始执行的任务, 任务实例ID -> 过期时间; 执行器关闭、重复推送或状态不可执行时fastflow不会发出开始事件,
+	// 用集合去重并按过期时间清理, 避免排队数只增不减
+	executorQueued  sync.Map
+	executorRunning atomic.Int64
+	// 任务开始时间
+	taskBeginTime sync.Map
+)
+
+// 初始化流水线指标, 指标注册到prometheus默认registry, 由/metrics接口统一输出
+// 需在fastflow.Init之前调用
+func InitMetrics() {
+	prometheus.MustRegister(dagInsTotal, dagInsDuration, taskInsTotal, taskInsDuration, &stateCollector{})
+	RegisterDagInsHook(entity.DagInstanceLifecycleHook{
+		BeforeSuccess: func(dagIns *entity.DagInstance) {
+			observeDagIns(dagIns, entity.DagInstanceStatusSuccess)
+		},
+		BeforeFail: func(dagIns *entity.DagInstance) {
+			observeDagIns(dagIns, entity.DagInstanceStatusFailed)
+		},
+	})
+	if err := goevent.Subscribe(&taskMetricsHandler{}); err != nil {
+		global.Log.Error("订阅流水线任务事件失败", "err", err.Error())
+	}
+}
+
+func observeDagIns(dagIns *entity.DagInstance, status entity.DagInstanceStatus) {
+	dagInsTotal.WithLabelValues(dagIns.DagID, string(dagIns.Trigger), string(status)).Inc()
+	if dagIns.CreatedAt > 0 {
+		dagInsDuration.WithLabelValues(dagIns.DagID, string(status)).Observe(float64(time.Now().Unix() - dagIns.CreatedAt))
+	}
+}
+
+// 任务推送到执行器, 命中前置检查跳过/阻塞的任务、不可执行状态的任务和正在执行的任务不会开始执行
+func observeTaskPush(dagIns *entity.DagInstance, taskIns *entity.TaskInstance) {
+	switch taskIns.Status {
+	case entity.TaskInstanceStatusInit, entity.TaskInstanceStatusEnding, entity.TaskInstanceStatusRetrying:
+	default:
+		return
+	}
+	if _, running := taskBeginTime.Load(taskIns.ID); running {
+		return
+	}
+	check := *taskIns
+	if isActive, err := check.DoPreCheck(dagIns); err != nil || isActive {
+		return
+	}
+	timeout := time.Duration(taskIns.TimeoutSecs) * time.Second
+	if timeout <= 0 {
+		timeout = time.Hour
+	}
+	executorQueued.LoadOrStore(taskIns.ID, time.Now().Add(timeout))
+}
+
+// 排队任务数, 同时清理超过任务超时时间仍未开始的任务
+func queuedTaskCount() int {
+	now := time.Now()
+	count := 0
+	executorQueued.Range(func(key, value interface{}) bool {
+		if now.After(value.(time.Time)) {
+			executorQueued.Delete(key)
+			return true
+		}
+		count++
+		return true
+	})
+	return count
+}
+
+// 任务事件处理
+type taskMetricsHandler struct{}
+
+func (h *taskMetricsHandler) Topic() []string {
+	return []string{event.KeyTaskBegin, event.KeyTaskCompleted}
+}
+
+func (h *taskMetricsHandler) Handle(ctx context.Context, e goevent.Event) {
+	switch taskEvent := e.(type) {
+	case *event.TaskBegin:
+		executorQueued.Delete(taskEvent.TaskIns.ID)
+		executorRunning.Add(1)
+		taskBeginTime.Store(taskEvent.TaskIns.ID, time.Now())
+	case *event.TaskCompleted:
+		executorRunning.Add(-1)
+		taskIns := taskEvent.TaskIns
+		executorQueued.Delete(taskIns.ID)
+		taskInsTotal.WithLabelValues(taskIns.ActionName, string(taskIns.Status)).Inc()
+		if begin, ok := taskBeginTime.LoadAndDelete(taskIns.ID); ok {
+			taskInsDuration.WithLabelValues(taskIns.ActionName, string(taskIns.Status)).Observe(time.Since(begin.(time.Time)).Seconds())
+		}
+	}
+}
+
+// 抓取时从数据库和执行器读取的状态类指标
+type stateCollector struct{}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dagInsStatusDesc
+	ch <- queueDepthDesc
+	ch <- runningTaskDesc
+	ch <- workerUpDesc
+	ch <- workerLeaderDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	// 流水线实例状态, init和scheduled合并为queued
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := global.Mysql.Table(DagInsTable).Select("status, count(*) AS count").
+		Where("status IN ?", []entity.DagInstanceStatus{entity.DagInstanceStatusInit, entity.DagInstanceStatusScheduled, entity.DagInstanceStatusRunning, entity.DagInstanceStatusBlocked}).
+		Group("status").Scan(&rows).Error
+	if err != nil {
+		global.Log.Error("采集流水线实例状态指标失败", "err", err.Error())
+	}
+	counts := map[string]int64{"queued": 0, "running": 0, "blocked": 0}
+	for _, row := range rows {
+		switch entity.DagInstanceStatus(row.Status) {
+		case entity.DagInstanceStatusInit, entity.DagInstanceStatusScheduled:
+			counts["queued"] += row.Count
+		default:
+			counts[row.Status] += row.Count
+		}
+	}
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(dagInsStatusDesc, prometheus.GaugeValue, float64(count), status)
+	}
+	// 本节点执行器
+	if keeper := mod.GetKeeper(); keeper != nil {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(queuedTaskCount()), keeper.WorkerKey())
+		ch <- prometheus.MustNewConstMetric(runningTaskDesc, prometheus.GaugeValue, float64(executorRunning.Load()), keeper.WorkerKey())
+	}
+	// keeper记录的worker
+	workers, err := ListKeeperWorkers()
+	if err != nil {
+		global.Log.Error("采集流水线worker指标失败", "err", err.Error())
+	}
+	for _, worker := range workers {
+		ch <- prometheus.MustNewConstMetric(workerUpDesc, prometheus.GaugeValue, boolToFloat(worker.Alive()), worker.WorkerKey)
+		ch <- prometheus.MustNewConstMetric(workerLeaderDesc, prometheus.GaugeValue, boolToFloat(worker.IsLeader()), worker.WorkerKey)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
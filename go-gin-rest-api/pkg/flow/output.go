@@ -0,0 +1,65 @@
+package flow
+
+import (
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/global"
+	"sync"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/entity/run"
+)
+
+var shareDataMutex sync.Mutex
+
+// 写入ShareData
+// fastflow的ShareData.Set每次都会重建Dict导致丢失其他键, 这里临时替换Save,
+// 在Set持有ShareData锁时合并原有的键后保存, 不与并行任务的ShareData().Get竞争
+func SetShareData(ctx run.ExecuteContext, key, value string) {
+	shareData, ok := ctx.ShareData().(*entity.ShareData)
+	if !ok {
+		ctx.ShareData().Set(key, value)
+		return
+	}
+	shareDataMutex.Lock()
+	defer shareDataMutex.Unlock()
+	// Dict只在这里替换, 持有shareDataMutex时读取的原Dict不会再被修改
+	previous, save := shareData.Dict, shareData.Save
+	shareData.Save = func(data *entity.ShareData) error {
+		dict := make(map[string]string, len(previous)+1)
+		for k, v := range previous {
+			dict[k] = v
+		}
+		dict[key] = value
+		data.Dict = dict
+		if save == nil {
+			return nil
+		}
+		if err := save(data); err != nil {
+			// 保存失败时恢复原Dict, 返回nil以免Set从原Dict中删除该键
+			data.Dict = previous
+			global.Log.Error("保存流水线ShareData失败", "key", key, "err", err.Error())
+		}
+		return nil
+	}
+	shareData.Set(key, value)
+	shareData.Save = save
+}
+
+// 写入任务输出, 同时写入ShareData供后续任务通过{{.shareData.name}}引用
+func (m *TaskMeta) SetOutput(ctx run.ExecuteContext, name, value string) {
+	SetShareData(ctx, name, value)
+	if !m.declared(name) || m.DagInsID == "" {
+		return
+	}
+	output := &pipeline.PipelineOutput{
+		DagID:     m.DagID,
+		DagInsID:  m.DagInsID,
+		TaskID:    m.TaskID,
+		TaskInsID: m.TaskInsID,
+		Name:      name,
+		Value:     value,
+	}
+	if err := pipeline.SavePipelineOutput(output); err != nil {
+		ctx.Tracef("保存任务输出%s失败: %s", name, err)
+	}
+}
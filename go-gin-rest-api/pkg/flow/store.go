@@ -0,0 +1,101 @@
+package flow
+
+import (
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/global"
+	"math/rand"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+// store装饰
+// fastflow分配和重试流水线实例时不区分worker, 这里按任务要求的worker标签重新选择节点
+type Store struct {
+	mod.Store
+}
+
+// 批量更新流水线实例, 分配节点时调用
+func (s *Store) BatchUpdateDagIns(dagIns []*entity.DagInstance) error {
+	if placer := s.newPlacer(); placer != nil {
+		for _, d := range dagIns {
+			if d.Status == entity.DagInstanceStatusScheduled {
+				placer.place(d)
+			}
+		}
+	}
+	return s.Store.BatchUpdateDagIns(dagIns)
+}
+
+// 更新流水线实例, 重试时可能重新分配节点
+func (s *Store) PatchDagIns(dagIns *entity.DagInstance, mustsPatchFields ...string) error {
+	if dagIns.Worker != "" && dagIns.Cmd != nil {
+		if placer := s.newPlacer(); placer != nil {
+			placer.place(dagIns)
+		}
+	}
+	return s.Store.PatchDagIns(dagIns, mustsPatchFields...)
+}
+
+// 按标签选择节点
+type placer struct {
+	store   mod.Store
+	workers []pipeline.PipelineWorker
+	// 按流水线ID缓存要求的标签
+	required map[string]map[string]string
+	// 按流水线ID缓存标签冲突, 冲突的流水线实例无法分配
+	conflicts map[string]error
+}
+
+func (s *Store) newPlacer() *placer {
+	workers, err := schedulableWorkers()
+	if err != nil {
+		global.Log.Error("获取流水线worker失败", "err", err.Error())
+		return nil
+	}
+	return &placer{store: s.Store, workers: workers, required: map[string]map[string]string{}, conflicts: map[string]error{}}
+}
+
+// 当前节点不满足标签要求时在满足的节点中随机选择, 没有满足的节点时退回待分配;
+// 任务要求的标签冲突时任何节点都无法满足, 流水线实例直接置为失败
+func (p *placer) place(dagIns *entity.DagInstance) {
+	required, ok := p.required[dagIns.DagID]
+	if !ok {
+		dag, err := p.store.GetDag(dagIns.DagID)
+		if err != nil {
+			global.Log.Error("获取流水线定义失败", "dagId", dagIns.DagID, "err", err.Error())
+			required = map[string]string{}
+		} else if required, err = DagRequiredLabels(dag); err != nil {
+			global.Log.Error("获取流水线要求的worker标签失败", "dagId", dagIns.DagID, "err", err.Error())
+			p.conflicts[dagIns.DagID] = err
+		}
+		p.required[dagIns.DagID] = required
+	}
+	if err := p.conflicts[dagIns.DagID]; err != nil {
+		dagIns.Status = entity.DagInstanceStatusFailed
+		dagIns.Reason = err.Error()
+		dagIns.Worker = ""
+		return
+	}
+	if len(required) == 0 {
+		return
+	}
+	candidates := make([]string, 0, len(p.workers))
+	for _, worker := range p.workers {
+		if MatchLabels(required, ParseLabels(worker.Labels)) {
+			if worker.WorkerKey == dagIns.Worker {
+				return
+			}
+			candidates = append(candidates, worker.WorkerKey)
+		}
+	}
+	if len(candidates) > 0 {
+		dagIns.Worker = candidates[rand.Intn(len(candidates))]
+		return
+	}
+	if dagIns.Status == entity.DagInstanceStatusScheduled {
+		global.Log.Warn("没有满足标签要求的流水线worker, 等待重新分配", "dagInsId", dagIns.ID, "labels", FormatLabels(required))
+		dagIns.Status = entity.DagInstanceStatusInit
+		dagIns.Worker = ""
+	}
+}
@@ -0,0 +1,240 @@
+package flow
+
+import (
+	"fmt"
+	"go-gin-rest-api/models/pipeline"
+	"go-gin-rest-api/pkg/global"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linclin/fastflow"
+	"github.com/linclin/fastflow/pkg/entity"
+	"github.com/linclin/fastflow/pkg/mod"
+)
+
+var (
+	// fastflow keeper的worker表
+	WorkerTable = TablePrefix + "_worker"
+	// keeper判定worker不健康的时间, 与mysql keeper的默认值一致
+	WorkerUnhealthyTime = 5 * time.Second
+	// 本节点是否排空中
+	draining atomic.Bool
+	// 排空完成后关闭, 由主协程优雅关闭HTTP服务后退出进程
+	drainedCh   = make(chan struct{})
+	drainedOnce sync.Once
+	// 本节点正在执行worker命令
+	commandRunning atomic.Bool
+)
+
+// fastflow keeper记录的worker
+type Worker struct {
+	WorkerKey  string    `gorm:"column:worker_key" json:"workerKey"`   // worker key
+	WorkerType string    `gorm:"column:worker_type" json:"workerType"` // worker类型, leader为主节点
+	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updatedAt"`   // 最近心跳时间
+}
+
+// 是否存活
+func (w Worker) Alive() bool {
+	return w.UpdatedAt.After(time.Now().Add(-1 * WorkerUnhealthyTime))
+}
+
+// 是否主节点
+func (w Worker) IsLeader() bool {
+	return w.WorkerType == "leader"
+}
+
+// 获取keeper记录的全部worker
+func ListKeeperWorkers() ([]Worker, error) {
+	list := make([]Worker, 0)
+	err := global.Mysql.Table(WorkerTable).Find(&list).Error
+	return list, err
+}
+
+// worker注册表中的worker是否存活
+func WorkerAlive(worker pipeline.PipelineWorker) bool {
+	return worker.HeartbeatAt.After(time.Now().Add(-1 * WorkerUnhealthyTime))
+}
+
+// keeper装饰
+// mysql keeper的worker表只有主节点的记录, 存活节点改为以worker注册表为准, 排空中的worker不再分配新的流水线实例
+type Keeper struct {
+	mod.Keeper
+}
+
+// 存活且可分配流水线实例的worker
+func (k *Keeper) AliveNodes() ([]string, error) {
+	workers, err := schedulableWorkers()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]string, 0, len(workers))
+	for _, worker := range workers {
+		nodes = append(nodes, worker.WorkerKey)
+	}
+	return nodes, nil
+}
+
+// worker是否存活
+func (k *Keeper) IsAlive(workerKey string) (bool, error) {
+	worker, err := pipeline.GetPipelineWorker(workerKey)
+	if err != nil {
+		return k.Keeper.IsAlive(workerKey)
+	}
+	return WorkerAlive(*worker), nil
+}
+
+// 存活且未排空的worker
+func schedulableWorkers() ([]pipeline.PipelineWorker, error) {
+	workers, err := pipeline.ListPipelineWorkers()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]pipeline.PipelineWorker, 0, len(workers))
+	for _, worker := range workers {
+		if WorkerAlive(worker) && !worker.Draining {
+			list = append(list, worker)
+		}
+	}
+	return list, nil
+}
+
+// 获取执行中的任务实例及所在worker
+func ListRunningTasks() ([]pipeline.PipelineRunningTask, error) {
+	list := make([]pipeline.PipelineRunningTask, 0)
+	err := global.Mysql.Table(TaskInsTable+" AS t").
+		Select("d.worker, d.dag_id, t.dag_ins_id, t.task_id, t.id, t.action_name, t.updated_at").
+		Joins("JOIN "+DagInsTable+" AS d ON d.id = t.dag_ins_id").
+		Where("t.status = ?", entity.TaskInstanceStatusRunning).
+		Order("t.updated_at").Scan(&list).Error
+	return list, err
+}
+
+// 替换keeper和store并启动worker心跳, 需在fastflow.Init之后调用
+func StartWorker(ip string) {
+	keeper := &Keeper{Keeper: mod.GetKeeper()}
+	mod.SetStore(&Store{Store: mod.GetStore()})
+	// 重新启动的节点恢复接收流水线实例
+	if err := pipeline.SetPipelineWorkerDraining(keeper.WorkerKey(), false); err != nil {
+		global.Log.Error("重置流水线worker排空标记失败", "err", err.Error())
+	}
+	heartbeat(ip)
+	mod.SetKeeper(keeper)
+	go reportBuildCaches(keeper.WorkerKey())
+	go func() {
+		ticker := time.NewTicker(WorkerUnhealthyTime / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-drainedCh:
+				return
+			case <-ticker.C:
+				heartbeat(ip)
+			}
+		}
+	}()
+}
+
+// 本节点排空完成并关闭流水线组件后关闭的通道
+func Drained() <-chan struct{} {
+	return drainedCh
+}
+
+// 上报心跳, 按注册表的排空标记进入或退出排空
+func heartbeat(ip string) {
+	keeper := mod.GetKeeper()
+	worker, err := pipeline.HeartbeatPipelineWorker(&pipeline.PipelineWorker{
+		WorkerKey:    keeper.WorkerKey(),
+		Ip:           ip,
+		Leader:       keeper.IsLeader(),
+		Labels:       FormatLabels(WorkerLabels()),
+		RunningTasks: executorRunning.Load(),
+		HeartbeatAt:  time.Now(),
+	})
+	if err != nil {
+		global.Log.Error("流水线worker心跳失败", "err", err.Error())
+		return
+	}
+	runWorkerCommands(worker.WorkerKey)
+	if worker.Draining != draining.Load() {
+		draining.Store(worker.Draining)
+		if worker.Draining {
+			global.Log.Info("流水线worker开始排空", "worker", worker.WorkerKey)
+			releaseScheduledDagIns(worker.WorkerKey)
+		} else {
+			global.Log.Info("流水线worker取消排空", "worker", worker.WorkerKey)
+		}
+	}
+	if worker.Draining && drained(worker.WorkerKey) {
+		shutdown(worker.WorkerKey)
+	}
+}
+
+// 执行本节点待执行的worker命令, 命令可能耗时较长, 在单独的协程中执行, 上一批未执行完时跳过
+func runWorkerCommands(workerKey string) {
+	if !commandRunning.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer commandRunning.Store(false)
+		commands, err := pipeline.GetPendingPipelineWorkerCommands(workerKey)
+		if err != nil {
+			global.Log.Error("获取worker命令失败", "err", err.Error())
+			return
+		}
+		for i := range commands {
+			cmd := &commands[i]
+			var err error
+			switch cmd.Command {
+			case pipeline.WorkerCommandPurgeBuildCache:
+				cacheType, namespace, _ := strings.Cut(cmd.Args, "/")
+				err = PurgeBuildCache(cacheType, namespace)
+			default:
+				err = fmt.Errorf("不支持的worker命令: %s", cmd.Command)
+			}
+			global.Log.Info("执行worker命令", "worker", workerKey, "command", cmd.Command, "args", cmd.Args, "ok", err == nil)
+			if err := pipeline.FinishPipelineWorkerCommand(cmd, err); err != nil {
+				global.Log.Error("保存worker命令结果失败", "err", err.Error())
+			}
+		}
+	}()
+}
+
+// 已分配给本节点尚未开始的流水线实例退回重新分配
+func releaseScheduledDagIns(workerKey string) {
+	err := global.Mysql.Table(DagInsTable).
+		Where("worker = ? AND status = ?", workerKey, entity.DagInstanceStatusScheduled).
+		Updates(map[string]interface{}{"status": entity.DagInstanceStatusInit, "worker": ""}).Error
+	if err != nil {
+		global.Log.Error("退回流水线实例失败", "err", err.Error())
+	}
+}
+
+// 本节点没有执行中的流水线实例和任务
+func drained(workerKey string) bool {
+	if executorRunning.Load() > 0 {
+		return false
+	}
+	var count int64
+	err := global.Mysql.Table(DagInsTable).
+		Where("worker = ? AND status IN ?", workerKey, []entity.DagInstanceStatus{entity.DagInstanceStatusScheduled, entity.DagInstanceStatusRunning}).
+		Count(&count).Error
+	if err != nil {
+		global.Log.Error("查询流水线实例失败", "err", err.Error())
+		return false
+	}
+	return count == 0
+}
+
+// 排空完成, 关闭流水线组件并通知主协程退出, 由主协程优雅关闭HTTP服务
+func shutdown(workerKey string) {
+	drainedOnce.Do(func() {
+		global.Log.Info("流水线worker排空完成, 进程退出", "worker", workerKey)
+		fastflow.Close()
+		if err := pipeline.DeletePipelineWorker(workerKey); err != nil {
+			global.Log.Error("注销流水线worker失败", "err", err.Error())
+		}
+		close(drainedCh)
+	})
+}
@@ -0,0 +1,108 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/global"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/linclin/fastflow/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// 工作空间根目录, 每个流水线实例一个子目录, 同一实例的任务共享
+var WorkspaceRoot = "./storage/workspace"
+
+// 流水线实例的工作空间路径
+func workspacePath(dagInsId string) (string, error) {
+	if dagInsId == "" || strings.ContainsAny(dagInsId, `/\.`) {
+		return "", fmt.Errorf("非法的流水线实例ID: %s", dagInsId)
+	}
+	return filepath.Abs(filepath.Join(WorkspaceRoot, dagInsId))
+}
+
+// 获取流水线实例的工作空间目录, 不存在时创建
+func WorkspaceDir(dagInsId string) (string, error) {
+	dir, err := workspacePath(dagInsId)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("创建工作空间失败: %w", err)
+	}
+	return dir, nil
+}
+
+// 任务所在流水线实例的工作空间目录, 不存在时创建
+func (m *TaskMeta) WorkspaceDir() (string, error) {
+	if m.Workspace == "" {
+		return WorkspaceDir(m.DagInsID)
+	}
+	if err := os.MkdirAll(m.Workspace, os.ModePerm); err != nil {
+		return "", fmt.Errorf("创建工作空间失败: %w", err)
+	}
+	return m.Workspace, nil
+}
+
+// 拼接工作空间内的相对路径, 不允许跳出工作空间
+func WorkspacePath(workspace, rel string) (string, error) {
+	path := filepath.Join(workspace, rel)
+	if path != workspace && !strings.HasPrefix(path, workspace+string(filepath.Separator)) {
+		return "", fmt.Errorf("路径不能超出工作空间: %s", rel)
+	}
+	return path, nil
+}
+
+// 清理过期的工作空间, 返回删除的流水线实例ID
+// 成功的实例超过ttl删除; 失败的实例开启keep-on-failure时超过failed-ttl删除; 执行中的实例不删除
+func CleanWorkspaces() ([]string, error) {
+	conf := global.Conf.Pipeline.Workspace
+	entries, err := os.ReadDir(WorkspaceRoot)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dagInsId := entry.Name()
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		finishedAt := info.ModTime()
+		ttl := conf.Ttl
+		dagIns := new(entity.DagInstance)
+		err = global.Mysql.Table(DagInsTable).Select("id, status, updated_at").Where("id = ?", dagInsId).First(dagIns).Error
+		if err == nil {
+			switch dagIns.Status {
+			case entity.DagInstanceStatusSuccess:
+			case entity.DagInstanceStatusFailed:
+				if conf.KeepOnFailure {
+					ttl = conf.FailedTtl
+				}
+			default:
+				continue
+			}
+			finishedAt = time.Unix(dagIns.UpdatedAt, 0)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return removed, err
+		}
+		if time.Since(finishedAt) < time.Duration(ttl)*time.Hour {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(WorkspaceRoot, dagInsId)); err != nil {
+			global.Log.Error("删除工作空间失败", "dagInsId", dagInsId, "err", err.Error())
+			continue
+		}
+		removed = append(removed, dagInsId)
+	}
+	return removed, nil
+}
@@ -0,0 +1,177 @@
+// git仓库操作, 基于go-git, 认证信息从密钥存储和storage/git-key目录读取
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/secret"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+var (
+	// 工程目录, 按host/path存放
+	SourceDir = "./storage/git-source"
+	// SSH私钥目录
+	KeyDir = "./storage/git-key"
+)
+
+// token认证时默认的用户名, GitLab、Gitee等均接受任意用户名
+const defaultTokenUsername = "oauth2"
+
+// scp风格的SSH地址, 如git@gitee.com:linclin/cdms.git
+var scpURLRegexp = regexp.MustCompile(`^(?:([^@/]+)@)?([^:/]+):(.+)$`)
+
+// 仓库认证信息
+type Credential struct {
+	Username    string `json:"username"`    // 用户名, token认证时默认oauth2
+	TokenSecret string `json:"tokenSecret"` // 访问令牌或密码的密钥名称, 从密钥存储读取
+	SSHKey      string `json:"sshKey"`      // SSH私钥文件名, 位于storage/git-key目录
+	Password    string `json:"-"`           // 已解密的访问令牌或密码, 由代码仓库配置填入, 优先于TokenSecret
+}
+
+// 读取访问令牌
+func (c Credential) Token() (string, error) {
+	if c.Password != "" {
+		return c.Password, nil
+	}
+	if c.TokenSecret == "" {
+		return "", nil
+	}
+	return secret.Get(c.TokenSecret)
+}
+
+// 生成go-git认证方式, 未设置认证信息时返回nil
+func (c Credential) Auth() (transport.AuthMethod, error) {
+	if c.SSHKey != "" {
+		if strings.ContainsAny(c.SSHKey, `/\`) || strings.HasPrefix(c.SSHKey, ".") {
+			return nil, fmt.Errorf("非法的SSH私钥文件名: %s", c.SSHKey)
+		}
+		user := c.Username
+		if user == "" {
+			user = "git"
+		}
+		auth, err := ssh.NewPublicKeysFromFile(user, filepath.Join(KeyDir, c.SSHKey), "")
+		if err != nil {
+			return nil, fmt.Errorf("读取SSH私钥%s失败: %w", c.SSHKey, err)
+		}
+		return auth, nil
+	}
+	token, err := c.Token()
+	if err != nil || token == "" {
+		return nil, err
+	}
+	user := c.Username
+	if user == "" {
+		user = defaultTokenUsername
+	}
+	return &http.BasicAuth{Username: user, Password: token}, nil
+}
+
+// 解析仓库地址, 返回host和去掉.git后缀的路径, 支持https和scp风格的SSH地址
+func ParseURL(repoURL string) (string, string, error) {
+	if m := scpURLRegexp.FindStringSubmatch(repoURL); m != nil && !strings.Contains(repoURL, "://") {
+		return m[2], strings.TrimSuffix(strings.Trim(m[3], "/"), ".git"), nil
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", "", fmt.Errorf("非法的仓库地址%s: %w", repoURL, err)
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if u.Host == "" || path == "" {
+		return "", "", fmt.Errorf("非法的仓库地址: %s", repoURL)
+	}
+	return u.Hostname(), path, nil
+}
+
+// 克隆仓库到目录, depth为0时完整克隆; 本地已有裸镜像时更新镜像后从镜像克隆, 镜像不可用时直接克隆远程仓库
+func Clone(ctx context.Context, repoURL, branch, dir string, depth int, auth transport.AuthMethod) (*gogit.Repository, error) {
+	if repo, err := cloneFromMirror(ctx, repoURL, branch, dir, auth); err == nil {
+		return repo, nil
+	} else if !errors.Is(err, errNoMirror) {
+		os.RemoveAll(dir)
+	}
+	opts := &gogit.CloneOptions{URL: repoURL, Auth: auth, Depth: depth, SingleBranch: branch != ""}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+	repo, err := gogit.PlainCloneContext(ctx, dir, false, opts)
+	if err != nil {
+		return nil, fmt.Errorf("克隆仓库%s失败: %w", repoURL, err)
+	}
+	return repo, nil
+}
+
+// 提交作者
+type Signature struct {
+	Name  string `json:"name"`  // 姓名
+	Email string `json:"email"` // 邮箱
+}
+
+// 提交工作区全部变更, 没有变更时返回空
+func CommitAll(repo *gogit.Repository, message string, author Signature) (string, error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return "", err
+	}
+	if status.IsClean() {
+		return "", nil
+	}
+	if err := worktree.AddWithOptions(&gogit.AddOptions{All: true}); err != nil {
+		return "", err
+	}
+	hash, err := worktree.Commit(message, &gogit.CommitOptions{
+		Author: &object.Signature{Name: author.Name, Email: author.Email, When: time.Now()},
+	})
+	if err != nil {
+		return "", fmt.Errorf("提交失败: %w", err)
+	}
+	return hash.String(), nil
+}
+
+// 是否为远程分支已有新提交导致的推送失败, 此时需要基于远程最新提交重新提交
+// go-git推送前发现远程分支不是本地祖先时返回ErrForceNeeded, 推送期间被抢先时服务端拒绝为non-fast-forward或fetch first
+func IsNonFastForward(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gogit.ErrForceNeeded) || errors.Is(err, gogit.ErrNonFastForwardUpdate) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "non-fast-forward") || strings.Contains(msg, "fetch first")
+}
+
+// 将当前分支推送到远程分支
+func Push(ctx context.Context, repo *gogit.Repository, branch string, auth transport.AuthMethod) error {
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if !head.Name().IsBranch() {
+		return errors.New("当前不在分支上, 无法推送")
+	}
+	refSpec := config.RefSpec(head.Name().String() + ":" + plumbing.NewBranchReferenceName(branch).String())
+	err = repo.PushContext(ctx, &gogit.PushOptions{RemoteName: gogit.DefaultRemoteName, RefSpecs: []config.RefSpec{refSpec}, Auth: auth})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("推送分支%s失败: %w", branch, err)
+	}
+	return nil
+}
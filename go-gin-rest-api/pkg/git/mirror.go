@@ -0,0 +1,158 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// 同一镜像仓库的克隆和更新串行执行
+var mirrorLocks sync.Map
+
+// 镜像目录中的主机名, 只允许域名或IPv4地址
+var mirrorHostRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// 镜像仓库目录, SourceDir/<host>/<path>.git, 主机名和路径不能跳出SourceDir
+func MirrorDir(repoURL string) (string, error) {
+	host, path, err := ParseURL(repoURL)
+	if err != nil {
+		return "", err
+	}
+	path = strings.TrimSuffix(path, ".git")
+	if !mirrorHostRegexp.MatchString(host) || path == "" {
+		return "", fmt.Errorf("非法的仓库地址: %s", repoURL)
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, `\`) {
+			return "", fmt.Errorf("非法的仓库地址: %s", repoURL)
+		}
+	}
+	return filepath.Join(SourceDir, host, filepath.FromSlash(path)+".git"), nil
+}
+
+// 获取仓库的本地裸镜像: 不存在时镜像克隆, 已存在时更新全部分支和标签, 返回镜像仓库
+func Mirror(ctx context.Context, repoURL string, auth transport.AuthMethod) (*gogit.Repository, error) {
+	dir, err := MirrorDir(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	lock, _ := mirrorLocks.LoadOrStore(dir, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	repo, err := gogit.PlainOpen(dir)
+	if errors.Is(err, gogit.ErrRepositoryNotExists) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return nil, err
+		}
+		repo, err = gogit.PlainCloneContext(ctx, dir, true, &gogit.CloneOptions{URL: repoURL, Auth: auth, Mirror: true})
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("镜像克隆仓库%s失败: %w", repoURL, err)
+		}
+		return repo, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开镜像仓库%s失败: %w", dir, err)
+	}
+	err = repo.FetchContext(ctx, &gogit.FetchOptions{
+		RemoteName: gogit.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"},
+		Auth:       auth,
+		Prune:      true,
+		Force:      true,
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("更新镜像仓库%s失败: %w", repoURL, err)
+	}
+	return repo, nil
+}
+
+// 解析提交SHA、分支或标签为提交
+func ResolveCommit(repo *gogit.Repository, rev string) (plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("找不到提交%s: %w", rev, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("找不到提交%s: %w", rev, err)
+	}
+	return commit.Hash, nil
+}
+
+// 本地没有裸镜像
+var errNoMirror = errors.New("本地没有裸镜像")
+
+// 从本地裸镜像克隆: 先更新镜像, 再本地完整克隆, 并将origin改回远程地址以便后续拉取和推送
+func cloneFromMirror(ctx context.Context, repoURL, branch, dir string, auth transport.AuthMethod) (*gogit.Repository, error) {
+	mirrorDir, err := MirrorDir(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(mirrorDir); err != nil {
+		return nil, errNoMirror
+	}
+	if _, err := Mirror(ctx, repoURL, auth); err != nil {
+		return nil, err
+	}
+	source, err := filepath.Abs(mirrorDir)
+	if err != nil {
+		return nil, err
+	}
+	opts := &gogit.CloneOptions{URL: source, SingleBranch: branch != ""}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+	repo, err := gogit.PlainCloneContext(ctx, dir, false, opts)
+	if err != nil {
+		return nil, fmt.Errorf("从镜像克隆仓库%s失败: %w", repoURL, err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Remotes[gogit.DefaultRemoteName].URLs = []string{repoURL}
+	if err := repo.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// 删除仓库的本地裸镜像
+func RemoveMirror(repoURL string) error {
+	dir, err := MirrorDir(repoURL)
+	if err != nil {
+		return err
+	}
+	lock, _ := mirrorLocks.LoadOrStore(dir, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	return os.RemoveAll(dir)
+}
+
+// 读取提交中的文件内容, 文件不存在时返回object.ErrFileNotFound
+func ReadFile(repo *gogit.Repository, commit plumbing.Hash, name string) ([]byte, error) {
+	c, err := repo.CommitObject(commit)
+	if err != nil {
+		return nil, fmt.Errorf("找不到提交%s: %w", commit, err)
+	}
+	file, err := c.File(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件%s失败: %w", name, err)
+	}
+	return []byte(content), nil
+}
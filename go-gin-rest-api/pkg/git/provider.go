@@ -0,0 +1,220 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 代码托管平台
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitee  = "gitee"
+)
+
+// 代码托管平台API
+type Provider struct {
+	Kind   string // 平台类型: github/gitlab/gitee
+	APIURL string // API地址
+	Repo   string // 仓库路径, 如linclin/cdms
+	token  string
+	client *resty.Client
+}
+
+// 合并请求
+type MergeRequest struct {
+	Source      string // 源分支
+	Target      string // 目标分支
+	Title       string // 标题
+	Description string // 描述
+}
+
+// 创建代码托管平台API客户端, apiURL为空时按仓库地址推断, 如https://gitlab.example.com/api/v4
+func NewProvider(kind, repoURL, apiURL, token string) (*Provider, error) {
+	if kind != ProviderGitHub && kind != ProviderGitLab && kind != ProviderGitee {
+		return nil, fmt.Errorf("不支持的代码托管平台: %s", kind)
+	}
+	host, path, err := ParseURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	if apiURL == "" {
+		switch kind {
+		case ProviderGitHub:
+			apiURL = "https://api.github.com"
+			if host != "github.com" {
+				apiURL = "https://" + host + "/api/v3"
+			}
+		case ProviderGitLab:
+			apiURL = "https://" + host + "/api/v4"
+		case ProviderGitee:
+			apiURL = "https://gitee.com/api/v5"
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("调用%s API需要访问令牌", kind)
+	}
+	client := resty.New().SetBaseURL(strings.TrimRight(apiURL, "/")).SetTimeout(30*time.Second).
+		SetHeader("Accept", "application/json")
+	switch kind {
+	case ProviderGitHub:
+		client.SetAuthToken(token)
+	case ProviderGitLab:
+		client.SetHeader("PRIVATE-TOKEN", token)
+	}
+	return &Provider{Kind: kind, APIURL: apiURL, Repo: path, token: token, client: client}, nil
+}
+
+// 接口路径中的仓库, GitLab使用URL编码的项目路径
+func (p *Provider) repoPath() string {
+	if p.Kind == ProviderGitLab {
+		return "/projects/" + url.PathEscape(p.Repo)
+	}
+	return "/repos/" + p.Repo
+}
+
+// 发送请求, 状态码不是2xx时返回错误
+func (p *Provider) do(ctx context.Context, method, path string, body map[string]interface{}, result interface{}) error {
+	resp, err := p.execute(ctx, method, path, body, result)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return p.respError(resp)
+	}
+	return nil
+}
+
+// 执行请求, Gitee的访问令牌有请求体时放在请求体中, 否则只能放在查询参数中, 错误信息中屏蔽令牌
+func (p *Provider) execute(ctx context.Context, method, path string, body map[string]interface{}, result interface{}) (*resty.Response, error) {
+	req := p.client.R().SetContext(ctx)
+	if p.Kind == ProviderGitee {
+		if body != nil {
+			withToken := make(map[string]interface{}, len(body)+1)
+			for k, v := range body {
+				withToken[k] = v
+			}
+			withToken["access_token"] = p.token
+			body = withToken
+		} else {
+			req.SetQueryParam("access_token", p.token)
+		}
+	}
+	if body != nil {
+		req.SetBody(body)
+	}
+	if result != nil {
+		req.SetResult(result)
+	}
+	resp, err := req.Execute(method, path)
+	if err != nil {
+		return nil, fmt.Errorf("调用%s API失败: %s", p.Kind, p.scrub(err.Error()))
+	}
+	return resp, nil
+}
+
+// 状态码不是2xx时的错误
+func (p *Provider) respError(resp *resty.Response) error {
+	return fmt.Errorf("调用%s API失败: %s %s", p.Kind, resp.Status(), p.scrub(strings.TrimSpace(resp.String())))
+}
+
+// 屏蔽错误信息中的访问令牌, 如请求失败时url.Error中的完整地址
+func (p *Provider) scrub(msg string) string {
+	if p.token == "" {
+		return msg
+	}
+	msg = strings.ReplaceAll(msg, p.token, "******")
+	return strings.ReplaceAll(msg, url.QueryEscape(p.token), "******")
+}
+
+// 创建合并请求, 返回合并请求地址
+func (p *Provider) CreateMergeRequest(ctx context.Context, mr MergeRequest) (string, error) {
+	var result struct {
+		HTMLURL string `json:"html_url"`
+		WebURL  string `json:"web_url"`
+	}
+	var body map[string]interface{}
+	path := p.repoPath()
+	switch p.Kind {
+	case ProviderGitLab:
+		path += "/merge_requests"
+		body = map[string]interface{}{
+			"source_branch":        mr.Source,
+			"target_branch":        mr.Target,
+			"title":                mr.Title,
+			"description":          mr.Description,
+			"remove_source_branch": true,
+		}
+	default:
+		path += "/pulls"
+		body = map[string]interface{}{"head": mr.Source, "base": mr.Target, "title": mr.Title, "body": mr.Description}
+	}
+	if err := p.do(ctx, "POST", path, body, &result); err != nil {
+		return "", err
+	}
+	if result.WebURL != "" {
+		return result.WebURL, nil
+	}
+	return result.HTMLURL, nil
+}
+
+// 创建推送、标签推送和合并请求事件的webhook, secret用于平台签名或令牌校验, 返回webhook ID
+func (p *Provider) CreateWebhook(ctx context.Context, hookURL, secret string) (string, error) {
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	var body map[string]interface{}
+	switch p.Kind {
+	case ProviderGitHub:
+		body = map[string]interface{}{
+			"name":   "web",
+			"active": true,
+			"events": []string{"push", "pull_request"},
+			"config": map[string]interface{}{"url": hookURL, "content_type": "json", "secret": secret, "insecure_ssl": "0"},
+		}
+	case ProviderGitLab:
+		body = map[string]interface{}{
+			"url":                     hookURL,
+			"token":                   secret,
+			"push_events":             true,
+			"tag_push_events":         true,
+			"merge_requests_events":   true,
+			"enable_ssl_verification": true,
+		}
+	case ProviderGitee:
+		body = map[string]interface{}{
+			"url":                   hookURL,
+			"password":              secret,
+			"encryption_type":       0,
+			"push_events":           true,
+			"tag_push_events":       true,
+			"merge_requests_events": true,
+		}
+	}
+	if err := p.do(ctx, "POST", p.repoPath()+"/hooks", body, &result); err != nil {
+		return "", err
+	}
+	if result.ID == 0 {
+		return "", fmt.Errorf("调用%s API失败: 未返回webhook ID", p.Kind)
+	}
+	return strconv.FormatInt(result.ID, 10), nil
+}
+
+// 删除webhook, 平台上已不存在时忽略
+func (p *Provider) DeleteWebhook(ctx context.Context, id string) error {
+	resp, err := p.execute(ctx, http.MethodDelete, p.repoPath()+"/hooks/"+url.PathEscape(id), nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() && resp.StatusCode() != http.StatusNotFound {
+		return p.respError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// 远程引用类型
+const (
+	RefBranch = "branch"
+	RefTag    = "tag"
+)
+
+// 远程分支或标签
+type Ref struct {
+	Name   string `json:"name"`   // 分支或标签名称
+	Type   string `json:"type"`   // 类型: branch/tag
+	Commit string `json:"commit"` // 指向的提交, 附注标签为解引用后的提交
+	Head   bool   `json:"head"`   // 是否为默认分支
+}
+
+// 不克隆仓库, 列出远程仓库的分支和标签及其指向的提交, 分支和标签分别按名称排序
+func ListRefs(ctx context.Context, repoURL string, auth transport.AuthMethod) ([]Ref, error) {
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: gogit.DefaultRemoteName, URLs: []string{repoURL}})
+	list, err := remote.ListContext(ctx, &gogit.ListOptions{Auth: auth, PeelingOption: gogit.AppendPeeled})
+	if err != nil {
+		return nil, fmt.Errorf("获取仓库%s的分支和标签失败: %w", repoURL, err)
+	}
+	head := ""
+	peeled := map[string]string{}
+	for _, ref := range list {
+		name := ref.Name().String()
+		switch {
+		case ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference:
+			head = ref.Target().String()
+		case strings.HasSuffix(name, "^{}"):
+			peeled[strings.TrimSuffix(name, "^{}")] = ref.Hash().String()
+		}
+	}
+	refs := make([]Ref, 0, len(list))
+	for _, ref := range list {
+		name := ref.Name()
+		if ref.Type() != plumbing.HashReference || strings.HasSuffix(name.String(), "^{}") {
+			continue
+		}
+		switch {
+		case name.IsBranch():
+			refs = append(refs, Ref{Name: name.Short(), Type: RefBranch, Commit: ref.Hash().String(), Head: name.String() == head})
+		case name.IsTag():
+			commit := ref.Hash().String()
+			if hash, ok := peeled[name.String()]; ok {
+				commit = hash
+			}
+			refs = append(refs, Ref{Name: name.Short(), Type: RefTag, Commit: commit})
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].Type != refs[j].Type {
+			return refs[i].Type == RefBranch
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	return refs, nil
+}
+
+// 按类型过滤远程引用
+func FilterRefs(refs []Ref, refType string) []Ref {
+	list := make([]Ref, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Type == refType {
+			list = append(list, ref)
+		}
+	}
+	return list
+}
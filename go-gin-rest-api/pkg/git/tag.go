@@ -0,0 +1,168 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/semver"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// 版本标签
+type VersionTag struct {
+	Name    string         // 标签名称, 如v1.2.0
+	Version semver.Version // 版本
+	Commit  plumbing.Hash  // 标签指向的提交
+}
+
+// 查找commit可达的最高正式版本标签, 标签名须为prefix加语义化版本, 忽略预发布版本; 没有时返回nil
+func LatestVersionTag(repo *gogit.Repository, commit plumbing.Hash, prefix string) (*VersionTag, error) {
+	tags, err := versionTags(repo, prefix)
+	if err != nil || len(tags) == 0 {
+		return nil, err
+	}
+	ancestors, err := ancestorSet(repo, commit, nil)
+	if err != nil {
+		return nil, err
+	}
+	var latest *VersionTag
+	for i := range tags {
+		if !ancestors[tags[i].Commit] {
+			continue
+		}
+		if latest == nil || tags[i].Version.Compare(latest.Version) > 0 {
+			latest = &tags[i]
+		}
+	}
+	return latest, nil
+}
+
+// commit可达但base不可达的提交, 按提交时间从新到旧排列; base为零值时返回全部祖先提交
+func CommitsSince(repo *gogit.Repository, commit, base plumbing.Hash) ([]*object.Commit, error) {
+	exclude := map[plumbing.Hash]bool{}
+	if !base.IsZero() {
+		var err error
+		if exclude, err = ancestorSet(repo, base, nil); err != nil {
+			return nil, err
+		}
+	}
+	commits := make([]*object.Commit, 0)
+	_, err := ancestorSet(repo, commit, func(c *object.Commit) bool {
+		if exclude[c.Hash] {
+			return false
+		}
+		commits = append(commits, c)
+		return true
+	})
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.After(commits[j].Committer.When)
+	})
+	return commits, err
+}
+
+// 创建轻量标签并推送到远程仓库, 标签已存在且指向同一提交时不报错
+func PushTag(ctx context.Context, repo *gogit.Repository, name string, commit plumbing.Hash, auth transport.AuthMethod) error {
+	ref, err := repo.Tag(name)
+	switch {
+	case errors.Is(err, gogit.ErrTagNotFound):
+		if _, err := repo.CreateTag(name, commit, nil); err != nil {
+			return fmt.Errorf("创建标签%s失败: %w", name, err)
+		}
+	case err != nil:
+		return err
+	case ref.Hash() != commit:
+		return fmt.Errorf("标签%s已存在且指向其他提交%s", name, ref.Hash())
+	}
+	refName := plumbing.NewTagReferenceName(name).String()
+	err = repo.PushContext(ctx, &gogit.PushOptions{
+		RemoteName: gogit.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(refName + ":" + refName)},
+		Auth:       auth,
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("推送标签%s失败: %w", name, err)
+	}
+	return nil
+}
+
+// 当前提交和分支, 不在分支上时分支为空
+func Head(repo *gogit.Repository) (plumbing.Hash, string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("获取HEAD失败: %w", err)
+	}
+	branch := ""
+	if head.Name().IsBranch() {
+		branch = head.Name().Short()
+	}
+	return head.Hash(), branch, nil
+}
+
+// 全部版本标签, 附注标签解析到其指向的提交
+func versionTags(repo *gogit.Repository, prefix string) ([]VersionTag, error) {
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]VersionTag, 0)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		version, err := semver.Parse(strings.TrimPrefix(name, prefix))
+		if err != nil || version.Prerelease != "" {
+			return nil
+		}
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			c, err := tag.Commit()
+			if err != nil {
+				return nil
+			}
+			hash = c.Hash
+		}
+		tags = append(tags, VersionTag{Name: name, Version: version, Commit: hash})
+		return nil
+	})
+	return tags, err
+}
+
+// 遍历commit的全部祖先提交(含自身), visit返回false时不再遍历该提交的父提交
+func ancestorSet(repo *gogit.Repository, commit plumbing.Hash, visit func(*object.Commit) bool) (map[plumbing.Hash]bool, error) {
+	seen := map[plumbing.Hash]bool{}
+	start, err := repo.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+	queue := []*object.Commit{start}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if seen[c.Hash] {
+			continue
+		}
+		seen[c.Hash] = true
+		if visit != nil && !visit(c) {
+			continue
+		}
+		err := c.Parents().ForEach(func(parent *object.Commit) error {
+			if !seen[parent.Hash] {
+				queue = append(queue, parent)
+			}
+			return nil
+		})
+		// 浅克隆时父提交不存在
+		if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, err
+		}
+	}
+	return seen, nil
+}
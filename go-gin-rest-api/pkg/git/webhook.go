@@ -0,0 +1,124 @@
+package git
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// webhook事件类型
+const (
+	EventPush         = "push"
+	EventTagPush      = "tag_push"
+	EventMergeRequest = "merge_request"
+	EventPing         = "ping"
+)
+
+// 各平台webhook的事件和签名请求头
+var webhookHeaders = map[string]struct{ Event, Token string }{
+	ProviderGitHub: {"X-GitHub-Event", "X-Hub-Signature-256"},
+	ProviderGitLab: {"X-Gitlab-Event", "X-Gitlab-Token"},
+	ProviderGitee:  {"X-Gitee-Event", "X-Gitee-Token"},
+}
+
+// 统一的webhook事件, 只解析推送相关字段
+type WebhookEvent struct {
+	Event   string `json:"event"`   // 事件类型: push/tag_push/merge_request/ping, 其他事件为平台原始事件名
+	Ref     string `json:"ref"`     // 完整引用名称, 如refs/heads/main
+	Branch  string `json:"branch"`  // 分支名称, 标签推送时为空
+	Tag     string `json:"tag"`     // 标签名称, 分支推送时为空
+	Before  string `json:"before"`  // 推送前的提交
+	After   string `json:"after"`   // 推送后的提交
+	Deleted bool   `json:"deleted"` // 是否为删除分支或标签
+	Pusher  string `json:"pusher"`  // 推送者在代码托管平台的用户名, 由平台上报
+}
+
+// 校验webhook请求: GitHub校验HMAC-SHA256签名, GitLab和Gitee校验令牌(Gitee使用密码方式)
+func VerifyWebhook(kind string, header http.Header, body []byte, secret string) error {
+	headers, ok := webhookHeaders[kind]
+	if !ok {
+		return fmt.Errorf("不支持的代码托管平台: %s", kind)
+	}
+	if secret == "" {
+		return errors.New("未配置webhook密钥")
+	}
+	value := header.Get(headers.Token)
+	if value == "" {
+		return fmt.Errorf("缺少请求头%s", headers.Token)
+	}
+	expected := secret
+	if kind == ProviderGitHub {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	if subtle.ConstantTimeCompare([]byte(value), []byte(expected)) != 1 {
+		return errors.New("webhook签名校验失败")
+	}
+	return nil
+}
+
+// 解析webhook请求, 推送和标签推送事件解析引用和前后提交
+func ParseWebhook(kind string, header http.Header, body []byte) (*WebhookEvent, error) {
+	headers, ok := webhookHeaders[kind]
+	if !ok {
+		return nil, fmt.Errorf("不支持的代码托管平台: %s", kind)
+	}
+	event := &WebhookEvent{Event: header.Get(headers.Event)}
+	switch event.Event {
+	case "push", "Push Hook":
+		event.Event = EventPush
+	case "Tag Push Hook":
+		event.Event = EventTagPush
+	case "pull_request", "Merge Request Hook":
+		event.Event = EventMergeRequest
+		return event, nil
+	case "ping", "Ping Hook":
+		event.Event = EventPing
+		return event, nil
+	default:
+		return event, nil
+	}
+	var payload struct {
+		Ref     string `json:"ref"`
+		Before  string `json:"before"`
+		After   string `json:"after"`
+		Deleted bool   `json:"deleted"`
+		// GitHub和Gitee为sender.login/pusher.name, GitLab为user_username
+		Sender struct {
+			Login string `json:"login"`
+		} `json:"sender"`
+		Pusher struct {
+			Name string `json:"name"`
+		} `json:"pusher"`
+		UserUsername string `json:"user_username"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("解析webhook请求失败: %w", err)
+	}
+	ref := plumbing.ReferenceName(payload.Ref)
+	event.Ref, event.Before, event.After = payload.Ref, payload.Before, payload.After
+	event.Deleted = payload.Deleted || strings.Trim(payload.After, "0") == ""
+	for _, pusher := range []string{payload.Sender.Login, payload.UserUsername, payload.Pusher.Name} {
+		if pusher != "" {
+			event.Pusher = pusher
+			break
+		}
+	}
+	switch {
+	case ref.IsBranch():
+		event.Branch = ref.Short()
+	case ref.IsTag():
+		event.Tag = ref.Short()
+		event.Event = EventTagPush
+	}
+	return event, nil
+}
@@ -0,0 +1,212 @@
+// GitOps配置仓库更新, 修改清单中的镜像标签或values文件中的值, 保留注释
+package gitops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件更新
+type Update struct {
+	File  string `json:"file"`  // 文件路径, 配置仓库内的相对路径, 支持通配符
+	Image string `json:"image"` // 镜像仓库地址(不含标签), 设置时将匹配的镜像标签更新为tag
+	Tag   string `json:"tag"`   // 镜像标签或摘要(sha256:开头)
+	Key   string `json:"key"`   // values文件中的键路径, 以.分隔, 如image.tag
+	Value string `json:"value"` // 键的新值
+}
+
+// 校验更新配置
+func (u Update) Validate() error {
+	if u.File == "" {
+		return errors.New("更新的文件路径不能为空")
+	}
+	switch {
+	case u.Image != "" && u.Key != "":
+		return fmt.Errorf("文件%s的更新不能同时设置image和key", u.File)
+	case u.Image != "":
+		if u.Tag == "" {
+			return fmt.Errorf("文件%s更新镜像%s须设置tag", u.File, u.Image)
+		}
+	case u.Key != "":
+	default:
+		return fmt.Errorf("文件%s的更新须设置image或key", u.File)
+	}
+	return nil
+}
+
+// 按更新配置修改YAML内容, 返回修改后的内容和修改的位置数
+func Apply(data []byte, u Update) ([]byte, int, error) {
+	docs, err := decode(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(docs) == 0 && u.Key != "" {
+		docs = append(docs, &yaml.Node{Kind: yaml.DocumentNode})
+	}
+	count := 0
+	for _, doc := range docs {
+		if u.Image != "" {
+			count += setImage(doc, u.Image, u.Tag)
+		} else {
+			n, err := setValue(doc, strings.Split(u.Key, "."), u.Value)
+			if err != nil {
+				return nil, 0, err
+			}
+			count += n
+		}
+	}
+	if count == 0 {
+		return data, 0, nil
+	}
+	out, err := encode(docs)
+	return out, count, err
+}
+
+func decode(data []byte) ([]*yaml.Node, error) {
+	docs := make([]*yaml.Node, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := &yaml.Node{}
+		if err := decoder.Decode(doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("YAML解析失败: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func encode(docs []*yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 镜像引用拼接标签或摘要
+func imageRef(image, tag string) string {
+	if strings.HasPrefix(tag, "sha256:") {
+		return image + "@" + tag
+	}
+	return image + ":" + tag
+}
+
+// 去掉镜像引用中的标签和摘要
+func imageRepo(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// 更新镜像, 支持以下写法:
+//
+//	image: repo:tag                         k8s清单
+//	images: [{name: repo, newTag: tag}]     kustomization.yaml
+//	image: {repository: repo, tag: tag}     helm values
+func setImage(node *yaml.Node, image, tag string) int {
+	count := 0
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			count += setImage(child, image, tag)
+		}
+	case yaml.MappingNode:
+		// kustomize images
+		if name := mappingValue(node, "name"); name != nil && name.Value == image && (mappingValue(node, "newTag") != nil || mappingValue(node, "newName") != nil || mappingValue(node, "digest") != nil) {
+			if strings.HasPrefix(tag, "sha256:") {
+				return setMappingValue(node, "digest", tag)
+			}
+			return setMappingValue(node, "newTag", tag)
+		}
+		// helm values
+		if repository := mappingValue(node, "repository"); repository != nil && repository.Value == image && mappingValue(node, "tag") != nil {
+			return setMappingValue(node, "tag", tag)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "image" && value.Kind == yaml.ScalarNode && imageRepo(value.Value) == image {
+				ref := imageRef(image, tag)
+				if value.Value != ref {
+					value.Value = ref
+					count++
+				}
+				continue
+			}
+			count += setImage(value, image, tag)
+		}
+	}
+	return count
+}
+
+// 设置键路径的值, 中间的键不存在时创建
+func setValue(doc *yaml.Node, path []string, value string) (int, error) {
+	node := doc
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+		}
+		node = node.Content[0]
+	}
+	for i, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return 0, fmt.Errorf("键%s不是对象", strings.Join(path[:i], "."))
+		}
+		if i == len(path)-1 {
+			return setMappingValue(node, key, value), nil
+		}
+		child := mappingValue(node, key)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		}
+		node = child
+	}
+	return 0, nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// 设置对象的字段, 值为字符串, 返回是否修改
+func setMappingValue(node *yaml.Node, key, value string) int {
+	if current := mappingValue(node, key); current != nil {
+		if current.Kind == yaml.ScalarNode {
+			if current.Value == value {
+				return 0
+			}
+			current.Value, current.Tag = value, "!!str"
+			return 1
+		}
+		*current = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		return 1
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+	return 1
+}
@@ -11,6 +11,7 @@ type Configuration struct {
 	Casbin    CasbinConfiguration    `mapstructure:"casbin" json:"casbin"`
 	Jwt       JwtConfiguration       `mapstructure:"jwt" json:"jwt"`
 	RateLimit RateLimitConfiguration `mapstructure:"rate-limit" json:"rateLimit"`
+	Pipeline  PipelineConfiguration  `mapstructure:"pipeline" json:"pipeline"`
 }
 
 type SystemConfiguration struct {
@@ -55,3 +56,31 @@ type JwtConfiguration struct {
 type RateLimitConfiguration struct {
 	Max int64 `mapstructure:"max" json:"max"`
 }
+
+type PipelineConfiguration struct {
+	WorkerKey          string                  `mapstructure:"worker-key" json:"workerKey"`
+	EncryptionKeyFile  string                  `mapstructure:"encryption-key-file" json:"encryptionKeyFile"`
+	ParserWorkers      int                     `mapstructure:"parser-workers" json:"parserWorkers"`
+	ExecutorWorkers    int                     `mapstructure:"executor-workers" json:"executorWorkers"`
+	ExecutorTimeout    int                     `mapstructure:"executor-timeout" json:"executorTimeout"`
+	DagScheduleTimeout int                     `mapstructure:"dag-schedule-timeout" json:"dagScheduleTimeout"`
+	Labels             map[string]string       `mapstructure:"labels" json:"labels"`
+	Workspace          WorkspaceConfiguration  `mapstructure:"workspace" json:"workspace"`
+	Cache              CacheConfiguration      `mapstructure:"cache" json:"cache"`
+	BuildCache         BuildCacheConfiguration `mapstructure:"build-cache" json:"buildCache"`
+}
+
+type WorkspaceConfiguration struct {
+	Ttl           int  `mapstructure:"ttl" json:"ttl"`
+	KeepOnFailure bool `mapstructure:"keep-on-failure" json:"keepOnFailure"`
+	FailedTtl     int  `mapstructure:"failed-ttl" json:"failedTtl"`
+}
+
+type CacheConfiguration struct {
+	MaxSize int64 `mapstructure:"max-size" json:"maxSize"`
+}
+
+type BuildCacheConfiguration struct {
+	MaxSize          int64 `mapstructure:"max-size" json:"maxSize"`
+	NamespaceMaxSize int64 `mapstructure:"namespace-max-size" json:"namespaceMaxSize"`
+}
@@ -0,0 +1,259 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// 资源变更类型
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionUnchanged = "unchanged"
+)
+
+// 资源所属应用集合的标签, 清理时按该标签查找不再渲染的资源
+const ApplySetLabel = "cdms.io/apply-set"
+
+// 按资源类型排序, 命名空间和CRD等被依赖的资源先创建, 清理时反序删除
+var kindOrder = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 1,
+	"PriorityClass":            2,
+	"StorageClass":             2,
+	"ServiceAccount":           3,
+	"Secret":                   3,
+	"ConfigMap":                3,
+	"PersistentVolumeClaim":    3,
+	"ClusterRole":              4,
+	"ClusterRoleBinding":       4,
+	"Role":                     4,
+	"RoleBinding":              4,
+	"Service":                  5,
+}
+
+// 其他资源的顺序
+const defaultKindOrder = 6
+
+// apply参数
+type ApplyOptions struct {
+	FieldManager string                    // server-side apply的字段管理者
+	Namespace    string                    // 未设置命名空间的资源使用的命名空间, 默认default
+	ApplySet     string                    // 应用集合名称, 设置时给资源打上ApplySetLabel标签
+	Prune        bool                      // 是否删除带有相同标签但不再渲染的资源, 需设置ApplySet
+	PruneKinds   []schema.GroupVersionKind // 清理时额外检查的资源类型, 默认只检查本次渲染的资源类型
+	Force        bool                      // 与其他字段管理者冲突时强制覆盖
+}
+
+// 资源变更
+type Change struct {
+	APIVersion string `json:"apiVersion"` // 资源版本
+	Kind       string `json:"kind"`       // 资源类型
+	Namespace  string `json:"namespace"`  // 命名空间
+	Name       string `json:"name"`       // 名称
+	Action     string `json:"action"`     // 变更类型: create/update/delete/unchanged
+	Diff       string `json:"diff"`       // 与集群中对象的diff
+	Error      string `json:"error"`      // dry-run失败原因, 不为空时diff为与渲染结果的比较
+}
+
+// 变更计划
+type Plan struct {
+	Changes []Change // 全部资源的变更
+	apply   []planItem
+	prune   []planItem
+}
+
+type planItem struct {
+	obj     *unstructured.Unstructured
+	mapping *meta.RESTMapping
+}
+
+// 变更统计
+func (p *Plan) Count(action string) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// 是否有变更
+func (p *Plan) Changed() bool {
+	return p.Count(ActionUnchanged) != len(p.Changes)
+}
+
+func (c *Clients) resource(mapping *meta.RESTMapping, namespace string) dynamic.ResourceInterface {
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return c.Dynamic.Resource(mapping.Resource).Namespace(namespace)
+	}
+	return c.Dynamic.Resource(mapping.Resource)
+}
+
+// 生成变更计划: 以server-side apply的dry-run结果与集群中对象比较, 开启清理时找出不再渲染的资源
+func (c *Clients) Plan(ctx context.Context, objs []*unstructured.Unstructured, opts ApplyOptions) (*Plan, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = metav1.NamespaceDefault
+	}
+	sorted := make([]*unstructured.Unstructured, len(objs))
+	copy(sorted, objs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order(sorted[i].GetKind()) < order(sorted[j].GetKind())
+	})
+	plan := &Plan{Changes: make([]Change, 0, len(objs))}
+	rendered := map[string]bool{}
+	kinds := map[schema.GroupVersionKind]bool{}
+	namespaces := map[string]bool{opts.Namespace: true}
+	for _, obj := range sorted {
+		gvk := obj.GroupVersionKind()
+		mapping, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("%s的资源类型不存在: %w", ObjectName(obj), err)
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(opts.Namespace)
+			}
+			namespaces[obj.GetNamespace()] = true
+		} else {
+			obj.SetNamespace("")
+		}
+		if opts.ApplySet != "" {
+			labels := obj.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[ApplySetLabel] = opts.ApplySet
+			obj.SetLabels(labels)
+		}
+		name := ObjectName(obj)
+		ri := c.resource(mapping, obj.GetNamespace())
+		live, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			live, err = nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("获取%s失败: %w", name, err)
+		}
+		dryRunError := ""
+		desired, err := c.patch(ctx, ri, obj, opts, true)
+		if err != nil {
+			// 新建资源所在的命名空间也是本次新建时dry-run会失败, 直接与渲染结果比较并在计划中记录失败原因
+			namespaceCreated := rendered[objectKey(schema.GroupKind{Kind: "Namespace"}, "", obj.GetNamespace())]
+			if live != nil || !apierrors.IsNotFound(err) || !namespaceCreated {
+				return nil, fmt.Errorf("%s dry-run失败: %w", name, err)
+			}
+			desired, dryRunError = obj, err.Error()
+		}
+		diff, err := Diff(name, live, desired)
+		if err != nil {
+			return nil, err
+		}
+		action := ActionUpdate
+		if live == nil {
+			action = ActionCreate
+		} else if diff == "" {
+			action = ActionUnchanged
+		}
+		plan.Changes = append(plan.Changes, Change{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName(), Action: action, Diff: diff, Error: dryRunError})
+		plan.apply = append(plan.apply, planItem{obj: obj, mapping: mapping})
+		rendered[objectKey(gvk.GroupKind(), obj.GetNamespace(), obj.GetName())] = true
+		kinds[gvk] = true
+	}
+	if !opts.Prune || opts.ApplySet == "" {
+		return plan, nil
+	}
+	for _, gvk := range opts.PruneKinds {
+		kinds[gvk] = true
+	}
+	selector := metav1.ListOptions{LabelSelector: ApplySetLabel + "=" + opts.ApplySet}
+	for gvk := range kinds {
+		mapping, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("清理的资源类型%s不存在: %w", gvk.Kind, err)
+		}
+		scopes := []string{""}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			scopes = scopes[:0]
+			for namespace := range namespaces {
+				scopes = append(scopes, namespace)
+			}
+		}
+		for _, namespace := range scopes {
+			list, err := c.resource(mapping, namespace).List(ctx, selector)
+			if err != nil {
+				return nil, fmt.Errorf("查询待清理的%s失败: %w", gvk.Kind, err)
+			}
+			for i := range list.Items {
+				item := &list.Items[i]
+				if rendered[objectKey(gvk.GroupKind(), item.GetNamespace(), item.GetName())] {
+					continue
+				}
+				diff, err := Diff(ObjectName(item), item, nil)
+				if err != nil {
+					return nil, err
+				}
+				plan.Changes = append(plan.Changes, Change{APIVersion: item.GetAPIVersion(), Kind: item.GetKind(), Namespace: item.GetNamespace(), Name: item.GetName(), Action: ActionDelete, Diff: diff})
+				plan.prune = append(plan.prune, planItem{obj: item, mapping: mapping})
+			}
+		}
+	}
+	sort.SliceStable(plan.prune, func(i, j int) bool {
+		return order(plan.prune[i].obj.GetKind()) > order(plan.prune[j].obj.GetKind())
+	})
+	return plan, nil
+}
+
+// 执行变更计划: 按顺序server-side apply, 再删除不再渲染的资源
+func (c *Clients) Apply(ctx context.Context, plan *Plan, opts ApplyOptions, trace func(format string, a ...interface{})) error {
+	for _, item := range plan.apply {
+		if _, err := c.patch(ctx, c.resource(item.mapping, item.obj.GetNamespace()), item.obj, opts, false); err != nil {
+			return fmt.Errorf("apply %s失败: %w", ObjectName(item.obj), err)
+		}
+		trace("apply %s完成", ObjectName(item.obj))
+	}
+	propagation := metav1.DeletePropagationBackground
+	for _, item := range plan.prune {
+		err := c.resource(item.mapping, item.obj.GetNamespace()).Delete(ctx, item.obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("删除%s失败: %w", ObjectName(item.obj), err)
+		}
+		trace("删除%s完成", ObjectName(item.obj))
+	}
+	return nil
+}
+
+func (c *Clients) patch(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured, opts ApplyOptions, dryRun bool) (*unstructured.Unstructured, error) {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	patchOptions := metav1.PatchOptions{FieldManager: opts.FieldManager, Force: &opts.Force}
+	if dryRun {
+		patchOptions.DryRun = []string{metav1.DryRunAll}
+	}
+	return ri.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, patchOptions)
+}
+
+func order(kind string) int {
+	if o, ok := kindOrder[kind]; ok {
+		return o
+	}
+	return defaultKindOrder
+}
+
+func objectKey(gk schema.GroupKind, namespace, name string) string {
+	return gk.String() + "/" + namespace + "/" + name
+}
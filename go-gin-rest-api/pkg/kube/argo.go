@@ -0,0 +1,235 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Argo Workflows资源
+var WorkflowResource = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "workflows"}
+
+// Argo Workflows的标签和注解
+const (
+	argoWorkflowLabel  = "workflows.argoproj.io/workflow"
+	argoNodeIDAnnotate = "workflows.argoproj.io/node-id"
+)
+
+// Workflow和节点阶段
+const (
+	WorkflowPending   = "Pending"
+	WorkflowRunning   = "Running"
+	WorkflowSucceeded = "Succeeded"
+	WorkflowFailed    = "Failed"
+	WorkflowError     = "Error"
+	WorkflowSkipped   = "Skipped"
+	WorkflowOmitted   = "Omitted"
+)
+
+// 提交Workflow的参数
+type WorkflowSubmit struct {
+	Namespace      string            // 命名空间
+	GenerateName   string            // 名称前缀, 默认<模板名称>-
+	Template       string            // WorkflowTemplate名称
+	ClusterScope   bool              // 是否引用ClusterWorkflowTemplate
+	Entrypoint     string            // 入口模板, 为空时使用WorkflowTemplate的入口
+	ServiceAccount string            // 运行Workflow的ServiceAccount
+	Parameters     map[string]string // Workflow参数
+	Labels         map[string]string // Workflow标签
+}
+
+// Workflow节点
+type WorkflowNode struct {
+	ID          string            `json:"id"`          // 节点ID
+	Name        string            `json:"name"`        // 节点全名
+	DisplayName string            `json:"displayName"` // 显示名称
+	Type        string            `json:"type"`        // 节点类型: Pod/DAG/Steps/StepGroup/Retry/Skipped等
+	Phase       string            `json:"phase"`       // 节点阶段
+	Message     string            `json:"message"`     // 说明
+	StartedAt   string            `json:"startedAt"`   // 开始时间
+	FinishedAt  string            `json:"finishedAt"`  // 结束时间
+	Outputs     map[string]string `json:"outputs"`     // 输出参数
+}
+
+// 节点是否已结束
+func (n WorkflowNode) Completed() bool {
+	switch n.Phase {
+	case WorkflowSucceeded, WorkflowFailed, WorkflowError, WorkflowSkipped, WorkflowOmitted:
+		return true
+	}
+	return false
+}
+
+// 节点耗时
+func (n WorkflowNode) Duration() time.Duration {
+	started, err := time.Parse(time.RFC3339, n.StartedAt)
+	if err != nil {
+		return 0
+	}
+	finished, err := time.Parse(time.RFC3339, n.FinishedAt)
+	if err != nil {
+		return 0
+	}
+	return finished.Sub(started)
+}
+
+// Workflow状态
+type WorkflowStatus struct {
+	Name    string            `json:"name"`    // Workflow名称
+	Phase   string            `json:"phase"`   // Workflow阶段
+	Message string            `json:"message"` // 说明
+	Nodes   []WorkflowNode    `json:"nodes"`   // 全部节点, 按开始时间排序
+	Outputs map[string]string `json:"outputs"` // Workflow输出参数, 即入口节点的输出参数
+}
+
+// 是否已结束
+func (s *WorkflowStatus) Completed() bool {
+	switch s.Phase {
+	case WorkflowSucceeded, WorkflowFailed, WorkflowError:
+		return true
+	}
+	return false
+}
+
+// 从WorkflowTemplate提交Workflow
+func (c *Clients) SubmitWorkflow(ctx context.Context, submit WorkflowSubmit) (string, error) {
+	if submit.Template == "" {
+		return "", fmt.Errorf("未设置WorkflowTemplate")
+	}
+	generateName := submit.GenerateName
+	if generateName == "" {
+		generateName = submit.Template + "-"
+	}
+	templateRef := map[string]interface{}{"name": submit.Template}
+	if submit.ClusterScope {
+		templateRef["clusterScope"] = true
+	}
+	spec := map[string]interface{}{"workflowTemplateRef": templateRef}
+	if submit.Entrypoint != "" {
+		spec["entrypoint"] = submit.Entrypoint
+	}
+	if submit.ServiceAccount != "" {
+		spec["serviceAccountName"] = submit.ServiceAccount
+	}
+	if len(submit.Parameters) > 0 {
+		names := make([]string, 0, len(submit.Parameters))
+		for name := range submit.Parameters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parameters := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			parameters = append(parameters, map[string]interface{}{"name": name, "value": submit.Parameters[name]})
+		}
+		spec["arguments"] = map[string]interface{}{"parameters": parameters}
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": WorkflowResource.GroupVersion().String(),
+		"kind":       "Workflow",
+		"spec":       spec,
+	}}
+	obj.SetGenerateName(generateName)
+	obj.SetNamespace(submit.Namespace)
+	if len(submit.Labels) > 0 {
+		obj.SetLabels(submit.Labels)
+	}
+	created, err := c.Dynamic.Resource(WorkflowResource).Namespace(submit.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("提交Workflow失败: %w", err)
+	}
+	return created.GetName(), nil
+}
+
+// 查询Workflow状态
+func (c *Clients) GetWorkflow(ctx context.Context, namespace, name string) (*WorkflowStatus, error) {
+	obj, err := c.Dynamic.Resource(WorkflowResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return ParseWorkflowStatus(obj), nil
+}
+
+// 解析Workflow状态
+func ParseWorkflowStatus(obj *unstructured.Unstructured) *WorkflowStatus {
+	status := &WorkflowStatus{Name: obj.GetName(), Nodes: make([]WorkflowNode, 0), Outputs: map[string]string{}}
+	status.Phase, _, _ = unstructured.NestedString(obj.Object, "status", "phase")
+	status.Message, _, _ = unstructured.NestedString(obj.Object, "status", "message")
+	nodes, _, _ := unstructured.NestedMap(obj.Object, "status", "nodes")
+	for id, raw := range nodes {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		node := WorkflowNode{ID: id, Outputs: map[string]string{}}
+		node.Name, _, _ = unstructured.NestedString(m, "name")
+		node.DisplayName, _, _ = unstructured.NestedString(m, "displayName")
+		node.Type, _, _ = unstructured.NestedString(m, "type")
+		node.Phase, _, _ = unstructured.NestedString(m, "phase")
+		node.Message, _, _ = unstructured.NestedString(m, "message")
+		node.StartedAt, _, _ = unstructured.NestedString(m, "startedAt")
+		node.FinishedAt, _, _ = unstructured.NestedString(m, "finishedAt")
+		parameters, _, _ := unstructured.NestedSlice(m, "outputs", "parameters")
+		for _, p := range parameters {
+			param, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(param, "name")
+			value, _, _ := unstructured.NestedString(param, "value")
+			if name != "" {
+				node.Outputs[name] = value
+			}
+		}
+		// 入口节点的ID与Workflow名称相同
+		if id == obj.GetName() {
+			status.Outputs = node.Outputs
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	sort.SliceStable(status.Nodes, func(i, j int) bool {
+		if status.Nodes[i].StartedAt != status.Nodes[j].StartedAt {
+			return status.Nodes[i].StartedAt < status.Nodes[j].StartedAt
+		}
+		return status.Nodes[i].Name < status.Nodes[j].Name
+	})
+	return status
+}
+
+// 查询Workflow的Pod, 返回节点ID到Pod名称的映射
+func (c *Clients) WorkflowPods(ctx context.Context, namespace, name string) (map[string]string, error) {
+	pods, err := c.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: argoWorkflowLabel + "=" + name})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(pods.Items))
+	for _, pod := range pods.Items {
+		if id := pod.Annotations[argoNodeIDAnnotate]; id != "" {
+			result[id] = pod.Name
+		}
+	}
+	return result, nil
+}
+
+// 获取Pod日志, 超过limitBytes时截断
+func (c *Clients) PodLogs(ctx context.Context, namespace, pod, container string, limitBytes int64) (string, error) {
+	data, err := c.Kubernetes.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{Container: container, LimitBytes: &limitBytes}).DoRaw(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// 终止Workflow, 与argo terminate一致
+func (c *Clients) TerminateWorkflow(ctx context.Context, namespace, name string) error {
+	patch := []byte(`{"spec":{"shutdown":"Terminate"}}`)
+	_, err := c.Dynamic.Resource(WorkflowResource).Namespace(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
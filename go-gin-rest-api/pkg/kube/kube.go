@@ -0,0 +1,79 @@
+// k8s集群客户端, 集群以kubeconfig文件保存在storage/kubeconfig目录, 文件名即集群名称
+package kube
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeconfig目录
+var Dir = "./storage/kubeconfig"
+
+// 集群名称只允许字母数字及._-, 且不能以.开头
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// 集群客户端
+type Clients struct {
+	Kubernetes kubernetes.Interface // 内置资源客户端
+	Dynamic    dynamic.Interface    // 动态客户端, 用于任意资源
+	Mapper     meta.RESTMapper      // GVK到资源的映射
+}
+
+// 创建集群客户端, 可替换为fake客户端
+var NewClients = newClients
+
+func newClients(cluster string) (*Clients, error) {
+	config, err := RestConfig(cluster)
+	if err != nil {
+		return nil, err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建集群%s客户端失败: %w", cluster, err)
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建集群%s客户端失败: %w", cluster, err)
+	}
+	return &Clients{
+		Kubernetes: kube,
+		Dynamic:    dyn,
+		Mapper:     restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(kube.Discovery())),
+	}, nil
+}
+
+// 获取集群连接配置, 集群名称为空时使用所在集群的ServiceAccount
+func RestConfig(cluster string) (*rest.Config, error) {
+	if cluster == "" {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("获取所在集群配置失败: %w", err)
+		}
+		return config, nil
+	}
+	if !nameRegexp.MatchString(cluster) {
+		return nil, fmt.Errorf("非法的集群名称: %s", cluster)
+	}
+	path := filepath.Join(Dir, cluster)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("集群kubeconfig不存在: %s", cluster)
+		}
+		return nil, err
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, fmt.Errorf("读取集群%s的kubeconfig失败: %w", cluster, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,146 @@
+package kube
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// 解析YAML/JSON清单, 支持多文档和List类型, 跳过空文档
+func DecodeManifests(data []byte) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0)
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for i := 1; ; i++ {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("第%d个文档解析失败: %w", i, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("第%d个文档缺少apiVersion或kind", i)
+		}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("第%d个文档解析失败: %w", i, err)
+			}
+			for j := range list.Items {
+				objs = append(objs, &list.Items[j])
+			}
+			continue
+		}
+		if obj.GetName() == "" {
+			return nil, fmt.Errorf("第%d个文档%s缺少metadata.name", i, obj.GetKind())
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// 对象的显示名称, 如Deployment default/nginx
+func ObjectName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetKind() + " " + obj.GetName()
+	}
+	return obj.GetKind() + " " + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// 去掉服务端维护的字段, 只保留用于比较的内容
+func sanitize(obj *unstructured.Unstructured) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	copied := obj.DeepCopy()
+	for _, field := range []string{"managedFields", "resourceVersion", "uid", "generation", "creationTimestamp", "selfLink"} {
+		unstructured.RemoveNestedField(copied.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(copied.Object, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	unstructured.RemoveNestedField(copied.Object, "metadata", "annotations", "deployment.kubernetes.io/revision")
+	if annotations, ok, _ := unstructured.NestedMap(copied.Object, "metadata", "annotations"); ok && len(annotations) == 0 {
+		unstructured.RemoveNestedField(copied.Object, "metadata", "annotations")
+	}
+	delete(copied.Object, "status")
+	if copied.GetKind() == "Secret" && copied.GroupVersionKind().Group == "" {
+		maskSecret(copied.Object)
+	}
+	return copied.Object
+}
+
+// 进程内随机生成的Secret屏蔽密钥, 同一次diff中相同的值得到相同的摘要, 保存的diff无法反推原值
+var secretMaskKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// 屏蔽Secret的data和stringData, 值替换为HMAC摘要, diff中只能看出哪些键有变化
+func maskSecret(object map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok, _ := unstructured.NestedMap(object, field)
+		if !ok {
+			continue
+		}
+		for key, value := range values {
+			raw := []byte(fmt.Sprint(value))
+			// data为base64编码, 解码后计算摘要, 与stringData中相同的值一致
+			if field == "data" {
+				if decoded, err := base64.StdEncoding.DecodeString(string(raw)); err == nil {
+					raw = decoded
+				}
+			}
+			mac := hmac.New(sha256.New, secretMaskKey)
+			mac.Write(raw)
+			values[key] = "<masked:" + hex.EncodeToString(mac.Sum(nil))[:16] + ">"
+		}
+		unstructured.SetNestedMap(object, values, field)
+	}
+}
+
+// 生成对象变更的unified diff, live为空表示新建, desired为空表示删除, Secret的值会被屏蔽
+func Diff(name string, live, desired *unstructured.Unstructured) (string, error) {
+	toYaml := func(obj *unstructured.Unstructured) (string, error) {
+		object := sanitize(obj)
+		if object == nil {
+			return "", nil
+		}
+		data, err := yaml.Marshal(object)
+		return string(data), err
+	}
+	from, err := toYaml(live)
+	if err != nil {
+		return "", err
+	}
+	to, err := toYaml(desired)
+	if err != nil {
+		return "", err
+	}
+	if from == to {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "live/" + strings.ReplaceAll(name, " ", "/"),
+		ToFile:   "desired/" + strings.ReplaceAll(name, " ", "/"),
+		Context:  3,
+	})
+}
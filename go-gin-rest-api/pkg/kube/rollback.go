@@ -0,0 +1,168 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// Deployment版本号注解
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// 回滚工作负载到上一个版本, 与kubectl rollout undo一致, 返回回滚到的版本号
+func (c *Clients) Undo(ctx context.Context, w Workload) (int64, error) {
+	switch w.Kind {
+	case KindDeployment:
+		return c.undoDeployment(ctx, w)
+	case KindStatefulSet:
+		return c.undoStatefulSet(ctx, w)
+	case KindCloneSet, KindAdvancedStatefulSet:
+		return c.undoKruise(ctx, w)
+	}
+	return 0, fmt.Errorf("不支持的工作负载类型: %s", w.Kind)
+}
+
+// Deployment: 以上一个版本的ReplicaSet的Pod模板替换当前模板
+func (c *Clients) undoDeployment(ctx context.Context, w Workload) (int64, error) {
+	var revision int64
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deploy, err := c.Kubernetes.AppsV1().Deployments(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if deploy.Spec.Paused {
+			return fmt.Errorf("%s已暂停, 无法回滚", w)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(deploy.Spec.Selector)
+		if err != nil {
+			return err
+		}
+		list, err := c.Kubernetes.AppsV1().ReplicaSets(w.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return err
+		}
+		current, _ := strconv.ParseInt(deploy.Annotations[deploymentRevisionAnnotation], 10, 64)
+		var previous *appsv1.ReplicaSet
+		for i := range list.Items {
+			rs := &list.Items[i]
+			if !metav1.IsControlledBy(rs, deploy) {
+				continue
+			}
+			r, err := strconv.ParseInt(rs.Annotations[deploymentRevisionAnnotation], 10, 64)
+			if err != nil || r >= current {
+				continue
+			}
+			if r > revision {
+				revision, previous = r, rs
+			}
+		}
+		if previous == nil {
+			return fmt.Errorf("%s没有可回滚的历史版本", w)
+		}
+		template := previous.Spec.Template.DeepCopy()
+		delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+		deploy.Spec.Template = *template
+		_, err = c.Kubernetes.AppsV1().Deployments(w.Namespace).Update(ctx, deploy, metav1.UpdateOptions{})
+		return err
+	})
+	return revision, err
+}
+
+// StatefulSet: 以上一个ControllerRevision的数据patch当前对象
+func (c *Clients) undoStatefulSet(ctx context.Context, w Workload) (int64, error) {
+	sts, err := c.Kubernetes.AppsV1().StatefulSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	previous, err := c.previousRevision(ctx, w, sts.Spec.Selector, sts.UID, sts.Status.UpdateRevision)
+	if err != nil {
+		return 0, err
+	}
+	_, err = c.Kubernetes.AppsV1().StatefulSets(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, previous.Data.Raw, metav1.PatchOptions{})
+	return previous.Revision, err
+}
+
+// OpenKruise工作负载: 以上一个ControllerRevision中的Pod模板替换当前模板
+func (c *Clients) undoKruise(ctx context.Context, w Workload) (int64, error) {
+	resource := cloneSetResource
+	if w.Kind == KindAdvancedStatefulSet {
+		resource = advancedStatefulSetResource
+	}
+	ri := c.Dynamic.Resource(resource).Namespace(w.Namespace)
+	var revision int64
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := ri.Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		selector := &metav1.LabelSelector{}
+		if raw, ok, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); ok {
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, selector); err != nil {
+				return err
+			}
+		}
+		updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		previous, err := c.previousRevision(ctx, w, selector, obj.GetUID(), updateRevision)
+		if err != nil {
+			return err
+		}
+		data := map[string]interface{}{}
+		if err := json.Unmarshal(previous.Data.Raw, &data); err != nil {
+			return fmt.Errorf("解析%s的历史版本失败: %w", w, err)
+		}
+		template, ok, _ := unstructured.NestedMap(data, "spec", "template")
+		if !ok {
+			return fmt.Errorf("%s的历史版本%d缺少Pod模板", w, previous.Revision)
+		}
+		delete(template, "$patch")
+		if err := unstructured.SetNestedMap(obj.Object, template, "spec", "template"); err != nil {
+			return err
+		}
+		revision = previous.Revision
+		_, err = ri.Update(ctx, obj, metav1.UpdateOptions{})
+		return err
+	})
+	return revision, err
+}
+
+// 查找当前版本之前的最近一个ControllerRevision
+func (c *Clients) previousRevision(ctx context.Context, w Workload, labelSelector *metav1.LabelSelector, owner types.UID, updateRevision string) (*appsv1.ControllerRevision, error) {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.Kubernetes.AppsV1().ControllerRevisions(w.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]*appsv1.ControllerRevision, 0)
+	current := int64(-1)
+	for i := range list.Items {
+		rev := &list.Items[i]
+		if ref := metav1.GetControllerOf(rev); ref == nil || ref.UID != owner {
+			continue
+		}
+		if rev.Name == updateRevision {
+			current = rev.Revision
+		}
+		revisions = append(revisions, rev)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision > revisions[j].Revision
+	})
+	for _, rev := range revisions {
+		if rev.Name != updateRevision && (current < 0 || rev.Revision < current) {
+			return rev, nil
+		}
+	}
+	return nil, fmt.Errorf("%s没有可回滚的历史版本", w)
+}
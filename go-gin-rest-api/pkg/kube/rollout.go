@@ -0,0 +1,436 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// 支持校验发布状态的工作负载类型
+const (
+	KindDeployment          = "Deployment"
+	KindStatefulSet         = "StatefulSet"
+	KindCloneSet            = "CloneSet"            // OpenKruise CloneSet
+	KindAdvancedStatefulSet = "AdvancedStatefulSet" // OpenKruise Advanced StatefulSet(apps.kruise.io StatefulSet)
+)
+
+var (
+	cloneSetResource            = schema.GroupVersionResource{Group: "apps.kruise.io", Version: "v1alpha1", Resource: "clonesets"}
+	advancedStatefulSetResource = schema.GroupVersionResource{Group: "apps.kruise.io", Version: "v1beta1", Resource: "statefulsets"}
+)
+
+// 容器处于这些等待原因时视为发布失败, 不再等待
+var degradedReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// 工作负载
+type Workload struct {
+	Kind      string `json:"kind"`      // 类型
+	Namespace string `json:"namespace"` // 命名空间
+	Name      string `json:"name"`      // 名称
+}
+
+func (w Workload) String() string {
+	return w.Kind + " " + w.Namespace + "/" + w.Name
+}
+
+// 解析工作负载, 格式为Kind/name或Kind/namespace/name
+func ParseWorkload(s, namespace string) (Workload, error) {
+	parts := strings.Split(s, "/")
+	w := Workload{Namespace: namespace}
+	switch len(parts) {
+	case 2:
+		w.Kind, w.Name = parts[0], parts[1]
+	case 3:
+		w.Kind, w.Namespace, w.Name = parts[0], parts[1], parts[2]
+	default:
+		return w, fmt.Errorf("非法的工作负载%s, 格式为Kind/name或Kind/namespace/name", s)
+	}
+	switch w.Kind {
+	case KindDeployment, KindStatefulSet, KindCloneSet, KindAdvancedStatefulSet:
+	default:
+		return w, fmt.Errorf("不支持的工作负载类型: %s", w.Kind)
+	}
+	if w.Name == "" {
+		return w, fmt.Errorf("非法的工作负载%s, 名称不能为空", s)
+	}
+	if w.Namespace == "" {
+		w.Namespace = metav1.NamespaceDefault
+	}
+	return w, nil
+}
+
+// 按apiVersion和kind识别工作负载类型, 不支持的类型返回空
+func WorkloadKind(apiVersion, kind string) string {
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	switch {
+	case gv.Group == "apps" && (kind == KindDeployment || kind == KindStatefulSet):
+		return kind
+	case gv.Group == cloneSetResource.Group && kind == KindCloneSet:
+		return KindCloneSet
+	case gv.Group == advancedStatefulSetResource.Group && kind == KindStatefulSet:
+		return KindAdvancedStatefulSet
+	}
+	return ""
+}
+
+// 发布状态
+type RolloutStatus struct {
+	Done     bool                  `json:"done"`     // 是否完成
+	Stalled  bool                  `json:"stalled"`  // 是否已停滞(如超过progressDeadlineSeconds)
+	Message  string                `json:"message"`  // 状态说明
+	Replicas int32                 `json:"replicas"` // 期望副本数
+	Updated  int32                 `json:"updated"`  // 已更新副本数
+	Ready    int32                 `json:"ready"`    // 就绪副本数
+	Selector *metav1.LabelSelector `json:"-"`        // 最新版本Pod的选择器, 最新版本尚未创建时为空
+}
+
+// 进度, 用于判断发布是否停滞
+func (s *RolloutStatus) Progress() string {
+	return fmt.Sprintf("%d/%d/%d", s.Replicas, s.Updated, s.Ready)
+}
+
+// 查询工作负载的发布状态
+func (c *Clients) RolloutStatus(ctx context.Context, w Workload) (*RolloutStatus, error) {
+	switch w.Kind {
+	case KindDeployment:
+		deploy, err := c.Kubernetes.AppsV1().Deployments(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		status := deploymentStatus(deploy)
+		hash, err := c.newReplicaSetHash(ctx, deploy)
+		if err != nil {
+			return nil, err
+		}
+		status.Selector = revisionSelector(status.Selector, appsv1.DefaultDeploymentUniqueLabelKey, hash)
+		return status, nil
+	case KindStatefulSet:
+		sts, err := c.Kubernetes.AppsV1().StatefulSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return statefulSetStatus(sts), nil
+	case KindCloneSet:
+		obj, err := c.Dynamic.Resource(cloneSetResource).Namespace(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return kruiseStatus(obj, false), nil
+	case KindAdvancedStatefulSet:
+		obj, err := c.Dynamic.Resource(advancedStatefulSetResource).Namespace(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return kruiseStatus(obj, true), nil
+	}
+	return nil, fmt.Errorf("不支持的工作负载类型: %s", w.Kind)
+}
+
+// Deployment当前版本的ReplicaSet的pod-template-hash, 控制器尚未创建时返回空
+func (c *Clients) newReplicaSetHash(ctx context.Context, deploy *appsv1.Deployment) (string, error) {
+	revision := deploy.Annotations[deploymentRevisionAnnotation]
+	if revision == "" || deploy.Generation > deploy.Status.ObservedGeneration {
+		return "", nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deploy.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+	list, err := c.Kubernetes.AppsV1().ReplicaSets(deploy.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", err
+	}
+	for i := range list.Items {
+		rs := &list.Items[i]
+		if metav1.IsControlledBy(rs, deploy) && rs.Annotations[deploymentRevisionAnnotation] == revision {
+			return rs.Labels[appsv1.DefaultDeploymentUniqueLabelKey], nil
+		}
+	}
+	return "", nil
+}
+
+// 在工作负载选择器上增加版本标签, 只匹配最新版本的Pod, 版本为空时返回nil不匹配任何Pod
+func revisionSelector(selector *metav1.LabelSelector, key, revision string) *metav1.LabelSelector {
+	if selector == nil || revision == "" {
+		return nil
+	}
+	copied := selector.DeepCopy()
+	if copied.MatchLabels == nil {
+		copied.MatchLabels = map[string]string{}
+	}
+	copied.MatchLabels[key] = revision
+	return copied
+}
+
+// 与kubectl rollout status的判断一致
+func deploymentStatus(deploy *appsv1.Deployment) *RolloutStatus {
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	status := &RolloutStatus{
+		Replicas: replicas,
+		Updated:  deploy.Status.UpdatedReplicas,
+		Ready:    deploy.Status.AvailableReplicas,
+		Selector: deploy.Spec.Selector,
+	}
+	switch {
+	case deploy.Generation > deploy.Status.ObservedGeneration:
+		status.Message = "等待控制器处理最新的变更"
+	case progressDeadlineExceeded(deploy):
+		status.Stalled = true
+		status.Message = "发布超过progressDeadlineSeconds仍未完成"
+	case deploy.Status.UpdatedReplicas < replicas:
+		status.Message = fmt.Sprintf("已更新%d/%d个副本", deploy.Status.UpdatedReplicas, replicas)
+	case deploy.Status.Replicas > deploy.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("等待%d个旧副本终止", deploy.Status.Replicas-deploy.Status.UpdatedReplicas)
+	case deploy.Status.AvailableReplicas < deploy.Status.UpdatedReplicas:
+		status.Message = fmt.Sprintf("可用%d/%d个副本", deploy.Status.AvailableReplicas, deploy.Status.UpdatedReplicas)
+	default:
+		status.Done = true
+		status.Message = "发布完成"
+	}
+	return status
+}
+
+func progressDeadlineExceeded(deploy *appsv1.Deployment) bool {
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+func statefulSetStatus(sts *appsv1.StatefulSet) *RolloutStatus {
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	status := &RolloutStatus{
+		Replicas: replicas,
+		Updated:  sts.Status.UpdatedReplicas,
+		Ready:    sts.Status.ReadyReplicas,
+		Selector: revisionSelector(sts.Spec.Selector, appsv1.ControllerRevisionHashLabelKey, sts.Status.UpdateRevision),
+	}
+	partition := int32(0)
+	if rolling := sts.Spec.UpdateStrategy.RollingUpdate; rolling != nil && rolling.Partition != nil {
+		partition = *rolling.Partition
+	}
+	switch {
+	case sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType:
+		status.Done = true
+		status.Message = "OnDelete更新策略不支持校验发布状态"
+	case sts.Generation > sts.Status.ObservedGeneration:
+		status.Message = "等待控制器处理最新的变更"
+	case sts.Status.ReadyReplicas < replicas:
+		status.Message = fmt.Sprintf("就绪%d/%d个副本", sts.Status.ReadyReplicas, replicas)
+	case partition > 0 && sts.Status.UpdatedReplicas < replicas-partition:
+		status.Message = fmt.Sprintf("分区发布, 已更新%d/%d个副本", sts.Status.UpdatedReplicas, replicas-partition)
+	case partition == 0 && sts.Status.UpdateRevision != sts.Status.CurrentRevision:
+		status.Message = fmt.Sprintf("已更新%d/%d个副本", sts.Status.UpdatedReplicas, replicas)
+	default:
+		status.Done = true
+		status.Message = "发布完成"
+	}
+	return status
+}
+
+// OpenKruise工作负载, 按分区计算期望更新的副本数, 已更新且就绪的副本达到期望时完成
+func kruiseStatus(obj *unstructured.Unstructured, statefulSet bool) *RolloutStatus {
+	replicas := int32(1)
+	if v, ok, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); ok {
+		replicas = int32(v)
+	}
+	nestedInt := func(fields ...string) int32 {
+		v, _, _ := unstructured.NestedInt64(obj.Object, fields...)
+		return int32(v)
+	}
+	observed := nestedInt("status", "observedGeneration")
+	updated := nestedInt("status", "updatedReplicas")
+	updatedReady := nestedInt("status", "updatedReadyReplicas")
+	ready := nestedInt("status", "readyReplicas")
+	partition := int32(0)
+	if raw, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "updateStrategy", "partition"); ok {
+		partition = kruisePartition(raw, replicas)
+	} else if raw, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition"); ok && statefulSet {
+		partition = kruisePartition(raw, replicas)
+	}
+	expected := replicas - partition
+	if expected < 0 {
+		expected = 0
+	}
+	status := &RolloutStatus{Replicas: replicas, Updated: updated, Ready: ready}
+	if selector, ok, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); ok {
+		labelSelector := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, labelSelector); err == nil {
+			updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+			status.Selector = revisionSelector(labelSelector, appsv1.ControllerRevisionHashLabelKey, updateRevision)
+		}
+	}
+	switch {
+	case int64(observed) < obj.GetGeneration():
+		status.Message = "等待控制器处理最新的变更"
+	case updated < expected:
+		status.Message = fmt.Sprintf("已更新%d/%d个副本", updated, expected)
+	case updatedReady < expected:
+		status.Message = fmt.Sprintf("已更新的副本就绪%d/%d个", updatedReady, expected)
+	case ready < replicas:
+		status.Message = fmt.Sprintf("就绪%d/%d个副本", ready, replicas)
+	default:
+		status.Done = true
+		status.Message = "发布完成"
+		if partition > 0 {
+			status.Message = fmt.Sprintf("分区发布完成, 已更新%d/%d个副本", updated, replicas)
+		}
+	}
+	return status
+}
+
+// 分区可以是数字或百分比
+func kruisePartition(raw interface{}, replicas int32) int32 {
+	var value intstr.IntOrString
+	switch v := raw.(type) {
+	case int64:
+		value = intstr.FromInt32(int32(v))
+	case float64:
+		value = intstr.FromInt32(int32(v))
+	case string:
+		value = intstr.FromString(v)
+	default:
+		return 0
+	}
+	partition, err := intstr.GetScaledValueFromIntOrPercent(&value, int(replicas), true)
+	if err != nil {
+		return 0
+	}
+	return int32(partition)
+}
+
+// 异常Pod
+type UnhealthyPod struct {
+	Name       string   `json:"name"`       // Pod名称
+	Phase      string   `json:"phase"`      // Pod阶段
+	Degraded   bool     `json:"degraded"`   // 是否为不会自愈的异常, 如CrashLoopBackOff、镜像拉取失败
+	Containers []string `json:"containers"` // 容器异常说明, 包含等待原因、上次终止原因和退出码
+	Events     []string `json:"events"`     // 最近的Warning事件
+}
+
+// 查询选择器匹配的异常Pod及其最近事件, 选择器通常为RolloutStatus返回的最新版本Pod选择器
+func (c *Clients) UnhealthyPods(ctx context.Context, namespace string, selector *metav1.LabelSelector, maxEvents int) ([]UnhealthyPod, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := c.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return nil, err
+	}
+	unhealthy := make([]UnhealthyPod, 0)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		item, ok := podHealth(pod)
+		if !ok {
+			continue
+		}
+		events, err := c.Kubernetes.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: "involvedObject.kind=Pod,involvedObject.name=" + pod.Name,
+		})
+		if err == nil {
+			item.Events = recentEvents(events.Items, maxEvents)
+		}
+		unhealthy = append(unhealthy, item)
+	}
+	return unhealthy, nil
+}
+
+// 检查Pod健康状态, 返回是否异常
+func podHealth(pod *corev1.Pod) (UnhealthyPod, bool) {
+	item := UnhealthyPod{Name: pod.Name, Phase: string(pod.Status.Phase), Containers: make([]string, 0)}
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded {
+		return item, false
+	}
+	unhealthy := pod.Status.Phase == corev1.PodFailed
+	if pod.Status.Phase == corev1.PodFailed {
+		item.Degraded = true
+		item.Containers = append(item.Containers, fmt.Sprintf("Pod失败: %s %s", pod.Status.Reason, pod.Status.Message))
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		msgs := make([]string, 0)
+		if waiting := cs.State.Waiting; waiting != nil && waiting.Reason != "" && waiting.Reason != "ContainerCreating" && waiting.Reason != "PodInitializing" {
+			msgs = append(msgs, fmt.Sprintf("等待中: %s %s", waiting.Reason, waiting.Message))
+			if degradedReasons[waiting.Reason] {
+				item.Degraded = true
+			}
+		}
+		if terminated := cs.LastTerminationState.Terminated; terminated != nil && cs.RestartCount > 0 {
+			msgs = append(msgs, fmt.Sprintf("重启%d次, 上次终止: %s, 退出码%d %s", cs.RestartCount, terminated.Reason, terminated.ExitCode, terminated.Message))
+		}
+		if terminated := cs.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			msgs = append(msgs, fmt.Sprintf("已终止: %s, 退出码%d %s", terminated.Reason, terminated.ExitCode, terminated.Message))
+		}
+		if len(msgs) > 0 {
+			unhealthy = true
+			item.Containers = append(item.Containers, cs.Name+": "+strings.Join(msgs, "; "))
+		}
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse {
+			unhealthy = true
+			item.Containers = append(item.Containers, fmt.Sprintf("无法调度: %s %s", cond.Reason, cond.Message))
+		}
+	}
+	return item, unhealthy
+}
+
+// 最近的Warning事件
+func recentEvents(events []corev1.Event, max int) []string {
+	warnings := make([]corev1.Event, 0)
+	for _, event := range events {
+		if event.Type == corev1.EventTypeWarning {
+			warnings = append(warnings, event)
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return eventTime(warnings[i]).Time.After(eventTime(warnings[j]).Time)
+	})
+	if len(warnings) > max {
+		warnings = warnings[:max]
+	}
+	list := make([]string, 0, len(warnings))
+	for _, event := range warnings {
+		list = append(list, fmt.Sprintf("%s %s(x%d): %s", eventTime(event).Format("15:04:05"), event.Reason, event.Count, event.Message))
+	}
+	return list
+}
+
+func eventTime(event corev1.Event) metav1.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp
+	}
+	if !event.EventTime.IsZero() {
+		return metav1.NewTime(event.EventTime.Time)
+	}
+	return event.CreationTimestamp
+}
@@ -0,0 +1,235 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func fakeClients(objs ...runtime.Object) *Clients {
+	scheme := runtime.NewScheme()
+	listKinds := map[schema.GroupVersionResource]string{
+		cloneSetResource:            "CloneSetList",
+		advancedStatefulSetResource: "StatefulSetList",
+	}
+	typed := make([]runtime.Object, 0, len(objs))
+	dynamic := make([]runtime.Object, 0)
+	for _, obj := range objs {
+		if _, ok := obj.(*unstructured.Unstructured); ok {
+			dynamic = append(dynamic, obj)
+		} else {
+			typed = append(typed, obj)
+		}
+	}
+	return &Clients{
+		Kubernetes: fake.NewSimpleClientset(typed...),
+		Dynamic:    dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, listKinds, dynamic...),
+	}
+}
+
+func testDeployment(revision string, replicas, updated, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web", Namespace: "default", UID: types.UID("deploy-uid"), Generation: 2,
+			Annotations: map[string]string{deploymentRevisionAnnotation: revision},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           updated,
+			UpdatedReplicas:    updated,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+func testReplicaSet(deploy *appsv1.Deployment, revision, hash string) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-" + hash, Namespace: "default",
+		Labels:          map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: hash},
+		Annotations:     map[string]string{deploymentRevisionAnnotation: revision},
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deploy, appsv1.SchemeGroupVersion.WithKind(KindDeployment))},
+	}}
+}
+
+func crashingPod(name string, labels map[string]string, reason string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}},
+			}},
+		},
+	}
+}
+
+func healthyPod(name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+		},
+	}
+}
+
+func podNames(pods []UnhealthyPod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func TestRolloutStatusDeployment(t *testing.T) {
+	cases := []struct {
+		name     string
+		deploy   *appsv1.Deployment
+		done     bool
+		progress string
+	}{
+		{"done", testDeployment("2", 2, 2, 2), true, "2/2/2"},
+		{"updating", testDeployment("2", 2, 1, 1), false, "2/1/1"},
+		{"unavailable", testDeployment("2", 2, 2, 1), false, "2/2/1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clients := fakeClients(c.deploy, testReplicaSet(c.deploy, "2", "new"))
+			status, err := clients.RolloutStatus(context.Background(), Workload{Kind: KindDeployment, Namespace: "default", Name: "web"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Done != c.done || status.Progress() != c.progress {
+				t.Errorf("done=%v progress=%s, want done=%v progress=%s (%s)", status.Done, status.Progress(), c.done, c.progress, status.Message)
+			}
+		})
+	}
+}
+
+func TestUnhealthyPodsDeploymentNewReplicaSet(t *testing.T) {
+	deploy := testDeployment("2", 2, 1, 0)
+	clients := fakeClients(
+		deploy,
+		testReplicaSet(deploy, "1", "old"),
+		testReplicaSet(deploy, "2", "new"),
+		crashingPod("web-old-1", map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: "old"}, "CrashLoopBackOff"),
+		crashingPod("web-new-1", map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: "new"}, "ImagePullBackOff"),
+		healthyPod("web-new-2", map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: "new"}),
+	)
+	ctx := context.Background()
+	status, err := clients.RolloutStatus(ctx, Workload{Kind: KindDeployment, Namespace: "default", Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods, err := clients.UnhealthyPods(ctx, "default", status.Selector, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Name != "web-new-1" || !pods[0].Degraded {
+		t.Fatalf("unhealthy pods = %v, want only degraded web-new-1", podNames(pods))
+	}
+}
+
+func TestUnhealthyPodsDeploymentWithoutNewReplicaSet(t *testing.T) {
+	deploy := testDeployment("3", 2, 0, 0)
+	clients := fakeClients(
+		deploy,
+		testReplicaSet(deploy, "2", "old"),
+		crashingPod("web-old-1", map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: "old"}, "CrashLoopBackOff"),
+	)
+	ctx := context.Background()
+	status, err := clients.RolloutStatus(ctx, Workload{Kind: KindDeployment, Namespace: "default", Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Selector != nil {
+		t.Fatalf("selector = %v, want nil before the new ReplicaSet exists", status.Selector)
+	}
+	pods, err := clients.UnhealthyPods(ctx, "default", status.Selector, 5)
+	if err != nil || len(pods) != 0 {
+		t.Fatalf("unhealthy pods = %v, %v, want none", podNames(pods), err)
+	}
+}
+
+func TestUnhealthyPodsStatefulSetUpdateRevision(t *testing.T) {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", Generation: 1},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: ptr.To(int32(2)),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+		},
+		Status: appsv1.StatefulSetStatus{
+			ObservedGeneration: 1,
+			ReadyReplicas:      1,
+			UpdatedReplicas:    1,
+			CurrentRevision:    "db-1",
+			UpdateRevision:     "db-2",
+		},
+	}
+	clients := fakeClients(
+		sts,
+		crashingPod("db-0", map[string]string{"app": "db", appsv1.ControllerRevisionHashLabelKey: "db-1"}, "CrashLoopBackOff"),
+		crashingPod("db-1", map[string]string{"app": "db", appsv1.ControllerRevisionHashLabelKey: "db-2"}, "CreateContainerConfigError"),
+	)
+	ctx := context.Background()
+	status, err := clients.RolloutStatus(ctx, Workload{Kind: KindStatefulSet, Namespace: "default", Name: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Done {
+		t.Fatalf("status done, want in progress: %s", status.Message)
+	}
+	pods, err := clients.UnhealthyPods(ctx, "default", status.Selector, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Name != "db-1" {
+		t.Fatalf("unhealthy pods = %v, want only db-1", podNames(pods))
+	}
+}
+
+func TestRolloutStatusCloneSetPartition(t *testing.T) {
+	cloneSet := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.kruise.io/v1alpha1",
+		"kind":       KindCloneSet,
+		"metadata":   map[string]interface{}{"name": "api", "namespace": "default", "generation": int64(3)},
+		"spec": map[string]interface{}{
+			"replicas":       int64(4),
+			"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "api"}},
+			"updateStrategy": map[string]interface{}{"partition": "50%"},
+		},
+		"status": map[string]interface{}{
+			"observedGeneration":   int64(3),
+			"updatedReplicas":      int64(2),
+			"updatedReadyReplicas": int64(2),
+			"readyReplicas":        int64(4),
+			"updateRevision":       "api-7d9f",
+		},
+	}}
+	clients := fakeClients(cloneSet)
+	status, err := clients.RolloutStatus(context.Background(), Workload{Kind: KindCloneSet, Namespace: "default", Name: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Done {
+		t.Fatalf("status not done: %s", status.Message)
+	}
+	if status.Selector == nil || status.Selector.MatchLabels[appsv1.ControllerRevisionHashLabelKey] != "api-7d9f" {
+		t.Fatalf("selector = %v, want update revision label", status.Selector)
+	}
+}
@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 冻结窗口的cron表达式与定时任务一致, 包含秒字段, 支持CRON_TZ=前缀指定时区
+var freezeCronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// 未指定时区的cron表达式按定时任务的时区计算
+var freezeLocation = loadFreezeLocation()
+
+func loadFreezeLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// 冻结窗口: 周期性窗口由cron表达式确定开始时间并持续duration, 一次性窗口由开始和结束时间确定
+type FreezeWindow struct {
+	Cron     string        // 周期性窗口的开始时间, cron表达式(含秒), 如"0 0 22 * * *"每天22点
+	Duration time.Duration // 周期性窗口持续时间
+	StartAt  time.Time     // 一次性窗口开始时间
+	EndAt    time.Time     // 一次性窗口结束时间
+}
+
+// 冻结时段
+type FreezePeriod struct {
+	Start time.Time `json:"start"` // 开始时间
+	End   time.Time `json:"end"`   // 结束时间
+}
+
+// 校验冻结窗口
+func (w FreezeWindow) Validate() error {
+	if w.Cron != "" {
+		if _, err := freezeCronParser.Parse(w.Cron); err != nil {
+			return fmt.Errorf("冻结窗口cron表达式错误: %w", err)
+		}
+		if w.Duration <= 0 {
+			return errors.New("周期性冻结窗口需设置持续时间")
+		}
+		return nil
+	}
+	if w.StartAt.IsZero() || w.EndAt.IsZero() {
+		return errors.New("冻结窗口需设置cron表达式或开始和结束时间")
+	}
+	if !w.EndAt.After(w.StartAt) {
+		return errors.New("冻结窗口结束时间需晚于开始时间")
+	}
+	return nil
+}
+
+// 返回与[from, to)有交集的冻结时段, 最多limit个
+func (w FreezeWindow) Periods(from, to time.Time, limit int) []FreezePeriod {
+	periods := make([]FreezePeriod, 0)
+	if w.Cron == "" {
+		if w.StartAt.Before(to) && w.EndAt.After(from) {
+			periods = append(periods, FreezePeriod{Start: w.StartAt, End: w.EndAt})
+		}
+		return periods
+	}
+	schedule, err := freezeCronParser.Parse(w.Cron)
+	if err != nil || w.Duration <= 0 {
+		return periods
+	}
+	// 从from往前一个持续时间开始找, 包含from时已经开始的时段
+	start := schedule.Next(from.Add(-w.Duration).In(freezeLocation))
+	for !start.IsZero() && start.Before(to) && len(periods) < limit {
+		end := start.Add(w.Duration)
+		if end.After(from) {
+			periods = append(periods, FreezePeriod{Start: start, End: end})
+		}
+		start = schedule.Next(start)
+	}
+	return periods
+}
@@ -0,0 +1,183 @@
+// 部署准入策略和冻结窗口, 策略以CEL表达式描述, 表达式结果为true时允许部署
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+)
+
+// 表达式可用的变量:
+//
+//	vars   map(string, string) 流水线实例参数
+//	build  map(string, dyn)    构建记录: dagId/dagInsId/status/trigger/branch/vars/outputs/createdAt
+//	env    string              目标环境
+//	app    string              应用名称
+//	caller map(string, dyn)    调用方: appId/user/roles/trigger/overrideReason
+//	now    timestamp           当前时间, 按时区判断时使用now.getHours("Asia/Shanghai")等
+//
+// vars和caller.user由调用方在启动流水线时传入, 未经认证, 不能作为准入依据;
+// build.branch为流水线即代码按触发引用解析的分支, 不取自vars, 其他方式启动的流水线为空;
+// caller.appId和caller.roles来自接口认证的调用系统; webhook触发时caller.trigger为webhook,
+// caller.user为代码托管平台上报的推送者, caller.appId和caller.roles为空
+//
+// 示例: env != "prd" || ("release" in caller.roles && build.branch == "main")
+var (
+	envOnce sync.Once
+	celEnv  *cel.Env
+	envErr  error
+	// 已编译的表达式
+	programs sync.Map
+)
+
+// 策略输入
+type Input struct {
+	Vars   map[string]string      `json:"vars"`   // 流水线实例参数
+	Build  map[string]interface{} `json:"build"`  // 构建记录
+	Env    string                 `json:"env"`    // 目标环境
+	App    string                 `json:"app"`    // 应用名称
+	Caller map[string]interface{} `json:"caller"` // 调用方
+	Now    time.Time              `json:"now"`    // 当前时间
+}
+
+// 策略规则
+type Rule struct {
+	Name       string `json:"name"`       // 策略名称
+	Expression string `json:"expression"` // CEL表达式
+	Message    string `json:"message"`    // 拒绝原因
+}
+
+// 策略检查结果
+type Result struct {
+	Name    string `json:"name"`    // 策略名称
+	Allowed bool   `json:"allowed"` // 是否允许
+	Message string `json:"message"` // 拒绝原因或表达式错误
+}
+
+func getEnv() (*cel.Env, error) {
+	envOnce.Do(func() {
+		celEnv, envErr = cel.NewEnv(
+			cel.Variable("vars", cel.MapType(cel.StringType, cel.StringType)),
+			cel.Variable("build", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("env", cel.StringType),
+			cel.Variable("app", cel.StringType),
+			cel.Variable("caller", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("now", cel.TimestampType),
+			ext.Strings(),
+		)
+	})
+	return celEnv, envErr
+}
+
+// 编译表达式, 表达式结果必须为bool
+func compile(expression string) (cel.Program, error) {
+	if prg, ok := programs.Load(expression); ok {
+		return prg.(cel.Program), nil
+	}
+	env, err := getEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("策略表达式错误: %s", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("策略表达式结果须为bool, 实际为%s", ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("策略表达式错误: %w", err)
+	}
+	programs.Store(expression, prg)
+	return prg, nil
+}
+
+// 校验表达式
+func Validate(expression string) error {
+	if strings.TrimSpace(expression) == "" {
+		return errors.New("策略表达式不能为空")
+	}
+	_, err := compile(expression)
+	return err
+}
+
+// 计算表达式
+func Eval(expression string, input Input) (bool, error) {
+	prg, err := compile(expression)
+	if err != nil {
+		return false, err
+	}
+	activation := map[string]interface{}{
+		"vars":   nonNilVars(input.Vars),
+		"build":  nonNilMap(input.Build),
+		"env":    input.Env,
+		"app":    input.App,
+		"caller": nonNilMap(input.Caller),
+		"now":    input.Now,
+	}
+	if input.Now.IsZero() {
+		activation["now"] = time.Now()
+	}
+	out, _, err := prg.Eval(activation)
+	if err != nil {
+		return false, fmt.Errorf("策略表达式计算错误: %w", err)
+	}
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("策略表达式结果须为bool, 实际为%T", out.Value())
+	}
+	return allowed, nil
+}
+
+// 按顺序检查全部规则, 表达式计算出错视为拒绝
+func Evaluate(rules []Rule, input Input) []Result {
+	results := make([]Result, 0, len(rules))
+	for _, rule := range rules {
+		result := Result{Name: rule.Name}
+		allowed, err := Eval(rule.Expression, input)
+		switch {
+		case err != nil:
+			result.Message = err.Error()
+		case allowed:
+			result.Allowed = true
+		default:
+			result.Message = rule.Message
+			if result.Message == "" {
+				result.Message = fmt.Sprintf("不满足策略: %s", rule.Expression)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 拒绝的结果
+func Denied(results []Result) []Result {
+	denied := make([]Result, 0)
+	for _, result := range results {
+		if !result.Allowed {
+			denied = append(denied, result)
+		}
+	}
+	return denied
+}
+
+func nonNilVars(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+func nonNilMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
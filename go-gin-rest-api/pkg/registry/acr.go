@@ -0,0 +1,176 @@
+package registry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// 阿里云容器镜像服务企业版, 不支持/v2/_catalog, 仓库列表使用OpenAPI ListRepository,
+// 标签和清单仍通过Registry API访问, 使用Auth中的仓库登录凭据
+type ACR struct {
+	InstanceID      string // 企业版实例ID, 如cri-xxxxxxxx
+	AccessKeyID     string // 调用OpenAPI的AccessKey ID, 需cr:ListRepository权限
+	AccessKeySecret string // 调用OpenAPI的AccessKey Secret
+}
+
+// ACR OpenAPI分页大小
+const acrPageSize = 100
+
+// 企业版仓库地址为<实例名>-registry(-vpc).<地域>.cr.aliyuncs.com
+var acrHostRegexp = regexp.MustCompile(`^[a-z0-9-]+\.([a-z0-9-]+)\.cr\.aliyuncs\.com$`)
+
+// ACR OpenAPI地址, 测试时替换
+var acrAPIURL = func(region string) string {
+	return "https://cr." + region + ".aliyuncs.com"
+}
+
+// 校验ACR配置
+func (a ACR) Validate(host string) error {
+	if a.InstanceID == "" || a.AccessKeyID == "" {
+		return errors.New("ACR镜像仓库须设置企业版实例ID和AccessKey ID")
+	}
+	if _, err := acrRegion(host); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 从仓库地址解析地域
+func acrRegion(host string) (string, error) {
+	match := acrHostRegexp.FindStringSubmatch(host)
+	if match == nil {
+		return "", fmt.Errorf("镜像仓库地址%s不是ACR企业版地址, 应为<实例名>-registry.<地域>.cr.aliyuncs.com", host)
+	}
+	return match[1], nil
+}
+
+// 通过ACR OpenAPI分页获取实例中的全部镜像
+func acrRepositories(ctx context.Context, reg name.Registry, acr ACR) ([]string, error) {
+	if acr.AccessKeySecret == "" {
+		return nil, errors.New("ACR镜像仓库未设置AccessKey Secret")
+	}
+	region, err := acrRegion(reg.RegistryStr())
+	if err != nil {
+		return nil, err
+	}
+	repos := make([]string, 0)
+	for page := 1; ; page++ {
+		var resp struct {
+			Code         string          `json:"Code"`
+			Message      string          `json:"Message"`
+			IsSuccess    bool            `json:"IsSuccess"`
+			TotalCount   json.RawMessage `json:"TotalCount"` // 文档中为字符串, 兼容数字
+			Repositories []struct {
+				RepoNamespaceName string `json:"RepoNamespaceName"`
+				RepoName          string `json:"RepoName"`
+			} `json:"Repositories"`
+		}
+		params := map[string]string{
+			"Action":     "ListRepository",
+			"Version":    "2018-12-01",
+			"InstanceId": acr.InstanceID,
+			"RepoStatus": "ALL",
+			"PageNo":     strconv.Itoa(page),
+			"PageSize":   strconv.Itoa(acrPageSize),
+		}
+		if err := acrCall(ctx, acrAPIURL(region), acr, params, &resp); err != nil {
+			return nil, err
+		}
+		if !resp.IsSuccess {
+			return nil, fmt.Errorf("ACR API返回%s: %s", resp.Code, resp.Message)
+		}
+		for _, repo := range resp.Repositories {
+			repos = append(repos, repo.RepoNamespaceName+"/"+repo.RepoName)
+		}
+		total, err := strconv.Atoi(strings.Trim(string(resp.TotalCount), `"`))
+		if len(resp.Repositories) < acrPageSize || (err == nil && len(repos) >= total) {
+			return repos, nil
+		}
+	}
+}
+
+// 按RPC风格签名调用ACR OpenAPI, 签名算法HMAC-SHA1
+func acrCall(ctx context.Context, endpoint string, acr ACR, params map[string]string, out interface{}) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	query := map[string]string{
+		"Format":           "JSON",
+		"AccessKeyId":      acr.AccessKeyID,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   hex.EncodeToString(nonce),
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	for k, v := range params {
+		query[k] = v
+	}
+	query["Signature"] = acrSignature(http.MethodGet, query, acr.AccessKeySecret)
+	values := url.Values{}
+	for k, v := range query {
+		values.Set(k, v)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/?"+values.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ACR API返回%d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析ACR API响应失败: %w", err)
+	}
+	return nil
+}
+
+// 阿里云RPC签名: 参数按名称排序编码后拼接待签名字符串, 以secret&为密钥计算HMAC-SHA1
+func acrSignature(method string, query map[string]string, secret string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, acrEncode(k)+"="+acrEncode(query[k]))
+	}
+	stringToSign := method + "&" + acrEncode("/") + "&" + acrEncode(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 阿里云签名使用的URL编码, 空格编码为%20, 保留~
+func acrEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
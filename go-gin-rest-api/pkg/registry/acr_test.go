@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 模拟ACR OpenAPI, 校验签名后按PageNo/PageSize分页返回
+func fakeACR(t *testing.T, names []string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		signature := query["Signature"]
+		delete(query, "Signature")
+		if query["AccessKeyId"] != "ak" || signature != acrSignature(http.MethodGet, query, "secret") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"Code":"SignatureDoesNotMatch","Message":"signature mismatch"}`)
+			return
+		}
+		if query["Action"] != "ListRepository" || query["InstanceId"] != "cri-test" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"IsSuccess": false, "Code": "INSTANCE_NOT_EXIST", "Message": "instance not exist"})
+			return
+		}
+		page, _ := strconv.Atoi(query["PageNo"])
+		size, _ := strconv.Atoi(query["PageSize"])
+		list := make([]map[string]string, 0)
+		for i := (page - 1) * size; i >= 0 && i < len(names) && i < page*size; i++ {
+			namespace, repo, _ := strings.Cut(names[i], "/")
+			list = append(list, map[string]string{"RepoNamespaceName": namespace, "RepoName": repo})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"IsSuccess": true, "Code": "success", "TotalCount": strconv.Itoa(len(names)), "Repositories": list,
+		})
+	}))
+	t.Cleanup(server.Close)
+	apiURL := acrAPIURL
+	acrAPIURL = func(region string) string {
+		if region != "cn-hangzhou" {
+			t.Errorf("region = %s, want cn-hangzhou", region)
+		}
+		return server.URL
+	}
+	t.Cleanup(func() { acrAPIURL = apiURL })
+}
+
+func TestRepositoriesACR(t *testing.T) {
+	names := make([]string, 0, acrPageSize+20)
+	for i := acrPageSize + 20; i > 0; i-- {
+		names = append(names, fmt.Sprintf("team/app-%03d", i))
+	}
+	fakeACR(t, names)
+	e := Endpoint{
+		Type: TypeACR,
+		URL:  "https://demo-registry.cn-hangzhou.cr.aliyuncs.com",
+		Auth: testAuth,
+		ACR:  ACR{InstanceID: "cri-test", AccessKeyID: "ak", AccessKeySecret: "secret"},
+	}
+	repos, err := e.Repositories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string(nil), names...)
+	sort.Strings(want)
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("Repositories = %d repos, want %d", len(repos), len(want))
+	}
+	e.ACR.AccessKeySecret = "wrong"
+	if _, err := e.Repositories(context.Background()); err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Repositories with wrong secret error = %v, want signature mismatch", err)
+	}
+	e.ACR = ACR{InstanceID: "cri-other", AccessKeyID: "ak", AccessKeySecret: "secret"}
+	if _, err := e.Repositories(context.Background()); err == nil || !strings.Contains(err.Error(), "INSTANCE_NOT_EXIST") {
+		t.Errorf("Repositories with wrong instance error = %v, want INSTANCE_NOT_EXIST", err)
+	}
+}
+
+func TestACRValidate(t *testing.T) {
+	acr := ACR{InstanceID: "cri-test", AccessKeyID: "ak"}
+	for host, ok := range map[string]bool{
+		"demo-registry.cn-hangzhou.cr.aliyuncs.com":     true,
+		"demo-registry-vpc.cn-shanghai.cr.aliyuncs.com": true,
+		"registry.cn-hangzhou.aliyuncs.com":             false,
+		"harbor.example.com":                            false,
+	} {
+		if err := acr.Validate(host); (err == nil) != ok {
+			t.Errorf("Validate(%s) = %v, want ok=%v", host, err, ok)
+		}
+	}
+	if err := (ACR{AccessKeyID: "ak"}).Validate("demo-registry.cn-hangzhou.cr.aliyuncs.com"); err == nil {
+		t.Error("Validate without instance succeeded")
+	}
+}
+
+func TestACREncode(t *testing.T) {
+	if got, want := acrEncode("a b*c~d/e="), "a%20b%2Ac~d%2Fe%3D"; got != want {
+		t.Errorf("acrEncode = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,347 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-gin-rest-api/pkg/semver"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// 镜像仓库类型
+const (
+	TypeHarbor  = "harbor"  // Harbor, 仓库列表使用Harbor API, 只需项目的访问权限
+	TypeACR     = "acr"     // 阿里云容器镜像服务企业版, 仓库列表使用ACR OpenAPI
+	TypeGeneric = "generic" // 兼容Docker Registry HTTP API V2的仓库
+)
+
+// 查询标签详情的并发数
+const inspectWorkers = 8
+
+// Harbor API分页大小
+const harborPageSize = 100
+
+// 校验镜像仓库类型
+func ValidateType(registryType string) error {
+	switch registryType {
+	case TypeHarbor, TypeACR, TypeGeneric:
+		return nil
+	}
+	return fmt.Errorf("不支持的镜像仓库类型: %s, 可选harbor/acr/generic", registryType)
+}
+
+// 镜像仓库地址, 如https://harbor.example.com, 也可只填主机名; http地址按非安全仓库访问
+type Endpoint struct {
+	Type string // 仓库类型
+	URL  string // 仓库地址
+	Auth Auth   // 认证信息
+	ACR  ACR    // ACR OpenAPI配置, 仅acr类型使用
+}
+
+// 解析仓库地址为主机名, http地址设置Insecure
+func (e Endpoint) Registry() (name.Registry, Auth, error) {
+	auth := e.Auth
+	host := strings.TrimSuffix(e.URL, "/")
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		if u.Path != "" {
+			return name.Registry{}, auth, fmt.Errorf("镜像仓库地址%s不能包含路径", e.URL)
+		}
+		host = u.Host
+		auth.Insecure = auth.Insecure || u.Scheme == "http"
+	}
+	reg, err := name.NewRegistry(host, auth.NameOptions()...)
+	if err != nil {
+		return name.Registry{}, auth, fmt.Errorf("镜像仓库地址%s错误: %w", e.URL, err)
+	}
+	return reg, auth, nil
+}
+
+// 镜像仓库中的镜像, repository为不含仓库地址的路径, 如library/nginx
+func (e Endpoint) Repository(repository string) (name.Repository, Auth, error) {
+	reg, auth, err := e.Registry()
+	if err != nil {
+		return name.Repository{}, auth, err
+	}
+	repo, err := name.NewRepository(reg.Name()+"/"+strings.Trim(repository, "/"), auth.NameOptions()...)
+	if err != nil {
+		return name.Repository{}, auth, fmt.Errorf("镜像名称%s错误: %w", repository, err)
+	}
+	return repo, auth, nil
+}
+
+// 获取仓库中的全部镜像名称, 按名称排序
+func (e Endpoint) Repositories(ctx context.Context) ([]string, error) {
+	reg, auth, err := e.Registry()
+	if err != nil {
+		return nil, err
+	}
+	var repos []string
+	switch e.Type {
+	case TypeHarbor:
+		repos, err = harborRepositories(ctx, reg, auth)
+	case TypeACR:
+		repos, err = acrRepositories(ctx, reg, e.ACR)
+	default:
+		var opts []remote.Option
+		if opts, err = auth.RemoteOptions(ctx); err == nil {
+			repos, err = remote.Catalog(ctx, reg, opts...)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取镜像仓库%s的镜像列表失败: %w", e.URL, err)
+	}
+	sort.Strings(repos)
+	return repos, nil
+}
+
+// 获取镜像的全部标签, 语义化版本标签按版本从高到低在前, 其他标签按名称倒序在后
+func (e Endpoint) Tags(ctx context.Context, repository string) ([]string, error) {
+	repo, auth, err := e.Repository(repository)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := remote.List(repo, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("获取镜像%s的标签失败: %w", repo, err)
+	}
+	SortTags(tags)
+	return tags, nil
+}
+
+// 标签排序, 语义化版本标签按版本从高到低在前, 其他标签按名称倒序在后
+func SortTags(tags []string) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		vi, errI := semver.Parse(tags[i])
+		vj, errJ := semver.Parse(tags[j])
+		switch {
+		case errI == nil && errJ == nil:
+			if c := vi.Compare(vj); c != 0 {
+				return c > 0
+			}
+			return tags[i] > tags[j]
+		case errI == nil:
+			return true
+		case errJ == nil:
+			return false
+		}
+		return tags[i] > tags[j]
+	})
+}
+
+// 标签详情
+type TagInfo struct {
+	Tag       string    `json:"tag"`       // 标签
+	Digest    string    `json:"digest"`    // 清单摘要
+	MediaType string    `json:"mediaType"` // 清单媒体类型
+	Size      int64     `json:"size"`      // 镜像大小, 配置及各层压缩后的大小之和, 多架构镜像取默认平台
+	Created   time.Time `json:"created"`   // 镜像创建时间
+	Platforms []string  `json:"platforms"` // 平台, 如linux/amd64
+	Error     string    `json:"error"`     // 查询失败的原因
+}
+
+// 查询标签的摘要、大小和创建时间, 按创建时间从新到旧排列; 单个标签查询失败时记录在Error中
+func (e Endpoint) TagInfos(ctx context.Context, repository string, tags []string) ([]TagInfo, error) {
+	repo, auth, err := e.Repository(repository)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]TagInfo, len(tags))
+	sem := make(chan struct{}, inspectWorkers)
+	var wg sync.WaitGroup
+	for i, tag := range tags {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, tag string) {
+			defer func() { <-sem; wg.Done() }()
+			info, err := tagInfo(repo.Tag(tag), opts)
+			if err != nil {
+				info.Error = err.Error()
+			}
+			info.Tag = tag
+			infos[i] = info
+		}(i, tag)
+	}
+	wg.Wait()
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Created.After(infos[j].Created) })
+	return infos, nil
+}
+
+func tagInfo(ref name.Tag, opts []remote.Option) (TagInfo, error) {
+	info := TagInfo{Platforms: make([]string, 0)}
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return info, err
+	}
+	info.Digest, info.MediaType = desc.Digest.String(), string(desc.MediaType)
+	var img v1.Image
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return info, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return info, err
+		}
+		var child *v1.Descriptor
+		for i, m := range manifest.Manifests {
+			if !m.MediaType.IsImage() || (m.Platform != nil && m.Platform.OS == "unknown") {
+				continue
+			}
+			if m.Platform != nil {
+				info.Platforms = append(info.Platforms, m.Platform.String())
+			}
+			if child == nil || (m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == "amd64") {
+				child = &manifest.Manifests[i]
+			}
+		}
+		if child == nil {
+			return info, nil
+		}
+		if img, err = index.Image(child.Digest); err != nil {
+			return info, err
+		}
+	} else if img, err = desc.Image(); err != nil {
+		return info, err
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return info, err
+	}
+	info.Size = manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		info.Size += layer.Size
+	}
+	config, err := img.ConfigFile()
+	if err != nil {
+		return info, err
+	}
+	info.Created = config.Created.Time
+	if len(info.Platforms) == 0 && config.OS != "" {
+		info.Platforms = append(info.Platforms, config.Platform().String())
+	}
+	return info, nil
+}
+
+// 镜像清单及配置
+type Manifest struct {
+	Reference string          `json:"reference"` // 摘要引用
+	MediaType string          `json:"mediaType"` // 清单媒体类型
+	Size      int64           `json:"size"`      // 清单大小
+	Manifest  json.RawMessage `json:"manifest"`  // 清单
+	Config    json.RawMessage `json:"config"`    // 镜像配置, 多架构镜像及非镜像制品为空
+}
+
+// 查询标签或摘要的清单, 镜像同时返回配置
+func (e Endpoint) Inspect(ctx context.Context, repository, reference string) (*Manifest, error) {
+	repo, auth, err := e.Repository(repository)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ref name.Reference = repo.Tag(reference)
+	if strings.Contains(reference, ":") {
+		ref = repo.Digest(reference)
+	}
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("获取镜像%s的清单失败: %w", ref, err)
+	}
+	manifest := &Manifest{
+		Reference: repo.Digest(desc.Digest.String()).String(),
+		MediaType: string(desc.MediaType),
+		Size:      desc.Size,
+		Manifest:  desc.Manifest,
+	}
+	if !desc.MediaType.IsImage() {
+		return manifest, nil
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Config, err = img.RawConfigFile(); err != nil {
+		return nil, fmt.Errorf("获取镜像%s的配置失败: %w", ref, err)
+	}
+	if !json.Valid(manifest.Config) {
+		manifest.Config = nil
+	}
+	return manifest, nil
+}
+
+// 通过Harbor API分页获取有权限的全部镜像
+func harborRepositories(ctx context.Context, reg name.Registry, auth Auth) ([]string, error) {
+	username, password := auth.Username, ""
+	if username != "" {
+		var err error
+		if password, err = auth.password(); err != nil {
+			return nil, err
+		}
+	}
+	repos := make([]string, 0)
+	for page := 1; ; page++ {
+		api := fmt.Sprintf("%s://%s/api/v2.0/repositories?page=%d&page_size=%d", reg.Scheme(), reg.RegistryStr(), page, harborPageSize)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, api, nil)
+		if err != nil {
+			return nil, err
+		}
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Harbor API返回%d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		var list []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("解析Harbor API响应失败: %w", err)
+		}
+		for _, repo := range list {
+			repos = append(repos, repo.Name)
+		}
+		total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+		if len(list) < harborPageSize || (err == nil && len(repos) >= total) {
+			return repos, nil
+		}
+	}
+}
+
+// 从标签列表中取前limit个, limit<=0时不限制
+func LimitTags(tags []string, limit int) []string {
+	if limit > 0 && len(tags) > limit {
+		return tags[:limit]
+	}
+	return tags
+}
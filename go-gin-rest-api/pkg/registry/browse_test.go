@@ -0,0 +1,308 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// 设置用户名以免读取docker配置文件, 内存镜像仓库不校验认证
+var testAuth = Auth{Username: "test", Password: "test"}
+
+// 启动内存镜像仓库, 返回http地址的Endpoint
+func newTestEndpoint(t *testing.T) Endpoint {
+	t.Helper()
+	server := httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+	return Endpoint{Type: TypeGeneric, URL: server.URL, Auth: testAuth}
+}
+
+// 生成指定平台和创建时间的随机镜像
+func testImage(t *testing.T, platform string, created time.Time) v1.Image {
+	t.Helper()
+	img, err := random.Image(512, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = config.DeepCopy()
+	config.OS, config.Architecture, _ = strings.Cut(platform, "/")
+	config.Created = v1.Time{Time: created}
+	if img, err = mutate.ConfigFile(img, config); err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func push(t *testing.T, e Endpoint, repository, tag string, img v1.Image) v1.Hash {
+	t.Helper()
+	repo, auth, err := e.Repository(repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := auth.RemoteOptions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(repo.Tag(tag), img, opts...); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+func pushIndex(t *testing.T, e Endpoint, repository, tag string, images map[string]v1.Image) v1.Hash {
+	t.Helper()
+	index := mutate.IndexMediaType(empty.Index, types.OCIImageIndex)
+	for _, platform := range []string{"linux/arm64", "linux/amd64"} {
+		os, arch, _ := strings.Cut(platform, "/")
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        images[platform],
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: os, Architecture: arch}},
+		})
+	}
+	repo, auth, err := e.Repository(repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := auth.RemoteOptions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(repo.Tag(tag), index, opts...); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+func imageSize(t *testing.T, img v1.Image) int64 {
+	t.Helper()
+	manifest, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+func TestEndpointRegistry(t *testing.T) {
+	cases := []struct {
+		url      string
+		host     string
+		insecure bool
+		wantErr  bool
+	}{
+		{"harbor.example.com", "harbor.example.com", false, false},
+		{"https://harbor.example.com/", "harbor.example.com", false, false},
+		{"http://127.0.0.1:5000", "127.0.0.1:5000", true, false},
+		{"https://harbor.example.com/library", "", false, true},
+	}
+	for _, c := range cases {
+		reg, auth, err := (Endpoint{URL: c.url}).Registry()
+		if (err != nil) != c.wantErr {
+			t.Errorf("Registry(%s) error = %v", c.url, err)
+			continue
+		}
+		if err == nil && (reg.Name() != c.host || auth.Insecure != c.insecure) {
+			t.Errorf("Registry(%s) = %s insecure=%v, want %s insecure=%v", c.url, reg.Name(), auth.Insecure, c.host, c.insecure)
+		}
+	}
+}
+
+func TestRepositoriesCatalog(t *testing.T) {
+	e := newTestEndpoint(t)
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, repository := range []string{"team/web", "library/nginx", "team/api"} {
+		push(t, e, repository, "latest", testImage(t, "linux/amd64", created))
+	}
+	repos, err := e.Repositories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"library/nginx", "team/api", "team/web"}; !reflect.DeepEqual(repos, want) {
+		t.Errorf("Repositories = %v, want %v", repos, want)
+	}
+}
+
+func TestRepositoriesHarbor(t *testing.T) {
+	// 超过一页, 按page_size分页返回, 顺序与排序结果不同
+	names := make([]string, 0, harborPageSize+20)
+	for i := harborPageSize + 20; i > 0; i-- {
+		names = append(names, fmt.Sprintf("team/app-%03d", i))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2.0/repositories" {
+			http.NotFound(w, r)
+			return
+		}
+		if username, password, ok := r.BasicAuth(); !ok || username != "test" || password != "test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errors":[{"code":"UNAUTHORIZED"}]}`)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+		list := make([]map[string]string, 0)
+		for i := (page - 1) * size; i >= 0 && i < len(names) && i < page*size; i++ {
+			list = append(list, map[string]string{"name": names[i]})
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(names)))
+		json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(server.Close)
+	e := Endpoint{Type: TypeHarbor, URL: server.URL, Auth: testAuth}
+	repos, err := e.Repositories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string(nil), names...)
+	sort.Strings(want)
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("Repositories = %d repos %v..., want %d", len(repos), repos[:min(len(repos), 3)], len(want))
+	}
+	e.Auth = Auth{Username: "test", Password: "wrong"}
+	if _, err := e.Repositories(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Repositories with wrong password error = %v, want 401", err)
+	}
+}
+
+func TestTags(t *testing.T) {
+	e := newTestEndpoint(t)
+	img := testImage(t, "linux/amd64", time.Now())
+	for _, tag := range []string{"latest", "1.2.0", "v1.10.0", "1.2.0-rc.1", "dev", "1.9.0"} {
+		push(t, e, "team/web", tag, img)
+	}
+	tags, err := e.Tags(context.Background(), "team/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v1.10.0", "1.9.0", "1.2.0", "1.2.0-rc.1", "latest", "dev"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags = %v, want %v", tags, want)
+	}
+	if _, err := e.Tags(context.Background(), "team/missing"); err == nil {
+		t.Error("Tags of missing repository succeeded")
+	}
+}
+
+func TestSortTags(t *testing.T) {
+	tags := []string{"sha-abc", "2.0.0", "10.0.0", "2.0.0-beta.2", "2.0.0-beta.10", "main", "v2.0.0"}
+	SortTags(tags)
+	want := []string{"10.0.0", "v2.0.0", "2.0.0", "2.0.0-beta.10", "2.0.0-beta.2", "sha-abc", "main"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("SortTags = %v, want %v", tags, want)
+	}
+}
+
+func TestTagInfos(t *testing.T) {
+	e := newTestEndpoint(t)
+	older := testImage(t, "linux/amd64", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	amd64 := testImage(t, "linux/amd64", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	arm64 := testImage(t, "linux/arm64", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	olderDigest := push(t, e, "team/web", "1.0.0", older)
+	indexDigest := pushIndex(t, e, "team/web", "2.0.0", map[string]v1.Image{"linux/amd64": amd64, "linux/arm64": arm64})
+	infos, err := e.TagInfos(context.Background(), "team/web", []string{"1.0.0", "missing", "2.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("got %d tag infos", len(infos))
+	}
+	multi, single, missing := infos[0], infos[1], infos[2]
+	if multi.Tag != "2.0.0" || multi.Digest != indexDigest.String() || multi.MediaType != string(types.OCIImageIndex) || multi.Error != "" {
+		t.Errorf("multi-arch info = %+v", multi)
+	}
+	if !reflect.DeepEqual(multi.Platforms, []string{"linux/arm64", "linux/amd64"}) || multi.Size != imageSize(t, amd64) {
+		t.Errorf("multi-arch platforms = %v size = %d, want amd64 size %d", multi.Platforms, multi.Size, imageSize(t, amd64))
+	}
+	if single.Tag != "1.0.0" || single.Digest != olderDigest.String() || single.Size != imageSize(t, older) ||
+		!single.Created.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !reflect.DeepEqual(single.Platforms, []string{"linux/amd64"}) {
+		t.Errorf("single-arch info = %+v", single)
+	}
+	if missing.Tag != "missing" || missing.Error == "" {
+		t.Errorf("missing tag info = %+v, want error", missing)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	e := newTestEndpoint(t)
+	img := testImage(t, "linux/amd64", time.Now())
+	digest := push(t, e, "team/web", "1.0.0", img)
+	ctx := context.Background()
+	for _, reference := range []string{"1.0.0", digest.String()} {
+		manifest, err := e.Inspect(ctx, "team/web", reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(manifest.Reference, "/team/web@"+digest.String()) {
+			t.Errorf("Inspect(%s) reference = %s", reference, manifest.Reference)
+		}
+		var config v1.ConfigFile
+		if err := json.Unmarshal(manifest.Config, &config); err != nil || config.Architecture != "amd64" {
+			t.Errorf("Inspect(%s) config = %s, %v", reference, manifest.Config, err)
+		}
+		var m v1.Manifest
+		if err := json.Unmarshal(manifest.Manifest, &m); err != nil || len(m.Layers) != 2 {
+			t.Errorf("Inspect(%s) manifest = %s, %v", reference, manifest.Manifest, err)
+		}
+	}
+	indexDigest := pushIndex(t, e, "team/web", "2.0.0", map[string]v1.Image{
+		"linux/amd64": testImage(t, "linux/amd64", time.Now()),
+		"linux/arm64": testImage(t, "linux/arm64", time.Now()),
+	})
+	manifest, err := e.Inspect(ctx, "team/web", "2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(manifest.Reference, "@"+indexDigest.String()) || manifest.Config != nil {
+		t.Errorf("Inspect index = %s config %s, want index without config", manifest.Reference, manifest.Config)
+	}
+	if _, err := e.Inspect(ctx, "team/web", "missing"); err == nil {
+		t.Error("Inspect of missing tag succeeded")
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	ref, err := ParseDigest("harbor.example.com/team/web:1.0.0", digest, Auth{})
+	if err != nil || ref.String() != "harbor.example.com/team/web@"+digest {
+		t.Errorf("ParseDigest = %s, %v", ref, err)
+	}
+	if _, err := ParseDigest("harbor.example.com/team/web:1.0.0", "", Auth{}); err == nil {
+		t.Error("ParseDigest without digest succeeded")
+	}
+	other := "sha256:" + strings.Repeat("b", 64)
+	if _, err := ParseDigest("harbor.example.com/team/web@"+other, digest, Auth{}); err == nil {
+		t.Error("ParseDigest with mismatched digest succeeded")
+	}
+}
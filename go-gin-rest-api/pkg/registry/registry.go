@@ -0,0 +1,135 @@
+// 镜像仓库访问, 基于go-containerregistry
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/secret"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// 镜像仓库认证信息, 未设置用户名时使用docker配置文件(~/.docker/config.json)中的凭据
+type Auth struct {
+	Username       string `json:"username"`       // 用户名
+	PasswordSecret string `json:"passwordSecret"` // 密码或令牌的密钥名称
+	Password       string `json:"-"`              // 密码或令牌, 已解密的镜像仓库凭据, 设置时忽略PasswordSecret
+	Insecure       bool   `json:"insecure"`       // 使用http访问仓库
+}
+
+// 解析镜像地址的选项
+func (a Auth) NameOptions() []name.Option {
+	if a.Insecure {
+		return []name.Option{name.Insecure}
+	}
+	return nil
+}
+
+// 访问仓库的选项
+func (a Auth) RemoteOptions(ctx context.Context) ([]remote.Option, error) {
+	opts := []remote.Option{remote.WithContext(ctx)}
+	if a.Username == "" {
+		return append(opts, remote.WithAuthFromKeychain(authn.DefaultKeychain)), nil
+	}
+	password, err := a.password()
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, remote.WithAuth(&authn.Basic{Username: a.Username, Password: password})), nil
+}
+
+func (a Auth) password() (string, error) {
+	if a.Password != "" {
+		return a.Password, nil
+	}
+	return secret.Get(a.PasswordSecret)
+}
+
+// 解析镜像摘要引用: digest不为空时使用image的仓库加digest, 否则image须为repo@sha256:...格式
+func ParseDigest(image, digest string, auth Auth) (name.Digest, error) {
+	if digest == "" {
+		ref, err := name.NewDigest(image, auth.NameOptions()...)
+		if err != nil {
+			return name.Digest{}, fmt.Errorf("镜像%s未指定摘要: %w", image, err)
+		}
+		return ref, nil
+	}
+	ref, err := name.ParseReference(image, auth.NameOptions()...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("镜像地址%s错误: %w", image, err)
+	}
+	if d, ok := ref.(name.Digest); ok && d.DigestStr() != digest {
+		return name.Digest{}, fmt.Errorf("镜像%s与摘要%s不一致", image, digest)
+	}
+	return ref.Context().Digest(digest), nil
+}
+
+// 解析镜像摘要引用, 镜像只有标签时从仓库查询标签当前的摘要
+func ResolveDigest(ctx context.Context, image, digest string, auth Auth) (name.Digest, error) {
+	if digest != "" || strings.Contains(image, "@") {
+		return ParseDigest(image, digest, auth)
+	}
+	ref, err := name.ParseReference(image, auth.NameOptions()...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("镜像地址%s错误: %w", image, err)
+	}
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("查询镜像%s的摘要失败: %w", image, err)
+	}
+	return ref.Context().Digest(desc.Digest.String()), nil
+}
+
+// 是否为仓库返回的404错误
+func IsNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
+
+// 将内容作为OCI referrer附加到镜像: 以artifactType为配置媒体类型、subject指向镜像的OCI清单,
+// 仓库不支持Referrers API时按规范更新sha256-<摘要>回退标签, 返回referrer的摘要引用
+func Attach(ctx context.Context, subject name.Digest, artifactType string, content []byte, annotations map[string]string, auth Auth) (name.Digest, error) {
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	desc, err := remote.Head(subject, opts...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("获取镜像%s失败: %w", subject, err)
+	}
+	img, err := mutate.Append(
+		mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.MediaType(artifactType)),
+		mutate.Addendum{Layer: static.NewLayer(content, types.MediaType(artifactType))},
+	)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	if len(annotations) > 0 {
+		img = mutate.Annotations(img, annotations).(v1.Image)
+	}
+	img = mutate.Subject(img, v1.Descriptor{MediaType: desc.MediaType, Size: desc.Size, Digest: desc.Digest}).(v1.Image)
+	digest, err := img.Digest()
+	if err != nil {
+		return name.Digest{}, err
+	}
+	ref := subject.Context().Digest(digest.String())
+	if err := remote.Write(ref, img, opts...); err != nil {
+		return name.Digest{}, fmt.Errorf("推送%s失败: %w", ref, err)
+	}
+	return ref, nil
+}
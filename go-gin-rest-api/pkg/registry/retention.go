@@ -0,0 +1,137 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/semver"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// 标签保留规则: 按创建时间保留最近keepLast个标签、保留语义化版本标签、只删除超过olderThanDays天的标签,
+// 同时设置keepLast和olderThanDays时只删除两者都不满足的标签; 删除按摘要进行, 摘要的任一标签被保留时不删除
+type RetentionRule struct {
+	KeepLast      int  // 保留最近创建的标签数
+	KeepSemver    bool // 保留语义化版本标签
+	OlderThanDays int  // 只删除创建时间超过该天数的标签, 创建时间未知的标签不删除
+}
+
+// 校验保留规则, 至少设置保留数或保留天数, 避免删除全部标签
+func (r RetentionRule) Validate() error {
+	if r.KeepLast < 0 || r.OlderThanDays < 0 {
+		return errors.New("保留数和保留天数不能为负数")
+	}
+	if r.KeepLast == 0 && r.OlderThanDays == 0 {
+		return errors.New("至少设置保留数或保留天数")
+	}
+	return nil
+}
+
+// cosign签名、证明及OCI referrers回退标签, 如sha256-<摘要>.sig, 随所指向的镜像保留或删除
+var attachedTagRegexp = regexp.MustCompile(`^sha256-([0-9a-f]{64})(?:\.(?:sig|att|sbom))?$`)
+
+// 按规则选出要删除的标签, tags须按创建时间从新到旧排列(TagInfos的结果), protected返回true的标签保留;
+// 查询详情失败的标签保留, 与保留标签摘要相同的标签不删除, 签名等附属标签只随所指向的镜像删除
+func (r RetentionRule) Select(tags []TagInfo, protected func(TagInfo) bool, now time.Time) []TagInfo {
+	cutoff := now.AddDate(0, 0, -r.OlderThanDays)
+	kept := map[string]bool{}
+	candidates, attached := make([]TagInfo, 0), make([]TagInfo, 0)
+	n := 0
+	for _, tag := range tags {
+		if tag.Error != "" || tag.Digest == "" {
+			continue
+		}
+		if attachedTagRegexp.MatchString(tag.Tag) {
+			attached = append(attached, tag)
+			continue
+		}
+		n++
+		_, err := semver.Parse(tag.Tag)
+		switch {
+		case n <= r.KeepLast,
+			r.KeepSemver && err == nil,
+			r.OlderThanDays > 0 && (tag.Created.IsZero() || tag.Created.After(cutoff)),
+			protected != nil && protected(tag):
+			kept[tag.Digest] = true
+		default:
+			candidates = append(candidates, tag)
+		}
+	}
+	deletes := make([]TagInfo, 0, len(candidates))
+	deleted := map[string]bool{}
+	for _, tag := range candidates {
+		if !kept[tag.Digest] {
+			deletes = append(deletes, tag)
+			deleted[tag.Digest] = true
+		}
+	}
+	for _, tag := range attached {
+		if subject := "sha256:" + attachedTagRegexp.FindStringSubmatch(tag.Tag)[1]; deleted[subject] && !kept[tag.Digest] {
+			deletes = append(deletes, tag)
+		}
+	}
+	return deletes
+}
+
+// 镜像名称是否匹配模式, 模式语法同path.Match, 如team/*, 为空或*匹配全部
+func MatchRepository(pattern, repository string) (bool, error) {
+	if pattern == "" || pattern == "*" {
+		return true, nil
+	}
+	matched, err := path.Match(pattern, repository)
+	if err != nil {
+		return false, fmt.Errorf("镜像名称模式%s错误: %w", pattern, err)
+	}
+	return matched, nil
+}
+
+// 按摘要删除镜像清单, 指向该摘要的全部标签随之删除; 支持按标签删除的仓库先删除tags, 不支持时忽略
+func (e Endpoint) DeleteManifest(ctx context.Context, repository, digest string, tags ...string) error {
+	repo, auth, err := e.Repository(repository)
+	if err != nil {
+		return err
+	}
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		remote.Delete(repo.Tag(tag), opts...)
+	}
+	if err := remote.Delete(repo.Digest(digest), opts...); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("删除镜像%s@%s失败: %w", repository, digest, err)
+	}
+	return nil
+}
+
+// 标签清理报告
+type RetentionReport struct {
+	Policy       string          `json:"policy"`       // 保留策略名称
+	DryRun       bool            `json:"dryRun"`       // 是否只报告不删除
+	Repositories int             `json:"repositories"` // 匹配的镜像数
+	Tags         int             `json:"tags"`         // 标签总数
+	Deleted      []RetentionItem `json:"deleted"`      // 删除(或将删除)的标签
+	Errors       []string        `json:"errors"`       // 错误信息
+}
+
+// 删除的标签
+type RetentionItem struct {
+	Repository string    `json:"repository"` // 镜像名称
+	Tag        string    `json:"tag"`        // 标签
+	Digest     string    `json:"digest"`     // 摘要
+	Created    time.Time `json:"created"`    // 创建时间
+}
+
+// 报告摘要, 用于定时任务日志
+func (r *RetentionReport) Summary() string {
+	items := make([]string, 0, len(r.Deleted))
+	for _, item := range r.Deleted {
+		items = append(items, item.Repository+":"+item.Tag)
+	}
+	return fmt.Sprintf("policy=%s,dryRun=%t,repositories=%d,tags=%d,deleted=%d [%s]", r.Policy, r.DryRun, r.Repositories, r.Tags, len(r.Deleted), strings.Join(items, ","))
+}
@@ -0,0 +1,248 @@
+// 发布说明, 按约定式提交类型和关联的需求编号汇总两次部署之间的提交
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-gin-rest-api/pkg/git"
+	"go-gin-rest-api/pkg/semver"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// 发布说明格式
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+// 需求编号: Jira风格的ABC-123, 或GitHub/GitLab/Gitee风格的#123
+var issueRegexp = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-\d+\b|(?:^|[\s(])#\d+\b`)
+
+// 提交类型的分组标题, 按顺序输出, 未列出的类型归入其他
+var sections = []struct {
+	Type  string
+	Title string
+}{
+	{"feat", "新功能"},
+	{"fix", "问题修复"},
+	{"perf", "性能优化"},
+	{"refactor", "重构"},
+	{"revert", "回退"},
+	{"docs", "文档"},
+	{"test", "测试"},
+	{"build", "构建"},
+	{"ci", "持续集成"},
+	{"chore", "其他"},
+}
+
+// 未列出类型及不符合约定式提交的提交归入的分组
+const otherType = "chore"
+
+// 提交
+type Commit struct {
+	SHA      string    `json:"sha"`      // 提交SHA
+	Type     string    `json:"type"`     // 约定式提交类型, 不符合约定式提交时为空
+	Scope    string    `json:"scope"`    // 范围
+	Subject  string    `json:"subject"`  // 标题
+	Breaking bool      `json:"breaking"` // 是否为不兼容变更
+	Issues   []string  `json:"issues"`   // 关联的需求编号
+	Author   string    `json:"author"`   // 作者
+	Time     time.Time `json:"time"`     // 提交时间
+}
+
+// 提交分组
+type Section struct {
+	Type    string   `json:"type"`    // 提交类型
+	Title   string   `json:"title"`   // 标题
+	Commits []Commit `json:"commits"` // 提交
+}
+
+// 发布说明
+type Notes struct {
+	From     string    `json:"from"`     // 起始提交(不含)
+	To       string    `json:"to"`       // 结束提交
+	Total    int       `json:"total"`    // 提交数, 不含合并提交
+	Breaking []Commit  `json:"breaking"` // 不兼容变更
+	Sections []Section `json:"sections"` // 按类型分组的提交
+	Issues   []string  `json:"issues"`   // 关联的需求编号
+}
+
+// 校验发布说明格式
+func ValidateFormat(format string) error {
+	if format != FormatMarkdown && format != FormatJSON {
+		return fmt.Errorf("不支持的发布说明格式: %s, 可选markdown/json", format)
+	}
+	return nil
+}
+
+// 解析提交说明
+func NewCommit(sha, message, author string, when time.Time) Commit {
+	conventional, ok := semver.ParseConventional(message)
+	commit := Commit{
+		SHA:      sha,
+		Scope:    conventional.Scope,
+		Subject:  conventional.Subject,
+		Breaking: conventional.Breaking,
+		Issues:   ParseIssues(message),
+		Author:   author,
+		Time:     when,
+	}
+	if ok {
+		commit.Type = conventional.Type
+	}
+	return commit
+}
+
+// 提取提交说明中的需求编号, 去重并保持出现顺序
+func ParseIssues(message string) []string {
+	issues := make([]string, 0)
+	seen := map[string]bool{}
+	for _, match := range issueRegexp.FindAllString(message, -1) {
+		issue := strings.TrimLeft(match, " \t\r\n(")
+		if !seen[issue] {
+			seen[issue] = true
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// 生成发布说明, commits按提交时间从新到旧排列, 合并提交应事先排除
+func NewNotes(from, to string, commits []Commit) Notes {
+	notes := Notes{From: from, To: to, Total: len(commits), Breaking: make([]Commit, 0), Sections: make([]Section, 0), Issues: make([]string, 0)}
+	grouped := map[string][]Commit{}
+	seen := map[string]bool{}
+	for _, commit := range commits {
+		if commit.Breaking {
+			notes.Breaking = append(notes.Breaking, commit)
+		}
+		grouped[sectionType(commit.Type)] = append(grouped[sectionType(commit.Type)], commit)
+		for _, issue := range commit.Issues {
+			if !seen[issue] {
+				seen[issue] = true
+				notes.Issues = append(notes.Issues, issue)
+			}
+		}
+	}
+	for _, section := range sections {
+		if len(grouped[section.Type]) > 0 {
+			notes.Sections = append(notes.Sections, Section{Type: section.Type, Title: section.Title, Commits: grouped[section.Type]})
+		}
+	}
+	sort.Strings(notes.Issues)
+	return notes
+}
+
+func sectionType(commitType string) string {
+	for _, section := range sections {
+		if section.Type == commitType {
+			return commitType
+		}
+	}
+	return otherType
+}
+
+// 生成Markdown格式的发布说明
+func (n Notes) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## 发布说明 %s...%s\n\n", shortSHA(n.From), shortSHA(n.To))
+	if n.Total == 0 {
+		b.WriteString("没有新的提交\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "共%d个提交\n", n.Total)
+	if len(n.Breaking) > 0 {
+		b.WriteString("\n### 不兼容变更\n\n")
+		for _, commit := range n.Breaking {
+			writeCommit(&b, commit)
+		}
+	}
+	for _, section := range n.Sections {
+		fmt.Fprintf(&b, "\n### %s\n\n", section.Title)
+		for _, commit := range section.Commits {
+			writeCommit(&b, commit)
+		}
+	}
+	if len(n.Issues) > 0 {
+		fmt.Fprintf(&b, "\n### 关联需求\n\n%s\n", strings.Join(n.Issues, ", "))
+	}
+	return b.String()
+}
+
+func writeCommit(b *strings.Builder, commit Commit) {
+	b.WriteString("- ")
+	if commit.Scope != "" {
+		fmt.Fprintf(b, "**%s**: ", commit.Scope)
+	}
+	fmt.Fprintf(b, "%s (%s", commit.Subject, shortSHA(commit.SHA))
+	if commit.Author != "" {
+		fmt.Fprintf(b, ", %s", commit.Author)
+	}
+	b.WriteString(")\n")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// 从仓库生成from(不含)到to之间的发布说明, from为空时包含to的全部历史, 排除合并提交
+func Generate(repo *gogit.Repository, from, to string) (Notes, error) {
+	toHash, err := git.ResolveCommit(repo, to)
+	if err != nil {
+		return Notes{}, err
+	}
+	fromHash := plumbing.ZeroHash
+	if from != "" {
+		if fromHash, err = git.ResolveCommit(repo, from); err != nil {
+			return Notes{}, err
+		}
+	}
+	list, err := git.CommitsSince(repo, toHash, fromHash)
+	if err != nil {
+		return Notes{}, err
+	}
+	commits := make([]Commit, 0, len(list))
+	for _, c := range list {
+		if c.NumParents() > 1 {
+			continue
+		}
+		commits = append(commits, NewCommit(c.Hash.String(), c.Message, c.Author.Name, c.Author.When))
+	}
+	fromSHA := ""
+	if !fromHash.IsZero() {
+		fromSHA = fromHash.String()
+	}
+	return NewNotes(fromSHA, toHash.String(), commits), nil
+}
+
+// 按格式输出发布说明
+func (n Notes) Render(format string) (string, error) {
+	if format == FormatJSON {
+		data, err := json.MarshalIndent(n, "", "  ")
+		return string(data), err
+	}
+	return n.Markdown(), nil
+}
+
+// 更新仓库的本地镜像并生成发布说明
+func FromRepo(ctx context.Context, repoURL string, credential git.Credential, from, to string) (Notes, error) {
+	auth, err := credential.Auth()
+	if err != nil {
+		return Notes{}, err
+	}
+	repo, err := git.Mirror(ctx, repoURL, auth)
+	if err != nil {
+		return Notes{}, err
+	}
+	return Generate(repo, from, to)
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 覆盖率统计
+type Coverage struct {
+	Covered int64 // 覆盖的语句/行数
+	Total   int64 // 总语句/行数
+}
+
+// 覆盖率百分比
+func (c Coverage) Percent() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Covered) * 100 / float64(c.Total)
+}
+
+// 合并覆盖率
+func (c *Coverage) Add(other Coverage) {
+	c.Covered += other.Covered
+	c.Total += other.Total
+}
+
+// 解析go test -coverprofile输出, 按语句统计, 合并后的profile中同一代码块取最大执行次数
+func ParseGoCoverprofile(r io.Reader) (Coverage, error) {
+	type block struct {
+		stmts int64
+		count int64
+	}
+	blocks := map[string]*block{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "mode:") {
+			continue
+		}
+		// 格式: file:startLine.startCol,endLine.endCol numStmts count
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return Coverage{}, fmt.Errorf("coverprofile第%d行格式错误: %s", line, text)
+		}
+		stmts, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return Coverage{}, fmt.Errorf("coverprofile第%d行格式错误: %s", line, text)
+		}
+		count, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return Coverage{}, fmt.Errorf("coverprofile第%d行格式错误: %s", line, text)
+		}
+		if b, ok := blocks[fields[0]]; ok {
+			if count > b.count {
+				b.count = count
+			}
+			continue
+		}
+		blocks[fields[0]] = &block{stmts: stmts, count: count}
+	}
+	if err := scanner.Err(); err != nil {
+		return Coverage{}, err
+	}
+	var coverage Coverage
+	for _, b := range blocks {
+		coverage.Total += b.stmts
+		if b.count > 0 {
+			coverage.Covered += b.stmts
+		}
+	}
+	return coverage, nil
+}
+
+// 解析Cobertura XML, 按行统计
+func ParseCobertura(r io.Reader) (Coverage, error) {
+	var doc struct {
+		XMLName      xml.Name `xml:"coverage"`
+		LineRate     float64  `xml:"line-rate,attr"`
+		LinesCovered int64    `xml:"lines-covered,attr"`
+		LinesValid   int64    `xml:"lines-valid,attr"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return Coverage{}, fmt.Errorf("解析Cobertura XML失败: %w", err)
+	}
+	if doc.LinesValid > 0 {
+		return Coverage{Covered: doc.LinesCovered, Total: doc.LinesValid}, nil
+	}
+	// 没有行数统计时按line-rate折算
+	return Coverage{Covered: int64(doc.LineRate * 10000), Total: 10000}, nil
+}
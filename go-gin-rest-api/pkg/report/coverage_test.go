@@ -0,0 +1,63 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGoCoverprofile(t *testing.T) {
+	coverage, err := ParseGoCoverprofile(openTestdata(t, "coverprofile.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 合并profile中重复的代码块取最大执行次数, 按语句统计: 覆盖2+5, 共2+3+5
+	if coverage.Covered != 7 || coverage.Total != 10 || coverage.Percent() != 70 {
+		t.Fatalf("coverage = %+v, %.1f%%", coverage, coverage.Percent())
+	}
+}
+
+func TestParseGoCoverprofileMalformed(t *testing.T) {
+	for _, input := range []string{
+		"mode: set\nexample.com/a.go:1.1,2.2 1\n",
+		"mode: set\nexample.com/a.go:1.1,2.2 x 1\n",
+		"mode: set\nexample.com/a.go:1.1,2.2 1 y\n",
+	} {
+		if _, err := ParseGoCoverprofile(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "第2行") {
+			t.Errorf("ParseGoCoverprofile(%q) error = %v, want line 2", input, err)
+		}
+	}
+	coverage, err := ParseGoCoverprofile(strings.NewReader("mode: set\n"))
+	if err != nil || coverage.Total != 0 || coverage.Percent() != 0 {
+		t.Errorf("empty profile = %+v, %v", coverage, err)
+	}
+}
+
+func TestParseCobertura(t *testing.T) {
+	coverage, err := ParseCobertura(openTestdata(t, "cobertura.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coverage.Covered != 150 || coverage.Total != 200 {
+		t.Fatalf("coverage = %+v", coverage)
+	}
+	// 没有行数统计时按line-rate折算
+	coverage, err = ParseCobertura(strings.NewReader(`<coverage line-rate="0.8123"></coverage>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coverage.Percent() != 81.23 {
+		t.Fatalf("line-rate coverage = %.2f%%", coverage.Percent())
+	}
+	coverage.Add(Coverage{Covered: 0, Total: 10000})
+	if coverage.Total != 20000 {
+		t.Errorf("Add = %+v", coverage)
+	}
+}
+
+func TestParseCoberturaMalformed(t *testing.T) {
+	for _, input := range []string{`<checkstyle></checkstyle>`, `<coverage line-rate="x"/>`, `<coverage`, ``} {
+		if _, err := ParseCobertura(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseCobertura(%q) succeeded", input)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DORA指标的统计周期
+const (
+	DoraWeek  = "week"
+	DoraMonth = "month"
+)
+
+// DORA指标的分组方式
+const (
+	DoraGroupApp  = "app"
+	DoraGroupTeam = "team"
+	DoraGroupEnv  = "env"
+)
+
+// 用于计算DORA指标的部署
+type DoraDeployment struct {
+	Env        string     // 环境
+	App        string     // 应用
+	Team       string     // 团队
+	Deployed   bool       // 是否部署成功, 成功后被回滚的也算部署成功
+	Failed     bool       // 是否为失败的变更: 部署失败或部署后被回滚
+	DeployedAt time.Time  // 部署结束时间
+	CommitAt   *time.Time // 代码提交时间
+	RestoredAt *time.Time // 恢复时间, 回滚的部署为回滚时间, 部署失败的为之后首次成功部署的时间
+}
+
+// DORA指标
+type DoraMetrics struct {
+	Deployments         int     `json:"deployments"`         // 部署次数, 包含失败的部署
+	SuccessDeployments  int     `json:"successDeployments"`  // 成功部署次数
+	FailedChanges       int     `json:"failedChanges"`       // 失败的变更数: 部署失败或部署后被回滚
+	DeploymentFrequency float64 `json:"deploymentFrequency"` // 部署频率, 平均每天成功部署次数
+	LeadTimeHours       float64 `json:"leadTimeHours"`       // 变更前置时间, 提交到部署成功的小时数中位数
+	ChangeFailureRate   float64 `json:"changeFailureRate"`   // 变更失败率, 百分比
+	RestoreTimeHours    float64 `json:"restoreTimeHours"`    // 平均恢复时间, 小时
+	Restores            int     `json:"restores"`            // 已恢复的失败变更数
+}
+
+// 时间序列中的一个周期
+type DoraPoint struct {
+	Start   time.Time   `json:"start"`   // 周期开始时间
+	End     time.Time   `json:"end"`     // 周期结束时间(不含)
+	Metrics DoraMetrics `json:"metrics"` // 周期内的指标
+}
+
+// 分组统计
+type DoraGroup struct {
+	Key     string      `json:"key"`     // 分组值, 如应用名称
+	Summary DoraMetrics `json:"summary"` // 整个时间范围的指标
+	Series  []DoraPoint `json:"series"`  // 时间序列
+}
+
+// DORA指标报告
+type DoraReport struct {
+	Start    time.Time   `json:"start"`    // 开始时间
+	End      time.Time   `json:"end"`      // 结束时间(不含)
+	Interval string      `json:"interval"` // 统计周期: week/month
+	GroupBy  string      `json:"groupBy"`  // 分组方式: app/team/env, 为空时不分组
+	Summary  DoraMetrics `json:"summary"`  // 整个时间范围的指标
+	Series   []DoraPoint `json:"series"`   // 时间序列
+	Groups   []DoraGroup `json:"groups"`   // 分组统计
+}
+
+// 校验统计周期和分组方式
+func ValidateDora(interval, groupBy string) error {
+	if interval != DoraWeek && interval != DoraMonth {
+		return fmt.Errorf("不支持的统计周期: %s, 可选week/month", interval)
+	}
+	switch groupBy {
+	case "", DoraGroupApp, DoraGroupTeam, DoraGroupEnv:
+		return nil
+	}
+	return fmt.Errorf("不支持的分组方式: %s, 可选app/team/env", groupBy)
+}
+
+// 统计周期的开始时间, 周从周一开始
+func DoraPeriodStart(t time.Time, interval string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if interval == DoraMonth {
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// 按周期切分时间范围, 首尾周期按范围截断
+func doraPeriods(from, to time.Time, interval string) [][2]time.Time {
+	periods := make([][2]time.Time, 0)
+	for start := DoraPeriodStart(from, interval); start.Before(to); {
+		next := start.AddDate(0, 0, 7)
+		if interval == DoraMonth {
+			next = start.AddDate(0, 1, 0)
+		}
+		begin, end := start, next
+		if begin.Before(from) {
+			begin = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		periods = append(periods, [2]time.Time{begin, end})
+		start = next
+	}
+	return periods
+}
+
+// 部署失败且没有恢复时间的, 以同一环境和应用之后首次成功且未失败的部署作为恢复时间
+func FillDoraRestores(deployments []DoraDeployment) {
+	sort.SliceStable(deployments, func(i, j int) bool {
+		return deployments[i].DeployedAt.Before(deployments[j].DeployedAt)
+	})
+	for i := range deployments {
+		if !deployments[i].Failed || deployments[i].RestoredAt != nil {
+			continue
+		}
+		for j := i + 1; j < len(deployments); j++ {
+			next := deployments[j]
+			if next.Env == deployments[i].Env && next.App == deployments[i].App && next.Deployed && !next.Failed {
+				restoredAt := next.DeployedAt
+				deployments[i].RestoredAt = &restoredAt
+				break
+			}
+		}
+	}
+}
+
+// 计算DORA指标报告, deployments须已调用FillDoraRestores
+func NewDoraReport(deployments []DoraDeployment, from, to time.Time, interval, groupBy string) DoraReport {
+	report := DoraReport{
+		Start:    from,
+		End:      to,
+		Interval: interval,
+		GroupBy:  groupBy,
+		Summary:  doraMetrics(deployments, from, to),
+		Series:   doraSeries(deployments, from, to, interval),
+		Groups:   make([]DoraGroup, 0),
+	}
+	if groupBy == "" {
+		return report
+	}
+	groups := map[string][]DoraDeployment{}
+	for _, deployment := range deployments {
+		key := deployment.App
+		switch groupBy {
+		case DoraGroupTeam:
+			key = deployment.Team
+		case DoraGroupEnv:
+			key = deployment.Env
+		}
+		groups[key] = append(groups[key], deployment)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		report.Groups = append(report.Groups, DoraGroup{
+			Key:     key,
+			Summary: doraMetrics(groups[key], from, to),
+			Series:  doraSeries(groups[key], from, to, interval),
+		})
+	}
+	return report
+}
+
+func doraSeries(deployments []DoraDeployment, from, to time.Time, interval string) []DoraPoint {
+	series := make([]DoraPoint, 0)
+	for _, period := range doraPeriods(from, to, interval) {
+		series = append(series, DoraPoint{Start: period[0], End: period[1], Metrics: doraMetrics(deployments, period[0], period[1])})
+	}
+	return series
+}
+
+// 计算时间范围内部署的指标
+func doraMetrics(deployments []DoraDeployment, from, to time.Time) DoraMetrics {
+	var metrics DoraMetrics
+	leadTimes := make([]float64, 0)
+	restoreHours := 0.0
+	for _, deployment := range deployments {
+		if deployment.DeployedAt.Before(from) || !deployment.DeployedAt.Before(to) {
+			continue
+		}
+		metrics.Deployments++
+		if deployment.Deployed {
+			metrics.SuccessDeployments++
+			if deployment.CommitAt != nil && deployment.DeployedAt.After(*deployment.CommitAt) {
+				leadTimes = append(leadTimes, deployment.DeployedAt.Sub(*deployment.CommitAt).Hours())
+			}
+		}
+		if deployment.Failed {
+			metrics.FailedChanges++
+			if deployment.RestoredAt != nil && !deployment.RestoredAt.Before(deployment.DeployedAt) {
+				metrics.Restores++
+				restoreHours += deployment.RestoredAt.Sub(deployment.DeployedAt).Hours()
+			}
+		}
+	}
+	if days := to.Sub(from).Hours() / 24; days > 0 {
+		metrics.DeploymentFrequency = round2(float64(metrics.SuccessDeployments) / days)
+	}
+	metrics.LeadTimeHours = round2(median(leadTimes))
+	if metrics.Deployments > 0 {
+		metrics.ChangeFailureRate = round2(float64(metrics.FailedChanges) * 100 / float64(metrics.Deployments))
+	}
+	if metrics.Restores > 0 {
+		metrics.RestoreTimeHours = round2(restoreHours / float64(metrics.Restores))
+	}
+	return metrics
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,151 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// go test -json输出的事件
+type goTestEvent struct {
+	Action      string
+	Package     string
+	ImportPath  string
+	Test        string
+	Elapsed     float64
+	Output      string
+	FailedBuild string
+}
+
+// 包级失败(构建失败、TestMain异常退出等)记录的用例名称
+const goTestPackageCase = "(package)"
+
+// 解析go test -json输出, 以包名作为测试套件
+// 包失败但没有失败用例时(构建失败、TestMain或init异常等)记录一条包级失败用例
+func ParseGoTestJSON(r io.Reader) ([]TestCase, error) {
+	cases := make([]TestCase, 0)
+	index := map[string]int{}
+	outputs := map[string]*strings.Builder{}
+	// 包级输出, 包括构建输出和不属于任何用例的输出
+	packageOutputs := map[string]*strings.Builder{}
+	packageOutput := func(pkg string) *strings.Builder {
+		b, ok := packageOutputs[pkg]
+		if !ok {
+			b = &strings.Builder{}
+			packageOutputs[pkg] = b
+		}
+		return b
+	}
+	// 非JSON输出, 旧版本go的构建错误直接输出到标准输出
+	plain := &strings.Builder{}
+	// 失败的包及其构建失败的ImportPath
+	packageFailed := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		// go test -json会混入构建错误等非JSON输出
+		if !strings.HasPrefix(text, "{") {
+			if text != "" && plain.Len() < maxOutputBytes {
+				plain.WriteString(scanner.Text() + "\n")
+			}
+			continue
+		}
+		var e goTestEvent
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			return nil, fmt.Errorf("解析go test -json第%d行失败: %w", line, err)
+		}
+		if e.Test == "" {
+			switch e.Action {
+			case "build-output":
+				if b := packageOutput(e.ImportPath); b.Len() < maxOutputBytes {
+					b.WriteString(e.Output)
+				}
+			case "output":
+				if b := packageOutput(e.Package); b.Len() < maxOutputBytes {
+					b.WriteString(e.Output)
+				}
+			case "fail":
+				// 构建失败时FailedBuild为构建输出的ImportPath
+				packageFailed[e.Package] = e.FailedBuild
+			}
+			continue
+		}
+		key := e.Package + "\x00" + e.Test
+		switch e.Action {
+		case "run":
+			if _, ok := index[key]; !ok {
+				index[key] = len(cases)
+				cases = append(cases, TestCase{Suite: e.Package, Name: e.Test, Classname: e.Package})
+				outputs[key] = &strings.Builder{}
+			}
+		case "output":
+			if b, ok := outputs[key]; ok && b.Len() < maxOutputBytes {
+				b.WriteString(e.Output)
+			}
+		case "pass", "fail", "skip":
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(cases)
+				i = len(cases)
+				cases = append(cases, TestCase{Suite: e.Package, Name: e.Test, Classname: e.Package})
+				outputs[key] = &strings.Builder{}
+			}
+			cases[i].Duration = e.Elapsed
+			switch e.Action {
+			case "pass":
+				cases[i].Status = TestPassed
+			case "fail":
+				cases[i].Status = TestFailed
+				cases[i].Message = "FAIL: " + e.Test
+				cases[i].Output = truncate(outputs[key].String())
+			case "skip":
+				cases[i].Status = TestSkipped
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// 没有结束事件的用例(如测试超时被中断)按失败处理
+	for key, i := range index {
+		if cases[i].Status == "" {
+			cases[i].Status = TestFailed
+			cases[i].Message = "测试未结束"
+			cases[i].Output = truncate(outputs[key].String())
+		}
+	}
+	for _, c := range cases {
+		if c.Status == TestFailed {
+			delete(packageFailed, c.Suite)
+		}
+	}
+	packages := make([]string, 0, len(packageFailed))
+	for pkg := range packageFailed {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	for _, pkg := range packages {
+		output := packageOutput(pkg).String()
+		if failedBuild := packageFailed[pkg]; failedBuild != "" {
+			output = packageOutput(failedBuild).String() + output
+		}
+		if strings.TrimSpace(output) == "" {
+			output = plain.String()
+		}
+		cases = append(cases, TestCase{
+			Suite:     pkg,
+			Name:      goTestPackageCase,
+			Classname: pkg,
+			Status:    TestFailed,
+			Message:   "FAIL: " + pkg,
+			Output:    truncate(output),
+		})
+	}
+	return cases, nil
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 打开testdata中的样例文件
+func openTestdata(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestParseGoTestJSON(t *testing.T) {
+	cases, err := ParseGoTestJSON(openTestdata(t, "gotest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		suite, name, status, message, output string
+	}{
+		{"example.com/app", "TestPass", TestPassed, "", ""},
+		{"example.com/app", "TestFail", TestFailed, "FAIL: TestFail", "app_test.go:12: got 1, want 2"},
+		{"example.com/app", "TestTable", TestPassed, "", ""},
+		{"example.com/app", "TestTable/case_1", TestPassed, "", ""},
+		{"example.com/app", "TestSkip", TestSkipped, "", ""},
+		{"example.com/hang", "TestHang", TestFailed, "测试未结束", "panic: test timed out"},
+		{"example.com/broken", goTestPackageCase, TestFailed, "FAIL: example.com/broken", "undefined: foo"},
+		{"example.com/initfail", goTestPackageCase, TestFailed, "FAIL: example.com/initfail", "panic: missing config"},
+	}
+	if len(cases) != len(want) {
+		t.Fatalf("got %d cases, want %d: %+v", len(cases), len(want), cases)
+	}
+	for i, w := range want {
+		c := cases[i]
+		if c.Suite != w.suite || c.Name != w.name || c.Status != w.status || c.Message != w.message || !strings.Contains(c.Output, w.output) {
+			t.Errorf("case %d = %+v, want %+v", i, c, w)
+		}
+	}
+	if cases[1].Duration != 0.02 || !cases[1].Failed() || cases[0].Failed() {
+		t.Errorf("TestFail duration = %v, failed = %v", cases[1].Duration, cases[1].Failed())
+	}
+	// 构建失败的包输出在前, 包级输出在后
+	if out := cases[6].Output; strings.Index(out, "undefined: foo") > strings.Index(out, "[build failed]") {
+		t.Errorf("build output order:\n%s", out)
+	}
+}
+
+func TestParseGoTestJSONPlainBuildError(t *testing.T) {
+	// 旧版本go的构建错误直接输出到标准输出, 包级失败用例使用该输出
+	input := "# example.com/old\n./a.go:3:1: syntax error\n" +
+		`{"Action":"fail","Package":"example.com/old","Elapsed":0}` + "\n"
+	cases, err := ParseGoTestJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 1 || cases[0].Name != goTestPackageCase || !strings.Contains(cases[0].Output, "syntax error") {
+		t.Fatalf("cases = %+v", cases)
+	}
+}
+
+func TestParseGoTestJSONMalformed(t *testing.T) {
+	input := `{"Action":"run","Package":"p","Test":"T"}` + "\n" + `{"Action":"pass",` + "\n"
+	if _, err := ParseGoTestJSON(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "第2行") {
+		t.Fatalf("error = %v, want line 2", err)
+	}
+	cases, err := ParseGoTestJSON(strings.NewReader(""))
+	if err != nil || len(cases) != 0 {
+		t.Fatalf("empty input = %+v, %v", cases, err)
+	}
+}
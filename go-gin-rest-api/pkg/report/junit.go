@@ -0,0 +1,110 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type junitSuites struct {
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	Skipped   *junitFailure `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+	SystemErr string        `xml:"system-err"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// 解析JUnit XML, 根节点可以是testsuites或testsuite
+func ParseJUnit(r io.Reader) ([]TestCase, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("解析JUnit XML失败: %w", err)
+	}
+	var suites []junitSuite
+	switch root.XMLName.Local {
+	case "testsuites":
+		var doc junitSuites
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析JUnit XML失败: %w", err)
+		}
+		suites = doc.Suites
+	case "testsuite":
+		var suite junitSuite
+		if err := xml.Unmarshal(data, &suite); err != nil {
+			return nil, fmt.Errorf("解析JUnit XML失败: %w", err)
+		}
+		suites = []junitSuite{suite}
+	default:
+		return nil, fmt.Errorf("不是JUnit XML, 根节点为%s", root.XMLName.Local)
+	}
+	cases := make([]TestCase, 0)
+	for _, suite := range suites {
+		cases = appendJUnitSuite(cases, suite)
+	}
+	return cases, nil
+}
+
+func appendJUnitSuite(cases []TestCase, suite junitSuite) []TestCase {
+	for _, c := range suite.Cases {
+		duration, _ := strconv.ParseFloat(strings.ReplaceAll(c.Time, ",", ""), 64)
+		testCase := TestCase{
+			Suite:     suite.Name,
+			Name:      c.Name,
+			Classname: c.Classname,
+			Status:    TestPassed,
+			Duration:  duration,
+		}
+		var failure *junitFailure
+		switch {
+		case c.Failure != nil:
+			testCase.Status = TestFailed
+			failure = c.Failure
+		case c.Error != nil:
+			testCase.Status = TestError
+			failure = c.Error
+		case c.Skipped != nil:
+			testCase.Status = TestSkipped
+			testCase.Message = c.Skipped.Message
+		}
+		if failure != nil {
+			testCase.Message = failure.Message
+			output := strings.TrimSpace(failure.Text)
+			if out := strings.TrimSpace(c.SystemOut + "\n" + c.SystemErr); out != "" {
+				output += "\n" + out
+			}
+			testCase.Output = truncate(output)
+		}
+		cases = append(cases, testCase)
+	}
+	// 嵌套的测试套件
+	for _, child := range suite.Suites {
+		cases = appendJUnitSuite(cases, child)
+	}
+	return cases
+}
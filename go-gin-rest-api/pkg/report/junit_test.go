@@ -0,0 +1,62 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJUnit(t *testing.T) {
+	cases, err := ParseJUnit(openTestdata(t, "junit.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		suite, name, status, message, output string
+		duration                             float64
+	}{
+		{"api", "returns user", TestPassed, "", "", 0.012},
+		{"api", "rejects bad id", TestFailed, "expected 400, got 500", "request id=42", 1234.5},
+		{"api", "needs db", TestSkipped, "no database", "", 0},
+		{"api.nested", "crashes", TestError, "NullPointerException", "boom", 0.5},
+		{"web", "renders", TestPassed, "", "", 0.2},
+	}
+	if len(cases) != len(want) {
+		t.Fatalf("got %d cases, want %d: %+v", len(cases), len(want), cases)
+	}
+	for i, w := range want {
+		c := cases[i]
+		if c.Suite != w.suite || c.Name != w.name || c.Status != w.status || c.Message != w.message || !strings.Contains(c.Output, w.output) || c.Duration != w.duration {
+			t.Errorf("case %d = %+v, want %+v", i, c, w)
+		}
+	}
+	if !strings.HasPrefix(cases[1].Output, "AssertionError: expected 400") {
+		t.Errorf("failure output = %q", cases[1].Output)
+	}
+	if cases[0].Classname != "api.UserTest" {
+		t.Errorf("classname = %q", cases[0].Classname)
+	}
+}
+
+func TestParseJUnitSingleSuite(t *testing.T) {
+	input := `<testsuite name="unit"><testcase name="a" time="0.1"/><testcase name="b"><failure>boom</failure></testcase></testsuite>`
+	cases, err := ParseJUnit(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 || cases[0].Suite != "unit" || cases[1].Status != TestFailed || cases[1].Output != "boom" {
+		t.Fatalf("cases = %+v", cases)
+	}
+}
+
+func TestParseJUnitMalformed(t *testing.T) {
+	for name, input := range map[string]string{
+		"not junit":   `<html><body/></html>`,
+		"broken xml":  `<testsuites><testsuite name="a">`,
+		"empty input": ``,
+		"json":        `{"tests": []}`,
+	} {
+		if _, err := ParseJUnit(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: ParseJUnit succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 代码检查问题统计
+type LintResult struct {
+	Errors   int64 // 错误数
+	Warnings int64 // 警告数
+	Infos    int64 // 提示数
+}
+
+// 问题总数
+func (l LintResult) Issues() int64 {
+	return l.Errors + l.Warnings + l.Infos
+}
+
+// 合并统计
+func (l *LintResult) Add(other LintResult) {
+	l.Errors += other.Errors
+	l.Warnings += other.Warnings
+	l.Infos += other.Infos
+}
+
+func (l *LintResult) count(level string) {
+	switch strings.ToLower(level) {
+	case "error":
+		l.Errors++
+	case "info", "note", "none":
+		l.Infos++
+	default:
+		l.Warnings++
+	}
+}
+
+// 解析checkstyle XML(golangci-lint、eslint等均支持输出)
+func ParseCheckstyle(r io.Reader) (LintResult, error) {
+	var doc struct {
+		XMLName xml.Name `xml:"checkstyle"`
+		Files   []struct {
+			Errors []struct {
+				Severity string `xml:"severity,attr"`
+			} `xml:"error"`
+		} `xml:"file"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return LintResult{}, fmt.Errorf("解析checkstyle XML失败: %w", err)
+	}
+	var result LintResult
+	for _, file := range doc.Files {
+		for _, e := range file.Errors {
+			result.count(e.Severity)
+		}
+	}
+	return result, nil
+}
+
+// 解析SARIF, 未设置level的结果按warning统计
+func ParseSARIF(r io.Reader) (LintResult, error) {
+	var doc struct {
+		Runs []struct {
+			Results []struct {
+				Level string `json:"level"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return LintResult{}, fmt.Errorf("解析SARIF失败: %w", err)
+	}
+	var result LintResult
+	for _, run := range doc.Runs {
+		for _, res := range run.Results {
+			result.count(res.Level)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCheckstyle(t *testing.T) {
+	result, err := ParseCheckstyle(openTestdata(t, "checkstyle.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未设置severity的问题按warning统计
+	if want := (LintResult{Errors: 1, Warnings: 2, Infos: 1}); result != want || result.Issues() != 4 {
+		t.Fatalf("result = %+v, want %+v", result, want)
+	}
+}
+
+func TestParseSARIF(t *testing.T) {
+	result, err := ParseSARIF(openTestdata(t, "sarif.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未设置level的结果按warning统计, note和none按提示统计
+	if want := (LintResult{Errors: 1, Warnings: 2, Infos: 2}); result != want {
+		t.Fatalf("result = %+v, want %+v", result, want)
+	}
+	result.Add(LintResult{Errors: 1})
+	if result.Errors != 2 || result.Issues() != 6 {
+		t.Errorf("Add = %+v", result)
+	}
+}
+
+func TestParseLintMalformed(t *testing.T) {
+	for _, input := range []string{`<testsuites/>`, `<checkstyle><file>`, ``} {
+		if _, err := ParseCheckstyle(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseCheckstyle(%q) succeeded", input)
+		}
+	}
+	for _, input := range []string{`{"runs": {}}`, `{"runs": [`, ``} {
+		if _, err := ParseSARIF(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseSARIF(%q) succeeded", input)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 质量指标
+const (
+	MetricTestPassRate = "testPassRate" // 测试通过率, 百分比
+	MetricTestFailed   = "testFailed"   // 失败用例数
+	MetricCoverage     = "coverage"     // 覆盖率, 百分比
+	MetricLintErrors   = "lintErrors"   // 代码检查错误数
+	MetricLintWarnings = "lintWarnings" // 代码检查警告数
+	MetricLintIssues   = "lintIssues"   // 代码检查问题总数
+)
+
+// 指标是否越大越好, 用于判断回退方向
+var metricHigherBetter = map[string]bool{
+	MetricTestPassRate: true,
+	MetricTestFailed:   false,
+	MetricCoverage:     true,
+	MetricLintErrors:   false,
+	MetricLintWarnings: false,
+	MetricLintIssues:   false,
+}
+
+// 质量门禁规则
+type QualityRule struct {
+	Metric       string   `json:"metric"`       // 指标: testPassRate/testFailed/coverage/lintErrors/lintWarnings/lintIssues
+	Op           string   `json:"op"`           // 比较符: >=/>/<=/</==, 与threshold一起使用
+	Threshold    *float64 `json:"threshold"`    // 阈值
+	NoRegression bool     `json:"noRegression"` // 不允许比基线分支最近一次结果变差
+	Tolerance    float64  `json:"tolerance"`    // 与基线比较时允许变差的幅度
+}
+
+// 校验规则
+func (r QualityRule) Validate() error {
+	if _, ok := metricHigherBetter[r.Metric]; !ok {
+		return fmt.Errorf("不支持的质量指标: %s", r.Metric)
+	}
+	if r.Threshold == nil && !r.NoRegression {
+		return fmt.Errorf("质量指标%s的规则需设置threshold或noRegression", r.Metric)
+	}
+	if r.Threshold != nil {
+		if _, err := compare(0, r.Op, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 规则检查结果
+type QualityResult struct {
+	Rule     QualityRule `json:"rule"`     // 规则
+	Value    *float64    `json:"value"`    // 本次运行的指标值, 未采集时为空
+	Baseline *float64    `json:"baseline"` // 基线值, 未开启回归比较或没有基线时为空
+	Passed   bool        `json:"passed"`   // 是否通过
+	Message  string      `json:"message"`  // 说明
+}
+
+// 按规则检查指标, baseline为基线分支最近一次运行的指标
+func EvaluateQuality(rules []QualityRule, metrics, baseline map[string]float64) []QualityResult {
+	results := make([]QualityResult, 0, len(rules))
+	for _, rule := range rules {
+		result := QualityResult{Rule: rule, Passed: true}
+		value, ok := metrics[rule.Metric]
+		if !ok {
+			result.Passed = false
+			result.Message = fmt.Sprintf("%s: 未采集到指标", rule.Metric)
+			results = append(results, result)
+			continue
+		}
+		result.Value = &value
+		messages := make([]string, 0, 2)
+		if rule.Threshold != nil {
+			passed, err := compare(value, rule.Op, *rule.Threshold)
+			if err != nil {
+				passed = false
+				messages = append(messages, err.Error())
+			} else if !passed {
+				messages = append(messages, fmt.Sprintf("%s=%s, 要求%s%s", rule.Metric, formatMetric(value), rule.Op, formatMetric(*rule.Threshold)))
+			}
+			result.Passed = passed
+		}
+		if rule.NoRegression {
+			if base, ok := baseline[rule.Metric]; ok {
+				result.Baseline = &base
+				regressed := value < base-rule.Tolerance
+				if !metricHigherBetter[rule.Metric] {
+					regressed = value > base+rule.Tolerance
+				}
+				if regressed {
+					result.Passed = false
+					messages = append(messages, fmt.Sprintf("%s=%s, 比基线%s变差", rule.Metric, formatMetric(value), formatMetric(base)))
+				}
+			}
+		}
+		if result.Passed {
+			result.Message = fmt.Sprintf("%s=%s, 通过", rule.Metric, formatMetric(value))
+		} else {
+			result.Message = strings.Join(messages, "; ")
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 检查结果的可读明细
+func FormatQualityResults(results []QualityResult) string {
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		mark := "[通过]"
+		if !result.Passed {
+			mark = "[失败]"
+		}
+		lines = append(lines, mark+" "+result.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func compare(value float64, op string, threshold float64) (bool, error) {
+	switch op {
+	case ">=":
+		return value >= threshold, nil
+	case ">":
+		return value > threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "==":
+		return value == threshold, nil
+	}
+	return false, fmt.Errorf("不支持的比较符: %s", op)
+}
+
+func formatMetric(value float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
@@ -0,0 +1,36 @@
+// 测试报告、覆盖率和代码检查结果解析
+package report
+
+// 测试用例状态
+const (
+	TestPassed  = "passed"
+	TestFailed  = "failed"
+	TestError   = "error"
+	TestSkipped = "skipped"
+)
+
+// 单个用例输出最大保留字节数
+const maxOutputBytes = 64 * 1024
+
+// 测试用例结果
+type TestCase struct {
+	Suite     string  // 测试套件, go test为包名
+	Name      string  // 用例名称
+	Classname string  // 类名
+	Status    string  // 状态: passed/failed/error/skipped
+	Duration  float64 // 耗时, 秒
+	Message   string  // 失败信息
+	Output    string  // 失败输出
+}
+
+// 是否失败
+func (c TestCase) Failed() bool {
+	return c.Status == TestFailed || c.Status == TestError
+}
+
+func truncate(s string) string {
+	if len(s) > maxOutputBytes {
+		return s[:maxOutputBytes] + "\n...(输出已截断)"
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 旧版本保存在密钥目录中的加密密钥名称, 流水线任务可读取密钥目录, 该名称保留, Get拒绝读取
+const legacyEncryptionKey = "encryption-key"
+
+// 加密数据库中凭据的AES-256密钥, 启动时由LoadEncryptionKey加载
+var (
+	keyLock sync.RWMutex
+	key     []byte
+)
+
+// 加载加密密钥, value为base64编码的32字节密钥, 如: head -c 32 /dev/urandom | base64;
+// 多副本部署时各副本须使用相同密钥, 因此不自动生成
+func LoadEncryptionKey(value string) error {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(data) != 32 {
+		return errors.New("加密密钥须为base64编码的32字节密钥")
+	}
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	key = data
+	return nil
+}
+
+// 读取加密密钥文件, 文件不能位于密钥目录中, 以免流水线任务通过密钥存储读取
+func LoadEncryptionKeyFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(Dir)
+	if err != nil {
+		return err
+	}
+	// 按链接目标判断, 挂载的k8s Secret中文件为符号链接
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	if rel, err := filepath.Rel(dir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("加密密钥文件%s不能位于密钥目录%s中", path, Dir)
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return fmt.Errorf("读取加密密钥文件失败: %w", err)
+	}
+	return LoadEncryptionKey(string(data))
+}
+
+// 旧版本密钥目录中的加密密钥文件是否存在, 升级时须移出密钥目录
+func LegacyEncryptionKeyExists() bool {
+	_, err := os.Stat(filepath.Join(Dir, legacyEncryptionKey))
+	return err == nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	keyLock.RLock()
+	defer keyLock.RUnlock()
+	if key == nil {
+		return nil, errors.New("未加载加密密钥")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 使用AES-GCM加密, 返回base64编码的nonce+密文, 空字符串不加密
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// 解密Encrypt的结果
+func Decrypt(ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("解密失败: 密文长度错误")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败, 加密密钥可能已变更: %w", err)
+	}
+	return string(plaintext), nil
+}
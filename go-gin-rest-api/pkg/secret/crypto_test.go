@@ -0,0 +1,93 @@
+package secret
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), 32)))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	if err := LoadEncryptionKey(testKey('a')); err != nil {
+		t.Fatal(err)
+	}
+	for _, plaintext := range []string{"", "token", "多字节令牌\n带换行"} {
+		ciphertext, err := Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext != "" && (ciphertext == plaintext || strings.Contains(ciphertext, plaintext)) {
+			t.Errorf("Encrypt(%q) = %q, not encrypted", plaintext, ciphertext)
+		}
+		got, err := Decrypt(ciphertext)
+		if err != nil || got != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", plaintext, got, err)
+		}
+	}
+	first, _ := Encrypt("token")
+	second, _ := Encrypt("token")
+	if first == second {
+		t.Error("Encrypt uses a fixed nonce")
+	}
+	if err := LoadEncryptionKey(testKey('b')); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(first); err == nil {
+		t.Error("Decrypt with another key succeeded")
+	}
+	if _, err := Decrypt("bm90LWVuY3J5cHRlZA=="); err == nil {
+		t.Error("Decrypt of short ciphertext succeeded")
+	}
+}
+
+func TestLoadEncryptionKey(t *testing.T) {
+	for _, value := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if err := LoadEncryptionKey(value); err == nil {
+			t.Errorf("LoadEncryptionKey(%q) succeeded", value)
+		}
+	}
+	if err := LoadEncryptionKey(testKey('c') + "\n"); err != nil {
+		t.Errorf("LoadEncryptionKey with trailing newline: %v", err)
+	}
+}
+
+func TestLoadEncryptionKeyFile(t *testing.T) {
+	root := t.TempDir()
+	dir := Dir
+	Dir = filepath.Join(root, "secret")
+	t.Cleanup(func() { Dir = dir })
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	inside := filepath.Join(Dir, "encryption-key")
+	outside := filepath.Join(root, "encryption-key")
+	for _, path := range []string{inside, outside} {
+		if err := os.WriteFile(path, []byte(testKey('d')+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := LoadEncryptionKeyFile(inside); err == nil {
+		t.Error("loaded encryption key from the secret directory")
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(inside, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadEncryptionKeyFile(link); err == nil {
+		t.Error("loaded encryption key through a symlink into the secret directory")
+	}
+	if err := LoadEncryptionKeyFile(outside); err != nil {
+		t.Fatal(err)
+	}
+	if !LegacyEncryptionKeyExists() {
+		t.Error("legacy encryption key not detected")
+	}
+	if _, err := Get("encryption-key"); err == nil {
+		t.Error("Get returned the encryption key")
+	}
+}
@@ -0,0 +1,31 @@
+// 密钥存储, 密钥以文件形式保存在storage/secret目录, 文件名即密钥名称, 可直接挂载k8s Secret
+package secret
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 密钥目录
+var Dir = "./storage/secret"
+
+// 密钥名称只允许字母数字及._-, 且不能以.开头
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// 读取密钥, 去掉末尾换行
+func Get(name string) (string, error) {
+	if !nameRegexp.MatchString(name) || name == legacyEncryptionKey {
+		return "", fmt.Errorf("非法的密钥名称: %s", name)
+	}
+	data, err := os.ReadFile(filepath.Join(Dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("密钥不存在: %s", name)
+		}
+		return "", fmt.Errorf("读取密钥%s失败: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
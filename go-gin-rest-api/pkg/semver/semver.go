@@ -0,0 +1,209 @@
+// 语义化版本, 根据约定式提交(Conventional Commits)计算下一个版本
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 版本升级类型
+type Bump int
+
+const (
+	BumpNone Bump = iota
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	}
+	return "none"
+}
+
+// 语义化版本号的格式, 允许v前缀
+var versionRegexp = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// 约定式提交的标题, 如feat(api)!: xxx
+var commitRegexp = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s`)
+
+// 非法的预发布标识字符
+var identifierRegexp = regexp.MustCompile(`[^0-9A-Za-z-]+`)
+
+// 语义化版本
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string // 预发布标识, 如feature-login.3
+	Build      string // 构建元数据, 如提交SHA
+}
+
+// 解析版本号, 允许v前缀
+func Parse(s string) (Version, error) {
+	m := versionRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("非法的语义化版本: %s", s)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return Version{Major: major, Minor: minor, Patch: patch, Prerelease: m[4], Build: m[5]}, nil
+}
+
+// 版本号, 不含v前缀
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// 镜像标签, 镜像标签不允许+, 构建元数据以_连接
+func (v Version) ImageTag() string {
+	return strings.Replace(v.String(), "+", "_", 1)
+}
+
+// 比较版本优先级, 忽略构建元数据, 正式版本高于同号的预发布版本
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// 按升级类型计算下一个版本, 清空预发布标识和构建元数据;
+// 预发布版本已包含该级别的升级时只去掉预发布标识, 如1.2.0-rc.1按patch或minor升级为1.2.0
+func (v Version) Next(bump Bump) Version {
+	pre := v.Prerelease != ""
+	switch bump {
+	case BumpMajor:
+		if pre && v.Minor == 0 && v.Patch == 0 {
+			return Version{Major: v.Major}
+		}
+		return Version{Major: v.Major + 1}
+	case BumpMinor:
+		if pre && v.Patch == 0 {
+			return Version{Major: v.Major, Minor: v.Minor}
+		}
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	case BumpPatch:
+		if pre {
+			return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+		}
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+}
+
+// 约定式提交
+type Commit struct {
+	Type     string // 类型, 如feat/fix, 小写
+	Scope    string // 范围
+	Subject  string // 标题
+	Breaking bool   // 是否为不兼容变更
+}
+
+// 解析约定式提交的说明, 不符合约定式提交格式时ok为false, Subject为说明的第一行
+func ParseConventional(message string) (commit Commit, ok bool) {
+	title := strings.TrimSpace(strings.SplitN(message, "\n", 2)[0])
+	commit.Subject = title
+	commit.Breaking = strings.Contains(message, "BREAKING CHANGE:") || strings.Contains(message, "BREAKING-CHANGE:")
+	m := commitRegexp.FindStringSubmatch(title)
+	if m == nil {
+		return commit, false
+	}
+	commit.Type = strings.ToLower(m[1])
+	commit.Scope = m[2]
+	commit.Breaking = commit.Breaking || m[3] == "!"
+	commit.Subject = strings.TrimSpace(title[len(m[0]):])
+	return commit, true
+}
+
+// 根据约定式提交的说明确定升级类型: 类型后带!或包含BREAKING CHANGE为major, feat为minor, fix/perf为patch, 其他不升级
+func ParseCommit(message string) Bump {
+	commit, ok := ParseConventional(message)
+	switch {
+	case commit.Breaking:
+		return BumpMajor
+	case !ok:
+		return BumpNone
+	case commit.Type == "feat":
+		return BumpMinor
+	case commit.Type == "fix" || commit.Type == "perf":
+		return BumpPatch
+	}
+	return BumpNone
+}
+
+// 多个提交的最高升级类型
+func ParseCommits(messages []string) Bump {
+	bump := BumpNone
+	for _, message := range messages {
+		if b := ParseCommit(message); b > bump {
+			bump = b
+		}
+	}
+	return bump
+}
+
+// 将分支名转换为预发布标识, 如feature/Login_1转换为feature-login-1
+func Identifier(s string) string {
+	return strings.Trim(identifierRegexp.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return sign(an - bn)
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+func sign(d int) int {
+	switch {
+	case d > 0:
+		return 1
+	case d < 0:
+		return -1
+	}
+	return 0
+}
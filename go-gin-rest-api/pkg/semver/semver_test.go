@@ -0,0 +1,160 @@
+package semver
+
+import (
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) Version {
+	t.Helper()
+	v, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		{" v0.1.0 ", "0.1.0", true},
+		{"1.2.3-rc.1+abc1234", "1.2.3-rc.1+abc1234", true},
+		{"1.2", "", false},
+		{"01.2.3", "", false},
+		{"1.2.3-", "", false},
+		{"latest", "", false},
+	}
+	for _, c := range cases {
+		v, err := Parse(c.in)
+		if (err == nil) != c.ok {
+			t.Errorf("Parse(%q) error = %v, want ok=%v", c.in, err, c.ok)
+			continue
+		}
+		if c.ok && v.String() != c.want {
+			t.Errorf("Parse(%q) = %s, want %s", c.in, v, c.want)
+		}
+	}
+	if got := mustParse(t, "1.2.3-feature.2+abc1234").ImageTag(); got != "1.2.3-feature.2_abc1234" {
+		t.Errorf("ImageTag = %s", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "v1.2.3", 0},
+		{"1.2.3+abc", "1.2.3+def", 0},
+		{"2.0.0", "1.9.9", 1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.3", "1.2.4", -1},
+		{"1.2.3", "1.2.3-rc.1", 1},
+		{"1.2.3-rc.1", "1.2.3", -1},
+		{"1.2.3-rc.2", "1.2.3-rc.10", -1},
+		{"1.2.3-alpha", "1.2.3-beta", -1},
+		{"1.2.3-alpha", "1.2.3-alpha.1", -1},
+		{"1.2.3-1", "1.2.3-alpha", -1},
+		{"1.2.3-beta.11", "1.2.3-beta.2", 1},
+	}
+	for _, c := range cases {
+		if got := mustParse(t, c.a).Compare(mustParse(t, c.b)); got != c.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		base string
+		bump Bump
+		want string
+	}{
+		{"1.2.3", BumpNone, "1.2.3"},
+		{"1.2.3", BumpPatch, "1.2.4"},
+		{"1.2.3", BumpMinor, "1.3.0"},
+		{"1.2.3", BumpMajor, "2.0.0"},
+		{"1.2.3+abc", BumpPatch, "1.2.4"},
+		{"1.2.0-rc.1", BumpNone, "1.2.0"},
+		{"1.2.0-rc.1", BumpPatch, "1.2.0"},
+		{"1.2.0-rc.1", BumpMinor, "1.2.0"},
+		{"1.2.0-rc.1", BumpMajor, "2.0.0"},
+		{"1.2.3-rc.1", BumpPatch, "1.2.3"},
+		{"1.2.3-rc.1", BumpMinor, "1.3.0"},
+		{"2.0.0-rc.1", BumpPatch, "2.0.0"},
+		{"2.0.0-rc.1", BumpMinor, "2.0.0"},
+		{"2.0.0-rc.1", BumpMajor, "2.0.0"},
+	}
+	for _, c := range cases {
+		if got := mustParse(t, c.base).Next(c.bump).String(); got != c.want {
+			t.Errorf("%s.Next(%s) = %s, want %s", c.base, c.bump, got, c.want)
+		}
+	}
+}
+
+func TestParseCommit(t *testing.T) {
+	cases := []struct {
+		message string
+		want    Bump
+	}{
+		{"feat: add login", BumpMinor},
+		{"feat(api): add login", BumpMinor},
+		{"Feat: add login", BumpMinor},
+		{"fix: handle nil", BumpPatch},
+		{"perf(db): add index", BumpPatch},
+		{"docs: update readme", BumpNone},
+		{"chore(deps): bump go", BumpNone},
+		{"feat!: drop v1 api", BumpMajor},
+		{"fix(api)!: change response", BumpMajor},
+		{"feat: new api\n\nBREAKING CHANGE: removes old api", BumpMajor},
+		{"refactor: x\n\nBREAKING-CHANGE: y", BumpMajor},
+		{"update readme", BumpNone},
+		{"feat:missing space", BumpNone},
+		{"Merge branch 'main'", BumpNone},
+		{"", BumpNone},
+	}
+	for _, c := range cases {
+		if got := ParseCommit(c.message); got != c.want {
+			t.Errorf("ParseCommit(%q) = %s, want %s", c.message, got, c.want)
+		}
+	}
+	if got := ParseCommits([]string{"docs: x", "fix: y", "feat: z", "chore: w"}); got != BumpMinor {
+		t.Errorf("ParseCommits = %s, want minor", got)
+	}
+	if got := ParseCommits(nil); got != BumpNone {
+		t.Errorf("ParseCommits(nil) = %s, want none", got)
+	}
+}
+
+func TestParseConventional(t *testing.T) {
+	commit, ok := ParseConventional("feat(api)!: add login\n\nbody")
+	if !ok || commit.Type != "feat" || commit.Scope != "api" || !commit.Breaking || commit.Subject != "add login" {
+		t.Errorf("ParseConventional = %+v, %v", commit, ok)
+	}
+	commit, ok = ParseConventional("Update readme\n\nbody")
+	if ok || commit.Subject != "Update readme" || commit.Type != "" {
+		t.Errorf("ParseConventional non-conventional = %+v, %v", commit, ok)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	cases := map[string]string{
+		"main":                "main",
+		"feature/Login_1":     "feature-login-1",
+		"feature//login":      "feature-login",
+		"bugfix/JIRA-123.fix": "bugfix-jira-123-fix",
+		"/release/":           "release",
+		"中文分支":                "",
+		"":                    "",
+	}
+	for in, want := range cases {
+		if got := Identifier(in); got != want {
+			t.Errorf("Identifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/secret"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PEM块类型, 与cosign generate-key-pair生成的密钥一致
+const (
+	pemEncryptedSigstoreKey = "ENCRYPTED SIGSTORE PRIVATE KEY"
+	pemEncryptedCosignKey   = "ENCRYPTED COSIGN PRIVATE KEY"
+	pemPrivateKey           = "PRIVATE KEY"
+	pemECPrivateKey         = "EC PRIVATE KEY"
+	pemPublicKey            = "PUBLIC KEY"
+)
+
+// cosign加密私钥的内容, scrypt派生密钥后用nacl/secretbox加密PKCS8私钥
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// 签名器, 支持ECDSA和Ed25519私钥
+type Signer struct {
+	key crypto.Signer
+}
+
+// 从密钥存储加载私钥, passwordSecret为cosign加密私钥的密码的密钥名称, 未加密的PKCS8/EC私钥可为空
+func LoadSigner(keySecret, passwordSecret string) (*Signer, error) {
+	data, err := secret.Get(keySecret)
+	if err != nil {
+		return nil, err
+	}
+	password := ""
+	if passwordSecret != "" {
+		if password, err = secret.Get(passwordSecret); err != nil {
+			return nil, err
+		}
+	}
+	return ParsePrivateKey([]byte(data), []byte(password))
+}
+
+// 解析PEM格式私钥, 支持cosign加密私钥、PKCS8私钥和EC私钥
+func ParsePrivateKey(data, password []byte) (*Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("私钥不是PEM格式")
+	}
+	der := block.Bytes
+	switch block.Type {
+	case pemEncryptedSigstoreKey, pemEncryptedCosignKey:
+		var err error
+		if der, err = decryptKey(block.Bytes, password); err != nil {
+			return nil, err
+		}
+	case pemECPrivateKey:
+		key, err := x509.ParseECPrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("解析EC私钥失败: %w", err)
+		}
+		return &Signer{key: key}, nil
+	case pemPrivateKey:
+	default:
+		return nil, fmt.Errorf("不支持的私钥类型: %s", block.Type)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return &Signer{key: key}, nil
+	case ed25519.PrivateKey:
+		return &Signer{key: key}, nil
+	}
+	return nil, fmt.Errorf("不支持的私钥算法: %T", key)
+}
+
+// 解密cosign加密私钥
+func decryptKey(data, password []byte) ([]byte, error) {
+	var key encryptedKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("解析加密私钥失败: %w", err)
+	}
+	if key.KDF.Name != "scrypt" || key.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("不支持的私钥加密方式: %s/%s", key.KDF.Name, key.Cipher.Name)
+	}
+	if len(key.Cipher.Nonce) != 24 {
+		return nil, errors.New("加密私钥的nonce长度错误")
+	}
+	secretKey, err := scrypt.Key(password, key.KDF.Salt, key.KDF.Params.N, key.KDF.Params.R, key.KDF.Params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("派生私钥密码失败: %w", err)
+	}
+	var nonce [24]byte
+	var boxKey [32]byte
+	copy(nonce[:], key.Cipher.Nonce)
+	copy(boxKey[:], secretKey)
+	der, ok := secretbox.Open(nil, key.Ciphertext, &nonce, &boxKey)
+	if !ok {
+		return nil, errors.New("解密私钥失败, 请检查私钥密码")
+	}
+	return der, nil
+}
+
+// 签名, ECDSA对payload的sha256摘要签名(ASN.1编码), Ed25519直接对payload签名
+func (s *Signer) Sign(payload []byte) ([]byte, error) {
+	if key, ok := s.key.(ed25519.PrivateKey); ok {
+		return ed25519.Sign(key, payload), nil
+	}
+	digest := sha256.Sum256(payload)
+	return s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// 从密钥存储加载公钥, 如cosign.pub
+func LoadPublicKeys(keySecrets []string) ([]crypto.PublicKey, error) {
+	keys := make([]crypto.PublicKey, 0, len(keySecrets))
+	for _, keySecret := range keySecrets {
+		data, err := secret.Get(keySecret)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePublicKey([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("公钥%s: %w", keySecret, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// 解析PEM格式公钥, 如cosign.pub
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemPublicKey {
+		return nil, errors.New("公钥不是PEM格式")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析公钥失败: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("不支持的公钥算法: %T", key)
+}
+
+// 校验签名
+func verify(key crypto.PublicKey, payload, signature []byte) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	}
+	return false
+}
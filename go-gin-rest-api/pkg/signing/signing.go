@@ -0,0 +1,182 @@
+// 镜像签名与验签, 签名格式与cosign一致: 签名以sha256-<摘要>.sig标签保存在镜像所在仓库,
+// 每个签名为一层simple signing载荷, 签名值保存在层的dev.cosignproject.cosign/signature注解中
+package signing
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-gin-rest-api/pkg/registry"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// cosign约定的媒体类型、注解和载荷类型
+const (
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	SignatureAnnotation    = "dev.cosignproject.cosign/signature"
+	payloadType            = "cosign container image signature"
+)
+
+// 签名载荷的最大长度
+const maxPayloadSize = 1 << 20
+
+// simple signing签名载荷
+type Payload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"` // 镜像仓库
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"` // 镜像摘要
+		} `json:"image"`
+		Type string `json:"type"` // 固定为cosign container image signature
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"` // 附加信息, 如流水线ID
+}
+
+// 签名标签, 如registry/app:sha256-<hex>.sig
+func SignatureTag(ref name.Digest) name.Tag {
+	return ref.Context().Tag(strings.Replace(ref.DigestStr(), ":", "-", 1) + ".sig")
+}
+
+// 生成签名载荷
+func NewPayload(ref name.Digest, optional map[string]interface{}) ([]byte, error) {
+	var payload Payload
+	payload.Critical.Identity.DockerReference = ref.Context().Name()
+	payload.Critical.Image.DockerManifestDigest = ref.DigestStr()
+	payload.Critical.Type = payloadType
+	payload.Optional = optional
+	return json.Marshal(payload)
+}
+
+// 签名镜像摘要并推送签名, 已有签名时追加, 返回签名标签
+func Sign(ctx context.Context, ref name.Digest, signer *Signer, optional map[string]interface{}, auth registry.Auth) (string, error) {
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	payload, err := NewPayload(ref, optional)
+	if err != nil {
+		return "", err
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("签名失败: %w", err)
+	}
+	tag := SignatureTag(ref)
+	base, err := remote.Image(tag, opts...)
+	if registry.IsNotFound(err) {
+		base = mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	} else if err != nil {
+		return "", fmt.Errorf("获取已有签名%s失败: %w", tag, err)
+	}
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer:       static.NewLayer(payload, SimpleSigningMediaType),
+		Annotations: map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := remote.Write(tag, img, opts...); err != nil {
+		return "", fmt.Errorf("推送签名%s失败: %w", tag, err)
+	}
+	return tag.String(), nil
+}
+
+// 验签结果
+type Verified struct {
+	Key      int                    `json:"key"`      // 验签通过的公钥序号
+	Identity string                 `json:"identity"` // 签名载荷中的镜像仓库
+	Optional map[string]interface{} `json:"optional"` // 签名载荷中的附加信息
+}
+
+// 校验镜像摘要是否有任一公钥的有效签名, 返回全部有效签名, 没有有效签名时返回错误
+func Verify(ctx context.Context, ref name.Digest, keys []crypto.PublicKey, auth registry.Auth) ([]Verified, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("未设置验签公钥")
+	}
+	opts, err := auth.RemoteOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tag := SignatureTag(ref)
+	img, err := remote.Image(tag, opts...)
+	if registry.IsNotFound(err) {
+		return nil, fmt.Errorf("镜像%s没有签名", ref)
+	} else if err != nil {
+		return nil, fmt.Errorf("获取镜像签名%s失败: %w", tag, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("获取镜像签名%s失败: %w", tag, err)
+	}
+	verified := make([]Verified, 0)
+	reasons := make([]string, 0)
+	for _, desc := range manifest.Layers {
+		if desc.MediaType != SimpleSigningMediaType {
+			continue
+		}
+		result, err := verifyLayer(img, desc, ref, keys)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		verified = append(verified, *result)
+	}
+	if len(verified) == 0 {
+		if len(reasons) == 0 {
+			return nil, fmt.Errorf("镜像%s没有签名", ref)
+		}
+		return nil, fmt.Errorf("镜像%s没有可信公钥的有效签名: %s", ref, strings.Join(reasons, "; "))
+	}
+	return verified, nil
+}
+
+// 校验一个签名层: 载荷须指向ref的摘要, 且签名可由任一公钥验证
+func verifyLayer(img v1.Image, desc v1.Descriptor, ref name.Digest, keys []crypto.PublicKey) (*Verified, error) {
+	signature, err := base64.StdEncoding.DecodeString(desc.Annotations[SignatureAnnotation])
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("签名%s缺少签名值", desc.Digest)
+	}
+	layer, err := img.LayerByDigest(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxPayloadSize))
+	if err != nil {
+		return nil, fmt.Errorf("读取签名载荷%s失败: %w", desc.Digest, err)
+	}
+	var payload Payload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("解析签名载荷%s失败: %w", desc.Digest, err)
+	}
+	if payload.Critical.Type != payloadType {
+		return nil, fmt.Errorf("签名%s的载荷类型错误: %s", desc.Digest, payload.Critical.Type)
+	}
+	if payload.Critical.Image.DockerManifestDigest != ref.DigestStr() {
+		return nil, fmt.Errorf("签名%s的镜像摘要%s不匹配", desc.Digest, payload.Critical.Image.DockerManifestDigest)
+	}
+	for i, key := range keys {
+		if verify(key, data, signature) {
+			return &Verified{Key: i, Identity: payload.Critical.Identity.DockerReference, Optional: payload.Optional}, nil
+		}
+	}
+	return nil, fmt.Errorf("签名%s不是可信公钥签署的", desc.Digest)
+}
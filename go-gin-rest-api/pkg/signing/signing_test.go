@@ -0,0 +1,157 @@
+package signing
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"go-gin-rest-api/pkg/registry"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// 内存镜像仓库使用http访问, 设置用户名以免读取docker配置文件
+var testAuth = registry.Auth{Username: "test", Password: "test", Insecure: true}
+
+// 向内存镜像仓库推送一个随机镜像, 返回镜像摘要引用
+func pushRandomImage(t *testing.T, host, repository string) name.Digest {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.NewDigest(host+"/"+repository+"@"+digest.String(), testAuth.NameOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := testAuth.RemoteOptions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img, opts...); err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+// 启动内存镜像仓库, 返回仓库地址
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// 生成PKCS8格式的ECDSA私钥
+func newTestSigner(t *testing.T) (*Signer, crypto.PublicKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, key.Public()
+}
+
+func TestSignAndVerify(t *testing.T) {
+	host := newTestRegistry(t)
+	ref := pushRandomImage(t, host, "demo/app")
+	signer, public := newTestSigner(t)
+	ctx := context.Background()
+	tag, err := Sign(ctx, ref, signer, map[string]interface{}{"dagInsId": "ins-1"}, testAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := SignatureTag(ref).String(); tag != want {
+		t.Errorf("signature tag = %s, want %s", tag, want)
+	}
+	verified, err := Verify(ctx, ref, []crypto.PublicKey{public}, testAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 1 || verified[0].Key != 0 || verified[0].Optional["dagInsId"] != "ins-1" {
+		t.Fatalf("verified = %+v", verified)
+	}
+	if !strings.HasSuffix(verified[0].Identity, "demo/app") {
+		t.Errorf("identity = %s, want demo/app", verified[0].Identity)
+	}
+}
+
+func TestSignAppends(t *testing.T) {
+	host := newTestRegistry(t)
+	ref := pushRandomImage(t, host, "demo/app")
+	first, firstPublic := newTestSigner(t)
+	second, secondPublic := newTestSigner(t)
+	ctx := context.Background()
+	for _, signer := range []*Signer{first, second} {
+		if _, err := Sign(ctx, ref, signer, nil, testAuth); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verified, err := Verify(ctx, ref, []crypto.PublicKey{secondPublic, firstPublic}, testAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 2 || verified[0].Key != 1 || verified[1].Key != 0 {
+		t.Fatalf("verified = %+v, want both signatures", verified)
+	}
+}
+
+func TestVerifyUntrustedKey(t *testing.T) {
+	host := newTestRegistry(t)
+	ref := pushRandomImage(t, host, "demo/app")
+	signer, _ := newTestSigner(t)
+	_, other := newTestSigner(t)
+	ctx := context.Background()
+	if _, err := Sign(ctx, ref, signer, nil, testAuth); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(ctx, ref, []crypto.PublicKey{other}, testAuth); err == nil || !strings.Contains(err.Error(), "没有可信公钥的有效签名") {
+		t.Fatalf("Verify error = %v, want untrusted signature", err)
+	}
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	host := newTestRegistry(t)
+	ref := pushRandomImage(t, host, "demo/app")
+	_, public := newTestSigner(t)
+	if _, err := Verify(context.Background(), ref, []crypto.PublicKey{public}, testAuth); err == nil || !strings.Contains(err.Error(), "没有签名") {
+		t.Fatalf("Verify error = %v, want unsigned", err)
+	}
+}
+
+func TestVerifyOtherDigest(t *testing.T) {
+	host := newTestRegistry(t)
+	signed := pushRandomImage(t, host, "demo/app")
+	unsigned := pushRandomImage(t, host, "demo/app")
+	signer, public := newTestSigner(t)
+	ctx := context.Background()
+	if _, err := Sign(ctx, signed, signer, nil, testAuth); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(ctx, unsigned, []crypto.PublicKey{public}, testAuth); err == nil {
+		t.Fatal("signature of another digest verified")
+	}
+}
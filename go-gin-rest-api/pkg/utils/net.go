@@ -1,6 +1,9 @@
 package utils
 
-import "net"
+import (
+	"fmt"
+	"net"
+)
 
 // 获取本机网卡IP
 func GetLocalIP() (ipv4 string, err error) {
@@ -30,3 +33,13 @@ func GetLocalIP() (ipv4 string, err error) {
 
 	return
 }
+
+// IPv4地址转换为数字编号, 每段补齐3位, 如192.168.1.1转换为192168001001
+// fastflow的worker key需要满足"xxx-{{number}}"格式且编号不超过255255255255
+func IPv4ToNumber(ipv4 string) (string, error) {
+	ip := net.ParseIP(ipv4).To4()
+	if ip == nil {
+		return "", fmt.Errorf("非法的IPv4地址: %s", ipv4)
+	}
+	return fmt.Sprintf("%d%03d%03d%03d", ip[0], ip[1], ip[2], ip[3]), nil
+}
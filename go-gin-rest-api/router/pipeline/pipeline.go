@@ -14,6 +14,67 @@ func InitPipelineRouter(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware
 	{
 		router.POST("/run", pipeline.RunPipeline)
 		router.POST("/upsert", pipeline.UpsertPipeline)
+		router.GET("/outputs/:id", pipeline.GetPipelineOutputs)
+		router.POST("/outputs/latest", pipeline.GetLatestPipelineOutputs)
+		router.GET("/workers", pipeline.GetPipelineWorkers)
+		router.POST("/workers/:key/drain", pipeline.DrainPipelineWorker)
+		router.POST("/workers/:key/undrain", pipeline.UndrainPipelineWorker)
+		router.GET("/test-reports/:id", pipeline.GetPipelineTestReport)
+		router.GET("/test-reports/:id/failures", pipeline.GetPipelineTestFailures)
+		router.GET("/test-history/:dag", pipeline.GetPipelineTestHistory)
+		router.GET("/quality-policies", pipeline.GetPipelineQualityPolicies)
+		router.POST("/quality-policies", pipeline.UpsertPipelineQualityPolicy)
+		router.DELETE("/quality-policies/:app", pipeline.DeletePipelineQualityPolicy)
+		router.GET("/deploy-policies", pipeline.GetPipelineDeployPolicies)
+		router.POST("/deploy-policies", pipeline.UpsertPipelineDeployPolicy)
+		router.POST("/deploy-policies/test", pipeline.TestPipelineDeployPolicy)
+		router.DELETE("/deploy-policies/:name", pipeline.DeletePipelineDeployPolicy)
+		router.GET("/freeze-windows", pipeline.GetPipelineFreezeWindows)
+		router.POST("/freeze-windows", pipeline.UpsertPipelineFreezeWindow)
+		router.GET("/freeze-windows/upcoming", pipeline.GetUpcomingPipelineFreezes)
+		router.DELETE("/freeze-windows/:name", pipeline.DeletePipelineFreezeWindow)
+		router.GET("/deploy-calendar", pipeline.GetPipelineDeployCalendar)
+		router.GET("/apply-diffs/:id", pipeline.GetPipelineApplyDiffs)
+		router.GET("/apps", pipeline.GetPipelineApps)
+		router.POST("/apps", pipeline.UpsertPipelineApp)
+		router.DELETE("/apps/:name", pipeline.DeletePipelineApp)
+		router.GET("/dora", pipeline.GetPipelineDoraMetrics)
+		router.GET("/attestations/:id", pipeline.GetPipelineAttestations)
+		router.GET("/attestations/:id/:type", pipeline.DownloadPipelineAttestation)
+		router.POST("/release-notes", pipeline.GetPipelineReleaseNotes)
+		router.GET("/registries", pipeline.GetPipelineRegistries)
+		router.POST("/registries", pipeline.UpsertPipelineRegistry)
+		router.DELETE("/registries/:name", pipeline.DeletePipelineRegistry)
+		router.GET("/registries/:name/repositories", pipeline.GetPipelineRegistryRepositories)
+		router.GET("/registries/:name/tags", pipeline.GetPipelineRegistryTags)
+		router.GET("/registries/:name/manifest", pipeline.GetPipelineRegistryManifest)
+		router.GET("/retention-policies", pipeline.GetPipelineRetentionPolicies)
+		router.POST("/retention-policies", pipeline.UpsertPipelineRetentionPolicy)
+		router.DELETE("/retention-policies/:name", pipeline.DeletePipelineRetentionPolicy)
+		router.POST("/retention-policies/:name/run", pipeline.RunPipelineRetentionPolicy)
+		router.GET("/build-caches", pipeline.GetPipelineBuildCaches)
+		router.DELETE("/build-caches/:worker/:type/:namespace", pipeline.PurgePipelineBuildCache)
+		router.GET("/build-cache-quotas", pipeline.GetPipelineBuildCacheQuotas)
+		router.POST("/build-cache-quotas", pipeline.UpsertPipelineBuildCacheQuota)
+		router.DELETE("/build-cache-quotas/:type/:namespace", pipeline.DeletePipelineBuildCacheQuota)
+		router.GET("/git-repos", pipeline.GetPipelineGitRepos)
+		router.POST("/git-repos", pipeline.UpsertPipelineGitRepo)
+		router.DELETE("/git-repos/:name", pipeline.DeletePipelineGitRepo)
+		router.GET("/git-repos/:name/branches", pipeline.GetPipelineGitRepoBranches)
+		router.GET("/git-repos/:name/tags", pipeline.GetPipelineGitRepoTags)
+		router.POST("/git-repos/:name/webhook", pipeline.RegisterPipelineGitRepoWebhook)
+		router.DELETE("/git-repos/:name/webhook", pipeline.UnregisterPipelineGitRepoWebhook)
+		router.POST("/git-repos/:name/mirror", pipeline.SyncPipelineGitRepoMirror)
+		router.POST("/git-repos/:name/run", pipeline.RunPipelineGitRepo)
+	}
+	return router
+}
+
+// 流水线公共接口, 不鉴权, 由代码托管平台回调, 请求在接口内校验签名
+func InitPipelinePublicRouter(r *gin.RouterGroup) (R gin.IRoutes) {
+	router := r.Group("public")
+	{
+		router.POST("/git-repos/:name/webhook", pipeline.ReceivePipelineGitRepoWebhook)
 	}
 	return router
 }